@@ -0,0 +1,525 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The account databases of the remote. They are variables so tests can point
+// them at fixtures.
+var (
+	passwdPath = "/etc/passwd"
+	groupPath  = "/etc/group"
+)
+
+// UnknownUserError is returned when a user doesn't exist on the remote.
+type UnknownUserError struct {
+	Name string
+}
+
+func (e UnknownUserError) Error() string {
+	return fmt.Sprintf("unknown user %q", e.Name)
+}
+
+// UnknownGroupError is returned when a group doesn't exist on the remote.
+type UnknownGroupError struct {
+	Name string
+}
+
+func (e UnknownGroupError) Error() string {
+	return fmt.Sprintf("unknown group %q", e.Name)
+}
+
+// UserAccount is a user from the remote's /etc/passwd.
+type UserAccount struct {
+	Name    string
+	UID     uint32
+	GID     uint32
+	Comment string
+	Home    string
+	Shell   string
+
+	// Group is the name of the user's primary group, if it exists.
+	Group string
+
+	// Groups lists the supplementary groups of the user, from /etc/group.
+	Groups []string
+}
+
+// GroupAccount is a group from the remote's /etc/group.
+type GroupAccount struct {
+	Name    string
+	GID     uint32
+	Members []string
+}
+
+// Accounts is a snapshot of the users and groups of the remote.
+type Accounts struct {
+	Users  []UserAccount
+	Groups []GroupAccount
+}
+
+// User returns the named user.
+func (a *Accounts) User(name string) (UserAccount, bool) {
+	for _, u := range a.Users {
+		if u.Name == name {
+			return u, true
+		}
+	}
+	return UserAccount{}, false
+}
+
+// Group returns the named group.
+func (a *Accounts) Group(name string) (GroupAccount, bool) {
+	for _, g := range a.Groups {
+		if g.Name == name {
+			return g, true
+		}
+	}
+	return GroupAccount{}, false
+}
+
+// ReadAccounts reads the users and groups of the remote from /etc/passwd and
+// /etc/group.
+func (c *Client) ReadAccounts(ctx context.Context) (*Accounts, error) {
+	passwd, err := c.Output(ctx, "cat", passwdPath)
+	if err != nil {
+		return nil, err
+	}
+	group, err := c.Output(ctx, "cat", groupPath)
+	if err != nil {
+		return nil, err
+	}
+	return parseAccounts(passwd, group), nil
+}
+
+// parseAccounts parses the contents of /etc/passwd and /etc/group. As with
+// parseAccountDB, lines with unparseable ids are skipped.
+func parseAccounts(passwd, group []byte) *Accounts {
+	a := &Accounts{}
+	groupNames := make(map[uint32]string)
+	memberships := make(map[string][]string)
+	for _, fields := range accountFields(group) {
+		id, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			continue
+		}
+		g := GroupAccount{Name: fields[0], GID: uint32(id)}
+		if len(fields) > 3 && fields[3] != "" {
+			g.Members = strings.Split(fields[3], ",")
+		}
+		for _, member := range g.Members {
+			memberships[member] = append(memberships[member], g.Name)
+		}
+		if _, ok := groupNames[g.GID]; !ok {
+			groupNames[g.GID] = g.Name
+		}
+		a.Groups = append(a.Groups, g)
+	}
+	for _, fields := range accountFields(passwd) {
+		if len(fields) < 7 {
+			continue
+		}
+		uid, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			continue
+		}
+		gid, err := strconv.ParseUint(fields[3], 10, 32)
+		if err != nil {
+			continue
+		}
+		a.Users = append(a.Users, UserAccount{
+			Name:    fields[0],
+			UID:     uint32(uid),
+			GID:     uint32(gid),
+			Comment: fields[4],
+			Home:    fields[5],
+			Shell:   fields[6],
+			Group:   groupNames[uint32(gid)],
+			Groups:  memberships[fields[0]],
+		})
+	}
+	return a
+}
+
+// UserOptions describes a user to create or modify. Empty fields are left to
+// the system's defaults when creating a user, and unchanged when modifying one.
+type UserOptions struct {
+	// UID is the user's ID, if not nil. It is a pointer so that 0 can be
+	// asked for.
+	UID *uint32
+
+	// Group is the name of the user's primary group, which must exist.
+	Group string
+
+	// Groups is the complete list of the user's supplementary groups. If nil,
+	// they are left alone; if empty, the user is removed from all of them.
+	Groups []string
+
+	Home    string
+	Shell   string
+	Comment string
+
+	// System creates a system account without a home directory. It is
+	// ignored when modifying a user.
+	System bool
+}
+
+// GroupOptions describes a group to create.
+type GroupOptions struct {
+	// GID of the group, or zero to let the system pick one.
+	GID uint32
+
+	// System creates a system group.
+	System bool
+}
+
+// validateUser checks that a user's settings can be written to /etc/passwd and
+// /etc/group, whose fields are separated by colons and entries by newlines.
+func validateUser(name string, opts UserOptions) error {
+	fields := append([]string{name, opts.Group, opts.Home, opts.Shell, opts.Comment}, opts.Groups...)
+	for _, f := range fields {
+		if strings.ContainsAny(f, ":\n\r") {
+			return fmt.Errorf("invalid user setting %q: contains a colon or line break", f)
+		}
+	}
+	return nil
+}
+
+// accountTools identifies the family of commands used to manage accounts.
+type accountTools int
+
+const (
+	// shadowTools are useradd, usermod, userdel, groupadd, groupdel and
+	// gpasswd from shadow-utils.
+	shadowTools accountTools = iota
+
+	// busyboxTools are adduser, deluser, addgroup and delgroup from BusyBox,
+	// which can't modify existing users, so those edits are made to
+	// /etc/passwd directly.
+	busyboxTools
+)
+
+func (c *Client) detectAccountTools(ctx context.Context) (accountTools, error) {
+	out, err := c.ShellOutput(ctx, `
+if command -v useradd >/dev/null 2>&1; then
+	echo shadow
+elif command -v adduser >/dev/null 2>&1; then
+	echo busybox
+fi`)
+	if err != nil {
+		return 0, err
+	}
+	switch strings.TrimSpace(string(out)) {
+	case "shadow":
+		return shadowTools, nil
+	case "busybox":
+		return busyboxTools, nil
+	}
+	return 0, fmt.Errorf("neither useradd nor adduser is available")
+}
+
+// CreateUser creates a user with useradd, or BusyBox's adduser if useradd
+// isn't available. Unless opts.System is set, the user's home directory is
+// created.
+func (c *Client) CreateUser(ctx context.Context, name string, opts UserOptions) error {
+	if err := validateUser(name, opts); err != nil {
+		return err
+	}
+	tools, err := c.detectAccountTools(ctx)
+	if err != nil {
+		return err
+	}
+	var args []string
+	if tools == shadowTools {
+		args = []string{"useradd"}
+		if opts.UID != nil {
+			args = append(args, "-u", strconv.FormatUint(uint64(*opts.UID), 10))
+		}
+		if opts.Group != "" {
+			args = append(args, "-g", opts.Group)
+		}
+		if len(opts.Groups) > 0 {
+			args = append(args, "-G", strings.Join(opts.Groups, ","))
+		}
+		if opts.Home != "" {
+			args = append(args, "-d", opts.Home)
+		}
+		if opts.Shell != "" {
+			args = append(args, "-s", opts.Shell)
+		}
+		if opts.Comment != "" {
+			args = append(args, "-c", opts.Comment)
+		}
+		if opts.System {
+			args = append(args, "-r")
+		} else {
+			args = append(args, "-m")
+		}
+		_, err := c.Output(ctx, append(args, name)...)
+		return err
+	}
+
+	args = []string{"adduser", "-D"}
+	if opts.UID != nil {
+		args = append(args, "-u", strconv.FormatUint(uint64(*opts.UID), 10))
+	}
+	if opts.Group != "" {
+		args = append(args, "-G", opts.Group)
+	}
+	if opts.Home != "" {
+		args = append(args, "-h", opts.Home)
+	}
+	if opts.Shell != "" {
+		args = append(args, "-s", opts.Shell)
+	}
+	if opts.Comment != "" {
+		args = append(args, "-g", opts.Comment)
+	}
+	if opts.System {
+		args = append(args, "-S", "-H")
+	}
+	if _, err := c.Output(ctx, append(args, name)...); err != nil {
+		return err
+	}
+	for _, group := range opts.Groups {
+		if _, err := c.Output(ctx, "addgroup", name, group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ModifyUser changes the settings of an existing user to match opts, and
+// reports whether anything changed. Changing the home directory doesn't move
+// the existing one. Without usermod, as on BusyBox, changing the UID also
+// leaves the files in the home directory owned by the old one.
+func (c *Client) ModifyUser(ctx context.Context, name string, opts UserOptions) (bool, error) {
+	if err := validateUser(name, opts); err != nil {
+		return false, err
+	}
+	accounts, err := c.ReadAccounts(ctx)
+	if err != nil {
+		return false, err
+	}
+	u, ok := accounts.User(name)
+	if !ok {
+		return false, UnknownUserError{Name: name}
+	}
+	tools, err := c.detectAccountTools(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var gid uint32
+	if opts.Group != "" && opts.Group != u.Group {
+		g, ok := accounts.Group(opts.Group)
+		if !ok {
+			return false, UnknownGroupError{Name: opts.Group}
+		}
+		gid = g.GID
+	}
+	add, remove := diffGroups(u.Groups, opts.Groups)
+	for _, group := range add {
+		if _, ok := accounts.Group(group); !ok {
+			return false, UnknownGroupError{Name: group}
+		}
+	}
+
+	// The flags are shared by usermod and the fields of /etc/passwd that
+	// BusyBox needs edited by hand.
+	var args []string
+	fields := make(map[int]string)
+	if opts.UID != nil && *opts.UID != u.UID {
+		args = append(args, "-u", strconv.FormatUint(uint64(*opts.UID), 10))
+		fields[2] = strconv.FormatUint(uint64(*opts.UID), 10)
+	}
+	if opts.Group != "" && opts.Group != u.Group {
+		args = append(args, "-g", opts.Group)
+		fields[3] = strconv.FormatUint(uint64(gid), 10)
+	}
+	if opts.Comment != "" && opts.Comment != u.Comment {
+		args = append(args, "-c", opts.Comment)
+		fields[4] = opts.Comment
+	}
+	if opts.Home != "" && opts.Home != u.Home {
+		args = append(args, "-d", opts.Home)
+		fields[5] = opts.Home
+	}
+	if opts.Shell != "" && opts.Shell != u.Shell {
+		args = append(args, "-s", opts.Shell)
+		fields[6] = opts.Shell
+	}
+
+	if tools == shadowTools {
+		if len(add) > 0 || len(remove) > 0 {
+			args = append(args, "-G", strings.Join(opts.Groups, ","))
+		}
+		if len(args) == 0 {
+			return false, nil
+		}
+		_, err := c.Output(ctx, append(append([]string{"usermod"}, args...), name)...)
+		return err == nil, err
+	}
+
+	if len(fields) > 0 {
+		if err := c.editPasswd(ctx, name, fields); err != nil {
+			return false, err
+		}
+	}
+	for _, group := range add {
+		if _, err := c.Output(ctx, "addgroup", name, group); err != nil {
+			return false, err
+		}
+	}
+	for _, group := range remove {
+		if _, err := c.Output(ctx, "delgroup", name, group); err != nil {
+			return false, err
+		}
+	}
+	return len(fields) > 0 || len(add) > 0 || len(remove) > 0, nil
+}
+
+// diffGroups returns the groups in want but not have, and the groups in have
+// but not want. A nil want means no change.
+func diffGroups(have, want []string) (add, remove []string) {
+	if want == nil {
+		return nil, nil
+	}
+	haveSet := make(map[string]bool, len(have))
+	for _, g := range have {
+		haveSet[g] = true
+	}
+	wantSet := make(map[string]bool, len(want))
+	for _, g := range want {
+		wantSet[g] = true
+		if !haveSet[g] {
+			add = append(add, g)
+		}
+	}
+	for _, g := range have {
+		if !wantSet[g] {
+			remove = append(remove, g)
+		}
+	}
+	sort.Strings(add)
+	sort.Strings(remove)
+	return add, remove
+}
+
+// editPasswd replaces fields of a user's line in /etc/passwd, keyed by their
+// index.
+func (c *Client) editPasswd(ctx context.Context, name string, fields map[int]string) error {
+	f, err := c.NewFileClient()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.editFile(ctx, passwdPath, EditOptions{SkipBackup: true}, func(lines []string) []string {
+		for i, line := range lines {
+			content := strings.TrimSuffix(line, "\n")
+			entry := strings.Split(content, ":")
+			if len(entry) != 7 || entry[0] != name {
+				continue
+			}
+			for j, v := range fields {
+				entry[j] = v
+			}
+			lines[i] = strings.Join(entry, ":") + line[len(content):]
+		}
+		return lines
+	})
+	return err
+}
+
+// DeleteUser deletes a user, and its home directory if removeHome is set.
+func (c *Client) DeleteUser(ctx context.Context, name string, removeHome bool) error {
+	tools, err := c.detectAccountTools(ctx)
+	if err != nil {
+		return err
+	}
+	args := []string{"userdel"}
+	if removeHome {
+		args = append(args, "-r")
+	}
+	if tools == busyboxTools {
+		args = []string{"deluser"}
+		if removeHome {
+			args = append(args, "--remove-home")
+		}
+	}
+	_, err = c.Output(ctx, append(args, name)...)
+	return err
+}
+
+// CreateGroup creates a group with groupadd, or BusyBox's addgroup if
+// groupadd isn't available.
+func (c *Client) CreateGroup(ctx context.Context, name string, opts GroupOptions) error {
+	tools, err := c.detectAccountTools(ctx)
+	if err != nil {
+		return err
+	}
+	args := []string{"groupadd"}
+	system := "-r"
+	if tools == busyboxTools {
+		args = []string{"addgroup"}
+		system = "-S"
+	}
+	if opts.GID != 0 {
+		args = append(args, "-g", strconv.FormatUint(uint64(opts.GID), 10))
+	}
+	if opts.System {
+		args = append(args, system)
+	}
+	_, err = c.Output(ctx, append(args, name)...)
+	return err
+}
+
+// DeleteGroup deletes a group.
+func (c *Client) DeleteGroup(ctx context.Context, name string) error {
+	tools, err := c.detectAccountTools(ctx)
+	if err != nil {
+		return err
+	}
+	cmd := "groupdel"
+	if tools == busyboxTools {
+		cmd = "delgroup"
+	}
+	_, err = c.Output(ctx, cmd, name)
+	return err
+}
+
+// AddGroupMember adds a user to a supplementary group.
+func (c *Client) AddGroupMember(ctx context.Context, group, user string) error {
+	tools, err := c.detectAccountTools(ctx)
+	if err != nil {
+		return err
+	}
+	if tools == busyboxTools {
+		_, err = c.Output(ctx, "addgroup", user, group)
+	} else {
+		_, err = c.Output(ctx, "usermod", "-a", "-G", group, user)
+	}
+	return err
+}
+
+// RemoveGroupMember removes a user from a supplementary group.
+func (c *Client) RemoveGroupMember(ctx context.Context, group, user string) error {
+	tools, err := c.detectAccountTools(ctx)
+	if err != nil {
+		return err
+	}
+	if tools == busyboxTools {
+		_, err = c.Output(ctx, "delgroup", user, group)
+	} else {
+		_, err = c.Output(ctx, "gpasswd", "-d", user, group)
+	}
+	return err
+}
@@ -0,0 +1,232 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+)
+
+const (
+	testPasswd = `root:x:0:0:root:/root:/bin/bash
+# comment
+svc:x:1000:1000:Service:/home/svc:/bin/false
+nobody:x:65534:65534:nobody:/nonexistent:/usr/sbin/nologin
+`
+	testGroup = `root:x:0:
+wheel:x:10:root,svc
+svc:x:1000:
+audio:x:29:svc
+video:x:44:
+`
+)
+
+func TestParseAccounts(t *testing.T) {
+	a := parseAccounts([]byte(testPasswd), []byte(testGroup))
+	svc, ok := a.User("svc")
+	if !ok {
+		t.Fatalf("expected to find svc")
+	}
+	want := UserAccount{
+		Name:    "svc",
+		UID:     1000,
+		GID:     1000,
+		Comment: "Service",
+		Home:    "/home/svc",
+		Shell:   "/bin/false",
+		Group:   "svc",
+		Groups:  []string{"wheel", "audio"},
+	}
+	if !reflect.DeepEqual(svc, want) {
+		t.Errorf("got %+v, want %+v", svc, want)
+	}
+	if wheel, ok := a.Group("wheel"); !ok || wheel.GID != 10 || !reflect.DeepEqual(wheel.Members, []string{"root", "svc"}) {
+		t.Errorf("got wheel %+v", wheel)
+	}
+	if len(a.Users) != 3 || len(a.Groups) != 5 {
+		t.Errorf("got %d users and %d groups, want 3 and 5", len(a.Users), len(a.Groups))
+	}
+}
+
+func uint32Ptr(v uint32) *uint32 {
+	return &v
+}
+
+// setUpAccountTools points the account databases at fixtures and puts fake
+// versions of tools on the PATH, which log their arguments. It returns a
+// function returning the logged commands.
+func setUpAccountTools(t *testing.T, tools ...string) (dir string, log func() []string) {
+	fakes := make(map[string]string)
+	for _, tool := range tools {
+		fakes[tool] = "#!/bin/sh\necho \"${0##*/} $*\" >> \"${0%/*}/log\"\n"
+	}
+	dir = setUpPath(t, fakes, "sh", "cat", "sync", "mv", "rm")
+	for name, content := range map[string]string{
+		"passwd": testPasswd,
+		"group":  testGroup,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldPasswd, oldGroup := passwdPath, groupPath
+	passwdPath, groupPath = filepath.Join(dir, "passwd"), filepath.Join(dir, "group")
+	t.Cleanup(func() { passwdPath, groupPath = oldPasswd, oldGroup })
+
+	return dir, func() []string {
+		data, err := ioutil.ReadFile(filepath.Join(dir, "log"))
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		os.Remove(filepath.Join(dir, "log"))
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+}
+
+func TestShadowAccounts(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+	_, log := setUpAccountTools(t, "useradd", "usermod", "userdel", "groupadd", "groupdel", "gpasswd")
+
+	check := func(want ...string) {
+		t.Helper()
+		if got := log(); !reflect.DeepEqual(got, want) {
+			t.Errorf("ran %q, want %q", got, want)
+		}
+	}
+
+	if err := client.CreateUser(ctx, "app", UserOptions{Groups: []string{"wheel", "audio"}, Shell: "/bin/sh"}); err != nil {
+		t.Fatal(err)
+	}
+	check("useradd -G wheel,audio -s /bin/sh -m app")
+	if err := client.CreateUser(ctx, "daemon", UserOptions{UID: uint32Ptr(500), Group: "svc", System: true, Comment: "A daemon"}); err != nil {
+		t.Fatal(err)
+	}
+	check("useradd -u 500 -g svc -c A daemon -r daemon")
+
+	changed, err := client.ModifyUser(ctx, "svc", UserOptions{Shell: "/bin/false", Groups: []string{"audio", "wheel"}})
+	if err != nil || changed {
+		t.Errorf("expected modifying svc to its current state to do nothing, got %v (err: %v)", changed, err)
+	}
+	check("")
+	changed, err = client.ModifyUser(ctx, "svc", UserOptions{Shell: "/bin/sh", Home: "/srv/svc", Groups: []string{"video"}})
+	if err != nil || !changed {
+		t.Errorf("expected svc to change, got %v (err: %v)", changed, err)
+	}
+	check("usermod -d /srv/svc -s /bin/sh -G video svc")
+	if _, err := client.ModifyUser(ctx, "svc", UserOptions{UID: uint32Ptr(0)}); err != nil {
+		t.Fatal(err)
+	}
+	check("usermod -u 0 svc")
+	if _, err := client.ModifyUser(ctx, "svc", UserOptions{Comment: "x\nevil::0:0::/root:/bin/sh"}); err == nil {
+		t.Errorf("expected an error for a comment with a line break")
+	}
+	check("")
+
+	if _, err := client.ModifyUser(ctx, "svc", UserOptions{Groups: []string{"missing"}}); !errors.As(err, &UnknownGroupError{}) {
+		t.Errorf("expected an UnknownGroupError, got %v", err)
+	}
+	if _, err := client.ModifyUser(ctx, "missing", UserOptions{}); !errors.As(err, &UnknownUserError{}) {
+		t.Errorf("expected an UnknownUserError, got %v", err)
+	}
+
+	for _, op := range []func() error{
+		func() error { return client.DeleteUser(ctx, "svc", true) },
+		func() error { return client.CreateGroup(ctx, "logs", GroupOptions{GID: 400, System: true}) },
+		func() error { return client.DeleteGroup(ctx, "logs") },
+		func() error { return client.AddGroupMember(ctx, "audio", "app") },
+		func() error { return client.RemoveGroupMember(ctx, "audio", "app") },
+	} {
+		if err := op(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check(
+		"userdel -r svc",
+		"groupadd -g 400 -r logs",
+		"groupdel logs",
+		"usermod -a -G audio app",
+		"gpasswd -d app audio",
+	)
+}
+
+func TestBusyBoxAccounts(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+	dir, log := setUpAccountTools(t, "adduser", "deluser", "addgroup", "delgroup")
+
+	check := func(want ...string) {
+		t.Helper()
+		if got := log(); !reflect.DeepEqual(got, want) {
+			t.Errorf("ran %q, want %q", got, want)
+		}
+	}
+
+	if err := client.CreateUser(ctx, "app", UserOptions{Groups: []string{"wheel"}, Home: "/srv/app", System: true}); err != nil {
+		t.Fatal(err)
+	}
+	check("adduser -D -h /srv/app -S -H app", "addgroup app wheel")
+
+	// BusyBox has no usermod, so the user's entry is edited in place.
+	changed, err := client.ModifyUser(ctx, "svc", UserOptions{Shell: "/bin/ash", Group: "wheel", Groups: []string{"wheel", "video"}})
+	if err != nil || !changed {
+		t.Errorf("expected svc to change, got %v (err: %v)", changed, err)
+	}
+	check("addgroup svc video", "delgroup svc audio")
+	passwd, err := ioutil.ReadFile(filepath.Join(dir, "passwd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(testPasswd, "1000:1000:Service:/home/svc:/bin/false", "1000:10:Service:/home/svc:/bin/ash", 1)
+	if string(passwd) != want {
+		t.Errorf("got passwd:\n%s\nwant:\n%s", passwd, want)
+	}
+
+	// Settings that would add or shift fields of /etc/passwd are refused.
+	for _, opts := range []UserOptions{
+		{Comment: "x\nevil::0:0::/root:/bin/sh"},
+		{Home: "/home/a:b"},
+		{Shell: "/bin/sh\r"},
+	} {
+		if _, err := client.ModifyUser(ctx, "svc", opts); err == nil {
+			t.Errorf("expected an error modifying svc with %+v", opts)
+		}
+		if err := client.CreateUser(ctx, "evil", opts); err == nil {
+			t.Errorf("expected an error creating a user with %+v", opts)
+		}
+	}
+	check("")
+	if passwd, err := ioutil.ReadFile(filepath.Join(dir, "passwd")); err != nil || string(passwd) != want {
+		t.Errorf("got passwd:\n%s\nwant it unchanged (err: %v)", passwd, err)
+	}
+
+	for _, op := range []func() error{
+		func() error { return client.DeleteUser(ctx, "svc", true) },
+		func() error { return client.CreateGroup(ctx, "logs", GroupOptions{System: true}) },
+		func() error { return client.DeleteGroup(ctx, "logs") },
+		func() error { return client.AddGroupMember(ctx, "audio", "app") },
+		func() error { return client.RemoveGroupMember(ctx, "audio", "app") },
+	} {
+		if err := op(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check(
+		"deluser --remove-home svc",
+		"addgroup -S logs",
+		"delgroup logs",
+		"addgroup app audio",
+		"delgroup app audio",
+	)
+}
@@ -0,0 +1,387 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aucloud/go-sshutil/agent"
+	"go.fuchsia.dev/fuchsia/tools/lib/logger"
+)
+
+// agentPackage is the command built by BuildAgent.
+const agentPackage = "github.com/aucloud/go-sshutil/cmd/sshutil-agent"
+
+// agentCloseTimeout is how long Agent.Close waits for the agent to exit. It's
+// a variable for tests.
+var agentCloseTimeout = 10 * time.Second
+
+// cacheDirScript creates the directory binaries are cached in, defaulting to
+// one in the user's cache directory, and prints its absolute path.
+const cacheDirScript = `d=${1:-${XDG_CACHE_HOME:-$HOME/.cache}/sshutil} && mkdir -p "$d" && cd "$d" && pwd`
+
+// unameOS and unameArch map the output of `uname -s` and `uname -m` to GOOS
+// and GOARCH.
+var (
+	unameOS = map[string]string{
+		"Linux":   "linux",
+		"Darwin":  "darwin",
+		"FreeBSD": "freebsd",
+		"NetBSD":  "netbsd",
+		"OpenBSD": "openbsd",
+	}
+	unameArch = map[string]string{
+		"x86_64":  "amd64",
+		"amd64":   "amd64",
+		"i386":    "386",
+		"i686":    "386",
+		"aarch64": "arm64",
+		"arm64":   "arm64",
+		"armv6l":  "arm",
+		"armv7l":  "arm",
+		"riscv64": "riscv64",
+		"ppc64le": "ppc64le",
+		"s390x":   "s390x",
+		"mips":    "mips",
+		"mipsel":  "mipsle",
+	}
+)
+
+// Platform returns the remote's operating system and architecture as Go
+// names them, as "GOOS/GOARCH".
+func (c *Client) Platform(ctx context.Context) (string, error) {
+	out, err := c.Output(ctx, "uname", "-sm")
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 {
+		return "", fmt.Errorf("unexpected output of uname: %q", out)
+	}
+	goos, ok := unameOS[fields[0]]
+	if !ok {
+		return "", fmt.Errorf("unsupported operating system %q", fields[0])
+	}
+	goarch, ok := unameArch[fields[1]]
+	if !ok {
+		return "", fmt.Errorf("unsupported architecture %q", fields[1])
+	}
+	return goos + "/" + goarch, nil
+}
+
+// BuildAgent cross-compiles the agent in cmd/sshutil-agent for a platform,
+// given as "GOOS/GOARCH", to the local path out. It needs a Go toolchain, and
+// runs `go build` in the current directory, so it only works within this
+// module or one that requires it. Programs run elsewhere should provide
+// prebuilt agents, with AgentOptions.Binaries or AgentOptions.Embedded.
+func BuildAgent(ctx context.Context, platform, out string) error {
+	parts := strings.SplitN(platform, "/", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid platform %q", platform)
+	}
+	goos, goarch := parts[0], parts[1]
+	cmd := exec.CommandContext(ctx, "go", "build", "-trimpath", "-ldflags=-s -w", "-o", out, agentPackage)
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=0", "GOOS="+goos, "GOARCH="+goarch)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to build the agent for %s: %w: %s", platform, err, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+// AgentOptions configure StartAgent.
+type AgentOptions struct {
+	// Binaries maps platforms, as "GOOS/GOARCH", to local agent binaries,
+	// such as ones from BuildAgent.
+	Binaries map[string]string
+
+	// Embedded maps platforms to the contents of agent binaries, such as
+	// ones embedded in a program with go:embed. They are used for platforms
+	// without a local binary. The agent for a platform with neither is built
+	// with BuildAgent.
+	Embedded map[string][]byte
+
+	// Dir is the remote directory agents are cached in. It defaults to
+	// sshutil in the user's cache directory.
+	Dir string
+}
+
+// Agent is a helper running on the remote, which answers requests over its
+// standard input and output.
+type Agent struct {
+	session *Session
+	stdin   *io.PipeWriter
+	stderr  syncBuffer
+
+	// Path is the remote path of the agent, which is named after the hash of
+	// its binary.
+	Path string
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan agent.Response
+	err     error
+	done    chan struct{}
+}
+
+// StartAgent starts the agent on the remote, uploading it unless a copy with
+// the same hash is cached there.
+func (c *Client) StartAgent(ctx context.Context, opts AgentOptions) (*Agent, error) {
+	platform, err := c.Platform(ctx)
+	if err != nil {
+		return nil, err
+	}
+	binary := opts.Binaries[platform]
+	if binary == "" {
+		dir, err := ioutil.TempDir("", "sshutil-agent")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		binary = filepath.Join(dir, "sshutil-agent")
+		if data, ok := opts.Embedded[platform]; ok {
+			if err := ioutil.WriteFile(binary, data, 0o755); err != nil {
+				return nil, err
+			}
+		} else if err := BuildAgent(ctx, platform, binary); err != nil {
+			return nil, fmt.Errorf("no agent for %s: %w", platform, err)
+		}
+	}
+	remote, err := c.installBinary(ctx, binary, opts.Dir, "sshutil-agent")
+	if err != nil {
+		return nil, err
+	}
+
+	stdin, stdinW := io.Pipe()
+	stdoutR, stdout := io.Pipe()
+	a := &Agent{
+		Path:    remote,
+		stdin:   stdinW,
+		pending: make(map[uint64]chan agent.Response),
+		done:    make(chan struct{}),
+	}
+	a.session, err = c.StartWithInput(ctx, []string{ShellQuote(remote)}, stdin, stdout, &a.stderr)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		err := a.session.Wait(context.Background())
+		if err == nil {
+			err = io.EOF
+		}
+		stdout.CloseWithError(err)
+		stdin.Close()
+	}()
+	go a.read(stdoutR)
+	return a, nil
+}
+
+// installBinary uploads a binary to dir, named after prefix and its hash,
+// unless it's already there, and returns its remote path.
+func (c *Client) installBinary(ctx context.Context, binary, dir, prefix string) (string, error) {
+	data, err := ioutil.ReadFile(binary)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+
+	out, err := c.Output(ctx, "sh", "-c", cacheDirScript, "sh", dir)
+	if err != nil {
+		return "", err
+	}
+	remote := path.Join(strings.TrimSpace(string(out)), prefix+"-"+hex.EncodeToString(sum[:8]))
+
+	f, err := c.NewFileClient()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.SFTP().Stat(remote); err == nil {
+		return remote, nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	// The upload is atomic, so a partly uploaded binary is never run.
+	if err := f.Upload(ctx, binary, remote, TransferOptions{FileChmod: ChmodMask{Set: 0o755}}); err != nil {
+		return "", err
+	}
+	logger.Debugf(ctx, "installed %s at %s", binary, remote)
+	return remote, nil
+}
+
+// read dispatches the responses from the agent until it exits.
+func (a *Agent) read(r io.Reader) {
+	defer close(a.done)
+	for {
+		var resp agent.Response
+		if err := agent.ReadFrame(r, &resp); err != nil {
+			if stderr := strings.TrimSpace(a.stderr.String()); stderr != "" {
+				err = fmt.Errorf("%w: %s", err, stderr)
+			}
+			a.mu.Lock()
+			a.err = fmt.Errorf("agent exited: %w", err)
+			for id, ch := range a.pending {
+				close(ch)
+				delete(a.pending, id)
+			}
+			a.mu.Unlock()
+			return
+		}
+		a.mu.Lock()
+		ch, ok := a.pending[resp.ID]
+		delete(a.pending, resp.ID)
+		a.mu.Unlock()
+		if ok {
+			ch <- resp
+		}
+	}
+}
+
+// Call calls a method of the agent with params, and decodes its result into
+// result. Errors from the agent are *agent.Error.
+func (a *Agent) Call(ctx context.Context, method string, params, result interface{}) error {
+	req := agent.Request{Method: method}
+	if params != nil {
+		var err error
+		if req.Params, err = json.Marshal(params); err != nil {
+			return err
+		}
+	}
+	ch := make(chan agent.Response, 1)
+	a.mu.Lock()
+	if a.err != nil {
+		a.mu.Unlock()
+		return a.err
+	}
+	a.nextID++
+	req.ID = a.nextID
+	a.pending[req.ID] = ch
+	a.mu.Unlock()
+
+	a.writeMu.Lock()
+	err := agent.WriteFrame(a.stdin, req)
+	a.writeMu.Unlock()
+	if err != nil {
+		a.forget(req.ID)
+		return err
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			return a.err
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	case <-ctx.Done():
+		a.forget(req.ID)
+		return ctx.Err()
+	}
+}
+
+func (a *Agent) forget(id uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.pending, id)
+}
+
+// Stat describes a remote file, without following symlinks.
+func (a *Agent) Stat(ctx context.Context, name string) (agent.FileInfo, error) {
+	var info agent.FileInfo
+	err := a.Call(ctx, agent.MethodStat, agent.PathParams{Path: name}, &info)
+	return info, err
+}
+
+// Walk describes the remote files under root, including root, in lexical
+// order.
+func (a *Agent) Walk(ctx context.Context, root string) ([]agent.FileInfo, error) {
+	var infos []agent.FileInfo
+	err := a.Call(ctx, agent.MethodWalk, agent.PathParams{Path: root}, &infos)
+	return infos, err
+}
+
+// Hash returns the hex hash of a remote file with an algorithm: "sha256",
+// the default, "sha1" or "md5".
+func (a *Agent) Hash(ctx context.Context, name, algorithm string) (string, error) {
+	var result agent.HashResult
+	err := a.Call(ctx, agent.MethodHash, agent.HashParams{Path: name, Algorithm: algorithm}, &result)
+	return result.Sum, err
+}
+
+// ReadFile reads a remote file of up to 32 MiB.
+func (a *Agent) ReadFile(ctx context.Context, name string) ([]byte, error) {
+	var result agent.ReadResult
+	err := a.Call(ctx, agent.MethodRead, agent.ReadParams{Path: name}, &result)
+	return result.Data, err
+}
+
+// WriteFile atomically replaces a remote file.
+func (a *Agent) WriteFile(ctx context.Context, name string, data []byte, perm os.FileMode) error {
+	return a.Call(ctx, agent.MethodWrite, agent.WriteParams{Path: name, Data: data, Mode: perm}, nil)
+}
+
+// Exec runs a command directly, without a shell, and returns its exit code
+// and output. A command that fails isn't an error.
+func (a *Agent) Exec(ctx context.Context, params agent.ExecParams) (*agent.ExecResult, error) {
+	var result agent.ExecResult
+	if err := a.Call(ctx, agent.MethodExec, params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Processes lists the remote's processes.
+func (a *Agent) Processes(ctx context.Context) ([]agent.Process, error) {
+	var procs []agent.Process
+	err := a.Call(ctx, agent.MethodProcesses, nil, &procs)
+	return procs, err
+}
+
+// Close stops the agent once it has answered the requests it was sent. If
+// the agent doesn't exit within agentCloseTimeout, its session is closed.
+func (a *Agent) Close() error {
+	err := a.stdin.Close()
+	timer := time.NewTimer(agentCloseTimeout)
+	defer timer.Stop()
+	select {
+	case <-a.done:
+	case <-timer.C:
+		// Closing the session ends the agent's output, and so the reader.
+		a.session.Close()
+		<-a.done
+		return fmt.Errorf("agent %s didn't exit within %s", a.Path, agentCloseTimeout)
+	}
+	a.session.Close()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.err != nil && !errors.Is(a.err, io.EOF) {
+		return a.err
+	}
+	return err
+}
@@ -0,0 +1,211 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package agent implements a helper that runs on a remote host and answers
+// requests for file and process operations over its standard input and
+// output, so that they don't need a shell command each.
+//
+// Each message is a frame of a 4-byte big-endian length followed by that many
+// bytes of JSON. A client sends Requests, and the agent answers each with a
+// Response carrying the same ID, in any order.
+package agent
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// MaxFrameSize is the largest frame either side accepts.
+const MaxFrameSize = 64 << 20
+
+// The methods the agent serves.
+const (
+	MethodStat      = "stat"
+	MethodWalk      = "walk"
+	MethodHash      = "hash"
+	MethodRead      = "read"
+	MethodWrite     = "write"
+	MethodExec      = "exec"
+	MethodProcesses = "processes"
+)
+
+// Request is a call of a method.
+type Request struct {
+	ID     uint64
+	Method string
+	Params json.RawMessage `json:",omitempty"`
+}
+
+// Response is the result of a Request. Error is set if it failed.
+type Response struct {
+	ID     uint64
+	Result json.RawMessage `json:",omitempty"`
+	Error  *Error          `json:",omitempty"`
+}
+
+// Error codes, for errors matching fs.ErrNotExist, fs.ErrExist and
+// fs.ErrPermission.
+const (
+	CodeNotExist   = "not-exist"
+	CodeExist      = "exist"
+	CodePermission = "permission"
+)
+
+// Error is an error returned by the agent.
+type Error struct {
+	Message string
+	Code    string `json:",omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is reports whether the error has the code of target, so that
+// errors.Is(err, fs.ErrNotExist) works across the connection.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case fs.ErrNotExist:
+		return e.Code == CodeNotExist
+	case fs.ErrExist:
+		return e.Code == CodeExist
+	case fs.ErrPermission:
+		return e.Code == CodePermission
+	}
+	return false
+}
+
+// newError converts err to an Error with its code.
+func newError(err error) *Error {
+	e := &Error{Message: err.Error()}
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		e.Code = CodeNotExist
+	case errors.Is(err, fs.ErrExist):
+		e.Code = CodeExist
+	case errors.Is(err, fs.ErrPermission):
+		e.Code = CodePermission
+	}
+	return e
+}
+
+// PathParams are the parameters of the stat and walk methods.
+type PathParams struct {
+	Path string
+}
+
+// FileInfo describes a file. Stat and walk don't follow symlinks; Link is the
+// target of one.
+type FileInfo struct {
+	Path    string
+	Size    int64
+	Mode    os.FileMode
+	ModTime time.Time
+	Link    string `json:",omitempty"`
+}
+
+// HashParams are the parameters of the hash method. Algorithm is "sha256",
+// the default, "sha1" or "md5".
+type HashParams struct {
+	Path      string
+	Algorithm string `json:",omitempty"`
+}
+
+// HashResult is the result of the hash method, in hex.
+type HashResult struct {
+	Sum string
+}
+
+// ReadParams are the parameters of the read method. A zero Length reads to
+// the end of the file.
+type ReadParams struct {
+	Path   string
+	Offset int64 `json:",omitempty"`
+	Length int64 `json:",omitempty"`
+}
+
+// ReadResult is the result of the read method.
+type ReadResult struct {
+	Data []byte
+}
+
+// WriteParams are the parameters of the write method, which replaces the
+// file atomically.
+type WriteParams struct {
+	Path string
+	Data []byte
+	Mode os.FileMode
+}
+
+// ExecParams are the parameters of the exec method. Env is added to the
+// agent's environment.
+type ExecParams struct {
+	Args    []string
+	Dir     string        `json:",omitempty"`
+	Env     []string      `json:",omitempty"`
+	Stdin   []byte        `json:",omitempty"`
+	Timeout time.Duration `json:",omitempty"`
+}
+
+// ExecResult is the result of the exec method. ExitCode is -1 if the command
+// was killed by a signal, including when it timed out.
+type ExecResult struct {
+	ExitCode int
+	Stdout   []byte
+	Stderr   []byte
+	Duration time.Duration
+	TimedOut bool `json:",omitempty"`
+}
+
+// Process is a process listed by the processes method.
+type Process struct {
+	PID     int
+	PPID    int
+	UID     int
+	Name    string
+	State   string
+	Cmdline []string `json:",omitempty"`
+}
+
+// WriteFrame writes v as a frame.
+func WriteFrame(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if len(data) > MaxFrameSize {
+		return fmt.Errorf("frame of %d bytes exceeds the maximum of %d", len(data), MaxFrameSize)
+	}
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+	_, err = w.Write(frame)
+	return err
+}
+
+// ReadFrame reads a frame into v. It returns io.EOF if r ends between frames.
+func ReadFrame(r io.Reader, v interface{}) error {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > MaxFrameSize {
+		return fmt.Errorf("frame of %d bytes exceeds the maximum of %d", size, MaxFrameSize)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
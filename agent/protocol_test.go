@@ -0,0 +1,46 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package agent
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+)
+
+func TestFrames(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFrame(&buf, Request{ID: 1, Method: MethodStat}); err != nil {
+		t.Fatal(err)
+	}
+	var req Request
+	if err := ReadFrame(&buf, &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.ID != 1 || req.Method != MethodStat {
+		t.Errorf("got request %+v", req)
+	}
+	if err := ReadFrame(&buf, &req); err != io.EOF {
+		t.Errorf("got error %v at the end, want EOF", err)
+	}
+	if err := ReadFrame(bytes.NewReader([]byte{0, 0, 0, 9, '{'}), &req); err != io.ErrUnexpectedEOF {
+		t.Errorf("got error %v for a truncated frame, want an unexpected EOF", err)
+	}
+	if err := ReadFrame(bytes.NewReader([]byte{0xff, 0, 0, 0}), &req); err == nil {
+		t.Errorf("expected an error for an oversized frame")
+	}
+}
+
+func TestErrorIs(t *testing.T) {
+	err := error(&Error{Message: "missing", Code: CodeNotExist})
+	if !errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+		t.Errorf("got the wrong matches for %v", err)
+	}
+	if e := newError(fs.ErrPermission); e.Code != CodePermission {
+		t.Errorf("got code %q for a permission error, want %q", e.Code, CodePermission)
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// procDir is where processes are listed from.
+var procDir = "/proc"
+
+// Serve answers the requests read from r, writing responses to w, until r
+// ends. Requests are handled concurrently.
+func Serve(r io.Reader, w io.Writer) error {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		var req Request
+		if err := ReadFrame(r, &req); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := Response{ID: req.ID}
+			result, err := handle(req)
+			if err != nil {
+				var e *Error
+				if !errors.As(err, &e) {
+					e = newError(err)
+				}
+				resp.Error = e
+			} else if resp.Result, err = json.Marshal(result); err != nil {
+				resp.Error = newError(err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err := WriteFrame(w, resp); err != nil {
+				// The result may be too large to send, which the client
+				// should hear about.
+				WriteFrame(w, Response{ID: req.ID, Error: newError(err)})
+			}
+		}()
+	}
+}
+
+func handle(req Request) (interface{}, error) {
+	decode := func(params interface{}) error {
+		if err := json.Unmarshal(req.Params, params); err != nil {
+			return fmt.Errorf("invalid parameters for %s: %w", req.Method, err)
+		}
+		return nil
+	}
+	switch req.Method {
+	case MethodStat:
+		var p PathParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return stat(p.Path)
+	case MethodWalk:
+		var p PathParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return walk(p.Path)
+	case MethodHash:
+		var p HashParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return hashFile(p)
+	case MethodRead:
+		var p ReadParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return readFile(p)
+	case MethodWrite:
+		var p WriteParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return struct{}{}, writeFile(p)
+	case MethodExec:
+		var p ExecParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return execCommand(p)
+	case MethodProcesses:
+		return processes()
+	}
+	return nil, fmt.Errorf("unknown method %q", req.Method)
+}
+
+func fileInfo(name string, fi os.FileInfo) (FileInfo, error) {
+	info := FileInfo{Path: name, Size: fi.Size(), Mode: fi.Mode(), ModTime: fi.ModTime()}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(name)
+		if err != nil {
+			return FileInfo{}, err
+		}
+		info.Link = link
+	}
+	return info, nil
+}
+
+func stat(name string) (FileInfo, error) {
+	fi, err := os.Lstat(name)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return fileInfo(name, fi)
+}
+
+func walk(root string) ([]FileInfo, error) {
+	var infos []FileInfo
+	err := filepath.Walk(root, func(name string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := fileInfo(name, fi)
+		if err != nil {
+			return err
+		}
+		infos = append(infos, info)
+		return nil
+	})
+	return infos, err
+}
+
+func hashFile(p HashParams) (HashResult, error) {
+	var h hash.Hash
+	switch p.Algorithm {
+	case "", "sha256":
+		h = sha256.New()
+	case "sha1":
+		h = sha1.New()
+	case "md5":
+		h = md5.New()
+	default:
+		return HashResult{}, fmt.Errorf("unknown hash algorithm %q", p.Algorithm)
+	}
+	f, err := os.Open(p.Path)
+	if err != nil {
+		return HashResult{}, err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return HashResult{}, err
+	}
+	return HashResult{Sum: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+func readFile(p ReadParams) (ReadResult, error) {
+	f, err := os.Open(p.Path)
+	if err != nil {
+		return ReadResult{}, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(p.Offset, io.SeekStart); err != nil {
+		return ReadResult{}, err
+	}
+	var r io.Reader = f
+	if p.Length > 0 {
+		r = io.LimitReader(f, p.Length)
+	}
+	// Leave room for the base64 encoding and the rest of the response.
+	data, err := ioutil.ReadAll(io.LimitReader(r, MaxFrameSize/2+1))
+	if err != nil {
+		return ReadResult{}, err
+	}
+	if len(data) > MaxFrameSize/2 {
+		return ReadResult{}, fmt.Errorf("%s is too large to read at once; read it in parts", p.Path)
+	}
+	return ReadResult{Data: data}, nil
+}
+
+func writeFile(p WriteParams) error {
+	f, err := ioutil.TempFile(filepath.Dir(p.Path), "."+filepath.Base(p.Path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(p.Data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(p.Mode.Perm()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p.Path)
+}
+
+func execCommand(p ExecParams) (ExecResult, error) {
+	if len(p.Args) == 0 {
+		return ExecResult{}, errors.New("no command given")
+	}
+	ctx := context.Background()
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, p.Args[0], p.Args[1:]...)
+	cmd.Dir = p.Dir
+	if len(p.Env) > 0 {
+		cmd.Env = append(os.Environ(), p.Env...)
+	}
+	cmd.Stdin = bytes.NewReader(p.Stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	result := ExecResult{
+		Stdout:   stdout.Bytes(),
+		Stderr:   stderr.Bytes(),
+		Duration: time.Since(start),
+		TimedOut: ctx.Err() != nil,
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode()
+	} else if err != nil {
+		return ExecResult{}, err
+	}
+	return result, nil
+}
+
+func processes() ([]Process, error) {
+	entries, err := ioutil.ReadDir(procDir)
+	if err != nil {
+		return nil, err
+	}
+	var procs []Process
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		p, err := readProcess(filepath.Join(procDir, e.Name()), pid)
+		if err != nil {
+			// The process exited while being read.
+			continue
+		}
+		procs = append(procs, p)
+	}
+	return procs, nil
+}
+
+// readProcess reads a process's name, state and parent from its stat file,
+// in which the name is in parentheses and may contain spaces and
+// parentheses, its UID from its status file, and its arguments.
+func readProcess(dir string, pid int) (Process, error) {
+	p := Process{PID: pid}
+	stat, err := ioutil.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return p, err
+	}
+	open, end := bytes.IndexByte(stat, '('), bytes.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return p, fmt.Errorf("invalid stat for %d", pid)
+	}
+	p.Name = string(stat[open+1 : end])
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 2 {
+		return p, fmt.Errorf("invalid stat for %d", pid)
+	}
+	p.State = fields[0]
+	if p.PPID, err = strconv.Atoi(fields[1]); err != nil {
+		return p, err
+	}
+
+	status, err := ioutil.ReadFile(filepath.Join(dir, "status"))
+	if err != nil {
+		return p, err
+	}
+	for _, line := range strings.Split(string(status), "\n") {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "Uid:" {
+			if p.UID, err = strconv.Atoi(fields[1]); err != nil {
+				return p, err
+			}
+		}
+	}
+
+	// Kernel threads have no arguments, and others' may be unreadable.
+	if cmdline, err := ioutil.ReadFile(filepath.Join(dir, "cmdline")); err == nil && len(cmdline) > 0 {
+		p.Cmdline = strings.Split(strings.TrimSuffix(string(cmdline), "\x00"), "\x00")
+	}
+	return p, nil
+}
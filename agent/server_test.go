@@ -0,0 +1,178 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// call sends a request to a new server, and returns its response.
+func call(t *testing.T, method string, params interface{}) Response {
+	t.Helper()
+	req := Request{ID: 7, Method: method}
+	if params != nil {
+		var err error
+		if req.Params, err = json.Marshal(params); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var in, out bytes.Buffer
+	if err := WriteFrame(&in, req); err != nil {
+		t.Fatal(err)
+	}
+	if err := Serve(&in, &out); err != nil {
+		t.Fatal(err)
+	}
+	var resp Response
+	if err := ReadFrame(&out, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != req.ID {
+		t.Errorf("got response ID %d, want %d", resp.ID, req.ID)
+	}
+	return resp
+}
+
+// callResult calls a method that must succeed and decodes its result.
+func callResult(t *testing.T, method string, params, result interface{}) {
+	t.Helper()
+	resp := call(t, method, params)
+	if resp.Error != nil {
+		t.Fatalf("%s failed: %v", method, resp.Error)
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(name, []byte("hello world"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("file", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	var info FileInfo
+	callResult(t, MethodStat, PathParams{Path: filepath.Join(dir, "link")}, &info)
+	if info.Link != "file" || info.Mode&os.ModeSymlink == 0 {
+		t.Errorf("got %+v for a symlink", info)
+	}
+
+	var infos []FileInfo
+	callResult(t, MethodWalk, PathParams{Path: dir}, &infos)
+	var paths []string
+	for _, info := range infos {
+		paths = append(paths, info.Path)
+	}
+	if want := []string{dir, name, filepath.Join(dir, "link")}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("walked %q, want %q", paths, want)
+	}
+
+	var sum HashResult
+	callResult(t, MethodHash, HashParams{Path: name}, &sum)
+	if want := "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"; sum.Sum != want {
+		t.Errorf("got sha256 %s, want %s", sum.Sum, want)
+	}
+	callResult(t, MethodHash, HashParams{Path: name, Algorithm: "md5"}, &sum)
+	if want := "5eb63bbbe01eeed093cb22bb8f5acdc3"; sum.Sum != want {
+		t.Errorf("got md5 %s, want %s", sum.Sum, want)
+	}
+
+	var read ReadResult
+	callResult(t, MethodRead, ReadParams{Path: name, Offset: 6, Length: 3}, &read)
+	if string(read.Data) != "wor" {
+		t.Errorf("read %q, want %q", read.Data, "wor")
+	}
+
+	written := filepath.Join(dir, "written")
+	var empty struct{}
+	callResult(t, MethodWrite, WriteParams{Path: written, Data: []byte{0, 1, 2}, Mode: 0o640}, &empty)
+	fi, err := os.Stat(written)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(written); !bytes.Equal(data, []byte{0, 1, 2}) || fi.Mode().Perm() != 0o640 {
+		t.Errorf("wrote %v with mode %v", data, fi.Mode())
+	}
+
+	resp := call(t, MethodStat, PathParams{Path: filepath.Join(dir, "missing")})
+	if resp.Error == nil || !errors.Is(resp.Error, fs.ErrNotExist) {
+		t.Errorf("got error %v for a missing file, want one matching fs.ErrNotExist", resp.Error)
+	}
+	if resp := call(t, "bogus", nil); resp.Error == nil {
+		t.Errorf("expected an error for an unknown method")
+	}
+}
+
+func TestExec(t *testing.T) {
+	dir := t.TempDir()
+	var result ExecResult
+	callResult(t, MethodExec, ExecParams{
+		Args:  []string{"sh", "-c", `pwd; echo "$GREETING"; cat; echo oops >&2; exit 3`},
+		Dir:   dir,
+		Env:   []string{"GREETING=hi"},
+		Stdin: []byte("input\n"),
+	}, &result)
+	if want := dir + "\nhi\ninput\n"; string(result.Stdout) != want {
+		t.Errorf("got stdout %q, want %q", result.Stdout, want)
+	}
+	if string(result.Stderr) != "oops\n" || result.ExitCode != 3 || result.TimedOut {
+		t.Errorf("got result %+v, want exit code 3 and stderr oops", result)
+	}
+
+	callResult(t, MethodExec, ExecParams{Args: []string{"sleep", "10"}, Timeout: 50 * time.Millisecond}, &result)
+	if !result.TimedOut || result.ExitCode != -1 {
+		t.Errorf("got result %+v, want a timeout", result)
+	}
+
+	if resp := call(t, MethodExec, ExecParams{Args: []string{filepath.Join(dir, "missing")}}); resp.Error == nil {
+		t.Errorf("expected an error for a missing command")
+	}
+}
+
+func TestProcesses(t *testing.T) {
+	defer func(old string) { procDir = old }(procDir)
+	procDir = t.TempDir()
+
+	write := func(pid, file, data string) {
+		if err := os.MkdirAll(filepath.Join(procDir, pid), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(procDir, pid, file), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("1", "stat", "1 (init) S 0 1 1 0 -1")
+	write("1", "status", "Name:\tinit\nUid:\t0\t0\t0\t0\n")
+	write("1", "cmdline", "/sbin/init\x00splash\x00")
+	write("42", "stat", "42 (odd) name) R 1 42 42 0 -1")
+	write("42", "status", "Name:\todd) name\nUid:\t1000\t1000\t1000\t1000\n")
+	write("42", "cmdline", "")
+	// Entries that aren't processes, or that exited, are skipped.
+	write("self", "stat", "")
+	write("99", "status", "")
+
+	var procs []Process
+	callResult(t, MethodProcesses, nil, &procs)
+	want := []Process{
+		{PID: 1, PPID: 0, UID: 0, Name: "init", State: "S", Cmdline: []string{"/sbin/init", "splash"}},
+		{PID: 42, PPID: 1, UID: 1000, Name: "odd) name", State: "R"},
+	}
+	if !reflect.DeepEqual(procs, want) {
+		t.Errorf("got processes %+v, want %+v", procs, want)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aucloud/go-sshutil/agent"
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestPlatform(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+
+	platform, err := client.Platform(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := runtime.GOOS + "/" + runtime.GOARCH; platform != want {
+		t.Errorf("got platform %s, want %s", platform, want)
+	}
+}
+
+func TestAgent(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the agent")
+	}
+	ctx := context.Background()
+	// Building the agent can outlast a keepalive, which must be answered.
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), func(req *ssh.Request) {
+		req.Reply(true, nil)
+	})
+
+	dir := t.TempDir()
+	cache := filepath.Join(dir, "cache")
+	a, err := client.StartAgent(ctx, AgentOptions{Dir: cache})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if filepath.Dir(a.Path) != cache {
+		t.Errorf("agent installed at %s, want it in %s", a.Path, cache)
+	}
+
+	name := filepath.Join(dir, "file")
+	if err := a.WriteFile(ctx, name, []byte("hello world"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	data, err := a.ReadFile(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello world" {
+		t.Errorf("read %q, want %q", data, "hello world")
+	}
+	info, err := a.Stat(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != 11 || info.Mode.Perm() != 0o600 {
+		t.Errorf("got %+v", info)
+	}
+	if _, err := a.Stat(ctx, filepath.Join(dir, "missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got error %v for a missing file, want one matching fs.ErrNotExist", err)
+	}
+
+	// Requests are answered concurrently.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if sum, err := a.Hash(ctx, name, ""); err != nil {
+				t.Error(err)
+			} else if want := "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"; sum != want {
+				t.Errorf("got hash %s, want %s", sum, want)
+			}
+		}()
+	}
+	wg.Wait()
+
+	result, err := a.Exec(ctx, agent.ExecParams{Args: []string{"sh", "-c", "echo $PPID"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode != 0 || len(result.Stdout) == 0 {
+		t.Errorf("got result %+v", result)
+	}
+	procs, err := a.Processes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, p := range procs {
+		if p.PID == os.Getpid() {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("the test's process %d wasn't listed", os.Getpid())
+	}
+
+	if err := a.Close(); err != nil {
+		t.Errorf("failed to close the agent: %v", err)
+	}
+	if _, err := a.Stat(ctx, name); err == nil {
+		t.Errorf("expected an error calling a closed agent")
+	}
+
+	// The cached agent is reused rather than uploaded again.
+	before, err := os.Stat(a.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := client.StartAgent(ctx, AgentOptions{
+		Binaries: map[string]string{runtime.GOOS + "/" + runtime.GOARCH: a.Path},
+		Dir:      cache,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer again.Close()
+	if again.Path != a.Path {
+		t.Errorf("got agent %s, want the cached %s", again.Path, a.Path)
+	}
+	after, err := os.Stat(a.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(before, after) || !after.ModTime().Equal(before.ModTime()) {
+		t.Errorf("the cached agent was replaced")
+	}
+	entries, err := ioutil.ReadDir(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d files in the cache, want 1", len(entries))
+	}
+}
+
+func TestAgentCloseHung(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+	defer func(timeout time.Duration) { agentCloseTimeout = timeout }(agentCloseTimeout)
+	agentCloseTimeout = 100 * time.Millisecond
+
+	// The embedded agent ignores its input closing, like a hung one.
+	a, err := client.StartAgent(ctx, AgentOptions{
+		Embedded: map[string][]byte{runtime.GOOS + "/" + runtime.GOARCH: []byte("#!/bin/sh\nexec sleep 60\n")},
+		Dir:      t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- a.Close() }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("expected an error closing a hung agent")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("closing a hung agent didn't time out")
+	}
+}
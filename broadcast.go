@@ -0,0 +1,292 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"go.fuchsia.dev/fuchsia/tools/lib/logger"
+)
+
+// broadcastQueueSize is how many writes may be queued for a host before
+// Broadcast.Write waits for it.
+const broadcastQueueSize = 256
+
+// BroadcastHost is a host to broadcast to.
+type BroadcastHost struct {
+	// Label names the host in output and for SetEnabled. Labels must be
+	// unique.
+	Label  string
+	Client *Client
+
+	// Output is the host's pane, which gets its output as is. If nil, its
+	// output is interleaved with the other hosts' in BroadcastOptions.Output.
+	Output io.Writer
+}
+
+// BroadcastOptions configure StartBroadcast.
+type BroadcastOptions struct {
+	Hosts []BroadcastHost
+
+	// Command is run on every host. It defaults to the login shell.
+	Command []string
+
+	PTY PTYOptions
+
+	// Output gets the complete lines of output of hosts without their own,
+	// each prefixed by the host's label. Partial lines, such as prompts, are
+	// shown once they're complete or the host exits.
+	Output io.Writer
+}
+
+// BroadcastHostStatus describes a host of a Broadcast.
+type BroadcastHostStatus struct {
+	Label   string
+	Enabled bool
+	Exited  bool
+
+	// Err is the reason the host's session ended, if it failed.
+	Err error
+}
+
+// Broadcast is a terminal on many hosts at once, like cssh, which sends the
+// input written to it to every enabled host.
+type Broadcast struct {
+	hosts []*broadcastHost
+	wg    sync.WaitGroup
+
+	// mu serializes writes, and their end.
+	mu          sync.Mutex
+	inputClosed bool
+}
+
+type broadcastHost struct {
+	label   string
+	session *Session
+	stdin   io.WriteCloser
+	lines   *lineWriter
+	queue   chan []byte
+	done    chan struct{}
+
+	mu      sync.Mutex
+	enabled bool
+	exited  bool
+	err     error
+}
+
+// StartBroadcast starts the command, or a login shell, with a terminal on
+// every host. If any fails to start, those already started are closed.
+func StartBroadcast(ctx context.Context, opts BroadcastOptions) (*Broadcast, error) {
+	if len(opts.Hosts) == 0 {
+		return nil, errors.New("no hosts to broadcast to")
+	}
+	width := 0
+	labels := make(map[string]bool)
+	for _, h := range opts.Hosts {
+		if labels[h.Label] {
+			return nil, fmt.Errorf("duplicate label %q", h.Label)
+		}
+		labels[h.Label] = true
+		if len(h.Label) > width {
+			width = len(h.Label)
+		}
+	}
+	var interleaved io.Writer
+	if opts.Output != nil {
+		interleaved = &syncWriter{w: opts.Output}
+	}
+
+	b := &Broadcast{}
+	for _, h := range opts.Hosts {
+		host := &broadcastHost{
+			label:   h.Label,
+			queue:   make(chan []byte, broadcastQueueSize),
+			done:    make(chan struct{}),
+			enabled: true,
+		}
+		output := h.Output
+		if output == nil {
+			if interleaved == nil {
+				b.Close()
+				return nil, fmt.Errorf("no output for host %q", h.Label)
+			}
+			prefix := fmt.Sprintf("%-*s | ", width, h.Label)
+			host.lines = &lineWriter{line: func(line string) {
+				// Terminals end lines with "\r\n".
+				line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+				io.WriteString(interleaved, prefix+line+"\n")
+			}}
+			output = host.lines
+		}
+
+		session, stdin, err := h.Client.startPTYWithStdinPipe(ctx, shellQuoteArgs(opts.Command), opts.PTY, output, output)
+		if err != nil {
+			b.Close()
+			return nil, fmt.Errorf("failed to start on %s: %w", h.Label, err)
+		}
+		host.session, host.stdin = session, stdin
+		b.hosts = append(b.hosts, host)
+
+		go host.feed()
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			host.wait()
+		}()
+	}
+	return b, nil
+}
+
+// feed writes the host's queued input to it, until the input ends or the
+// host exits.
+func (h *broadcastHost) feed() {
+	for {
+		select {
+		case p, ok := <-h.queue:
+			if !ok {
+				h.stdin.Close()
+				return
+			}
+			if _, err := h.stdin.Write(p); err != nil {
+				logger.Debugf(context.Background(), "failed to send input to %s: %v", h.label, err)
+			}
+		case <-h.done:
+			return
+		}
+	}
+}
+
+func (h *broadcastHost) wait() {
+	err := h.session.Wait(context.Background())
+	if h.lines != nil {
+		h.lines.flush()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.exited = true
+	h.err = err
+	close(h.done)
+}
+
+// Write sends p to every enabled host that hasn't exited. It only waits for
+// a host if much of its input is still queued.
+func (b *Broadcast) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.inputClosed {
+		return 0, errors.New("broadcast input is closed")
+	}
+	for _, h := range b.hosts {
+		h.mu.Lock()
+		enabled := h.enabled
+		h.mu.Unlock()
+		if !enabled {
+			continue
+		}
+		select {
+		case h.queue <- append([]byte(nil), p...):
+		case <-h.done:
+		}
+	}
+	return len(p), nil
+}
+
+func (b *Broadcast) host(label string) (*broadcastHost, error) {
+	for _, h := range b.hosts {
+		if h.label == label {
+			return h, nil
+		}
+	}
+	return nil, fmt.Errorf("no host %q", label)
+}
+
+// SetEnabled turns input to a host on or off. Hosts start enabled.
+func (b *Broadcast) SetEnabled(label string, enabled bool) error {
+	h, err := b.host(label)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.enabled = enabled
+	return nil
+}
+
+// Hosts describes the hosts, in the order they were given.
+func (b *Broadcast) Hosts() []BroadcastHostStatus {
+	var statuses []BroadcastHostStatus
+	for _, h := range b.hosts {
+		h.mu.Lock()
+		statuses = append(statuses, BroadcastHostStatus{
+			Label:   h.label,
+			Enabled: h.enabled,
+			Exited:  h.exited,
+			Err:     h.err,
+		})
+		h.mu.Unlock()
+	}
+	return statuses
+}
+
+// Resize changes the size of every host's terminal.
+func (b *Broadcast) Resize(width, height int) error {
+	var errs []string
+	for _, h := range b.hosts {
+		h.mu.Lock()
+		exited := h.exited
+		h.mu.Unlock()
+		if exited {
+			continue
+		}
+		if err := h.session.WindowChange(width, height); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", h.label, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to resize: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Wait waits for every host's session to end, and returns an error naming
+// those that failed.
+func (b *Broadcast) Wait() error {
+	b.wg.Wait()
+	var errs []string
+	for _, s := range b.Hosts() {
+		if s.Err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", s.Label, s.Err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("broadcast failed on %d of %d hosts: %s", len(errs), len(b.hosts), strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// CloseInput ends the input of every host, once what's queued is sent.
+func (b *Broadcast) CloseInput() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.inputClosed {
+		return
+	}
+	b.inputClosed = true
+	for _, h := range b.hosts {
+		close(h.queue)
+	}
+}
+
+// Close ends every host's session.
+func (b *Broadcast) Close() {
+	for _, h := range b.hosts {
+		h.session.Close()
+	}
+}
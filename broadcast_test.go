@@ -0,0 +1,78 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+)
+
+func TestBroadcast(t *testing.T) {
+	ctx := context.Background()
+	var hosts []BroadcastHost
+	for _, label := range []string{"a", "bb", "c"} {
+		client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+		hosts = append(hosts, BroadcastHost{Label: label, Client: client})
+	}
+	var out, pane syncBuffer
+	hosts[2].Output = &pane
+
+	if _, err := StartBroadcast(ctx, BroadcastOptions{Hosts: []BroadcastHost{hosts[0], hosts[0]}, Output: &out}); err == nil {
+		t.Errorf("expected an error for duplicate labels")
+	}
+	if _, err := StartBroadcast(ctx, BroadcastOptions{Hosts: hosts}); err == nil {
+		t.Errorf("expected an error without output for hosts without panes")
+	}
+
+	b, err := StartBroadcast(ctx, BroadcastOptions{Hosts: hosts, Output: &out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	// Input goes to every host, whose lines are interleaved with their
+	// labels aligned, except for the host with its own pane.
+	fmt.Fprintln(b, "echo one")
+	waitForOutput(t, &out, "a  | one\n")
+	waitForOutput(t, &out, "bb | one\n")
+	waitForOutput(t, &pane, "one\n")
+	if strings.Contains(out.String(), "c ") {
+		t.Errorf("got output %q, want none from the host with a pane", out.String())
+	}
+	if err := b.Resize(100, 30); err != nil {
+		t.Errorf("failed to resize: %v", err)
+	}
+
+	// A disabled host gets no input.
+	if err := b.SetEnabled("nosuchhost", false); err == nil {
+		t.Errorf("expected an error for an unknown host")
+	}
+	if err := b.SetEnabled("bb", false); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintln(b, "echo two")
+	waitForOutput(t, &out, "a  | two\n")
+	waitForOutput(t, &pane, "two\n")
+
+	b.CloseInput()
+	if err := b.Wait(); err != nil {
+		t.Errorf("broadcast failed: %v", err)
+	}
+	if strings.Contains(out.String(), "bb | two") {
+		t.Errorf("got output %q, want none from the disabled host", out.String())
+	}
+	if _, err := fmt.Fprintln(b, "echo three"); err == nil {
+		t.Errorf("expected an error writing after the input is closed")
+	}
+	for _, s := range b.Hosts() {
+		if !s.Exited || s.Err != nil || s.Enabled != (s.Label != "bb") {
+			t.Errorf("got status %+v", s)
+		}
+	}
+}
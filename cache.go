@@ -0,0 +1,240 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bootIDPath is read to detect reboots of the remote.
+var bootIDPath = "/proc/sys/kernel/random/boot_id"
+
+// defaultCacheTTL is the TTL of the command cache if none is given.
+const defaultCacheTTL = time.Minute
+
+// CacheOptions configure the command cache enabled by EnableCache.
+type CacheOptions struct {
+	// TTL is how long the output of a command stays cached when the command
+	// doesn't give its own TTL. It defaults to a minute.
+	TTL time.Duration
+
+	// BootIDInterval, if set, is how often to check whether the remote has
+	// rebooted, which invalidates the cache. Otherwise the cache is only
+	// invalidated when the client reconnects, which may not happen until some
+	// time after a reboot if the connection is slow to time out.
+	BootIDInterval time.Duration
+}
+
+// CachedCommand is a command whose output may be cached, because it has no
+// side effects and its output changes rarely, such as `uname -a`.
+type CachedCommand struct {
+	// Args is the command, which is quoted as for Output.
+	Args []string
+
+	// Env is set in the command's environment with env(1).
+	Env map[string]string
+
+	// TTL is how long the output stays cached. If zero, the TTL of the cache
+	// is used.
+	TTL time.Duration
+}
+
+// command returns the arguments to run the command with Output.
+func (cmd CachedCommand) command() ([]string, error) {
+	if len(cmd.Env) == 0 {
+		return cmd.Args, nil
+	}
+	names := make([]string, 0, len(cmd.Env))
+	for name := range cmd.Env {
+		if !validEnvName(name) {
+			return nil, fmt.Errorf("invalid environment variable name %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	args := []string{"env"}
+	for _, name := range names {
+		args = append(args, name+"="+cmd.Env[name])
+	}
+	return append(args, cmd.Args...), nil
+}
+
+// CacheStats are counters of the command cache's activity.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+
+	// Invalidations counts the times the cache was emptied because the
+	// client reconnected, the remote rebooted, or InvalidateCache was called.
+	Invalidations uint64
+}
+
+type cacheEntry struct {
+	out     []byte
+	expires time.Time
+}
+
+type commandCache struct {
+	opts CacheOptions
+
+	// The following fields are protected by this mutex.
+	mu            sync.Mutex
+	conn          *Conn
+	bootID        string
+	bootIDChecked time.Time
+	entries       map[string]cacheEntry
+	stats         CacheStats
+}
+
+// invalidate empties the cache. The caller must hold the lock.
+func (cc *commandCache) invalidate() {
+	if len(cc.entries) > 0 {
+		cc.stats.Invalidations++
+	}
+	cc.entries = make(map[string]cacheEntry)
+}
+
+// EnableCache turns on caching of the output of commands run with
+// CachedOutput. Other commands are never cached. Enabling the cache again
+// replaces it, discarding its entries and statistics.
+func (c *Client) EnableCache(opts CacheOptions) {
+	if opts.TTL <= 0 {
+		opts.TTL = defaultCacheTTL
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cache = &commandCache{opts: opts, entries: make(map[string]cacheEntry)}
+}
+
+// DisableCache turns off the command cache and discards its entries.
+func (c *Client) DisableCache() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cache = nil
+}
+
+// InvalidateCache discards the entries of the command cache, for instance
+// after changing something that cached commands report.
+func (c *Client) InvalidateCache() {
+	c.mu.Lock()
+	cache := c.cache
+	c.mu.Unlock()
+
+	if cache != nil {
+		cache.mu.Lock()
+		cache.invalidate()
+		cache.mu.Unlock()
+	}
+}
+
+// CacheStats returns the statistics of the command cache, which are zero if
+// it isn't enabled.
+func (c *Client) CacheStats() CacheStats {
+	c.mu.Lock()
+	cache := c.cache
+	c.mu.Unlock()
+
+	if cache == nil {
+		return CacheStats{}
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	return cache.stats
+}
+
+// CachedOutput runs a command like Output, returning its output from the
+// cache if it ran within its TTL on the current connection. Entries are keyed
+// by the command's arguments and environment. Failures aren't cached. If the
+// cache isn't enabled, the command is always run.
+func (c *Client) CachedOutput(ctx context.Context, cmd CachedCommand) ([]byte, error) {
+	args, err := cmd.command()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	cache := c.cache
+	conn := c.conn
+	c.mu.Unlock()
+
+	if cache == nil {
+		return c.Output(ctx, args...)
+	}
+	if err := c.checkCache(ctx, cache, conn); err != nil {
+		return nil, err
+	}
+
+	key := strings.Join(args, "\x00")
+	cache.mu.Lock()
+	entry, ok := cache.entries[key]
+	if ok && time.Now().Before(entry.expires) {
+		cache.stats.Hits++
+		cache.mu.Unlock()
+		return append([]byte(nil), entry.out...), nil
+	}
+	cache.stats.Misses++
+	cache.mu.Unlock()
+
+	out, err := c.Output(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	ttl := cmd.TTL
+	if ttl == 0 {
+		ttl = cache.opts.TTL
+	}
+	cache.mu.Lock()
+	// Don't cache output from a connection that has since been replaced.
+	if cache.conn == conn {
+		cache.entries[key] = cacheEntry{
+			out:     append([]byte(nil), out...),
+			expires: time.Now().Add(ttl),
+		}
+	}
+	cache.mu.Unlock()
+	return out, nil
+}
+
+// checkCache invalidates the cache if the client has reconnected since it was
+// filled, or if the remote's boot ID is due to be checked and has changed.
+func (c *Client) checkCache(ctx context.Context, cache *commandCache, conn *Conn) error {
+	cache.mu.Lock()
+	if cache.conn != conn {
+		cache.invalidate()
+		cache.conn = conn
+		cache.bootID = ""
+		cache.bootIDChecked = time.Time{}
+	}
+	due := cache.opts.BootIDInterval > 0 && time.Since(cache.bootIDChecked) >= cache.opts.BootIDInterval
+	cache.mu.Unlock()
+	if !due {
+		return nil
+	}
+
+	out, err := c.Output(ctx, "cat", bootIDPath)
+	if err != nil {
+		return err
+	}
+	bootID := strings.TrimSpace(string(out))
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.conn == conn {
+		if cache.bootID != "" && cache.bootID != bootID {
+			cache.invalidate()
+		}
+		cache.bootID = bootID
+		cache.bootIDChecked = time.Now()
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestCachedOutput(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), func(req *ssh.Request) {
+		req.Reply(true, nil)
+	})
+
+	dir := t.TempDir()
+	oldBootID := bootIDPath
+	bootIDPath = filepath.Join(dir, "boot_id")
+	t.Cleanup(func() { bootIDPath = oldBootID })
+	setBootID := func(id string) {
+		if err := ioutil.WriteFile(bootIDPath, []byte(id+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setBootID("first")
+
+	// The command counts how many times it has run.
+	counter := filepath.Join(dir, "count")
+	count := CachedCommand{Args: []string{"sh", "-c", `echo >> "$COUNTER"; wc -l < "$COUNTER"`}, Env: map[string]string{"COUNTER": counter}}
+	run := func(cmd CachedCommand, want string) {
+		t.Helper()
+		out, err := client.CachedOutput(ctx, cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(out); len(got) == 0 || got[:len(got)-1] != want {
+			t.Errorf("got output %q, want %s", got, want)
+		}
+	}
+	checkStats := func(want CacheStats) {
+		t.Helper()
+		if got := client.CacheStats(); got != want {
+			t.Errorf("got stats %+v, want %+v", got, want)
+		}
+	}
+
+	// Without the cache, commands always run.
+	run(count, "1")
+	run(count, "2")
+	checkStats(CacheStats{})
+
+	client.EnableCache(CacheOptions{TTL: time.Hour})
+	run(count, "3")
+	run(count, "3")
+	checkStats(CacheStats{Hits: 1, Misses: 1})
+
+	// The environment is part of the key.
+	other := count
+	other.Env = map[string]string{"COUNTER": counter, "OTHER": "1"}
+	run(other, "4")
+	run(count, "3")
+	checkStats(CacheStats{Hits: 2, Misses: 2})
+
+	// Entries expire after their TTL.
+	short := count
+	short.Args = append([]string{}, count.Args...)
+	short.Args[2] += " # short"
+	short.TTL = time.Nanosecond
+	run(short, "5")
+	run(short, "6")
+	checkStats(CacheStats{Hits: 2, Misses: 4})
+
+	// Reconnecting invalidates the cache.
+	if err := client.Reconnect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	run(count, "7")
+	checkStats(CacheStats{Hits: 2, Misses: 5, Invalidations: 1})
+
+	client.InvalidateCache()
+	run(count, "8")
+	checkStats(CacheStats{Hits: 2, Misses: 6, Invalidations: 2})
+
+	// A change of boot ID invalidates the cache when it's checked.
+	client.EnableCache(CacheOptions{TTL: time.Hour, BootIDInterval: time.Nanosecond})
+	run(count, "9")
+	run(count, "9")
+	setBootID("second")
+	run(count, "10")
+	checkStats(CacheStats{Hits: 1, Misses: 2, Invalidations: 1})
+
+	if _, err := client.CachedOutput(ctx, CachedCommand{Args: []string{"true"}, Env: map[string]string{"A=B": ""}}); err == nil {
+		t.Errorf("expected an invalid environment variable name to fail")
+	}
+
+	// Without any TTL, entries are kept for the default one.
+	client.EnableCache(CacheOptions{})
+	run(count, "11")
+	run(count, "11")
+	checkStats(CacheStats{Hits: 1, Misses: 1})
+
+	client.DisableCache()
+	run(count, "12")
+	checkStats(CacheStats{})
+}
@@ -8,6 +8,7 @@ import (
 	"context"
 	"io"
 	"net"
+	"net/http"
 	"sync"
 
 	"go.fuchsia.dev/fuchsia/tools/lib/retry"
@@ -29,6 +30,17 @@ type Client struct {
 	mu        sync.Mutex
 	conn      *Conn
 	connected bool
+	cache     *commandCache
+
+	// toolsDir holds the tools provided by Bootstrap.
+	toolsDir string
+
+	// transport is shared by the HTTP clients from HTTPClient, so that they
+	// reuse its connections.
+	transport *http.Transport
+
+	// reconnected is closed when the client reconnects.
+	reconnected chan struct{}
 }
 
 // NewClient creates a new ssh client to the address.
@@ -61,6 +73,10 @@ func (c *Client) Close() {
 		c.conn.Close()
 		c.connected = false
 	}
+	// Idle connections were over the closed connection.
+	if c.transport != nil {
+		c.transport.CloseIdleConnections()
+	}
 }
 
 // RegisterDisconnectListener adds a waiter that gets notified when the ssh
@@ -125,6 +141,10 @@ func (c *Client) ReconnectWithBackoff(ctx context.Context, backoff retry.Backoff
 	} else {
 		c.conn = conn
 		c.connected = true
+		if c.reconnected != nil {
+			close(c.reconnected)
+			c.reconnected = nil
+		}
 
 		c.mu.Unlock()
 	}
@@ -132,6 +152,18 @@ func (c *Client) ReconnectWithBackoff(ctx context.Context, backoff retry.Backoff
 	return nil
 }
 
+// reconnectedChan returns a channel that's closed when the client next
+// reconnects.
+func (c *Client) reconnectedChan() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.reconnected == nil {
+		c.reconnected = make(chan struct{})
+	}
+	return c.reconnected
+}
+
 // Client returns the underlying *ssh.Client
 func (c *Client) Client() *ssh.Client {
 	c.mu.Lock()
@@ -146,9 +178,21 @@ func (c *Client) Client() *ssh.Client {
 func (c *Client) Start(ctx context.Context, command []string, stdout io.Writer, stderr io.Writer) (*Session, error) {
 	c.mu.Lock()
 	conn := c.conn
+	toolsDir := c.toolsDir
+	c.mu.Unlock()
+
+	return conn.Start(ctx, withTools(command, toolsDir), stdout, stderr)
+}
+
+// StartWithInput starts a command on the remote device like Start, with stdin
+// as its standard input.
+func (c *Client) StartWithInput(ctx context.Context, command []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) (*Session, error) {
+	c.mu.Lock()
+	conn := c.conn
+	toolsDir := c.toolsDir
 	c.mu.Unlock()
 
-	return conn.Start(ctx, command, stdout, stderr)
+	return conn.StartWithInput(ctx, withTools(command, toolsDir), stdin, stdout, stderr)
 }
 
 // Run a command to completion on the remote device and write STDOUT and STDERR
@@ -156,9 +200,21 @@ func (c *Client) Start(ctx context.Context, command []string, stdout io.Writer,
 func (c *Client) Run(ctx context.Context, command []string, stdout io.Writer, stderr io.Writer) error {
 	c.mu.Lock()
 	conn := c.conn
+	toolsDir := c.toolsDir
+	c.mu.Unlock()
+
+	return conn.Run(ctx, withTools(command, toolsDir), stdout, stderr)
+}
+
+// RunWithInput runs a command to completion on the remote device like Run,
+// with stdin as its standard input.
+func (c *Client) RunWithInput(ctx context.Context, command []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	c.mu.Lock()
+	conn := c.conn
+	toolsDir := c.toolsDir
 	c.mu.Unlock()
 
-	return conn.Run(ctx, command, stdout, stderr)
+	return conn.RunWithInput(ctx, withTools(command, toolsDir), stdin, stdout, stderr)
 }
 
 // LocalAddr returns the local address being used by the underlying ssh.Client.
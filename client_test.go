@@ -9,12 +9,17 @@ import (
 	"context"
 	"errors"
 	"io"
+	"io/ioutil"
 	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strconv"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/aucloud/go-sshutil/internal/sshtest"
 	"go.fuchsia.dev/fuchsia/tools/lib/retry"
 	"golang.org/x/crypto/ssh"
 )
@@ -24,19 +29,19 @@ func setUpClient(
 	t *testing.T,
 	onNewChannel func(ssh.NewChannel),
 	onRequest func(*ssh.Request),
-) (*Client, *sshServer) {
-	server, err := startSSHServer(onNewChannel, onRequest)
+) (*Client, *sshtest.Server) {
+	server, err := sshtest.Start(onNewChannel, onRequest)
 	if err != nil {
 		t.Fatalf("failed to start ssh server: %v", err)
 	}
-	t.Cleanup(server.stop)
+	t.Cleanup(server.Stop)
 
 	client, err := NewClient(
 		ctx,
 		ConstantAddrResolver{
-			Addr: server.addr,
+			Addr: server.Addr,
 		},
-		server.clientConfig,
+		server.ClientConfig,
 		retry.NoRetries())
 	if err != nil {
 		t.Fatalf("failed to create client: %v", err)
@@ -46,6 +51,30 @@ func setUpClient(
 	return client, server
 }
 
+// setUpPath replaces the PATH with a new directory holding executable scripts
+// with the given contents and links to the local versions of tools, so that
+// commands run by the test server find only those. It returns the directory.
+func setUpPath(t *testing.T, scripts map[string]string, tools ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, script := range scripts {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tool := range tools {
+		p, err := exec.LookPath(tool)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(p, filepath.Join(dir, tool)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+	return dir
+}
+
 func TestReconnect(t *testing.T) {
 	ctx := context.Background()
 
@@ -54,7 +83,7 @@ func TestReconnect(t *testing.T) {
 		client, _ := setUpClient(
 			ctx,
 			t,
-			onNewExecChannel(func(cmd string, stdout io.Writer, stderr io.Writer) int {
+			sshtest.OnExecChannel(func(cmd string, stdout io.Writer, stderr io.Writer) int {
 				expected := strconv.Itoa(int(atomic.AddInt64(&execCount, 1)))
 				if expected != cmd {
 					t.Fatalf("expected exec cmd to be %q, not %q", expected, cmd)
@@ -115,7 +144,7 @@ func TestCloseDuringConnection(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	serverConfig, clientConfig, err := genSSHConfig()
+	serverConfig, clientConfig, err := sshtest.GenConfig()
 	if err != nil {
 		t.Fatalf("failed to create ssh config: %v", err)
 	}
@@ -256,3 +285,25 @@ func TestCloseDuringConnection(t *testing.T) {
 		}
 	}
 }
+
+func TestConnectAndClose(t *testing.T) {
+	server, err := sshtest.Start(nil, nil)
+	if err != nil {
+		t.Fatalf("failed to start ssh server: %v", err)
+	}
+	defer server.Stop()
+
+	client, err := NewClient(
+		context.Background(),
+		ConstantAddrResolver{
+			Addr: server.Addr,
+		},
+		server.ClientConfig,
+		DefaultConnectBackoff(),
+	)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	client.Close()
+}
@@ -0,0 +1,228 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// clockSamples is the number of times ClockOffset reads the remote clock.
+	// The sample with the lowest round trip time is used.
+	clockSamples = 3
+)
+
+// ClockOffset is an estimate of how far the remote clock is from the local
+// one.
+type ClockOffset struct {
+	// Offset is the remote time minus the local time, so a positive offset
+	// means the remote clock is ahead.
+	Offset time.Duration
+
+	// Uncertainty bounds the error of Offset. It is half the round trip
+	// time, plus the resolution of the remote clock reading.
+	Uncertainty time.Duration
+
+	// RTT is the time from letting the remote read its clock to the reading
+	// arriving, which brackets when it was read. It's a round trip on the
+	// command's session rather than a keepalive, whose reply is independent
+	// of when the command reads the clock.
+	RTT time.Duration
+}
+
+// Exceeds reports whether the offset is certainly larger than threshold in
+// either direction, taking the uncertainty into account.
+func (o ClockOffset) Exceeds(threshold time.Duration) bool {
+	offset := o.Offset
+	if offset < 0 {
+		offset = -offset
+	}
+	return offset-o.Uncertainty > threshold
+}
+
+// ClockOffset estimates the offset of the remote clock from the local clock.
+//
+// The remote time is read with `date` by a shell that waits for a line of
+// input first, so that it's read between that line being sent and its output
+// arriving, and is taken to have been read midway. Waiting for the shell to
+// start before sending the line excludes the time spent setting up the
+// session, which would otherwise dominate the uncertainty.
+func (c *Client) ClockOffset(ctx context.Context) (*ClockOffset, error) {
+	var best *ClockOffset
+	for i := 0; i < clockSamples; i++ {
+		sample, err := c.sampleClock(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if best == nil || sample.RTT < best.RTT {
+			best = sample
+		}
+	}
+	return best, nil
+}
+
+// clockCommand prints an empty line once it's ready, then reads the clock
+// after reading a line.
+var clockCommand = []string{"sh", "-c", "echo; read -r line; date +%s.%N"}
+
+func (c *Client) sampleClock(ctx context.Context) (*ClockOffset, error) {
+	stdin, stdinWriter := io.Pipe()
+	// Once the command exits, this ends the copying of its input.
+	defer stdinWriter.Close()
+	stdout := clockProbe{stdin: stdinWriter}
+	var stderr bytes.Buffer
+	if err := c.RunWithInput(ctx, clockCommand, stdin, &stdout, &stderr); err != nil {
+		if ctx.Err() != nil || IsConnectionError(err) {
+			return nil, err
+		}
+		return nil, CommandError{Command: clockCommand, Stderr: stderr.String(), Err: err}
+	}
+	if stdout.arrived.IsZero() {
+		return nil, fmt.Errorf("no remote time in output %q", stdout.buf.String())
+	}
+	remote, resolution, err := parseRemoteTime(strings.TrimSpace(stdout.buf.String()))
+	if err != nil {
+		return nil, err
+	}
+	rtt := stdout.arrived.Sub(stdout.sent)
+	local := stdout.sent.Add(rtt / 2)
+	return &ClockOffset{
+		Offset:      remote.Sub(local),
+		Uncertainty: rtt/2 + resolution/2,
+		RTT:         rtt,
+	}, nil
+}
+
+// clockProbe is the standard output of clockCommand. Once the command is
+// ready, it sends the line letting it read the clock, and records when that
+// was sent and when the reading arrived.
+type clockProbe struct {
+	stdin io.WriteCloser
+
+	mu      sync.Mutex
+	sent    time.Time
+	arrived time.Time
+	// buf holds the output so far, and then just the reading.
+	buf bytes.Buffer
+}
+
+func (p *clockProbe) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.sent.IsZero() {
+		if p.arrived.IsZero() {
+			p.arrived = time.Now()
+		}
+		return p.buf.Write(b)
+	}
+	p.buf.Write(b)
+	if bytes.IndexByte(p.buf.Bytes(), '\n') < 0 {
+		return len(b), nil
+	}
+	// The reading can't have been written yet, as the command is waiting
+	// for this line.
+	p.buf.Reset()
+	p.sent = time.Now()
+	_, err := p.stdin.Write([]byte("\n"))
+	p.stdin.Close()
+	return len(b), err
+}
+
+// parseRemoteTime parses the output of `date +%s.%N`. Versions of date without
+// %N, such as some builds of BusyBox, print it literally, in which case the
+// time is only known to the second, and the middle of that second is returned.
+func parseRemoteTime(s string) (time.Time, time.Duration, error) {
+	secs, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		secs, frac = s[:i], s[i+1:]
+	}
+	sec, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid remote time %q", s)
+	}
+	if len(frac) == 0 || len(frac) > 9 || strings.Trim(frac, "0123456789") != "" {
+		return time.Unix(sec, int64(time.Second/2)), time.Second, nil
+	}
+	frac += strings.Repeat("0", 9-len(frac))
+	nsec, _ := strconv.ParseInt(frac, 10, 64)
+	return time.Unix(sec, nsec), time.Microsecond, nil
+}
+
+// ClockSync is whether the remote clock is synchronized by NTP, as reported by
+// timedatectl, chronyc or ntpstat.
+type ClockSync struct {
+	// Source is the command that reported the status, or empty if none of
+	// them is available, in which case the status is unknown.
+	Source string
+
+	Synchronized bool
+}
+
+// ClockSync reports whether the remote clock is synchronized by NTP or chrony.
+func (c *Client) ClockSync(ctx context.Context) (*ClockSync, error) {
+	// Each command prints "yes" or "no", or nothing if it isn't available.
+	for _, check := range []struct {
+		source string
+		script string
+	}{
+		{"timedatectl", `command -v timedatectl >/dev/null 2>&1 || exit 0; timedatectl show -p NTPSynchronized --value`},
+		{"chronyc", `command -v chronyc >/dev/null 2>&1 || exit 0; chronyc tracking | grep -q '^Leap status *: Normal' && echo yes || echo no`},
+		{"ntpstat", `command -v ntpstat >/dev/null 2>&1 || exit 0; ntpstat >/dev/null 2>&1 && echo yes || echo no`},
+	} {
+		out, err := c.ShellOutput(ctx, check.script)
+		var cmdErr CommandError
+		if errors.As(err, &cmdErr) {
+			// timedatectl fails without systemd running, so fall back to the
+			// other tools.
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		switch strings.TrimSpace(string(out)) {
+		case "yes":
+			return &ClockSync{Source: check.source, Synchronized: true}, nil
+		case "no":
+			return &ClockSync{Source: check.source}, nil
+		}
+	}
+	return &ClockSync{}, nil
+}
+
+// ClockCheck is the result of CheckClock, for callers to record alongside
+// their other reports about the host.
+type ClockCheck struct {
+	Offset ClockOffset
+	Sync   ClockSync
+
+	// Skewed is set if the offset certainly exceeds the threshold.
+	Skewed bool
+}
+
+// CheckClock estimates the offset of the remote clock and reports its
+// synchronization status, flagging it as skewed if the offset exceeds
+// threshold.
+func (c *Client) CheckClock(ctx context.Context, threshold time.Duration) (*ClockCheck, error) {
+	offset, err := c.ClockOffset(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sync, err := c.ClockSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &ClockCheck{
+		Offset: *offset,
+		Sync:   *sync,
+		Skewed: offset.Exceeds(threshold),
+	}, nil
+}
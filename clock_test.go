@@ -0,0 +1,150 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestClockOffset(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), func(req *ssh.Request) {
+		req.Reply(true, nil)
+	})
+
+	// The "remote" is the local machine, so there should be no offset.
+	offset, err := client.ClockOffset(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset.RTT <= 0 || offset.Uncertainty <= 0 {
+		t.Errorf("expected a positive RTT and uncertainty, got %+v", offset)
+	}
+	if offset.Exceeds(time.Second) {
+		t.Errorf("expected no significant offset, got %+v", offset)
+	}
+
+	check, err := client.CheckClock(ctx, -time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !check.Skewed {
+		t.Errorf("expected any offset to exceed a negative threshold, got %+v", check)
+	}
+}
+
+func TestClockOffsetExceeds(t *testing.T) {
+	o := ClockOffset{Offset: -3 * time.Second, Uncertainty: time.Second}
+	if !o.Exceeds(time.Second) || o.Exceeds(2*time.Second) {
+		t.Errorf("expected %+v to exceed 1s but not 2s", o)
+	}
+}
+
+// closeBuffer is a bytes.Buffer that records being closed.
+type closeBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *closeBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestClockProbe(t *testing.T) {
+	var stdin closeBuffer
+	p := clockProbe{stdin: &stdin}
+	p.Write([]byte("mo"))
+	if !p.sent.IsZero() || stdin.Len() != 0 {
+		t.Fatalf("expected nothing to be sent before the command is ready")
+	}
+	// The reading is bracketed by sending the line that allows it and its
+	// arrival.
+	p.Write([]byte("td\n"))
+	if p.sent.IsZero() || stdin.String() != "\n" || !stdin.closed {
+		t.Fatalf("expected a line to be sent once the command is ready, got %q", stdin.String())
+	}
+	p.Write([]byte("1700000000.5\n"))
+	if p.arrived.Before(p.sent) || p.buf.String() != "1700000000.5\n" {
+		t.Errorf("got reading %q sent at %v and arriving at %v", p.buf.String(), p.sent, p.arrived)
+	}
+}
+
+func TestParseRemoteTime(t *testing.T) {
+	for _, tc := range []struct {
+		in         string
+		want       time.Time
+		resolution time.Duration
+	}{
+		{"1700000000.123456789", time.Unix(1700000000, 123456789), time.Microsecond},
+		{"1700000000.5", time.Unix(1700000000, 500000000), time.Microsecond},
+		{"1700000000.%N", time.Unix(1700000000, 500000000), time.Second},
+		{"1700000000.N", time.Unix(1700000000, 500000000), time.Second},
+		{"1700000000", time.Unix(1700000000, 500000000), time.Second},
+	} {
+		got, resolution, err := parseRemoteTime(tc.in)
+		if err != nil {
+			t.Errorf("failed to parse %q: %v", tc.in, err)
+			continue
+		}
+		if !got.Equal(tc.want) || resolution != tc.resolution {
+			t.Errorf("parsed %q as %v with resolution %v, want %v with resolution %v", tc.in, got, resolution, tc.want, tc.resolution)
+		}
+	}
+	if _, _, err := parseRemoteTime("Mon Jan 1"); err == nil {
+		t.Errorf("expected an invalid time to fail")
+	}
+}
+
+func TestClockSync(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+
+	dir := setUpPath(t, nil, "sh", "grep")
+
+	sync, err := client.ClockSync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *sync != (ClockSync{}) {
+		t.Errorf("expected an unknown status without any tools, got %+v", sync)
+	}
+
+	// timedatectl fails when systemd isn't running, so chronyc is used.
+	for name, script := range map[string]string{
+		"timedatectl": "#!/bin/sh\necho 'System has not been booted with systemd' >&2\nexit 1\n",
+		"chronyc":     "#!/bin/sh\necho 'Reference ID    : C0A80001 (router)'\necho 'Leap status     : Normal'\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sync, err = client.ClockSync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ClockSync{Source: "chronyc", Synchronized: true}); *sync != want {
+		t.Errorf("got %+v, want %+v", sync, want)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "timedatectl"), []byte("#!/bin/sh\necho no\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sync, err = client.ClockSync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ClockSync{Source: "timedatectl"}); *sync != want {
+		t.Errorf("got %+v, want %+v", sync, want)
+	}
+}
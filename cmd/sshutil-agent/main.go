@@ -0,0 +1,21 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// sshutil-agent answers requests from sshutil on its standard input and
+// output. It's started by sshutil.Client.StartAgent, not by hand.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aucloud/go-sshutil/agent"
+)
+
+func main() {
+	if err := agent.Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "sshutil-agent: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package cmdparse parses the output of common system commands into typed
+// structs.
+//
+// Where a command has a JSON mode, such as `ip -j addr` and `lsblk -J`, that
+// is preferred. Each parser that reads a text format also accepts the output
+// of the BusyBox version of the command, and commands that BusyBox lacks have
+// parsers for a fallback that it does have, such as `netstat` for `ss` and
+// /proc/partitions for `lsblk`.
+package cmdparse
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// lines returns the non-blank lines of out, with surrounding whitespace
+// removed.
+func lines(out []byte) []string {
+	var result []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+func parseUint(field, s string) (uint64, error) {
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", field, s)
+	}
+	return v, nil
+}
+
+// lineError annotates an error with the line it was found on.
+func lineError(line string, err error) error {
+	return fmt.Errorf("%w in line %q", err, line)
+}
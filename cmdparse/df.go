@@ -0,0 +1,90 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package cmdparse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Filesystem is the usage of a mounted filesystem, as reported by df.
+type Filesystem struct {
+	Source     string
+	MountPoint string
+
+	// Size, Used and Available are in bytes.
+	Size      uint64
+	Used      uint64
+	Available uint64
+}
+
+// ParseDF parses the output of `df -P -k`, or `df -k` where -P isn't
+// supported. Entries too long for their column, which df without -P wraps
+// onto a second line, are joined back together. Both the source and the mount
+// point may contain spaces.
+func ParseDF(out []byte) ([]Filesystem, error) {
+	ls := lines(out)
+	if len(ls) == 0 || !strings.HasPrefix(ls[0], "Filesystem") {
+		return nil, fmt.Errorf("missing df header")
+	}
+	var result []Filesystem
+	for i := 1; i < len(ls); i++ {
+		line := ls[i]
+		fields := strings.Fields(line)
+		if len(fields) == 1 && i+1 < len(ls) {
+			i++
+			line += " " + ls[i]
+			fields = append(fields, strings.Fields(ls[i])...)
+		}
+		if len(fields) < 6 {
+			return nil, lineError(line, fmt.Errorf("too few fields"))
+		}
+		// The source is everything before the sizes, and the mount point
+		// everything after the capacity.
+		n := dfSourceFields(fields)
+		fs := Filesystem{
+			Source:     strings.Join(fields[:n], " "),
+			MountPoint: strings.Join(fields[n+4:], " "),
+		}
+		fields = fields[n-1:]
+		for _, f := range []struct {
+			name  string
+			value string
+			dst   *uint64
+		}{
+			{"size", fields[1], &fs.Size},
+			{"used", fields[2], &fs.Used},
+			{"available", fields[3], &fs.Available},
+		} {
+			v, err := parseUint(f.name, f.value)
+			if err != nil {
+				return nil, lineError(line, err)
+			}
+			*f.dst = v * 1024
+		}
+		result = append(result, fs)
+	}
+	return result, nil
+}
+
+// dfSourceFields returns the number of fields taken up by the source of a
+// filesystem in a line of df output, by finding the three sizes followed by
+// the capacity and a mount point. It defaults to one field if there are no
+// such columns, so that the sizes are reported as invalid.
+func dfSourceFields(fields []string) int {
+	isNumber := func(s string) bool {
+		_, err := strconv.ParseUint(s, 10, 64)
+		return err == nil
+	}
+	for n := 1; n+5 <= len(fields); n++ {
+		capacity := fields[n+3]
+		if isNumber(fields[n]) && isNumber(fields[n+1]) && isNumber(fields[n+2]) &&
+			(capacity == "-" || (strings.HasSuffix(capacity, "%") && isNumber(strings.TrimSuffix(capacity, "%")))) {
+			return n
+		}
+	}
+	return 1
+}
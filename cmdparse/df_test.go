@@ -0,0 +1,69 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package cmdparse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDF(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		out  string
+	}{
+		{
+			name: "posix",
+			out: `Filesystem     1024-blocks     Used Available Capacity Mounted on
+/dev/vda1         41152736 10412412  28626556      27% /
+tmpfs                 1024        0      1024       0% /mnt/my data
+`,
+		},
+		{
+			name: "busybox wrapped",
+			out: `Filesystem           1K-blocks      Used Available Use% Mounted on
+/dev/vda1
+                      41152736  10412412  28626556  27% /
+tmpfs                     1024         0      1024   0% /mnt/my data
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseDF([]byte(tc.out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []Filesystem{
+				{Source: "/dev/vda1", MountPoint: "/", Size: 41152736 * 1024, Used: 10412412 * 1024, Available: 28626556 * 1024},
+				{Source: "tmpfs", MountPoint: "/mnt/my data", Size: 1024 * 1024, Available: 1024 * 1024},
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	// Sources may contain spaces too, as may the names of network shares.
+	got, err := ParseDF([]byte(`Filesystem     1024-blocks    Used Available Capacity Mounted on
+/dev/my disk       1000     400       500      45% /my data
+//server/a share      2       1         1        - /
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Filesystem{
+		{Source: "/dev/my disk", MountPoint: "/my data", Size: 1000 * 1024, Used: 400 * 1024, Available: 500 * 1024},
+		{Source: "//server/a share", MountPoint: "/", Size: 2 * 1024, Used: 1024, Available: 1024},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	for _, out := range []string{"", "Filesystem 1K-blocks\n/dev/sda 1 2\n", "Filesystem\n/dev/sda x 1 2 3% /\n"} {
+		if _, err := ParseDF([]byte(out)); err == nil {
+			t.Errorf("expected %q to fail to parse", out)
+		}
+	}
+}
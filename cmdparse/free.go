@@ -0,0 +1,87 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package cmdparse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Memory is the memory usage reported by free, in bytes.
+type Memory struct {
+	Total     uint64
+	Used      uint64
+	Free      uint64
+	Shared    uint64
+	BuffCache uint64
+
+	// Available is an estimate of the memory available to new processes.
+	// It is zero if free doesn't report it, as with older versions.
+	Available uint64
+
+	SwapTotal uint64
+	SwapUsed  uint64
+	SwapFree  uint64
+}
+
+// ParseFree parses the output of free, whose values are multiplied by unit:
+// 1 for `free -b` and 1024 for plain `free`. The columns are found by name,
+// so both current versions and older ones reporting buffers and cache
+// separately, as BusyBox may, are supported.
+func ParseFree(out []byte, unit uint64) (*Memory, error) {
+	ls := lines(out)
+	if len(ls) == 0 {
+		return nil, fmt.Errorf("missing free header")
+	}
+	columns := strings.Fields(ls[0])
+	m := &Memory{}
+	var sawMem bool
+	for _, line := range ls[1:] {
+		fields := strings.Fields(line)
+		var dsts map[string]*uint64
+		switch fields[0] {
+		case "Mem:":
+			sawMem = true
+			dsts = map[string]*uint64{
+				"total":      &m.Total,
+				"used":       &m.Used,
+				"free":       &m.Free,
+				"shared":     &m.Shared,
+				"buff/cache": &m.BuffCache,
+				"buffers":    &m.BuffCache,
+				"cached":     &m.BuffCache,
+				"available":  &m.Available,
+			}
+		case "Swap:":
+			dsts = map[string]*uint64{
+				"total": &m.SwapTotal,
+				"used":  &m.SwapUsed,
+				"free":  &m.SwapFree,
+			}
+		default:
+			// Skip the "-/+ buffers/cache:" line of older versions.
+			continue
+		}
+		values := fields[1:]
+		if len(values) > len(columns) {
+			return nil, lineError(line, fmt.Errorf("more values than columns"))
+		}
+		for i, value := range values {
+			dst, ok := dsts[columns[i]]
+			if !ok {
+				continue
+			}
+			v, err := parseUint(columns[i], value)
+			if err != nil {
+				return nil, lineError(line, err)
+			}
+			*dst += v * unit
+		}
+	}
+	if !sawMem {
+		return nil, fmt.Errorf("missing Mem line")
+	}
+	return m, nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package cmdparse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFree(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		out  string
+		unit uint64
+		want Memory
+	}{
+		{
+			name: "procps",
+			out: `               total        used        free      shared  buff/cache   available
+Mem:      6305947648   553144320  4630167552     9510912  1373757440  5752803328
+Swap:     1073741824           0  1073741824
+`,
+			unit: 1,
+			want: Memory{
+				Total:     6305947648,
+				Used:      553144320,
+				Free:      4630167552,
+				Shared:    9510912,
+				BuffCache: 1373757440,
+				Available: 5752803328,
+				SwapTotal: 1073741824,
+				SwapFree:  1073741824,
+			},
+		},
+		{
+			name: "busybox",
+			out: `             total       used       free     shared    buffers     cached
+Mem:          1000        600        400         10         50        150
+-/+ buffers/cache:        400        600
+Swap:            0          0          0
+`,
+			unit: 1024,
+			want: Memory{
+				Total:     1000 * 1024,
+				Used:      600 * 1024,
+				Free:      400 * 1024,
+				Shared:    10 * 1024,
+				BuffCache: 200 * 1024,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseFree([]byte(tc.out), tc.unit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, tc.want) {
+				t.Errorf("got %+v, want %+v", *got, tc.want)
+			}
+		})
+	}
+
+	if _, err := ParseFree([]byte("total used\nSwap: 0 0\n"), 1); err == nil {
+		t.Errorf("expected output without a Mem line to fail")
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package cmdparse
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Interface is a network interface and its addresses, as reported by
+// `ip addr`.
+type Interface struct {
+	Index int      `json:"ifindex"`
+	Name  string   `json:"ifname"`
+	Flags []string `json:"flags"`
+	MTU   int      `json:"mtu"`
+	State string   `json:"operstate"`
+
+	// LinkType is the type of the link layer, such as "ether" or
+	// "loopback", and Address is its address.
+	LinkType string `json:"link_type"`
+	Address  string `json:"address"`
+
+	Addrs []Addr `json:"addr_info"`
+}
+
+// Addr is an address assigned to an interface.
+type Addr struct {
+	// Family is "inet" or "inet6".
+	Family    string `json:"family"`
+	Local     string `json:"local"`
+	PrefixLen int    `json:"prefixlen"`
+	Scope     string `json:"scope"`
+	Label     string `json:"label,omitempty"`
+}
+
+// CIDR returns the address in CIDR notation.
+func (a Addr) CIDR() string {
+	return a.Local + "/" + strconv.Itoa(a.PrefixLen)
+}
+
+// ParseIPAddrJSON parses the output of `ip -j addr`.
+func ParseIPAddrJSON(out []byte) ([]Interface, error) {
+	var ifaces []Interface
+	if err := json.Unmarshal(out, &ifaces); err != nil {
+		return nil, fmt.Errorf("invalid ip JSON: %w", err)
+	}
+	return ifaces, nil
+}
+
+// ParseIPAddr parses the text output of `ip addr`, for versions of ip that
+// have no JSON mode, including BusyBox's.
+func ParseIPAddr(out []byte) ([]Interface, error) {
+	var ifaces []Interface
+	for _, line := range lines(out) {
+		fields := strings.Fields(line)
+		switch {
+		case strings.HasSuffix(fields[0], ":"):
+			// 2: eth0@if5: <BROADCAST,UP> mtu 1500 qdisc noqueue state UP
+			if len(fields) < 3 {
+				return nil, lineError(line, fmt.Errorf("too few fields"))
+			}
+			index, err := strconv.Atoi(strings.TrimSuffix(fields[0], ":"))
+			if err != nil {
+				return nil, lineError(line, fmt.Errorf("invalid interface index"))
+			}
+			iface := Interface{
+				Index: index,
+				Name:  strings.TrimSuffix(fields[1], ":"),
+			}
+			if i := strings.IndexByte(iface.Name, '@'); i >= 0 {
+				iface.Name = iface.Name[:i]
+			}
+			if flags := strings.Trim(fields[2], "<>"); flags != "" {
+				iface.Flags = strings.Split(flags, ",")
+			}
+			for i := 3; i+1 < len(fields); i += 2 {
+				switch fields[i] {
+				case "mtu":
+					iface.MTU, _ = strconv.Atoi(fields[i+1])
+				case "state":
+					iface.State = fields[i+1]
+				}
+			}
+			ifaces = append(ifaces, iface)
+		case len(ifaces) == 0:
+			return nil, lineError(line, fmt.Errorf("address before any interface"))
+		case strings.HasPrefix(fields[0], "link/"):
+			iface := &ifaces[len(ifaces)-1]
+			iface.LinkType = strings.TrimPrefix(fields[0], "link/")
+			if len(fields) > 1 {
+				iface.Address = fields[1]
+			}
+		case fields[0] == "inet" || fields[0] == "inet6":
+			// inet 10.0.0.2/24 brd 10.0.0.255 scope global eth0
+			if len(fields) < 2 {
+				return nil, lineError(line, fmt.Errorf("too few fields"))
+			}
+			addr := Addr{Family: fields[0], Local: fields[1]}
+			if i := strings.IndexByte(addr.Local, '/'); i >= 0 {
+				prefixLen, err := strconv.Atoi(addr.Local[i+1:])
+				if err != nil {
+					return nil, lineError(line, fmt.Errorf("invalid prefix length"))
+				}
+				addr.Local, addr.PrefixLen = addr.Local[:i], prefixLen
+			}
+			for i := 2; i < len(fields); i++ {
+				if fields[i] == "scope" && i+1 < len(fields) {
+					addr.Scope = fields[i+1]
+					// IPv4 addresses are followed by their label.
+					if addr.Family == "inet" && i+2 < len(fields) {
+						addr.Label = fields[len(fields)-1]
+					}
+					break
+				}
+			}
+			iface := &ifaces[len(ifaces)-1]
+			iface.Addrs = append(iface.Addrs, addr)
+		}
+	}
+	return ifaces, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package cmdparse
+
+import (
+	"reflect"
+	"testing"
+)
+
+var wantInterfaces = []Interface{
+	{
+		Index:    1,
+		Name:     "lo",
+		Flags:    []string{"LOOPBACK", "UP", "LOWER_UP"},
+		MTU:      65536,
+		State:    "UNKNOWN",
+		LinkType: "loopback",
+		Address:  "00:00:00:00:00:00",
+		Addrs: []Addr{
+			{Family: "inet", Local: "127.0.0.1", PrefixLen: 8, Scope: "host", Label: "lo"},
+			{Family: "inet6", Local: "::1", PrefixLen: 128, Scope: "host"},
+		},
+	},
+	{
+		Index:    2,
+		Name:     "eth0",
+		Flags:    []string{"BROADCAST", "MULTICAST", "UP", "LOWER_UP"},
+		MTU:      1500,
+		State:    "UP",
+		LinkType: "ether",
+		Address:  "52:54:00:12:34:56",
+		Addrs: []Addr{
+			{Family: "inet", Local: "10.0.2.15", PrefixLen: 24, Scope: "global", Label: "eth0"},
+		},
+	},
+}
+
+func TestParseIPAddrJSON(t *testing.T) {
+	out := `[{"ifindex":1,"ifname":"lo","flags":["LOOPBACK","UP","LOWER_UP"],"mtu":65536,"qdisc":"noqueue","operstate":"UNKNOWN","group":"default","txqlen":1000,"link_type":"loopback","address":"00:00:00:00:00:00","broadcast":"00:00:00:00:00:00","addr_info":[{"family":"inet","local":"127.0.0.1","prefixlen":8,"scope":"host","label":"lo","valid_life_time":4294967295,"preferred_life_time":4294967295},{"family":"inet6","local":"::1","prefixlen":128,"scope":"host","valid_life_time":4294967295,"preferred_life_time":4294967295}]},` +
+		`{"ifindex":2,"link_index":5,"ifname":"eth0","flags":["BROADCAST","MULTICAST","UP","LOWER_UP"],"mtu":1500,"operstate":"UP","link_type":"ether","address":"52:54:00:12:34:56","addr_info":[{"family":"inet","local":"10.0.2.15","prefixlen":24,"broadcast":"10.0.2.255","scope":"global","label":"eth0"}]}]`
+	got, err := ParseIPAddrJSON([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, wantInterfaces) {
+		t.Errorf("got %+v, want %+v", got, wantInterfaces)
+	}
+	if cidr := got[1].Addrs[0].CIDR(); cidr != "10.0.2.15/24" {
+		t.Errorf("got CIDR %q", cidr)
+	}
+	if _, err := ParseIPAddrJSON([]byte("ip: invalid option")); err == nil {
+		t.Errorf("expected invalid JSON to fail")
+	}
+}
+
+func TestParseIPAddr(t *testing.T) {
+	out := `1: lo: <LOOPBACK,UP,LOWER_UP> mtu 65536 qdisc noqueue state UNKNOWN qlen 1000
+    link/loopback 00:00:00:00:00:00 brd 00:00:00:00:00:00
+    inet 127.0.0.1/8 scope host lo
+       valid_lft forever preferred_lft forever
+    inet6 ::1/128 scope host
+       valid_lft forever preferred_lft forever
+2: eth0@if5: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc noqueue state UP
+    link/ether 52:54:00:12:34:56 brd ff:ff:ff:ff:ff:ff
+    inet 10.0.2.15/24 brd 10.0.2.255 scope global eth0
+       valid_lft forever preferred_lft forever
+`
+	got, err := ParseIPAddr([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, wantInterfaces) {
+		t.Errorf("got %+v, want %+v", got, wantInterfaces)
+	}
+	if _, err := ParseIPAddr([]byte("    inet 10.0.0.1/8 scope global eth0\n")); err == nil {
+		t.Errorf("expected an address without an interface to fail")
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package cmdparse
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LsblkColumns are the columns ParseLsblkJSON expects lsblk to output, as in
+// `lsblk -J -b -o NAME,SIZE,TYPE,MOUNTPOINT,FSTYPE,RO,RM,MODEL`.
+const LsblkColumns = "NAME,SIZE,TYPE,MOUNTPOINT,FSTYPE,RO,RM,MODEL"
+
+// BlockDevice is a block device, as reported by lsblk.
+type BlockDevice struct {
+	Name string
+
+	// Size is in bytes.
+	Size uint64
+
+	// Type is the type of the device, such as "disk" or "part". It is empty
+	// for devices read from /proc/partitions.
+	Type       string
+	MountPoint string
+	FSType     string
+	ReadOnly   bool
+	Removable  bool
+	Model      string
+
+	// Children are the partitions and other devices stacked on this one.
+	Children []BlockDevice
+}
+
+// lsblkDevice is a device in the JSON output of lsblk. Older versions of lsblk
+// output every value as a string, and newer ones use numbers and booleans, so
+// the values are decoded loosely.
+type lsblkDevice struct {
+	Name       string        `json:"name"`
+	Size       lsblkValue    `json:"size"`
+	Type       string        `json:"type"`
+	MountPoint *string       `json:"mountpoint"`
+	FSType     *string       `json:"fstype"`
+	RO         lsblkValue    `json:"ro"`
+	RM         lsblkValue    `json:"rm"`
+	Model      *string       `json:"model"`
+	Children   []lsblkDevice `json:"children"`
+}
+
+// lsblkValue is the text of a JSON string, number or boolean.
+type lsblkValue string
+
+func (v *lsblkValue) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = lsblkValue(s)
+		return nil
+	}
+	*v = lsblkValue(strings.TrimSpace(string(data)))
+	return nil
+}
+
+func (v lsblkValue) bool() bool {
+	return v == "true" || v == "1"
+}
+
+func (v lsblkValue) uint() (uint64, error) {
+	if v == "" || v == "null" {
+		return 0, nil
+	}
+	return strconv.ParseUint(string(v), 10, 64)
+}
+
+// ParseLsblkJSON parses the output of `lsblk -J -b -o` with LsblkColumns.
+func ParseLsblkJSON(out []byte) ([]BlockDevice, error) {
+	var result struct {
+		BlockDevices []lsblkDevice `json:"blockdevices"`
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("invalid lsblk JSON: %w", err)
+	}
+	return convertLsblk(result.BlockDevices)
+}
+
+func convertLsblk(devices []lsblkDevice) ([]BlockDevice, error) {
+	var result []BlockDevice
+	for _, d := range devices {
+		size, err := d.Size.uint()
+		if err != nil {
+			return nil, fmt.Errorf("invalid size %q of %s", d.Size, d.Name)
+		}
+		children, err := convertLsblk(d.Children)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, BlockDevice{
+			Name:       d.Name,
+			Size:       size,
+			Type:       d.Type,
+			MountPoint: stringValue(d.MountPoint),
+			FSType:     stringValue(d.FSType),
+			ReadOnly:   d.RO.bool(),
+			Removable:  d.RM.bool(),
+			Model:      strings.TrimSpace(stringValue(d.Model)),
+			Children:   children,
+		})
+	}
+	return result, nil
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// ParseProcPartitions parses /proc/partitions, for systems without lsblk,
+// such as those using BusyBox. Only names and sizes are known, and the
+// devices aren't nested.
+func ParseProcPartitions(out []byte) ([]BlockDevice, error) {
+	var devices []BlockDevice
+	for _, line := range lines(out) {
+		fields := strings.Fields(line)
+		if fields[0] == "major" {
+			continue
+		}
+		if len(fields) != 4 {
+			return nil, lineError(line, fmt.Errorf("wrong number of fields"))
+		}
+		blocks, err := parseUint("size", fields[2])
+		if err != nil {
+			return nil, lineError(line, err)
+		}
+		devices = append(devices, BlockDevice{Name: fields[3], Size: blocks * 1024})
+	}
+	return devices, nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package cmdparse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLsblkJSON(t *testing.T) {
+	want := []BlockDevice{
+		{
+			Name:  "sda",
+			Size:  500107862016,
+			Type:  "disk",
+			Model: "Samsung SSD",
+			Children: []BlockDevice{
+				{Name: "sda1", Size: 536870912, Type: "part", MountPoint: "/boot/efi", FSType: "vfat"},
+			},
+		},
+		{Name: "sr0", Size: 1073741312, Type: "rom", ReadOnly: true, Removable: true},
+	}
+	for _, tc := range []struct {
+		name string
+		out  string
+	}{
+		{
+			name: "typed",
+			out: `{"blockdevices": [
+				{"name":"sda", "size":500107862016, "type":"disk", "mountpoint":null, "fstype":null, "ro":false, "rm":false, "model":"Samsung SSD ",
+				 "children": [{"name":"sda1", "size":536870912, "type":"part", "mountpoint":"/boot/efi", "fstype":"vfat", "ro":false, "rm":false, "model":null}]},
+				{"name":"sr0", "size":1073741312, "type":"rom", "mountpoint":null, "fstype":null, "ro":true, "rm":true, "model":null}
+			]}`,
+		},
+		{
+			name: "strings",
+			out: `{"blockdevices": [
+				{"name":"sda", "size":"500107862016", "type":"disk", "mountpoint":null, "fstype":null, "ro":"0", "rm":"0", "model":"Samsung SSD",
+				 "children": [{"name":"sda1", "size":"536870912", "type":"part", "mountpoint":"/boot/efi", "fstype":"vfat", "ro":"0", "rm":"0", "model":null}]},
+				{"name":"sr0", "size":"1073741312", "type":"rom", "mountpoint":null, "fstype":null, "ro":"1", "rm":"1", "model":null}
+			]}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseLsblkJSON([]byte(tc.out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	if _, err := ParseLsblkJSON([]byte(`{"blockdevices": [{"name":"sda", "size":"big"}]}`)); err == nil {
+		t.Errorf("expected an invalid size to fail")
+	}
+}
+
+func TestParseProcPartitions(t *testing.T) {
+	out := `major minor  #blocks  name
+
+ 254        0  268435456 vda
+ 254        1     524288 vda1
+`
+	got, err := ParseProcPartitions([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []BlockDevice{{Name: "vda", Size: 268435456 * 1024}, {Name: "vda1", Size: 524288 * 1024}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package cmdparse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Mount is a mounted filesystem.
+type Mount struct {
+	Source  string
+	Target  string
+	Type    string
+	Options []string
+}
+
+// HasOption reports whether the filesystem was mounted with an option, such
+// as "ro".
+func (m Mount) HasOption(opt string) bool {
+	for _, o := range m.Options {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseMount parses the output of `mount` with no arguments, which has the
+// same format for util-linux and BusyBox:
+//
+//	/dev/sda1 on /boot type ext4 (rw,relatime)
+func ParseMount(out []byte) ([]Mount, error) {
+	var mounts []Mount
+	for _, line := range lines(out) {
+		on := strings.Index(line, " on ")
+		typ := strings.LastIndex(line, " type ")
+		// The type must follow the target, rather than overlap the " on ".
+		if on < 0 || typ < on+len(" on ") {
+			return nil, lineError(line, fmt.Errorf("invalid mount entry"))
+		}
+		m := Mount{
+			Source: line[:on],
+			Target: line[on+len(" on ") : typ],
+		}
+		rest := strings.Fields(line[typ+len(" type "):])
+		if len(rest) == 0 {
+			return nil, lineError(line, fmt.Errorf("missing filesystem type"))
+		}
+		m.Type = rest[0]
+		if len(rest) > 1 {
+			m.Options = strings.Split(strings.Trim(rest[1], "()"), ",")
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, nil
+}
+
+// ParseProcMounts parses /proc/mounts or /etc/mtab, which unlike the output
+// of `mount` escapes spaces in paths, so is unambiguous.
+func ParseProcMounts(out []byte) ([]Mount, error) {
+	var mounts []Mount
+	for _, line := range lines(out) {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			return nil, lineError(line, fmt.Errorf("too few fields"))
+		}
+		mounts = append(mounts, Mount{
+			Source:  unescapeOctal(fields[0]),
+			Target:  unescapeOctal(fields[1]),
+			Type:    fields[2],
+			Options: strings.Split(fields[3], ","),
+		})
+	}
+	return mounts, nil
+}
+
+// unescapeOctal replaces the \ooo escapes used by the kernel for whitespace
+// and backslashes in mount paths.
+func unescapeOctal(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,52 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package cmdparse
+
+import (
+	"reflect"
+	"testing"
+)
+
+var wantMounts = []Mount{
+	{Source: "/dev/vda1", Target: "/", Type: "ext4", Options: []string{"rw", "relatime"}},
+	{Source: "tmpfs", Target: "/mnt/my data", Type: "tmpfs", Options: []string{"ro", "nosuid"}},
+}
+
+func TestParseMount(t *testing.T) {
+	out := `/dev/vda1 on / type ext4 (rw,relatime)
+tmpfs on /mnt/my data type tmpfs (ro,nosuid)
+`
+	got, err := ParseMount([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, wantMounts) {
+		t.Errorf("got %+v, want %+v", got, wantMounts)
+	}
+	if !got[1].HasOption("ro") || got[0].HasOption("ro") {
+		t.Errorf("HasOption is wrong")
+	}
+	for _, line := range []string{"garbage\n", "0 on type 0\n"} {
+		if _, err := ParseMount([]byte(line)); err == nil {
+			t.Errorf("expected an error for %q", line)
+		}
+	}
+}
+
+func TestParseProcMounts(t *testing.T) {
+	out := `/dev/vda1 / ext4 rw,relatime 0 0
+tmpfs /mnt/my\040data tmpfs ro,nosuid 0 0
+`
+	got, err := ParseProcMounts([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, wantMounts) {
+		t.Errorf("got %+v, want %+v", got, wantMounts)
+	}
+	if got := unescapeOctal(`a\134b\`); got != `a\b\` {
+		t.Errorf("got %q", got)
+	}
+}
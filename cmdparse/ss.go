@@ -0,0 +1,130 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package cmdparse
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Socket is a TCP or UDP socket, as reported by ss or netstat.
+type Socket struct {
+	// Protocol is "tcp" or "udp", with a "6" suffix for IPv6 sockets in the
+	// output of netstat.
+	Protocol string
+
+	// State is the state of the socket as the command reports it, such as
+	// "LISTEN" or "ESTAB" for ss and "LISTEN" or "ESTABLISHED" for netstat.
+	// It may be empty for UDP sockets in the output of netstat.
+	State string
+
+	RecvQ uint64
+	SendQ uint64
+	Local Endpoint
+	Peer  Endpoint
+}
+
+// Endpoint is the address of one end of a socket. Either part may be "*".
+type Endpoint struct {
+	Host string
+	Port string
+}
+
+func (e Endpoint) String() string {
+	return net.JoinHostPort(e.Host, e.Port)
+}
+
+// parseEndpoint splits the address of a socket at its last colon. The host
+// may be bracketed or include a zone, as in "[::]:22" or "127.0.0.53%lo:53",
+// or, in the output of netstat, be an unbracketed IPv6 address.
+func parseEndpoint(s string) (Endpoint, error) {
+	i := strings.LastIndexByte(s, ':')
+	if i < 0 {
+		return Endpoint{}, fmt.Errorf("invalid address %q", s)
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(s[:i], "["), "]")
+	if host == "" {
+		host = "*"
+	}
+	return Endpoint{Host: host, Port: s[i+1:]}, nil
+}
+
+// ParseSS parses the output of `ss -tuan` or a similar invocation listing TCP
+// and/or UDP sockets numerically. The output must include the header line.
+func ParseSS(out []byte) ([]Socket, error) {
+	ls := lines(out)
+	if len(ls) == 0 {
+		return nil, fmt.Errorf("missing ss header")
+	}
+	// The Netid column is only present when more than one kind of socket is
+	// listed.
+	hasNetid := strings.HasPrefix(ls[0], "Netid")
+	if !hasNetid && !strings.HasPrefix(ls[0], "State") {
+		return nil, fmt.Errorf("missing ss header")
+	}
+	var sockets []Socket
+	for _, line := range ls[1:] {
+		fields := strings.Fields(line)
+		var s Socket
+		if hasNetid {
+			if len(fields) == 0 {
+				continue
+			}
+			s.Protocol, fields = fields[0], fields[1:]
+		}
+		if len(fields) < 5 {
+			return nil, lineError(line, fmt.Errorf("too few fields"))
+		}
+		s.State = fields[0]
+		if err := parseSocketFields(&s, fields[1:5]); err != nil {
+			return nil, lineError(line, err)
+		}
+		sockets = append(sockets, s)
+	}
+	return sockets, nil
+}
+
+// ParseNetstat parses the output of `netstat -tuan`, which BusyBox provides
+// instead of ss.
+func ParseNetstat(out []byte) ([]Socket, error) {
+	var sockets []Socket
+	for _, line := range lines(out) {
+		fields := strings.Fields(line)
+		if !strings.HasPrefix(fields[0], "tcp") && !strings.HasPrefix(fields[0], "udp") {
+			// Skip the headers.
+			continue
+		}
+		if len(fields) < 5 {
+			return nil, lineError(line, fmt.Errorf("too few fields"))
+		}
+		s := Socket{Protocol: fields[0]}
+		if err := parseSocketFields(&s, fields[1:5]); err != nil {
+			return nil, lineError(line, err)
+		}
+		if len(fields) > 5 {
+			s.State = fields[5]
+		}
+		sockets = append(sockets, s)
+	}
+	return sockets, nil
+}
+
+// parseSocketFields parses the receive and send queues and local and peer
+// addresses, which ss and netstat print in the same order.
+func parseSocketFields(s *Socket, fields []string) error {
+	var err error
+	if s.RecvQ, err = parseUint("receive queue", fields[0]); err != nil {
+		return err
+	}
+	if s.SendQ, err = parseUint("send queue", fields[1]); err != nil {
+		return err
+	}
+	if s.Local, err = parseEndpoint(fields[2]); err != nil {
+		return err
+	}
+	s.Peer, err = parseEndpoint(fields[3])
+	return err
+}
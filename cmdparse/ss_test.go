@@ -0,0 +1,71 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package cmdparse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSS(t *testing.T) {
+	out := `Netid State  Recv-Q Send-Q      Local Address:Port  Peer Address:Port Process
+udp   UNCONN 0      0       127.0.0.53%lo:53              0.0.0.0:*
+tcp   LISTEN 0      128           0.0.0.0:22              0.0.0.0:*
+tcp   ESTAB  0      36          10.0.2.15:22             10.0.2.2:51234
+tcp   LISTEN 0      128              [::]:22                 [::]:*
+`
+	got, err := ParseSS([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Socket{
+		{Protocol: "udp", State: "UNCONN", Local: Endpoint{"127.0.0.53%lo", "53"}, Peer: Endpoint{"0.0.0.0", "*"}},
+		{Protocol: "tcp", State: "LISTEN", SendQ: 128, Local: Endpoint{"0.0.0.0", "22"}, Peer: Endpoint{"0.0.0.0", "*"}},
+		{Protocol: "tcp", State: "ESTAB", SendQ: 36, Local: Endpoint{"10.0.2.15", "22"}, Peer: Endpoint{"10.0.2.2", "51234"}},
+		{Protocol: "tcp", State: "LISTEN", SendQ: 128, Local: Endpoint{"::", "22"}, Peer: Endpoint{"::", "*"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if s := got[3].Local.String(); s != "[::]:22" {
+		t.Errorf("got endpoint %q", s)
+	}
+
+	// Without Netid, as when only TCP sockets are listed.
+	got, err = ParseSS([]byte("State Recv-Q Send-Q Local Address:Port Peer Address:Port\nLISTEN 0 128 *:80 *:*\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Socket{{State: "LISTEN", SendQ: 128, Local: Endpoint{"*", "80"}, Peer: Endpoint{"*", "*"}}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if _, err := ParseSS([]byte("tcp LISTEN 0 128 *:80 *:*\n")); err == nil {
+		t.Errorf("expected output without a header to fail")
+	}
+}
+
+func TestParseNetstat(t *testing.T) {
+	out := `Active Internet connections (servers and established)
+Proto Recv-Q Send-Q Local Address           Foreign Address         State
+tcp        0      0 0.0.0.0:22              0.0.0.0:*               LISTEN
+tcp        0     52 10.0.2.15:22            10.0.2.2:51234          ESTABLISHED
+tcp        0      0 :::22                   :::*                    LISTEN
+udp        0      0 0.0.0.0:68              0.0.0.0:*
+`
+	got, err := ParseNetstat([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Socket{
+		{Protocol: "tcp", State: "LISTEN", Local: Endpoint{"0.0.0.0", "22"}, Peer: Endpoint{"0.0.0.0", "*"}},
+		{Protocol: "tcp", State: "ESTABLISHED", SendQ: 52, Local: Endpoint{"10.0.2.15", "22"}, Peer: Endpoint{"10.0.2.2", "51234"}},
+		{Protocol: "tcp", State: "LISTEN", Local: Endpoint{"::", "22"}, Peer: Endpoint{"::", "*"}},
+		{Protocol: "udp", Local: Endpoint{"0.0.0.0", "68"}, Peer: Endpoint{"0.0.0.0", "*"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
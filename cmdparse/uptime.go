@@ -0,0 +1,88 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package cmdparse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Uptime is how long a system has been running and its load averages, as
+// reported by uptime.
+type Uptime struct {
+	// Up has a resolution of one minute.
+	Up time.Duration
+
+	// Users is the number of logged in users, or -1 if uptime doesn't
+	// report it, as BusyBox may not.
+	Users int
+
+	Load1, Load5, Load15 float64
+}
+
+// ParseUptime parses the output of uptime, such as:
+//
+//	12:00:01 up 3 days,  2:03,  2 users,  load average: 0.00, 0.01, 0.05
+//	12:00:01 up 5 min,  load average: 0.10, 0.20, 0.30
+func ParseUptime(out []byte) (*Uptime, error) {
+	line := strings.TrimSpace(string(out))
+	up := strings.Index(line, " up ")
+	load := strings.Index(line, "load average:")
+	if up < 0 || load < up {
+		return nil, fmt.Errorf("invalid uptime %q", line)
+	}
+	u := &Uptime{Users: -1}
+
+	for _, part := range strings.Split(line[up+len(" up "):load], ",") {
+		part = strings.TrimSpace(part)
+		fields := strings.Fields(part)
+		switch {
+		case part == "":
+		case len(fields) == 2 && strings.HasPrefix(fields[1], "user"):
+			users, err := strconv.Atoi(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid user count in uptime %q", line)
+			}
+			u.Users = users
+		case len(fields) == 2 && strings.HasPrefix(fields[1], "day"):
+			days, err := strconv.Atoi(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid days in uptime %q", line)
+			}
+			u.Up += time.Duration(days) * 24 * time.Hour
+		case len(fields) == 2 && strings.HasPrefix(fields[1], "min"):
+			mins, err := strconv.Atoi(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid minutes in uptime %q", line)
+			}
+			u.Up += time.Duration(mins) * time.Minute
+		case len(fields) == 1 && strings.Contains(part, ":"):
+			hm := strings.SplitN(part, ":", 2)
+			hours, err1 := strconv.Atoi(hm[0])
+			mins, err2 := strconv.Atoi(hm[1])
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("invalid hours in uptime %q", line)
+			}
+			u.Up += time.Duration(hours)*time.Hour + time.Duration(mins)*time.Minute
+		default:
+			return nil, fmt.Errorf("unexpected %q in uptime %q", part, line)
+		}
+	}
+
+	loads := strings.Split(line[load+len("load average:"):], ",")
+	if len(loads) != 3 {
+		return nil, fmt.Errorf("invalid load averages in uptime %q", line)
+	}
+	for i, dst := range []*float64{&u.Load1, &u.Load5, &u.Load15} {
+		v, err := strconv.ParseFloat(strings.TrimSpace(loads[i]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid load averages in uptime %q", line)
+		}
+		*dst = v
+	}
+	return u, nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package cmdparse
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseUptime(t *testing.T) {
+	for _, tc := range []struct {
+		out  string
+		want Uptime
+	}{
+		{
+			" 22:21:46 up 29 min,  0 user,  load average: 0.47, 0.36, 0.28\n",
+			Uptime{Up: 29 * time.Minute, Users: 0, Load1: 0.47, Load5: 0.36, Load15: 0.28},
+		},
+		{
+			"12:00:01 up 3 days,  2:03,  2 users,  load average: 0.00, 0.01, 0.05",
+			Uptime{Up: 3*24*time.Hour + 2*time.Hour + 3*time.Minute, Users: 2, Load5: 0.01, Load15: 0.05},
+		},
+		{
+			"12:00:01 up 1 day, 5 min,  load average: 1.00, 2.00, 3.00",
+			Uptime{Up: 24*time.Hour + 5*time.Minute, Users: -1, Load1: 1, Load5: 2, Load15: 3},
+		},
+	} {
+		got, err := ParseUptime([]byte(tc.out))
+		if err != nil {
+			t.Errorf("failed to parse %q: %v", tc.out, err)
+			continue
+		}
+		if *got != tc.want {
+			t.Errorf("parsed %q as %+v, want %+v", tc.out, *got, tc.want)
+		}
+	}
+
+	for _, out := range []string{"", "12:00 up 3 fortnights, load average: 0, 0, 0", "12:00 up 5 min, load average: 1, 2"} {
+		if _, err := ParseUptime([]byte(out)); err == nil {
+			t.Errorf("expected %q to fail to parse", out)
+		}
+	}
+}
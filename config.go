@@ -0,0 +1,39 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aucloud/go-sshutil/configedit"
+)
+
+// EditConfig applies path-based changes to a structured config file on the
+// remote, keeping comments and formatting where the format allows. If format
+// is empty it is guessed from the file's extension.
+//
+// As with EnsureLine, the file is only written if its content changes, in
+// which case the original is backed up and the new content is written
+// atomically with the original mode and ownership.
+func (f *FileClient) EditConfig(ctx context.Context, name string, format configedit.Format, changes []configedit.Change, opts EditOptions) (*EditResult, error) {
+	if format == "" {
+		var err error
+		if format, err = configedit.FormatFromPath(name); err != nil {
+			return nil, err
+		}
+	}
+
+	name, fi, old, err := f.readForEdit(name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := configedit.Apply(format, old, changes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to edit %q: %w", name, err)
+	}
+	return f.replaceContent(ctx, name, fi, old, updated, opts)
+}
@@ -0,0 +1,54 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aucloud/go-sshutil/configedit"
+)
+
+func TestEditConfig(t *testing.T) {
+	ctx := context.Background()
+	f := setUpFileClient(ctx, t)
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "config.yaml")
+	writeTree(t, dir, map[string]string{"config.yaml": "# managed by hand\nport: 22 # ssh\n"})
+
+	changes := []configedit.Change{configedit.Set("port", 2222)}
+	result, err := f.EditConfig(ctx, name, "", changes, EditOptions{SkipBackup: true})
+	if err != nil {
+		t.Fatalf("EditConfig failed: %v", err)
+	}
+	if !result.Changed {
+		t.Errorf("expected the first edit to change the file")
+	}
+	assertFileContents(t, name, "# managed by hand\nport: 2222 # ssh\n")
+
+	result, err = f.EditConfig(ctx, name, "", changes, EditOptions{})
+	if err != nil {
+		t.Fatalf("EditConfig failed: %v", err)
+	}
+	if result.Changed || result.Backup != "" {
+		t.Errorf("expected the second edit to be a no-op, got %+v", result)
+	}
+
+	missing := filepath.Join(dir, "app.json")
+	if _, err := f.EditConfig(ctx, missing, "", changes, EditOptions{}); !os.IsNotExist(err) {
+		t.Errorf("expected editing a missing file to fail without Create, got %v", err)
+	}
+	if _, err := f.EditConfig(ctx, missing, configedit.JSON, changes, EditOptions{Create: true}); err != nil {
+		t.Fatalf("EditConfig failed: %v", err)
+	}
+	assertFileContents(t, missing, "{\n  \"port\": 2222\n}\n")
+
+	if _, err := f.EditConfig(ctx, filepath.Join(dir, "unknown"), "", changes, EditOptions{}); err == nil {
+		t.Errorf("expected a file with an unknown format to fail")
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package configedit applies path-based changes to structured configuration
+// files while keeping as much of their original formatting as the format
+// allows.
+//
+// JSON keeps the order of object keys and the original indentation. YAML
+// keeps comments, through gopkg.in/yaml.v3's node API. INI and TOML are edited
+// line by line, so everything other than the lines being changed is kept
+// byte for byte.
+package configedit
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Format is a configuration file format.
+type Format string
+
+const (
+	JSON Format = "json"
+	YAML Format = "yaml"
+	INI  Format = "ini"
+	TOML Format = "toml"
+)
+
+// FormatFromPath guesses the format of a file from its extension.
+func FormatFromPath(name string) (Format, error) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json":
+		return JSON, nil
+	case ".yaml", ".yml":
+		return YAML, nil
+	case ".ini", ".conf", ".cfg":
+		return INI, nil
+	case ".toml":
+		return TOML, nil
+	}
+	return "", fmt.Errorf("cannot determine the config format of %q", name)
+}
+
+// Op is the kind of a Change.
+type Op int
+
+const (
+	// OpSet sets the value at the path, creating any missing parents.
+	OpSet Op = iota
+
+	// OpDelete removes the value at the path. Deleting a path that doesn't
+	// exist is not an error.
+	OpDelete
+
+	// OpMerge deep merges a map into the value at the path: nested maps are
+	// merged recursively and all other values are set.
+	OpMerge
+)
+
+// Change is a single modification of a config file.
+type Change struct {
+	Op Op
+
+	// Path is the sequence of keys leading to the value. For JSON and YAML,
+	// an element may also be the index of an existing array element. For INI
+	// it is either [key] for keys before the first section, [section] or
+	// [section, key].
+	Path []string
+
+	// Value is the value to set or merge. Values may be strings, booleans,
+	// numbers, []interface{} or map[string]interface{}.
+	Value interface{}
+}
+
+// Set returns a change setting the value at a dot-separated path.
+func Set(p string, value interface{}) Change {
+	return Change{Op: OpSet, Path: SplitPath(p), Value: value}
+}
+
+// Delete returns a change deleting the value at a dot-separated path.
+func Delete(p string) Change {
+	return Change{Op: OpDelete, Path: SplitPath(p)}
+}
+
+// Merge returns a change deep merging value into the map at a dot-separated
+// path.
+func Merge(p string, value map[string]interface{}) Change {
+	return Change{Op: OpMerge, Path: SplitPath(p), Value: value}
+}
+
+// SplitPath splits a dot-separated path. The empty path refers to the root.
+func SplitPath(p string) []string {
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, ".")
+}
+
+// ErrUnsupported is returned for changes that can't be expressed in a format,
+// such as nesting deeper than a section in INI files.
+var ErrUnsupported = errors.New("change not supported by this format")
+
+// Apply applies changes to the contents of a config file in the given format
+// and returns the new contents. An empty input is treated as an empty
+// document.
+func Apply(format Format, data []byte, changes []Change) ([]byte, error) {
+	var e editor
+	var err error
+	switch format {
+	case JSON:
+		e, err = parseJSON(data)
+	case YAML:
+		e, err = parseYAML(data)
+	case INI:
+		e, err = parseINI(data)
+	case TOML:
+		e, err = parseTOML(data)
+	default:
+		return nil, fmt.Errorf("unknown config format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", format, err)
+	}
+
+	for _, c := range changes {
+		if err := applyChange(e, c); err != nil {
+			return nil, fmt.Errorf("failed to apply change to %q: %w", strings.Join(c.Path, "."), err)
+		}
+	}
+	return e.bytes()
+}
+
+// editor is implemented by each format.
+type editor interface {
+	set(path []string, value interface{}) error
+	delete(path []string) error
+	// isMap reports whether there is a map at path that values can be merged
+	// into.
+	isMap(path []string) bool
+	bytes() ([]byte, error)
+}
+
+func applyChange(e editor, c Change) error {
+	switch c.Op {
+	case OpSet:
+		return e.set(c.Path, c.Value)
+	case OpDelete:
+		return e.delete(c.Path)
+	case OpMerge:
+		m, ok := c.Value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("merge value must be a map[string]interface{}, not %T", c.Value)
+		}
+		return merge(e, c.Path, m)
+	}
+	return fmt.Errorf("unknown op %d", c.Op)
+}
+
+func merge(e editor, p []string, m map[string]interface{}) error {
+	for _, k := range sortedKeys(m) {
+		child := append(append([]string(nil), p...), k)
+		if sub, ok := m[k].(map[string]interface{}); ok && e.isMap(child) {
+			if err := merge(e, child, sub); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := e.set(child, m[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// splitLines splits s into lines, keeping the line endings.
+func splitLines(s string) []string {
+	var lines []string
+	for s != "" {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			lines = append(lines, s)
+			break
+		}
+		lines = append(lines, s[:i+1])
+		s = s[i+1:]
+	}
+	return lines
+}
+
+// lineEnding returns the line ending used by the first line of lines that has
+// one.
+func lineEnding(lines []string) string {
+	for _, line := range lines {
+		if strings.HasSuffix(line, "\r\n") {
+			return "\r\n"
+		}
+		if strings.HasSuffix(line, "\n") {
+			return "\n"
+		}
+	}
+	return "\n"
+}
+
+// ensureNewline makes sure the last line ends with a newline, so that more
+// lines can be appended.
+func ensureNewline(lines []string, eol string) {
+	if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+		lines[n-1] += eol
+	}
+}
+
+// insertAt inserts new lines into lines at index i.
+func insertAt(lines []string, i int, insert ...string) []string {
+	out := make([]string, 0, len(lines)+len(insert))
+	out = append(out, lines[:i]...)
+	out = append(out, insert...)
+	return append(out, lines[i:]...)
+}
+
+// removeRange removes lines[start:end].
+func removeRange(lines []string, start, end int) []string {
+	return append(lines[:start:start], lines[end:]...)
+}
+
+func pathError(p []string, msg string) error {
+	return fmt.Errorf("%q %s", strings.Join(p, "."), msg)
+}
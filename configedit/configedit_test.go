@@ -0,0 +1,59 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package configedit
+
+import (
+	"testing"
+)
+
+func TestFormatFromPath(t *testing.T) {
+	for name, want := range map[string]Format{
+		"/etc/app/config.json": JSON,
+		"compose.YML":          YAML,
+		"/etc/app.conf":        INI,
+		"pyproject.toml":       TOML,
+	} {
+		if got, err := FormatFromPath(name); err != nil || got != want {
+			t.Errorf("FormatFromPath(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := FormatFromPath("/etc/hosts"); err == nil {
+		t.Errorf("expected a file without a known extension to fail")
+	}
+}
+
+// Applying the same changes twice must give the same result, so that config
+// edits are idempotent.
+func TestApplyIsIdempotent(t *testing.T) {
+	changes := []Change{
+		Set("server.port", 8080),
+		Merge("server", map[string]interface{}{"host": "localhost"}),
+		Delete("server.debug"),
+	}
+	for format, data := range map[Format]string{
+		JSON: "{\n  \"server\": {\n    \"debug\": true\n  }\n}\n",
+		YAML: "server:\n  debug: true\n",
+		INI:  "[server]\ndebug = true\n",
+		TOML: "[server]\ndebug = true\n",
+	} {
+		once, err := Apply(format, []byte(data), changes)
+		if err != nil {
+			t.Fatalf("%s: Apply failed: %v", format, err)
+		}
+		twice, err := Apply(format, once, changes)
+		if err != nil {
+			t.Fatalf("%s: Apply failed: %v", format, err)
+		}
+		if string(once) != string(twice) {
+			t.Errorf("%s: applying changes again modified the document:\n%s\nbecame:\n%s", format, once, twice)
+		}
+	}
+}
+
+func TestApplyUnknownFormat(t *testing.T) {
+	if _, err := Apply("xml", nil, nil); err == nil {
+		t.Errorf("expected an unknown format to fail")
+	}
+}
@@ -0,0 +1,368 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package configedit
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// INI files are edited line by line: only the lines holding changed keys are
+// rewritten, and new keys copy the separator style of existing ones.
+
+type iniLineKind int
+
+const (
+	iniBlank iniLineKind = iota
+	iniComment
+	iniSection
+	iniKey
+)
+
+// parseINILine classifies a line. For sections it returns the section name,
+// and for keys the key and the index of the separator in line, or -1 for keys
+// without a value.
+func parseINILine(line string) (kind iniLineKind, name string, sep int) {
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case trimmed == "":
+		return iniBlank, "", -1
+	case strings.HasPrefix(trimmed, ";") || strings.HasPrefix(trimmed, "#"):
+		return iniComment, "", -1
+	case strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]"):
+		return iniSection, strings.TrimSpace(trimmed[1 : len(trimmed)-1]), -1
+	}
+	sep = strings.IndexAny(line, "=:")
+	if sep < 0 {
+		return iniKey, trimmed, -1
+	}
+	return iniKey, strings.TrimSpace(line[:sep]), sep
+}
+
+// iniSectionRange is the range of lines belonging to a section. The global
+// section, holding keys before the first header, has the name "" and header
+// -1.
+type iniSectionRange struct {
+	name   string
+	header int
+	end    int
+}
+
+type iniEditor struct {
+	lines []string
+	eol   string
+}
+
+func parseINI(data []byte) (*iniEditor, error) {
+	lines := splitLines(string(data))
+	return &iniEditor{lines: lines, eol: lineEnding(lines)}, nil
+}
+
+func (e *iniEditor) sections() []iniSectionRange {
+	secs := []iniSectionRange{{header: -1}}
+	for i, line := range e.lines {
+		if kind, name, _ := parseINILine(line); kind == iniSection {
+			secs[len(secs)-1].end = i
+			secs = append(secs, iniSectionRange{name: name, header: i})
+		}
+	}
+	secs[len(secs)-1].end = len(e.lines)
+	return secs
+}
+
+// section returns the first section with the given name, or the global section
+// if name is empty.
+func (e *iniEditor) section(name string) (iniSectionRange, bool) {
+	for _, sec := range e.sections() {
+		if sec.name == name {
+			return sec, true
+		}
+	}
+	return iniSectionRange{}, false
+}
+
+// keyLines returns the indices of all lines setting key in sec.
+func (e *iniEditor) keyLines(sec iniSectionRange, key string) []int {
+	var idxs []int
+	for i := sec.header + 1; i < sec.end; i++ {
+		if kind, name, _ := parseINILine(e.lines[i]); kind == iniKey && name == key {
+			idxs = append(idxs, i)
+		}
+	}
+	return idxs
+}
+
+// separator returns the separator used by keys in sec, or in the whole file
+// if sec has none, including the whitespace around it.
+func (e *iniEditor) separator(sec iniSectionRange) string {
+	if sep, ok := e.separatorIn(sec.header+1, sec.end); ok {
+		return sep
+	}
+	if sep, ok := e.separatorIn(0, len(e.lines)); ok {
+		return sep
+	}
+	return " = "
+}
+
+// separatorIn returns the separator of the first key with a value in
+// e.lines[start:end].
+func (e *iniEditor) separatorIn(start, end int) (string, bool) {
+	for _, line := range e.lines[start:end] {
+		kind, _, sep := parseINILine(line)
+		if kind != iniKey || sep < 0 {
+			continue
+		}
+		from := len(strings.TrimRight(line[:sep], " \t"))
+		to := sep + 1
+		for to < len(line) && (line[to] == ' ' || line[to] == '\t') {
+			to++
+		}
+		return line[from:to], true
+	}
+	return "", false
+}
+
+func iniValue(value interface{}) (string, error) {
+	if value == nil {
+		return "", nil
+	}
+	switch reflect.TypeOf(value).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		return "", fmt.Errorf("%T values: %w", value, ErrUnsupported)
+	}
+	v := fmt.Sprint(value)
+	if strings.ContainsAny(v, "\r\n") {
+		return "", fmt.Errorf("multi-line values: %w", ErrUnsupported)
+	}
+	// The rest of such a value would be read back as a comment.
+	if inlineINIComment(v) != "" {
+		return "", fmt.Errorf("values with inline comments, like %q: %w", v, ErrUnsupported)
+	}
+	return v, nil
+}
+
+func (e *iniEditor) set(p []string, value interface{}) error {
+	switch len(p) {
+	case 0:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("the root of an INI file must be a map, not %T", value)
+		}
+		e.lines = nil
+		// Keys before the first section have to be written first.
+		for _, k := range sortedKeys(m) {
+			if _, ok := m[k].(map[string]interface{}); !ok {
+				if err := e.setKey("", k, m[k]); err != nil {
+					return err
+				}
+			}
+		}
+		for _, k := range sortedKeys(m) {
+			if sub, ok := m[k].(map[string]interface{}); ok {
+				if err := e.setSection(k, sub); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case 1:
+		if m, ok := value.(map[string]interface{}); ok {
+			return e.setSection(p[0], m)
+		}
+		return e.setKey("", p[0], value)
+	case 2:
+		return e.setKey(p[0], p[1], value)
+	}
+	return fmt.Errorf("%w: INI files only have sections and keys", ErrUnsupported)
+}
+
+func (e *iniEditor) setKey(section, key string, value interface{}) error {
+	v, err := iniValue(value)
+	if err != nil {
+		return err
+	}
+	sec, ok := e.section(section)
+	if !ok {
+		e.appendSection(section, map[string]string{key: v})
+		return nil
+	}
+	if idxs := e.keyLines(sec, key); len(idxs) > 0 {
+		for _, i := range idxs {
+			e.lines[i] = replaceINIValue(e.lines[i], e.separator(sec), v)
+		}
+		return nil
+	}
+
+	line := key + e.separator(sec) + v + e.eol
+	pos := e.insertPos(sec)
+	if pos > 0 && !strings.HasSuffix(e.lines[pos-1], "\n") {
+		e.lines[pos-1] += e.eol
+	}
+	e.lines = insertAt(e.lines, pos, line)
+	return nil
+}
+
+// insertPos returns where a new key is inserted in sec: after its last key,
+// or after its header.
+func (e *iniEditor) insertPos(sec iniSectionRange) int {
+	for i := sec.end - 1; i > sec.header; i-- {
+		if kind, _, _ := parseINILine(e.lines[i]); kind == iniKey {
+			return i + 1
+		}
+	}
+	if sec.header >= 0 {
+		return sec.header + 1
+	}
+	// Keep new global keys away from the blank lines separating them from the
+	// first section.
+	pos := sec.end
+	for pos > 0 && strings.TrimSpace(e.lines[pos-1]) == "" {
+		pos--
+	}
+	return pos
+}
+
+// replaceINIValue replaces the value of a key line, keeping everything up to
+// the value, and any comment after it, as it was.
+func replaceINIValue(line, defaultSep, v string) string {
+	content := strings.TrimRight(line, "\r\n")
+	eol := line[len(content):]
+	_, key, sep := parseINILine(line)
+	if sep < 0 {
+		indent := content[:len(content)-len(strings.TrimLeft(content, " \t"))]
+		return indent + key + defaultSep + v + eol
+	}
+	end := sep + 1
+	for end < len(content) && (content[end] == ' ' || content[end] == '\t') {
+		end++
+	}
+	return content[:end] + v + inlineINIComment(content[end:]) + eol
+}
+
+// inlineINIComment returns the comment after a value, with the space before
+// it, or "" if there is none. A comment starts with ';' or '#' after
+// whitespace, so that values like "a#b" are kept whole.
+func inlineINIComment(value string) string {
+	if strings.HasPrefix(value, ";") || strings.HasPrefix(value, "#") {
+		return " " + value
+	}
+	for i := 1; i < len(value); i++ {
+		if (value[i] == ';' || value[i] == '#') && (value[i-1] == ' ' || value[i-1] == '\t') {
+			start := i - 1
+			for start > 0 && (value[start-1] == ' ' || value[start-1] == '\t') {
+				start--
+			}
+			return value[start:]
+		}
+	}
+	return ""
+}
+
+// setSection sets the keys of a section, removing any other keys it had.
+func (e *iniEditor) setSection(name string, m map[string]interface{}) error {
+	values := make(map[string]string, len(m))
+	for k, value := range m {
+		v, err := iniValue(value)
+		if err != nil {
+			return err
+		}
+		values[k] = v
+	}
+
+	sec, ok := e.section(name)
+	if !ok {
+		e.appendSection(name, values)
+		return nil
+	}
+	for i := sec.end - 1; i > sec.header; i-- {
+		if kind, key, _ := parseINILine(e.lines[i]); kind == iniKey {
+			if _, keep := values[key]; !keep {
+				e.lines = removeRange(e.lines, i, i+1)
+			}
+		}
+	}
+	for _, k := range sortedKeys(m) {
+		if err := e.setKey(name, k, values[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *iniEditor) appendSection(name string, values map[string]string) {
+	ensureNewline(e.lines, e.eol)
+	if n := len(e.lines); n > 0 && strings.TrimSpace(e.lines[n-1]) != "" {
+		e.lines = append(e.lines, e.eol)
+	}
+	sep := e.separator(iniSectionRange{header: -1})
+	e.lines = append(e.lines, "["+name+"]"+e.eol)
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		e.lines = append(e.lines, k+sep+values[k]+e.eol)
+	}
+}
+
+func (e *iniEditor) delete(p []string) error {
+	switch len(p) {
+	case 0:
+		e.lines = nil
+	case 1:
+		if sec, ok := e.section(p[0]); ok && p[0] != "" {
+			e.deleteSection(sec)
+			return nil
+		}
+		return e.deleteKey("", p[0])
+	case 2:
+		return e.deleteKey(p[0], p[1])
+	}
+	return nil
+}
+
+func (e *iniEditor) deleteKey(section, key string) error {
+	sec, ok := e.section(section)
+	if !ok {
+		return nil
+	}
+	idxs := e.keyLines(sec, key)
+	for i := len(idxs) - 1; i >= 0; i-- {
+		e.lines = removeRange(e.lines, idxs[i], idxs[i]+1)
+	}
+	return nil
+}
+
+func (e *iniEditor) deleteSection(sec iniSectionRange) {
+	end := sec.end
+	// Comments directly above the next header belong to that section.
+	if end < len(e.lines) {
+		for end > sec.header+1 {
+			if kind, _, _ := parseINILine(e.lines[end-1]); kind != iniComment {
+				break
+			}
+			end--
+		}
+	}
+	e.lines = removeRange(e.lines, sec.header, end)
+}
+
+func (e *iniEditor) isMap(p []string) bool {
+	switch len(p) {
+	case 0:
+		return true
+	case 1:
+		_, ok := e.section(p[0])
+		return ok
+	}
+	return false
+}
+
+func (e *iniEditor) bytes() ([]byte, error) {
+	return []byte(strings.Join(e.lines, "")), nil
+}
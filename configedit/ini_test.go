@@ -0,0 +1,174 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package configedit
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApplyINI(t *testing.T) {
+	const doc = `; global settings
+debug=false
+
+[server]
+# address to listen on
+host = 0.0.0.0
+port = 8080
+
+; database settings
+[db]
+name = app
+`
+
+	for _, tc := range []struct {
+		name    string
+		data    string
+		changes []Change
+		want    string
+	}{
+		{
+			name:    "replace values",
+			data:    doc,
+			changes: []Change{Set("server.port", 9090), Set("debug", true)},
+			want: `; global settings
+debug=true
+
+[server]
+# address to listen on
+host = 0.0.0.0
+port = 9090
+
+; database settings
+[db]
+name = app
+`,
+		},
+		{
+			name:    "add keys after the last key of a section",
+			data:    doc,
+			changes: []Change{Set("server.timeout", "30s"), Set("verbose", 1)},
+			want: `; global settings
+debug=false
+verbose=1
+
+[server]
+# address to listen on
+host = 0.0.0.0
+port = 8080
+timeout = 30s
+
+; database settings
+[db]
+name = app
+`,
+		},
+		{
+			name:    "replace values keeps inline comments",
+			data:    "[a]\nx = 1   ; seconds\ny = a#b # not a comment start\nz =\t# unset\n",
+			changes: []Change{Set("a.x", 2), Set("a.y", "c"), Set("a.z", 3)},
+			want:    "[a]\nx = 2   ; seconds\ny = c # not a comment start\nz =\t3 # unset\n",
+		},
+		{
+			name:    "add section",
+			data:    "[a]\nx = 1",
+			changes: []Change{Set("b.y", 2)},
+			want:    "[a]\nx = 1\n\n[b]\ny = 2\n",
+		},
+		{
+			name:    "delete section keeps comments of the next one",
+			data:    doc,
+			changes: []Change{Delete("server"), Delete("db.missing")},
+			want: `; global settings
+debug=false
+
+; database settings
+[db]
+name = app
+`,
+		},
+		{
+			name:    "set section",
+			data:    doc,
+			changes: []Change{Set("server", map[string]interface{}{"port": 1, "tls": true})},
+			want: `; global settings
+debug=false
+
+[server]
+# address to listen on
+port = 1
+tls = true
+
+; database settings
+[db]
+name = app
+`,
+		},
+		{
+			name: "merge",
+			data: doc,
+			changes: []Change{Merge("", map[string]interface{}{
+				"db":   map[string]interface{}{"user": "root"},
+				"mail": map[string]interface{}{"host": "mx"},
+			})},
+			want: `; global settings
+debug=false
+
+[server]
+# address to listen on
+host = 0.0.0.0
+port = 8080
+
+; database settings
+[db]
+name = app
+user = root
+
+[mail]
+host=mx
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Apply(INI, []byte(tc.data), tc.changes)
+			if err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyINIUnsupported(t *testing.T) {
+	for _, c := range []Change{
+		Set("a.b.c", 1),
+		Set("a.b", []string{"x"}),
+		Set("a.b", "multi\nline"),
+		Set("a.b", "has ; semicolon"),
+		Set("a.b", "#hash"),
+	} {
+		if _, err := Apply(INI, nil, []Change{c}); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("expected %v to be unsupported, got %v", c, err)
+		}
+	}
+}
+
+func TestApplyINIIdempotent(t *testing.T) {
+	data := []byte("[s]\nx = old ; note\n")
+	changes := []Change{Set("s.x", "a#b;c"), Set("s.y", "v")}
+	once, err := Apply(INI, data, changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	twice, err := Apply(INI, once, changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[s]\nx = a#b;c ; note\ny = v\n"; string(once) != want || string(twice) != want {
+		t.Errorf("got %q, then %q, want %q both times", once, twice, want)
+	}
+}
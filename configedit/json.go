@@ -0,0 +1,599 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package configedit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// JSON has no comments, but we keep the original text of every value that
+// isn't edited, and of the keys and whitespace around the members of the
+// objects and arrays that are, so that a change only rewrites the values it
+// replaces. New members follow the indentation of the original document.
+
+// jsonObject is a JSON object that remembers the order of its keys.
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+
+	// raw is the original text of the values that haven't been replaced.
+	raw map[string][]byte
+
+	// prefix is the original text before each parsed value, after the '{'
+	// or ',': its key and the whitespace around them.
+	prefix map[string][]byte
+
+	// lead is the whitespace before the first member, and tail the text
+	// between the last value and the '}', if parsed is set.
+	lead   []byte
+	tail   []byte
+	parsed bool
+
+	// clean is whether the object was parsed and nothing in it has changed
+	// since.
+	clean bool
+
+	// inline is whether the object was on a single line.
+	inline bool
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{values: make(map[string]interface{}), raw: make(map[string][]byte), prefix: make(map[string][]byte)}
+}
+
+func (o *jsonObject) set(k string, v interface{}) {
+	if _, ok := o.values[k]; !ok {
+		o.keys = append(o.keys, k)
+	}
+	o.values[k] = v
+	delete(o.raw, k)
+}
+
+func (o *jsonObject) remove(k string) bool {
+	if _, ok := o.values[k]; !ok {
+		return false
+	}
+	delete(o.values, k)
+	delete(o.raw, k)
+	delete(o.prefix, k)
+	for i, key := range o.keys {
+		if key == k {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// jsonArray is a JSON array. It is a pointer type so arrays can be modified in
+// place.
+type jsonArray struct {
+	items []interface{}
+
+	// raw is the original text of each item, or nil if it was replaced.
+	raw [][]byte
+
+	// prefix, lead and tail are like those of jsonObject, without the keys.
+	prefix [][]byte
+	lead   []byte
+	tail   []byte
+	parsed bool
+
+	clean  bool
+	inline bool
+}
+
+type jsonEditor struct {
+	root interface{}
+
+	// original is the parsed document, or nil once the root is replaced.
+	original []byte
+
+	// Indentation of one level, or "" if the document is compact.
+	indent          string
+	trailingNewline bool
+}
+
+func parseJSON(data []byte) (*jsonEditor, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return &jsonEditor{root: newJSONObject(), indent: "  ", trailingNewline: true}, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	root, err := decodeJSON(dec, data)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the top-level value")
+	}
+	setJSONInline(root, bytes.TrimSpace(data))
+	return &jsonEditor{
+		root:            root,
+		original:        data,
+		indent:          detectJSONIndent(data),
+		trailingNewline: bytes.HasSuffix(data, []byte("\n")),
+	}, nil
+}
+
+// decodeValue decodes the next value, and returns it with its original text
+// and the offset just after it.
+func decodeValue(dec *json.Decoder, data []byte) (interface{}, []byte, int64, error) {
+	start := dec.InputOffset()
+	v, err := decodeJSON(dec, data)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	// The offset is after the previous token, before any separator.
+	end := dec.InputOffset()
+	raw := bytes.TrimLeft(data[start:end], " \t\r\n:,")
+	setJSONInline(v, raw)
+	return v, raw, end, nil
+}
+
+// memberPrefix returns the text from prev, the end of the previous member or
+// the opening delimiter, to the value at start, less the ',' after a previous
+// member.
+func memberPrefix(data []byte, prev, start int64, first bool) []byte {
+	gap := data[prev:start]
+	if !first {
+		gap = gap[bytes.IndexByte(gap, ',')+1:]
+	}
+	return gap
+}
+
+func leadingSpace(b []byte) []byte {
+	return b[:len(b)-len(bytes.TrimLeft(b, " \t\r\n"))]
+}
+
+// writePrefix writes the original text before a member, with the whitespace
+// of the original first member if it is now first.
+func writePrefix(buf *bytes.Buffer, prefix, lead []byte, first bool) {
+	if first {
+		buf.Write(lead)
+		prefix = prefix[len(leadingSpace(prefix)):]
+	}
+	buf.Write(prefix)
+}
+
+func setJSONInline(v interface{}, raw []byte) {
+	inline := !bytes.ContainsAny(raw, "\r\n")
+	switch c := v.(type) {
+	case *jsonObject:
+		c.inline = inline
+	case *jsonArray:
+		c.inline = inline
+	}
+}
+
+func decodeJSON(dec *json.Decoder, data []byte) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := newJSONObject()
+		prev := dec.InputOffset()
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := keyTok.(string)
+			if !ok {
+				return nil, fmt.Errorf("unexpected object key %v", keyTok)
+			}
+			v, raw, end, err := decodeValue(dec, data)
+			if err != nil {
+				return nil, err
+			}
+			obj.set(key, v)
+			obj.raw[key] = raw
+			obj.prefix[key] = memberPrefix(data, prev, end-int64(len(raw)), len(obj.keys) == 1)
+			prev = end
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		obj.tail = data[prev : dec.InputOffset()-1]
+		if len(obj.keys) > 0 {
+			obj.lead = leadingSpace(obj.prefix[obj.keys[0]])
+		}
+		obj.parsed = true
+		obj.clean = true
+		return obj, nil
+	case json.Delim('['):
+		arr := &jsonArray{}
+		prev := dec.InputOffset()
+		for dec.More() {
+			v, raw, end, err := decodeValue(dec, data)
+			if err != nil {
+				return nil, err
+			}
+			arr.items = append(arr.items, v)
+			arr.raw = append(arr.raw, raw)
+			arr.prefix = append(arr.prefix, memberPrefix(data, prev, end-int64(len(raw)), len(arr.items) == 1))
+			prev = end
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		arr.tail = data[prev : dec.InputOffset()-1]
+		if len(arr.prefix) > 0 {
+			arr.lead = leadingSpace(arr.prefix[0])
+		}
+		arr.parsed = true
+		arr.clean = true
+		return arr, nil
+	}
+	return tok, nil
+}
+
+// jsonEdited reports whether v is an object or array that was created or
+// changed since it was parsed, so that its original text is stale.
+func jsonEdited(v interface{}) bool {
+	switch c := v.(type) {
+	case *jsonObject:
+		return !c.clean
+	case *jsonArray:
+		return !c.clean
+	}
+	return false
+}
+
+// detectJSONIndent returns the indentation of the first indented line, which
+// is one level deep in a conventionally formatted document.
+func detectJSONIndent(data []byte) string {
+	i := bytes.IndexByte(data, '\n')
+	if i < 0 {
+		return ""
+	}
+	rest := data[i+1:]
+	n := 0
+	for n < len(rest) && (rest[n] == ' ' || rest[n] == '\t') {
+		n++
+	}
+	if n == 0 {
+		return "  "
+	}
+	return string(rest[:n])
+}
+
+// toJSONValue converts a Go value into the representation used by the editor.
+func toJSONValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		obj := newJSONObject()
+		for _, k := range sortedKeys(v) {
+			obj.set(k, toJSONValue(v[k]))
+		}
+		return obj
+	case []interface{}:
+		arr := &jsonArray{}
+		for _, item := range v {
+			arr.items = append(arr.items, toJSONValue(item))
+		}
+		return arr
+	}
+	return v
+}
+
+// lookup returns the value at p, or false if it doesn't exist.
+func (e *jsonEditor) lookup(p []string) (interface{}, bool, error) {
+	cur := e.root
+	for i, k := range p {
+		switch c := cur.(type) {
+		case *jsonObject:
+			v, ok := c.values[k]
+			if !ok {
+				return nil, false, nil
+			}
+			cur = v
+		case *jsonArray:
+			idx, err := strconv.Atoi(k)
+			if err != nil || idx < 0 || idx >= len(c.items) {
+				return nil, false, nil
+			}
+			cur = c.items[idx]
+		default:
+			return nil, false, pathError(p[:i], "is not an object or array")
+		}
+	}
+	return cur, true, nil
+}
+
+func (e *jsonEditor) set(p []string, value interface{}) error {
+	// Setting a value it already has changes nothing, not even the layout.
+	if cur, ok, err := e.lookup(p); err == nil && ok && sameJSON(cur, value) {
+		return nil
+	}
+	if len(p) == 0 {
+		e.root = toJSONValue(value)
+		e.original = nil
+		return nil
+	}
+
+	// Create any missing parent objects.
+	cur := e.root
+	for i, k := range p[:len(p)-1] {
+		switch c := cur.(type) {
+		case *jsonObject:
+			c.clean = false
+			next, ok := c.values[k]
+			if !ok {
+				next = newJSONObject()
+				c.set(k, next)
+			}
+			cur = next
+		case *jsonArray:
+			c.clean = false
+			idx, err := strconv.Atoi(k)
+			if err != nil || idx < 0 || idx >= len(c.items) {
+				return pathError(p[:i+1], "is not an element of the array")
+			}
+			cur = c.items[idx]
+		default:
+			return pathError(p[:i], "is not an object or array")
+		}
+	}
+
+	last := p[len(p)-1]
+	switch c := cur.(type) {
+	case *jsonObject:
+		c.clean = false
+		c.set(last, toJSONValue(value))
+	case *jsonArray:
+		idx, err := strconv.Atoi(last)
+		if err != nil || idx < 0 || idx >= len(c.items) {
+			return pathError(p, "is not an element of the array")
+		}
+		c.clean = false
+		c.items[idx] = toJSONValue(value)
+		c.raw[idx] = nil
+	default:
+		return pathError(p[:len(p)-1], "is not an object or array")
+	}
+	return nil
+}
+
+func (e *jsonEditor) delete(p []string) error {
+	if len(p) == 0 {
+		e.root = newJSONObject()
+		e.original = nil
+		return nil
+	}
+	parent, ok, err := e.lookup(p[:len(p)-1])
+	if err != nil || !ok {
+		return err
+	}
+	last := p[len(p)-1]
+	removed := false
+	switch c := parent.(type) {
+	case *jsonObject:
+		removed = c.remove(last)
+	case *jsonArray:
+		if idx, err := strconv.Atoi(last); err == nil && idx >= 0 && idx < len(c.items) {
+			c.items = append(c.items[:idx], c.items[idx+1:]...)
+			c.raw = append(c.raw[:idx], c.raw[idx+1:]...)
+			c.prefix = append(c.prefix[:idx], c.prefix[idx+1:]...)
+			removed = true
+		}
+	}
+	if removed {
+		e.markEdited(p[:len(p)-1])
+	}
+	return nil
+}
+
+// markEdited marks the objects and arrays from the root to the existing
+// value at p as changed.
+func (e *jsonEditor) markEdited(p []string) {
+	cur := e.root
+	for i := 0; ; i++ {
+		switch c := cur.(type) {
+		case *jsonObject:
+			c.clean = false
+			if i < len(p) {
+				cur = c.values[p[i]]
+				continue
+			}
+		case *jsonArray:
+			c.clean = false
+			if i < len(p) {
+				if idx, err := strconv.Atoi(p[i]); err == nil && idx >= 0 && idx < len(c.items) {
+					cur = c.items[idx]
+					continue
+				}
+			}
+		}
+		return
+	}
+}
+
+// sameJSON reports whether the editor's value a and the Go value b encode to
+// the same JSON.
+func sameJSON(a, b interface{}) bool {
+	compact := &jsonEditor{}
+	var x, y bytes.Buffer
+	if compact.encode(&x, a, 0) != nil || compact.encode(&y, toJSONValue(b), 0) != nil {
+		return false
+	}
+	var cx, cy bytes.Buffer
+	if json.Compact(&cx, x.Bytes()) != nil || json.Compact(&cy, y.Bytes()) != nil {
+		return false
+	}
+	return bytes.Equal(cx.Bytes(), cy.Bytes())
+}
+
+func (e *jsonEditor) isMap(p []string) bool {
+	v, ok, _ := e.lookup(p)
+	_, isObj := v.(*jsonObject)
+	return ok && isObj
+}
+
+func (e *jsonEditor) bytes() ([]byte, error) {
+	if e.original != nil && !jsonEdited(e.root) {
+		return e.original, nil
+	}
+	var buf bytes.Buffer
+	if err := e.encode(&buf, e.root, 0); err != nil {
+		return nil, err
+	}
+	if e.trailingNewline {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// separate starts a member of an object or array at depth: on its own line,
+// or after a space if the object or array is on a single line.
+func (e *jsonEditor) separate(buf *bytes.Buffer, depth int, inline, first bool) {
+	if e.indent == "" {
+		return
+	}
+	if inline {
+		if !first {
+			buf.WriteByte(' ')
+		}
+		return
+	}
+	e.newline(buf, depth)
+}
+
+// close writes what comes before the closing delimiter of an object or array
+// at depth: its original text if it was parsed, or else a newline if it spans
+// lines.
+func (e *jsonEditor) close(buf *bytes.Buffer, depth int, parsed bool, tail []byte, inline bool) {
+	if parsed {
+		buf.Write(tail)
+	} else if !inline && e.indent != "" {
+		e.newline(buf, depth)
+	}
+}
+
+func (e *jsonEditor) newline(buf *bytes.Buffer, depth int) {
+	buf.WriteByte('\n')
+	for i := 0; i < depth; i++ {
+		buf.WriteString(e.indent)
+	}
+}
+
+// encodeMember encodes a member of an object or array, as its original text
+// if it has one that is still current.
+func (e *jsonEditor) encodeMember(buf *bytes.Buffer, v interface{}, raw []byte, depth int) error {
+	if raw != nil && !jsonEdited(v) {
+		buf.Write(raw)
+		return nil
+	}
+	return e.encode(buf, v, depth)
+}
+
+func (e *jsonEditor) encode(buf *bytes.Buffer, v interface{}, depth int) error {
+	switch v := v.(type) {
+	case *jsonObject:
+		if len(v.keys) == 0 && !v.parsed {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteByte('{')
+		for i, k := range v.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if prefix, ok := v.prefix[k]; ok {
+				writePrefix(buf, prefix, v.lead, i == 0)
+			} else {
+				e.separate(buf, depth+1, v.inline, i == 0)
+				if err := encodeJSONScalar(buf, k); err != nil {
+					return err
+				}
+				buf.WriteByte(':')
+				if e.indent != "" {
+					buf.WriteByte(' ')
+				}
+			}
+			if err := e.encodeMember(buf, v.values[k], v.raw[k], depth+1); err != nil {
+				return err
+			}
+		}
+		e.close(buf, depth, v.parsed, v.tail, v.inline)
+		buf.WriteByte('}')
+	case *jsonArray:
+		if len(v.items) == 0 && !v.parsed {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteByte('[')
+		for i, item := range v.items {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			var raw []byte
+			if i < len(v.raw) {
+				raw = v.raw[i]
+			}
+			if i < len(v.prefix) {
+				writePrefix(buf, v.prefix[i], v.lead, i == 0)
+			} else {
+				e.separate(buf, depth+1, v.inline, i == 0)
+			}
+			if err := e.encodeMember(buf, item, raw, depth+1); err != nil {
+				return err
+			}
+		}
+		e.close(buf, depth, v.parsed, v.tail, v.inline)
+		buf.WriteByte(']')
+	default:
+		// Values set by callers may be arbitrary Go types, such as structs
+		// or typed slices, so normalize them through encoding/json.
+		if v != nil {
+			switch reflect.TypeOf(v).Kind() {
+			case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct, reflect.Ptr:
+				normalized, err := normalizeJSON(v)
+				if err != nil {
+					return err
+				}
+				return e.encode(buf, normalized, depth)
+			}
+		}
+		return encodeJSONScalar(buf, v)
+	}
+	return nil
+}
+
+func normalizeJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return toJSONValue(generic), nil
+}
+
+func encodeJSONScalar(buf *bytes.Buffer, v interface{}) error {
+	var tmp bytes.Buffer
+	enc := json.NewEncoder(&tmp)
+	// Don't rewrite characters like '<' that the original document may have
+	// contained literally.
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	buf.Write(bytes.TrimSuffix(tmp.Bytes(), []byte("\n")))
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package configedit
+
+import (
+	"testing"
+)
+
+func TestApplyJSON(t *testing.T) {
+	const doc = `{
+    "name": "device <1>",
+    "port": 8080,
+    "ratio": 1.50,
+    "tags": ["a", "b"],
+    "log": {
+        "level": "info"
+    }
+}
+`
+
+	for _, tc := range []struct {
+		name    string
+		data    string
+		changes []Change
+		want    string
+	}{
+		{
+			name:    "set keeps key order, indentation, numbers and untouched values",
+			data:    doc,
+			changes: []Change{Set("log.level", "debug")},
+			want: `{
+    "name": "device <1>",
+    "port": 8080,
+    "ratio": 1.50,
+    "tags": ["a", "b"],
+    "log": {
+        "level": "debug"
+    }
+}
+`,
+		},
+		{
+			name:    "no-op edits keep the document",
+			data:    "{ \"a\" : [1,  2, 3],\n\"b\": \"\\u00e9\", \"c\": {\"d\": 1} }",
+			changes: []Change{Delete("missing"), Delete("c.missing"), Set("b", "é"), Set("c", map[string]interface{}{"d": 1})},
+			want:    "{ \"a\" : [1,  2, 3],\n\"b\": \"\\u00e9\", \"c\": {\"d\": 1} }",
+		},
+		{
+			name:    "single-line arrays stay on one line",
+			data:    "{\n  \"a\": [1, 2, 3],\n  \"b\": [\"\\u00e9\"]\n}\n",
+			changes: []Change{Set("a.1", 5)},
+			want:    "{\n  \"a\": [1, 5, 3],\n  \"b\": [\"\\u00e9\"]\n}\n",
+		},
+		{
+			name:    "single-line objects keep their spacing and escapes",
+			data:    `{"a": "\u00e9", "b": 1, "c" :[ 1 ]}`,
+			changes: []Change{Set("b", 2), Set("c.0", 3)},
+			want:    `{"a": "\u00e9", "b": 2, "c" :[ 3 ]}`,
+		},
+		{
+			name:    "deleting the first member keeps the layout of the rest",
+			data:    "{\n  \"a\": 1,\n  \"b\": \"\\u00e9\"\n}\n",
+			changes: []Change{Delete("a"), Set("c", []interface{}{})},
+			want:    "{\n  \"b\": \"\\u00e9\",\n  \"c\": []\n}\n",
+		},
+		{
+			name:    "set creates parents and appends keys",
+			data:    `{"a":1}`,
+			changes: []Change{Set("b.c", true)},
+			want:    `{"a":1,"b":{"c":true}}`,
+		},
+		{
+			name:    "set array element",
+			data:    `{"tags":["a","b"]}`,
+			changes: []Change{Set("tags.1", "c")},
+			want:    `{"tags":["a","c"]}`,
+		},
+		{
+			name:    "delete",
+			data:    `{"a":1,"b":2}`,
+			changes: []Change{Delete("a"), Delete("missing.key")},
+			want:    `{"b":2}`,
+		},
+		{
+			name: "merge",
+			data: `{"log":{"level":"info","file":"/var/log/x"}}`,
+			changes: []Change{Merge("", map[string]interface{}{
+				"log":  map[string]interface{}{"level": "debug"},
+				"port": 22,
+			})},
+			want: `{"log":{"level":"debug","file":"/var/log/x"},"port":22}`,
+		},
+		{
+			name:    "empty document",
+			data:    "",
+			changes: []Change{Set("a", []string{"x"})},
+			want:    "{\n  \"a\": [\n    \"x\"\n  ]\n}\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Apply(JSON, []byte(tc.data), tc.changes)
+			if err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyJSONErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		data    string
+		changes []Change
+	}{
+		{"invalid document", `{"a":`, nil},
+		{"trailing data", `{} {}`, nil},
+		{"set inside scalar", `{"a":1}`, []Change{Set("a.b", 2)}},
+		{"array index out of range", `{"a":[1]}`, []Change{Set("a.3", 2)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Apply(JSON, []byte(tc.data), tc.changes); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
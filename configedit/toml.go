@@ -0,0 +1,757 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package configedit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TOML files are edited line by line, like INI files. The editor understands
+// enough of the syntax to find where each table and value starts and ends,
+// including values spanning several lines, so that replacing a value keeps its
+// key and any trailing comment. Arrays of tables can be deleted as a whole but
+// not edited.
+
+var tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+type tomlHeader struct {
+	path  []string
+	line  int
+	array bool
+}
+
+type tomlEntry struct {
+	table []string
+	key   []string
+	// Whether the entry belongs to an array of tables.
+	array bool
+
+	// The value spans lines[first:last+1], starting at valueStart in the
+	// first line and ending at valueEnd in the last.
+	first, last          int
+	valueStart, valueEnd int
+}
+
+func (en tomlEntry) path() []string {
+	return append(append([]string(nil), en.table...), en.key...)
+}
+
+type tomlEditor struct {
+	lines   []string
+	eol     string
+	headers []tomlHeader
+	entries []tomlEntry
+}
+
+func parseTOML(data []byte) (*tomlEditor, error) {
+	lines := splitLines(string(data))
+	e := &tomlEditor{lines: lines, eol: lineEnding(lines)}
+	if err := e.parse(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// parse indexes the tables and values in e.lines. It is called again after
+// each modification.
+func (e *tomlEditor) parse() error {
+	e.headers, e.entries = nil, nil
+	var table []string
+	array := false
+	for i := 0; i < len(e.lines); i++ {
+		line := strings.TrimRight(e.lines[i], "\r\n")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "[") {
+			h, err := parseTOMLHeader(trimmed)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", i+1, err)
+			}
+			h.line = i
+			e.headers = append(e.headers, h)
+			table, array = h.path, h.array
+			continue
+		}
+
+		eq := findOutsideQuotes(line, 0, '=')
+		if eq < 0 {
+			return fmt.Errorf("line %d: expected key = value", i+1)
+		}
+		key, err := parseTOMLKey(line[:eq])
+		if err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+		start := eq + 1
+		for start < len(line) && (line[start] == ' ' || line[start] == '\t') {
+			start++
+		}
+		last, end, err := scanTOMLValue(e.lines, i, start)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+		e.entries = append(e.entries, tomlEntry{
+			table:      table,
+			key:        key,
+			array:      array,
+			first:      i,
+			last:       last,
+			valueStart: start,
+			valueEnd:   end,
+		})
+		i = last
+	}
+	return nil
+}
+
+func parseTOMLHeader(s string) (tomlHeader, error) {
+	h := tomlHeader{array: strings.HasPrefix(s, "[[")}
+	open, close := "[", "]"
+	if h.array {
+		open, close = "[[", "]]"
+	}
+	rest := s[len(open):]
+	end := findOutsideQuotes(rest, 0, ']')
+	if end < 0 || !strings.HasPrefix(rest[end:], close) {
+		return h, errors.New("unterminated table header")
+	}
+	if after := strings.TrimSpace(rest[end+len(close):]); after != "" && !strings.HasPrefix(after, "#") {
+		return h, errors.New("unexpected data after table header")
+	}
+	var err error
+	h.path, err = parseTOMLKey(rest[:end])
+	return h, err
+}
+
+// findOutsideQuotes returns the index of the first c in s at or after i that
+// is not inside a quoted string, or -1.
+func findOutsideQuotes(s string, i int, c byte) int {
+	for i < len(s) {
+		switch s[i] {
+		case c:
+			return i
+		case '"', '\'':
+			if i = skipTOMLString(s, i); i < 0 {
+				return -1
+			}
+			continue
+		}
+		i++
+	}
+	return -1
+}
+
+// skipTOMLString returns the index after the single-line string starting at
+// s[i], or -1 if it isn't terminated.
+func skipTOMLString(s string, i int) int {
+	quote := s[i]
+	for j := i + 1; j < len(s); j++ {
+		switch {
+		case s[j] == '\\' && quote == '"':
+			j++
+		case s[j] == quote:
+			return j + 1
+		}
+	}
+	return -1
+}
+
+// parseTOMLKey splits a possibly dotted and quoted key into its parts.
+func parseTOMLKey(s string) ([]string, error) {
+	var parts []string
+	s = strings.TrimSpace(s)
+	for {
+		if s == "" {
+			return nil, errors.New("empty key")
+		}
+		var part string
+		switch s[0] {
+		case '"':
+			end := skipTOMLString(s, 0)
+			if end < 0 {
+				return nil, errors.New("unterminated quoted key")
+			}
+			var err error
+			if part, err = strconv.Unquote(s[:end]); err != nil {
+				return nil, fmt.Errorf("invalid quoted key %s", s[:end])
+			}
+			s = s[end:]
+		case '\'':
+			end := skipTOMLString(s, 0)
+			if end < 0 {
+				return nil, errors.New("unterminated quoted key")
+			}
+			part, s = s[1:end-1], s[end:]
+		default:
+			n := 0
+			for n < len(s) && (s[n] == '_' || s[n] == '-' || s[n] >= 'a' && s[n] <= 'z' || s[n] >= 'A' && s[n] <= 'Z' || s[n] >= '0' && s[n] <= '9') {
+				n++
+			}
+			if n == 0 {
+				return nil, fmt.Errorf("invalid key %q", s)
+			}
+			part, s = s[:n], s[n:]
+		}
+		parts = append(parts, part)
+
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return parts, nil
+		}
+		if s[0] != '.' {
+			return nil, fmt.Errorf("invalid key %q", s)
+		}
+		s = strings.TrimLeft(s[1:], " \t")
+	}
+}
+
+// scanTOMLValue finds the end of the value starting at lines[first][start]. It
+// returns the index of the value's last line and the offset just after the
+// value in that line.
+func scanTOMLValue(lines []string, first, start int) (last, end int, err error) {
+	depth := 0
+	i, pos := first, start
+	end = start
+	for i < len(lines) {
+		line := strings.TrimRight(lines[i], "\r\n")
+		for pos < len(line) {
+			c := line[pos]
+			switch {
+			case strings.HasPrefix(line[pos:], `"""`) || strings.HasPrefix(line[pos:], `'''`):
+				if i, pos, err = skipTOMLMultiline(lines, i, pos); err != nil {
+					return 0, 0, err
+				}
+				line = strings.TrimRight(lines[i], "\r\n")
+				end = pos
+				continue
+			case c == '"' || c == '\'':
+				if pos = skipTOMLString(line, pos); pos < 0 {
+					return 0, 0, errors.New("unterminated string")
+				}
+				end = pos
+				continue
+			case c == '#':
+				pos = len(line)
+				continue
+			case c == ' ' || c == '\t':
+				pos++
+				continue
+			case c == '[' || c == '{':
+				depth++
+			case c == ']' || c == '}':
+				depth--
+			}
+			pos++
+			end = pos
+		}
+		if depth <= 0 {
+			if end == start && i == first {
+				return 0, 0, errors.New("missing value")
+			}
+			return i, end, nil
+		}
+		i, pos = i+1, 0
+	}
+	return 0, 0, errors.New("unterminated value")
+}
+
+// skipTOMLMultiline returns the position after the multi-line string starting
+// at lines[i][pos].
+func skipTOMLMultiline(lines []string, i, pos int) (int, int, error) {
+	delim := lines[i][pos : pos+3]
+	pos += 3
+	for ; i < len(lines); i, pos = i+1, 0 {
+		line := strings.TrimRight(lines[i], "\r\n")
+		for pos < len(line) {
+			if line[pos] == '\\' && delim[0] == '"' {
+				pos += 2
+				continue
+			}
+			if strings.HasPrefix(line[pos:], delim) {
+				pos += 3
+				// Up to two quotes directly before the closing delimiter are
+				// part of the string.
+				for n := 0; n < 2 && pos < len(line) && line[pos] == delim[0]; n++ {
+					pos++
+				}
+				return i, pos, nil
+			}
+			pos++
+		}
+	}
+	return 0, 0, errors.New("unterminated multi-line string")
+}
+
+func hasPathPrefix(p, prefix []string) bool {
+	if len(prefix) > len(p) {
+		return false
+	}
+	for i := range prefix {
+		if p[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalPath(a, b []string) bool {
+	return len(a) == len(b) && hasPathPrefix(a, b)
+}
+
+func (e *tomlEditor) entry(p []string) (tomlEntry, bool) {
+	for _, en := range e.entries {
+		if !en.array && equalPath(en.path(), p) {
+			return en, true
+		}
+	}
+	return tomlEntry{}, false
+}
+
+func (e *tomlEditor) header(p []string) (tomlHeader, bool) {
+	for _, h := range e.headers {
+		if !h.array && equalPath(h.path, p) {
+			return h, true
+		}
+	}
+	return tomlHeader{}, false
+}
+
+// checkPath returns an error if p is inside an array of tables or a value.
+func (e *tomlEditor) checkPath(p []string) error {
+	for _, h := range e.headers {
+		if h.array && hasPathPrefix(p, h.path) && len(p) > len(h.path) {
+			return fmt.Errorf("%q is in an array of tables: %w", strings.Join(p, "."), ErrUnsupported)
+		}
+	}
+	for _, en := range e.entries {
+		if ep := en.path(); !en.array && hasPathPrefix(p, ep) && len(p) > len(ep) {
+			return pathError(ep, "is not a table")
+		}
+	}
+	return nil
+}
+
+// tableEnd returns the line where the contents of the table whose header is
+// at line ends, not counting comments directly above the next header.
+func (e *tomlEditor) tableEnd(line int) int {
+	for _, h := range e.headers {
+		if h.line > line {
+			end := h.line
+			for end > line+1 && strings.HasPrefix(strings.TrimSpace(e.lines[end-1]), "#") {
+				end--
+			}
+			return end
+		}
+	}
+	return len(e.lines)
+}
+
+func (e *tomlEditor) set(p []string, value interface{}) error {
+	if len(p) == 0 {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("the root of a TOML file must be a map, not %T", value)
+		}
+		e.lines = nil
+		if err := e.parse(); err != nil {
+			return err
+		}
+		// Keys of the root table have to come before any table headers.
+		for _, k := range sortedKeys(m) {
+			if _, ok := m[k].(map[string]interface{}); !ok {
+				if err := e.set([]string{k}, m[k]); err != nil {
+					return err
+				}
+			}
+		}
+		for _, k := range sortedKeys(m) {
+			if _, ok := m[k].(map[string]interface{}); ok {
+				if err := e.set([]string{k}, m[k]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := e.checkPath(p); err != nil {
+		return err
+	}
+
+	if en, ok := e.entry(p); ok {
+		v, err := encodeTOMLValue(value)
+		if err != nil {
+			return err
+		}
+		line := e.lines[en.first][:en.valueStart] + v + e.lines[en.last][en.valueEnd:]
+		e.lines = append(e.lines[:en.first:en.first], append([]string{line}, e.lines[en.last+1:]...)...)
+		return e.parse()
+	}
+
+	m, isMap := value.(map[string]interface{})
+	if _, ok := e.header(p); ok {
+		if isMap {
+			return e.replaceTable(p, m)
+		}
+		if err := e.removeUnder(p, false); err != nil {
+			return err
+		}
+	}
+	return e.insert(p, value)
+}
+
+// insert adds a value that doesn't exist yet.
+func (e *tomlEditor) insert(p []string, value interface{}) error {
+	parent, key := p[:len(p)-1], p[len(p)-1]
+	m, isMap := value.(map[string]interface{})
+
+	if !isMap {
+		if pos, ok := e.tableInsertPos(parent); ok {
+			return e.insertEntry(pos, []string{key}, value)
+		}
+	}
+
+	// The parent may be defined by dotted keys, such as "a.b = 1" for the
+	// parent "a", in which case it can't also get a table header.
+	if len(parent) > 0 {
+		var dotted *tomlEntry
+		for i, en := range e.entries {
+			if !en.array && len(en.table) < len(parent) && hasPathPrefix(parent, en.table) && hasPathPrefix(en.path(), parent) {
+				dotted = &e.entries[i]
+			}
+		}
+		if dotted != nil {
+			return e.insertEntry(dotted.last+1, p[len(dotted.table):], value)
+		}
+	}
+
+	if isMap {
+		return e.appendTable(p, m)
+	}
+	return e.appendTable(parent, map[string]interface{}{key: value})
+}
+
+// tableInsertPos returns where a new key of the table at p is inserted: after
+// its last key, or after its header.
+func (e *tomlEditor) tableInsertPos(p []string) (int, bool) {
+	if len(p) == 0 {
+		end := len(e.lines)
+		if len(e.headers) > 0 {
+			end = e.headers[0].line
+		}
+		last := -1
+		for _, en := range e.entries {
+			if en.first < end {
+				last = en.last
+			}
+		}
+		if last >= 0 {
+			return last + 1, true
+		}
+		// Keep new keys away from the first header and the comments and
+		// blank lines above it.
+		pos := end
+		if pos < len(e.lines) {
+			for pos > 0 && strings.HasPrefix(strings.TrimSpace(e.lines[pos-1]), "#") {
+				pos--
+			}
+		}
+		for pos > 0 && strings.TrimSpace(e.lines[pos-1]) == "" {
+			pos--
+		}
+		return pos, true
+	}
+
+	h, ok := e.header(p)
+	if !ok {
+		return 0, false
+	}
+	pos := h.line + 1
+	for _, en := range e.entries {
+		if en.first > h.line && en.first < e.tableEnd(h.line) && equalPath(en.table, p) {
+			pos = en.last + 1
+		}
+	}
+	return pos, true
+}
+
+func (e *tomlEditor) insertEntry(pos int, key []string, value interface{}) error {
+	v, err := encodeTOMLValue(value)
+	if err != nil {
+		return err
+	}
+	if pos > 0 && !strings.HasSuffix(e.lines[pos-1], "\n") {
+		e.lines[pos-1] += e.eol
+	}
+	lines := []string{formatTOMLKey(key) + " = " + v + e.eol}
+	// Separate the key from a following table, unless it is added to a
+	// table that already has keys directly followed by the next header.
+	if e.startsTable(pos) && (pos == 0 || e.startsTable(pos-1)) {
+		lines = append(lines, e.eol)
+	}
+	e.lines = insertAt(e.lines, pos, lines...)
+	return e.parse()
+}
+
+func (e *tomlEditor) appendTable(p []string, m map[string]interface{}) error {
+	lines, err := e.tableLines(p, m)
+	if err != nil {
+		return err
+	}
+	ensureNewline(e.lines, e.eol)
+	if n := len(e.lines); n > 0 && strings.TrimSpace(e.lines[n-1]) != "" {
+		e.lines = append(e.lines, e.eol)
+	}
+	e.lines = append(e.lines, lines...)
+	return e.parse()
+}
+
+// replaceTable replaces the contents of the table at p, and any tables nested
+// in it, with m. The new contents are written where the table was.
+func (e *tomlEditor) replaceTable(p []string, m map[string]interface{}) error {
+	if err := e.removeUnder(p, true); err != nil {
+		return err
+	}
+	lines, err := e.tableLines(p, m)
+	if err != nil {
+		return err
+	}
+	h, _ := e.header(p)
+	lines = lines[1:]
+	if e.startsTable(h.line+1) && len(lines) > 0 {
+		lines = append(lines, e.eol)
+	}
+	e.lines = insertAt(e.lines, h.line+1, lines...)
+	return e.parse()
+}
+
+// startsTable reports whether lines[i] is a table header, so that lines
+// inserted before it need a blank line to separate them from the table.
+func (e *tomlEditor) startsTable(i int) bool {
+	return i < len(e.lines) && strings.HasPrefix(strings.TrimSpace(e.lines[i]), "[")
+}
+
+// tableLines formats m as a table with header p, followed by its nested
+// tables.
+func (e *tomlEditor) tableLines(p []string, m map[string]interface{}) ([]string, error) {
+	lines := []string{"[" + formatTOMLKey(p) + "]" + e.eol}
+	var nested []string
+	for _, k := range sortedKeys(m) {
+		if _, ok := m[k].(map[string]interface{}); ok {
+			nested = append(nested, k)
+			continue
+		}
+		v, err := encodeTOMLValue(m[k])
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, formatTOMLKey([]string{k})+" = "+v+e.eol)
+	}
+	for _, k := range nested {
+		sub, err := e.tableLines(append(append([]string(nil), p...), k), m[k].(map[string]interface{}))
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, e.eol)
+		lines = append(lines, sub...)
+	}
+	return lines, nil
+}
+
+// removeUnder removes the value or table at p, including nested tables and
+// dotted keys below it. If keepHeader is set, the header of the table at p is
+// kept.
+func (e *tomlEditor) removeUnder(p []string, keepHeader bool) error {
+	type lineRange struct{ start, end int }
+	var ranges []lineRange
+	for _, h := range e.headers {
+		if !hasPathPrefix(h.path, p) {
+			continue
+		}
+		start := h.line
+		if keepHeader && !h.array && equalPath(h.path, p) {
+			start++
+		}
+		ranges = append(ranges, lineRange{start, e.tableEnd(h.line)})
+	}
+	for _, en := range e.entries {
+		// Entries in removed tables are covered by the table's range.
+		if hasPathPrefix(en.path(), p) && !hasPathPrefix(en.table, p) {
+			ranges = append(ranges, lineRange{en.first, en.last + 1})
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start > ranges[j].start })
+	for _, r := range ranges {
+		e.lines = removeRange(e.lines, r.start, r.end)
+	}
+	return e.parse()
+}
+
+func (e *tomlEditor) delete(p []string) error {
+	if len(p) == 0 {
+		e.lines = nil
+		return e.parse()
+	}
+	if err := e.checkPath(p); err != nil {
+		return err
+	}
+	return e.removeUnder(p, false)
+}
+
+func (e *tomlEditor) isMap(p []string) bool {
+	if len(p) == 0 {
+		return true
+	}
+	if e.checkPath(p) != nil {
+		return false
+	}
+	for _, h := range e.headers {
+		if hasPathPrefix(h.path, p) {
+			if h.array && equalPath(h.path, p) {
+				return false
+			}
+			// Tables are also defined implicitly by their sub-tables.
+			return true
+		}
+	}
+	for _, en := range e.entries {
+		if ep := en.path(); !en.array && hasPathPrefix(ep, p) && len(ep) > len(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *tomlEditor) bytes() ([]byte, error) {
+	return []byte(strings.Join(e.lines, "")), nil
+}
+
+func formatTOMLKey(p []string) string {
+	parts := make([]string, len(p))
+	for i, k := range p {
+		if tomlBareKey.MatchString(k) {
+			parts[i] = k
+		} else {
+			parts[i] = quoteTOMLString(k)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func quoteTOMLString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func encodeTOMLValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", fmt.Errorf("TOML has no null value: %w", ErrUnsupported)
+	case string:
+		return quoteTOMLString(v), nil
+	case json.Number:
+		return v.String(), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return formatTOMLFloat(rv.Float()), nil
+	case reflect.String:
+		return quoteTOMLString(rv.String()), nil
+	case reflect.Slice, reflect.Array:
+		items := make([]string, rv.Len())
+		for i := range items {
+			item, err := encodeTOMLValue(rv.Index(i).Interface())
+			if err != nil {
+				return "", err
+			}
+			items[i] = item
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return "", fmt.Errorf("%T values: %w", value, ErrUnsupported)
+		}
+		keys := make([]string, 0, rv.Len())
+		for _, k := range rv.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+		if len(keys) == 0 {
+			return "{}", nil
+		}
+		items := make([]string, len(keys))
+		for i, k := range keys {
+			item, err := encodeTOMLValue(rv.MapIndex(reflect.ValueOf(k).Convert(rv.Type().Key())).Interface())
+			if err != nil {
+				return "", err
+			}
+			items[i] = formatTOMLKey([]string{k}) + " = " + item
+		}
+		return "{ " + strings.Join(items, ", ") + " }", nil
+	}
+	return "", fmt.Errorf("%T values: %w", value, ErrUnsupported)
+}
+
+func formatTOMLFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
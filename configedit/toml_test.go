@@ -0,0 +1,167 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package configedit
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApplyTOML(t *testing.T) {
+	const doc = `# Device configuration
+title = "edge"
+
+[server]
+host = "0.0.0.0" # all interfaces
+ports = [
+  8080,
+  8081, # metrics
+]
+motd = """
+Welcome
+"""
+
+[server.tls]
+cert = '/etc/cert.pem'
+
+[[plugins]]
+name = "a"
+`
+
+	for _, tc := range []struct {
+		name    string
+		data    string
+		changes []Change
+		want    string
+	}{
+		{
+			name:    "replace values keeping comments",
+			data:    doc,
+			changes: []Change{Set("server.host", "127.0.0.1"), Set("server.ports", []int{22})},
+			want: `# Device configuration
+title = "edge"
+
+[server]
+host = "127.0.0.1" # all interfaces
+ports = [22]
+motd = """
+Welcome
+"""
+
+[server.tls]
+cert = '/etc/cert.pem'
+
+[[plugins]]
+name = "a"
+`,
+		},
+		{
+			name: "add keys to existing tables",
+			data: doc,
+			changes: []Change{
+				Set("server.timeout", 1.5),
+				Set("server.tls.verify", true),
+				Set("version", 2),
+			},
+			want: `# Device configuration
+title = "edge"
+version = 2
+
+[server]
+host = "0.0.0.0" # all interfaces
+ports = [
+  8080,
+  8081, # metrics
+]
+motd = """
+Welcome
+"""
+timeout = 1.5
+
+[server.tls]
+cert = '/etc/cert.pem'
+verify = true
+
+[[plugins]]
+name = "a"
+`,
+		},
+		{
+			name:    "delete table and sub-tables",
+			data:    doc,
+			changes: []Change{Delete("server"), Delete("missing")},
+			want: `# Device configuration
+title = "edge"
+
+[[plugins]]
+name = "a"
+`,
+		},
+		{
+			name: "merge creates tables",
+			data: "[a]\nx = 1\n",
+			changes: []Change{Merge("", map[string]interface{}{
+				"a": map[string]interface{}{"y": "two"},
+				"b": map[string]interface{}{
+					"z":   []interface{}{"p", "q"},
+					"sub": map[string]interface{}{"w": 0},
+				},
+			})},
+			want: "[a]\nx = 1\ny = \"two\"\n\n[b]\nz = [\"p\", \"q\"]\n\n[b.sub]\nw = 0\n",
+		},
+		{
+			name:    "dotted keys",
+			data:    "a.b = 1\n\n[c]\n",
+			changes: []Change{Set("a.c", "x"), Set("a.b", 2)},
+			want:    "a.b = 2\na.c = \"x\"\n\n[c]\n",
+		},
+		{
+			name:    "replace table contents in place",
+			data:    "[a]\nx = 1\n\n[a.sub]\ny = 2\n\n[b]\nz = 3\n",
+			changes: []Change{Set("a", map[string]interface{}{"w": 4})},
+			want:    "[a]\nw = 4\n\n[b]\nz = 3\n",
+		},
+		{
+			name:    "quoted keys",
+			data:    "[\"my table\"]\n'key one' = 1\n",
+			changes: []Change{Set("my table.key one", 2), Set("my table.key two", "a\"b")},
+			want:    "[\"my table\"]\n'key one' = 2\n\"key two\" = \"a\\\"b\"\n",
+		},
+		{
+			name:    "empty document",
+			data:    "",
+			changes: []Change{Set("a.b", 1), Set("c", 2)},
+			want:    "c = 2\n\n[a]\nb = 1\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Apply(TOML, []byte(tc.data), tc.changes)
+			if err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyTOMLErrors(t *testing.T) {
+	const doc = "a = 1\n\n[[plugins]]\nname = \"x\"\n"
+	if _, err := Apply(TOML, []byte(doc), []Change{Set("plugins.name", "y")}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected editing an array of tables to be unsupported, got %v", err)
+	}
+	if _, err := Apply(TOML, []byte(doc), []Change{Set("a.b", 2)}); err == nil {
+		t.Errorf("expected setting a key inside a value to fail")
+	}
+	if _, err := Apply(TOML, []byte(doc), []Change{Set("b", nil)}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected null values to be unsupported, got %v", err)
+	}
+	for _, data := range []string{"a = \"x\n", "a = [1,\n", "a\n", "[a\n"} {
+		if _, err := Apply(TOML, []byte(data), nil); err == nil {
+			t.Errorf("expected %q to fail to parse", data)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package configedit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Indentation used for documents that have no indented lines.
+const defaultYAMLIndent = 2
+
+type yamlEditor struct {
+	doc    *yaml.Node
+	indent int
+}
+
+func parseYAML(data []byte) (*yamlEditor, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var doc yaml.Node
+	if err := dec.Decode(&doc); err != nil && err != io.EOF {
+		return nil, err
+	}
+	var extra yaml.Node
+	if err := dec.Decode(&extra); err != io.EOF {
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("multi-document YAML: %w", ErrUnsupported)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		doc = yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}},
+		}
+	}
+	return &yamlEditor{doc: &doc, indent: detectYAMLIndent(data)}, nil
+}
+
+// detectYAMLIndent returns the smallest indentation of any content line, which
+// is the indentation of one nesting level.
+func detectYAMLIndent(data []byte) int {
+	indent := 0
+	for _, line := range splitLines(string(data)) {
+		trimmed := strings.TrimLeft(line, " ")
+		n := len(line) - len(trimmed)
+		if n == 0 || strings.TrimSpace(trimmed) == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if indent == 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent == 0 {
+		return defaultYAMLIndent
+	}
+	return indent
+}
+
+// yamlChild returns the node for key k in a mapping or sequence node, and its
+// index in n.Content. For mappings the index is that of the key node.
+func yamlChild(n *yaml.Node, k string) (*yaml.Node, int) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == k {
+				return n.Content[i+1], i
+			}
+		}
+	case yaml.SequenceNode:
+		if idx, err := strconv.Atoi(k); err == nil && idx >= 0 && idx < len(n.Content) {
+			return n.Content[idx], idx
+		}
+	}
+	return nil, -1
+}
+
+func (e *yamlEditor) root() *yaml.Node {
+	return e.doc.Content[0]
+}
+
+// lookup returns the node at p, or nil if it doesn't exist.
+func (e *yamlEditor) lookup(p []string) (*yaml.Node, error) {
+	cur := e.root()
+	for i, k := range p {
+		if cur.Kind == yaml.AliasNode {
+			return nil, pathError(p[:i], "is an alias, which cannot be edited")
+		}
+		if cur.Kind != yaml.MappingNode && cur.Kind != yaml.SequenceNode {
+			return nil, pathError(p[:i], "is not a mapping or sequence")
+		}
+		if cur, _ = yamlChild(cur, k); cur == nil {
+			return nil, nil
+		}
+	}
+	return cur, nil
+}
+
+func encodeYAMLValue(value interface{}) (*yaml.Node, error) {
+	var n yaml.Node
+	if err := n.Encode(value); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func (e *yamlEditor) set(p []string, value interface{}) error {
+	n, err := encodeYAMLValue(value)
+	if err != nil {
+		return err
+	}
+	if len(p) == 0 {
+		replaceYAMLNode(e.root(), n)
+		return nil
+	}
+
+	// Create any missing parent mappings.
+	cur := e.root()
+	for i, k := range p {
+		if cur.Kind == yaml.AliasNode {
+			return pathError(p[:i], "is an alias, which cannot be edited")
+		}
+		next, _ := yamlChild(cur, k)
+		last := i == len(p)-1
+		switch {
+		case next != nil && last:
+			replaceYAMLNode(next, n)
+			return nil
+		case next != nil:
+			cur = next
+		case cur.Kind == yaml.MappingNode:
+			if !last {
+				next = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			} else {
+				next = n
+			}
+			cur.Content = append(cur.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}, next)
+			cur = next
+		case cur.Kind == yaml.SequenceNode:
+			return pathError(p[:i+1], "is not an element of the sequence")
+		default:
+			return pathError(p[:i], "is not a mapping or sequence")
+		}
+	}
+	return nil
+}
+
+// replaceYAMLNode replaces the value of old with n in place, keeping the
+// comments attached to old.
+func replaceYAMLNode(old, n *yaml.Node) {
+	head, line, foot := old.HeadComment, old.LineComment, old.FootComment
+	*old = *n
+	if old.HeadComment == "" {
+		old.HeadComment = head
+	}
+	if old.LineComment == "" {
+		old.LineComment = line
+	}
+	if old.FootComment == "" {
+		old.FootComment = foot
+	}
+}
+
+func (e *yamlEditor) delete(p []string) error {
+	if len(p) == 0 {
+		*e.root() = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		return nil
+	}
+	parent, err := e.lookup(p[:len(p)-1])
+	if err != nil || parent == nil {
+		return err
+	}
+	_, i := yamlChild(parent, p[len(p)-1])
+	switch {
+	case i < 0:
+	case parent.Kind == yaml.MappingNode:
+		parent.Content = append(parent.Content[:i], parent.Content[i+2:]...)
+	case parent.Kind == yaml.SequenceNode:
+		parent.Content = append(parent.Content[:i], parent.Content[i+1:]...)
+	}
+	return nil
+}
+
+func (e *yamlEditor) isMap(p []string) bool {
+	n, err := e.lookup(p)
+	return err == nil && n != nil && n.Kind == yaml.MappingNode
+}
+
+func (e *yamlEditor) bytes() ([]byte, error) {
+	// Encoding an empty mapping produces "{}", which is surprising for a file
+	// whose settings were all deleted.
+	if root := e.root(); root.Kind == yaml.MappingNode && len(root.Content) == 0 && root.HeadComment == "" && e.doc.HeadComment == "" {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(e.indent)
+	if err := enc.Encode(e.doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package configedit
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApplyYAML(t *testing.T) {
+	const doc = `# Device configuration
+server:
+    # Listen address
+    host: 0.0.0.0 # all interfaces
+    port: 8080
+features:
+    - a
+    - b
+`
+
+	for _, tc := range []struct {
+		name    string
+		data    string
+		changes []Change
+		want    string
+	}{
+		{
+			name:    "set keeps comments and indentation",
+			data:    doc,
+			changes: []Change{Set("server.host", "127.0.0.1")},
+			want: `# Device configuration
+server:
+    # Listen address
+    host: 127.0.0.1 # all interfaces
+    port: 8080
+features:
+    - a
+    - b
+`,
+		},
+		{
+			name:    "set creates parents",
+			data:    "a: 1\n",
+			changes: []Change{Set("b.c", "x")},
+			want:    "a: 1\nb:\n  c: x\n",
+		},
+		{
+			name:    "set sequence element",
+			data:    doc,
+			changes: []Change{Set("features.1", "c")},
+			want: `# Device configuration
+server:
+    # Listen address
+    host: 0.0.0.0 # all interfaces
+    port: 8080
+features:
+    - a
+    - c
+`,
+		},
+		{
+			name:    "delete",
+			data:    "a: 1\nb: 2\n",
+			changes: []Change{Delete("a"), Delete("c")},
+			want:    "b: 2\n",
+		},
+		{
+			name: "merge",
+			data: "log:\n  level: info\n  file: x\n",
+			changes: []Change{Merge("log", map[string]interface{}{
+				"level":  "debug",
+				"rotate": map[string]interface{}{"keep": 3},
+			})},
+			want: "log:\n  level: debug\n  file: x\n  rotate:\n    keep: 3\n",
+		},
+		{
+			name:    "empty document",
+			data:    "",
+			changes: []Change{Set("a", 1)},
+			want:    "a: 1\n",
+		},
+		{
+			name:    "delete everything",
+			data:    "a: 1\n",
+			changes: []Change{Delete("a")},
+			want:    "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Apply(YAML, []byte(tc.data), tc.changes)
+			if err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyYAMLErrors(t *testing.T) {
+	if _, err := Apply(YAML, []byte("a: 1\n---\nb: 2\n"), nil); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected multi-document YAML to be unsupported, got %v", err)
+	}
+	if _, err := Apply(YAML, []byte("base: &base\n  a: 1\nother: *base\n"), []Change{Set("other.a", 2)}); err == nil {
+		t.Errorf("expected editing through an alias to fail")
+	}
+	if _, err := Apply(YAML, []byte("a: 1\n"), []Change{Set("a.b", 2)}); err == nil {
+		t.Errorf("expected setting a key inside a scalar to fail")
+	}
+}
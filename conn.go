@@ -298,7 +298,7 @@ func connectToSSHFromClient(ctx context.Context, c *Client, addr net.Addr, confi
 	}
 }
 
-func (c *Conn) makeSession(ctx context.Context, stdout io.Writer, stderr io.Writer) (*Session, error) {
+func (c *Conn) makeSession(ctx context.Context, stdin io.Reader, stdout io.Writer, stderr io.Writer) (*Session, error) {
 	// Temporarily grab the lock and make a copy of the client. This
 	// prevents a long running `Run` command from blocking the keepalive
 	// goroutine.
@@ -326,6 +326,7 @@ func (c *Conn) makeSession(ctx context.Context, stdout io.Writer, stderr io.Writ
 			return
 		}
 
+		session.Stdin = stdin
 		session.Stdout = stdout
 		session.Stderr = stderr
 
@@ -348,7 +349,13 @@ func (c *Conn) makeSession(ctx context.Context, stdout io.Writer, stderr io.Writ
 // Start a command on the remote device and write STDOUT and STDERR to the
 // passed in io.Writers.
 func (c *Conn) Start(ctx context.Context, command []string, stdout io.Writer, stderr io.Writer) (*Session, error) {
-	session, err := c.makeSession(ctx, stdout, stderr)
+	return c.StartWithInput(ctx, command, nil, stdout, stderr)
+}
+
+// StartWithInput starts a command like Start, with stdin as its standard
+// input.
+func (c *Conn) StartWithInput(ctx context.Context, command []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) (*Session, error) {
+	session, err := c.makeSession(ctx, stdin, stdout, stderr)
 	if err != nil {
 		return nil, err
 	}
@@ -365,7 +372,13 @@ func (c *Conn) Start(ctx context.Context, command []string, stdout io.Writer, st
 // Run a command to completion on the remote device and write STDOUT and STDERR
 // to the passed in io.Writers.
 func (c *Conn) Run(ctx context.Context, command []string, stdout io.Writer, stderr io.Writer) error {
-	session, err := c.makeSession(ctx, stdout, stderr)
+	return c.RunWithInput(ctx, command, nil, stdout, stderr)
+}
+
+// RunWithInput runs a command to completion like Run, with stdin as its
+// standard input.
+func (c *Conn) RunWithInput(ctx context.Context, command []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	session, err := c.makeSession(ctx, stdin, stdout, stderr)
 	if err != nil {
 		return err
 	}
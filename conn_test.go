@@ -15,6 +15,7 @@ import (
 
 	"go.fuchsia.dev/fuchsia/tools/lib/retry"
 
+	"github.com/aucloud/go-sshutil/internal/sshtest"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -25,19 +26,19 @@ func setUpConn(
 	t *testing.T,
 	onNewChannel func(ssh.NewChannel),
 	onRequest func(*ssh.Request),
-) (*Conn, *sshServer) {
-	server, err := startSSHServer(onNewChannel, onRequest)
+) (*Conn, *sshtest.Server) {
+	server, err := sshtest.Start(onNewChannel, onRequest)
 	if err != nil {
 		t.Fatalf("failed to start ssh server: %v", err)
 	}
-	t.Cleanup(server.stop)
+	t.Cleanup(server.Stop)
 
 	conn, err := connect(
 		ctx,
 		ConstantAddrResolver{
-			Addr: server.addr,
+			Addr: server.Addr,
 		},
-		server.clientConfig,
+		server.ClientConfig,
 		retry.NoRetries(),
 	)
 	if err != nil {
@@ -116,7 +117,7 @@ func TestKeepalive(t *testing.T) {
 
 		// The first keepalive request should fail immediately if the server is
 		// stopped.
-		server.stop()
+		server.Stop()
 
 		keepaliveTicks := make(chan time.Time)
 		keepaliveComplete := make(chan struct{})
@@ -163,7 +164,7 @@ func TestRun(t *testing.T) {
 		client, _ := setUpClient(
 			ctx,
 			t,
-			onNewExecChannel(func(cmd string, stdout io.Writer, stderr io.Writer) int {
+			sshtest.OnExecChannel(func(cmd string, stdout io.Writer, stderr io.Writer) int {
 				switch cmd {
 				case "pass":
 					stdout.Write([]byte("pass stdout"))
@@ -243,7 +244,7 @@ func TestRun(t *testing.T) {
 
 		}()
 
-		_, clientConfig, err := genSSHConfig()
+		_, clientConfig, err := sshtest.GenConfig()
 		if err != nil {
 			t.Fatalf("failed to create ssh config: %v", err)
 		}
@@ -328,7 +329,7 @@ func TestRun(t *testing.T) {
 	t.Run("exits early if session creation fails", func(t *testing.T) {
 		conn, server := setUpConn(ctx, t, nil, nil)
 
-		server.stop()
+		server.Stop()
 
 		errs := make(chan error)
 		go func() {
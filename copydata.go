@@ -0,0 +1,267 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// github.com/pkg/sftp doesn't implement a client for the copy-data extension,
+// so we speak just enough of SFTP version 3 on a dedicated subsystem channel
+// to open both files and ask the server to copy between them.
+//
+// See https://datatracker.ietf.org/doc/html/draft-ietf-secsh-filexfer-extensions-00#section-7
+
+const (
+	sftpProtocolVersion = 3
+
+	sftpPacketInit     = 1
+	sftpPacketVersion  = 2
+	sftpPacketOpen     = 3
+	sftpPacketClose    = 4
+	sftpPacketStatus   = 101
+	sftpPacketHandle   = 102
+	sftpPacketExtended = 200
+
+	sftpOpenRead  = 0x01
+	sftpOpenWrite = 0x02
+	sftpOpenCreat = 0x08
+	sftpOpenTrunc = 0x10
+
+	sftpStatusOK = 0
+
+	// Refuse to allocate absurdly large packets if the stream is corrupt.
+	sftpMaxPacketLength = 256 * 1024
+)
+
+// sftpStatusError is a non-OK SSH_FXP_STATUS response.
+type sftpStatusError struct {
+	Code    uint32
+	Message string
+}
+
+func (e sftpStatusError) Error() string {
+	return fmt.Sprintf("sftp status %d: %s", e.Code, e.Message)
+}
+
+// copyDataSession is a minimal SFTP client used only for copy-data.
+type copyDataSession struct {
+	rw     io.ReadWriter
+	nextID uint32
+}
+
+// copyData copies src to dst on the remote using the copy-data extension.
+func copyData(ctx context.Context, client *ssh.Client, src, dst string) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return ConnectionError{fmt.Errorf("failed to start ssh session: %w", err)}
+	}
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		return err
+	}
+
+	ch := make(chan error, 1)
+	go func() {
+		s := copyDataSession{rw: struct {
+			io.Reader
+			io.Writer
+		}{stdout, stdin}}
+		ch <- s.copy(src, dst)
+	}()
+
+	select {
+	case err := <-ch:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *copyDataSession) copy(src, dst string) error {
+	if err := s.init(); err != nil {
+		return err
+	}
+
+	srcHandle, err := s.open(src, sftpOpenRead)
+	if err != nil {
+		return fmt.Errorf("failed to open %q: %w", src, err)
+	}
+	defer s.close(srcHandle)
+
+	dstHandle, err := s.open(dst, sftpOpenWrite|sftpOpenCreat|sftpOpenTrunc)
+	if err != nil {
+		return fmt.Errorf("failed to open %q: %w", dst, err)
+	}
+
+	// A zero read length copies until the end of the source file.
+	var req []byte
+	req = appendString(req, ExtCopyData)
+	req = appendString(req, srcHandle)
+	req = appendUint64(req, 0)
+	req = appendUint64(req, 0)
+	req = appendString(req, dstHandle)
+	req = appendUint64(req, 0)
+	if err := s.expectStatus(sftpPacketExtended, req); err != nil {
+		s.close(dstHandle)
+		return fmt.Errorf("copy-data from %q to %q failed: %w", src, dst, err)
+	}
+	return s.close(dstHandle)
+}
+
+func (s *copyDataSession) init() error {
+	if err := s.writePacket(sftpPacketInit, appendUint32(nil, sftpProtocolVersion)); err != nil {
+		return err
+	}
+	typ, _, err := s.readPacket()
+	if err != nil {
+		return err
+	}
+	if typ != sftpPacketVersion {
+		return fmt.Errorf("expected sftp version packet, got type %d", typ)
+	}
+	return nil
+}
+
+func (s *copyDataSession) open(name string, flags uint32) (string, error) {
+	id := s.id()
+	req := appendUint32(nil, id)
+	req = appendString(req, name)
+	req = appendUint32(req, flags)
+	// No attributes.
+	req = appendUint32(req, 0)
+	if err := s.writePacket(sftpPacketOpen, req); err != nil {
+		return "", err
+	}
+	typ, data, err := s.readResponse(id)
+	if err != nil {
+		return "", err
+	}
+	switch typ {
+	case sftpPacketHandle:
+		handle, _, err := readString(data)
+		return handle, err
+	case sftpPacketStatus:
+		return "", parseStatus(data)
+	default:
+		return "", fmt.Errorf("unexpected sftp packet type %d", typ)
+	}
+}
+
+func (s *copyDataSession) close(handle string) error {
+	return s.expectStatus(sftpPacketClose, appendString(nil, handle))
+}
+
+// expectStatus sends a request of the given type, prefixed with a fresh
+// request id, and waits for an OK status.
+func (s *copyDataSession) expectStatus(typ byte, payload []byte) error {
+	id := s.id()
+	if err := s.writePacket(typ, append(appendUint32(nil, id), payload...)); err != nil {
+		return err
+	}
+	respType, data, err := s.readResponse(id)
+	if err != nil {
+		return err
+	}
+	if respType != sftpPacketStatus {
+		return fmt.Errorf("unexpected sftp packet type %d", respType)
+	}
+	return parseStatus(data)
+}
+
+func (s *copyDataSession) id() uint32 {
+	s.nextID++
+	return s.nextID
+}
+
+func (s *copyDataSession) writePacket(typ byte, payload []byte) error {
+	buf := appendUint32(nil, uint32(len(payload)+1))
+	buf = append(buf, typ)
+	buf = append(buf, payload...)
+	_, err := s.rw.Write(buf)
+	return err
+}
+
+func (s *copyDataSession) readPacket() (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(s.rw, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length == 0 || length > sftpMaxPacketLength {
+		return 0, nil, fmt.Errorf("invalid sftp packet length %d", length)
+	}
+	data := make([]byte, length-1)
+	if _, err := io.ReadFull(s.rw, data); err != nil {
+		return 0, nil, err
+	}
+	return header[4], data, nil
+}
+
+// readResponse reads a response packet and checks it matches the request id.
+// The returned data excludes the id.
+func (s *copyDataSession) readResponse(id uint32) (byte, []byte, error) {
+	typ, data, err := s.readPacket()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(data) < 4 {
+		return 0, nil, errors.New("short sftp response")
+	}
+	if got := binary.BigEndian.Uint32(data); got != id {
+		return 0, nil, fmt.Errorf("sftp response id %d does not match request id %d", got, id)
+	}
+	return typ, data[4:], nil
+}
+
+func parseStatus(data []byte) error {
+	if len(data) < 4 {
+		return errors.New("short sftp status")
+	}
+	code := binary.BigEndian.Uint32(data)
+	if code == sftpStatusOK {
+		return nil
+	}
+	msg, _, _ := readString(data[4:])
+	return sftpStatusError{Code: code, Message: msg}
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v>>32)), uint32(v))
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(appendUint32(b, uint32(len(s))), s...)
+}
+
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 4 {
+		return "", nil, errors.New("short sftp string")
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint32(len(b)-4) < n {
+		return "", nil, errors.New("short sftp string")
+	}
+	return string(b[4 : 4+n]), b[4+n:], nil
+}
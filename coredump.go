@@ -0,0 +1,540 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.fuchsia.dev/fuchsia/tools/lib/logger"
+)
+
+const (
+	// defaultCoreInterval is how often a CoreWatcher polls by default.
+	defaultCoreInterval = 5 * time.Second
+
+	coreSourceFile    = "file"
+	coreSourceSystemd = "systemd-coredump"
+
+	// ntFile is the type of the ELF note listing a core's mapped files,
+	// which debug/elf doesn't define.
+	ntFile = 0x46494c45
+
+	// ntGNUBuildID and ntGoBuildID are the types of the GNU and Go build ID
+	// notes.
+	ntGNUBuildID = 3
+	ntGoBuildID  = 4
+)
+
+// corePatternPath is read to find where the remote's kernel writes cores.
+var corePatternPath = "/proc/sys/kernel/core_pattern"
+
+// CoreDump is a core dump downloaded by a CoreWatcher. Its metadata is saved
+// as coredump.json in its directory.
+type CoreDump struct {
+	Host string
+
+	// Source is "file" for a core file the kernel wrote, or
+	// "systemd-coredump" for one collected by systemd-coredump.
+	Source string
+
+	// RemotePath is the remote core file, for cores from files.
+	RemotePath string `json:",omitempty"`
+
+	PID int `json:",omitempty"`
+
+	// Executable is the remote path of the program that crashed, and BuildID
+	// its GNU or Go build ID, if it could be downloaded.
+	Executable string `json:",omitempty"`
+	BuildID    string `json:",omitempty"`
+
+	// Time is when the dump was detected.
+	Time time.Time
+
+	// Dir is the local directory holding the dump, Core the core file in it,
+	// and Binary the executable, or empty if it couldn't be downloaded.
+	Dir    string `json:"-"`
+	Core   string `json:"-"`
+	Binary string `json:"-"`
+}
+
+// CoreEvent reports a core dump, or a failure to download one.
+type CoreEvent struct {
+	Dump *CoreDump
+	Err  error
+}
+
+// CoreWatcherOptions configure WatchCores.
+type CoreWatcherOptions struct {
+	// Dir is the local directory dumps are saved in, each in a directory
+	// named after the host, PID and time, with a number added if one with
+	// that name already exists.
+	Dir string
+
+	// Interval is how often the remote is polled. It defaults to 5 seconds.
+	Interval time.Duration
+
+	// CoreDirs are remote directories to look for files named core* in, in
+	// addition to the directory in the kernel's core pattern. They're needed
+	// when the pattern is relative, so that cores are written to the
+	// working directory of the process that crashed.
+	CoreDirs []string
+
+	// Events is called with each dump as it's downloaded.
+	Events func(CoreEvent)
+}
+
+// coreLocation is a remote directory the kernel writes cores to, with the
+// prefix of their names.
+type coreLocation struct {
+	dir    string
+	prefix string
+}
+
+// CoreWatcher watches a remote for new core dumps.
+type CoreWatcher struct {
+	client    *Client
+	opts      CoreWatcherOptions
+	host      string
+	locations []coreLocation
+	systemd   bool
+
+	// seen holds the core files and systemd-coredump entries that have been
+	// handled, or that existed when the watcher started. sizes holds the
+	// sizes of core files when last polled, as they're only downloaded once
+	// the kernel has finished writing them.
+	seen  map[string]bool
+	sizes map[string]int64
+
+	cancel   context.CancelFunc
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// WatchCores starts polling the remote for core files written by the kernel
+// or collected by systemd-coredump, and downloads each new one with the
+// binary that crashed, until Stop is called or ctx is canceled. Dumps that
+// exist when it starts are ignored.
+func (c *Client) WatchCores(ctx context.Context, opts CoreWatcherOptions) (*CoreWatcher, error) {
+	if opts.Interval == 0 {
+		opts.Interval = defaultCoreInterval
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	host, err := c.Output(ctx, "uname", "-n")
+	if err != nil {
+		return nil, err
+	}
+	out, err := c.Output(ctx, "cat", corePatternPath)
+	if err != nil {
+		return nil, err
+	}
+	w := &CoreWatcher{
+		client: c,
+		opts:   opts,
+		host:   strings.TrimSpace(string(host)),
+		seen:   make(map[string]bool),
+		sizes:  make(map[string]int64),
+		done:   make(chan struct{}),
+	}
+
+	pattern := strings.TrimSpace(string(out))
+	if strings.HasPrefix(pattern, "|") {
+		if strings.Contains(pattern, "systemd-coredump") {
+			_, err := c.ShellOutput(ctx, "command -v coredumpctl")
+			w.systemd = err == nil
+		}
+	} else if path.IsAbs(pattern) {
+		dir, base := path.Split(pattern)
+		if i := strings.IndexByte(base, '%'); i >= 0 {
+			base = base[:i]
+		}
+		w.locations = append(w.locations, coreLocation{dir: path.Clean(dir), prefix: base})
+	}
+	for _, dir := range opts.CoreDirs {
+		w.locations = append(w.locations, coreLocation{dir: dir, prefix: "core"})
+	}
+
+	// Note the existing dumps, which aren't reported.
+	if err := w.poll(ctx, true); err != nil {
+		return nil, err
+	}
+
+	ctx, w.cancel = context.WithCancel(ctx)
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := w.poll(ctx, false); err != nil && ctx.Err() == nil {
+					w.emit(CoreEvent{Err: err})
+				}
+			}
+		}
+	}()
+	return w, nil
+}
+
+// Stop stops polling, after a final poll to catch dumps from the end of a
+// run. Cores the kernel is still writing are missed.
+func (w *CoreWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		w.cancel()
+		<-w.done
+		if err := w.poll(context.Background(), false); err != nil {
+			w.emit(CoreEvent{Err: err})
+		}
+	})
+}
+
+func (w *CoreWatcher) emit(e CoreEvent) {
+	if w.opts.Events != nil {
+		w.opts.Events(e)
+	}
+}
+
+// poll looks for new dumps. When noting existing dumps, none are downloaded.
+func (w *CoreWatcher) poll(ctx context.Context, existing bool) error {
+	if len(w.locations) > 0 {
+		if err := w.pollFiles(ctx, existing); err != nil {
+			return err
+		}
+	}
+	if w.systemd {
+		return w.pollSystemd(ctx, existing)
+	}
+	return nil
+}
+
+func (w *CoreWatcher) pollFiles(ctx context.Context, existing bool) error {
+	f, err := w.client.NewFileClient()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, loc := range w.locations {
+		entries, err := f.SFTP().ReadDir(loc.dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			name := path.Join(loc.dir, e.Name())
+			if !e.Mode().IsRegular() || !strings.HasPrefix(e.Name(), loc.prefix) || w.seen[name] {
+				continue
+			}
+			if !existing && (e.Size() == 0 || w.sizes[name] != e.Size()) {
+				w.sizes[name] = e.Size()
+				continue
+			}
+			w.seen[name] = true
+			delete(w.sizes, name)
+			if existing {
+				continue
+			}
+			dump := &CoreDump{Source: coreSourceFile, RemotePath: name}
+			w.emit(w.download(ctx, dump, func(dst string) error {
+				return f.Download(ctx, name, dst, TransferOptions{})
+			}))
+		}
+	}
+	return nil
+}
+
+// coredumpEntry is an entry in the output of `coredumpctl list`.
+type coredumpEntry struct {
+	key        string
+	pid        int
+	executable string
+	present    bool
+}
+
+// parseCoredumpList parses the output of `coredumpctl --no-legend list`, in
+// which each line has the time as four fields, then the PID, UID, GID,
+// signal, whether the core is stored, the executable and its size:
+//
+//	Tue 2026-10-13 10:00:00 UTC 1234 0 0 SIGSEGV present /usr/bin/foo 1.2M
+func parseCoredumpList(out []byte) ([]coredumpEntry, error) {
+	var entries []coredumpEntry
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 10 {
+			return nil, fmt.Errorf("invalid coredumpctl entry %q", line)
+		}
+		pid, err := strconv.Atoi(fields[4])
+		if err != nil {
+			return nil, fmt.Errorf("invalid coredumpctl entry %q", line)
+		}
+		entries = append(entries, coredumpEntry{
+			key:        strings.Join(fields[:5], " "),
+			pid:        pid,
+			executable: fields[9],
+			present:    fields[8] == "present" || fields[8] == "journal",
+		})
+	}
+	return entries, nil
+}
+
+func (w *CoreWatcher) pollSystemd(ctx context.Context, existing bool) error {
+	out, err := w.client.Output(ctx, "coredumpctl", "--no-pager", "--no-legend", "list")
+	var cmdErr CommandError
+	if errors.As(err, &cmdErr) && cmdErr.ExitStatus() == 1 {
+		// coredumpctl fails when there are no dumps.
+		return nil
+	} else if err != nil {
+		return err
+	}
+	entries, err := parseCoredumpList(out)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if w.seen[e.key] {
+			continue
+		}
+		w.seen[e.key] = true
+		if existing || !e.present {
+			continue
+		}
+		e := e
+		dump := &CoreDump{Source: coreSourceSystemd, PID: e.pid, Executable: e.executable}
+		w.emit(w.download(ctx, dump, func(dst string) error {
+			return w.dumpSystemd(ctx, e.pid, dst)
+		}))
+	}
+	return nil
+}
+
+// dumpSystemd extracts the latest dump of a process from the journal, and
+// downloads it to dst.
+func (w *CoreWatcher) dumpSystemd(ctx context.Context, pid int, dst string) error {
+	c := w.client
+	dir, err := c.tempDir(ctx, "sshutil-core")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if _, err := c.Output(ctx, "rm", "-rf", dir); err != nil {
+			logger.Warningf(ctx, "failed to remove %s: %v", dir, err)
+		}
+	}()
+	core := path.Join(dir, "core")
+	if _, err := c.Output(ctx, "coredumpctl", "--no-pager", "-o", core, "dump", strconv.Itoa(pid)); err != nil {
+		return err
+	}
+	f, err := c.NewFileClient()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Download(ctx, core, dst, TransferOptions{})
+}
+
+// download fetches a dump's core with get, and the binary that crashed, and
+// saves its metadata.
+func (w *CoreWatcher) download(ctx context.Context, dump *CoreDump, get func(dst string) error) CoreEvent {
+	dump.Host = w.host
+	dump.Time = time.Now().UTC()
+	name := fmt.Sprintf("%s-%s", sanitizeFileName(dump.Host), dump.Time.Format(profileTimeFormat))
+	if dump.PID != 0 {
+		name = fmt.Sprintf("%s-%d-%s", sanitizeFileName(dump.Host), dump.PID, dump.Time.Format(profileTimeFormat))
+	}
+	dir, err := mkdirNumbered(filepath.Join(w.opts.Dir, name))
+	if err != nil {
+		return CoreEvent{Err: err}
+	}
+	dump.Dir = dir
+	dump.Core = filepath.Join(dir, "core")
+	if err := get(dump.Core); err != nil {
+		return CoreEvent{Dump: dump, Err: fmt.Errorf("failed to download core: %w", err)}
+	}
+
+	pid, executable, err := readCoreInfo(dump.Core)
+	if err != nil {
+		logger.Warningf(ctx, "failed to read core %s: %v", dump.Core, err)
+	}
+	if dump.PID == 0 {
+		dump.PID = pid
+	}
+	if dump.Executable == "" {
+		dump.Executable = executable
+	}
+
+	if dump.Executable != "" {
+		binary := filepath.Join(dir, path.Base(dump.Executable))
+		if err := w.downloadBinary(ctx, dump.Executable, binary); err != nil {
+			logger.Warningf(ctx, "failed to download %s: %v", dump.Executable, err)
+		} else {
+			dump.Binary = binary
+			if dump.BuildID, err = readBuildID(binary); err != nil {
+				logger.Warningf(ctx, "failed to read build ID of %s: %v", binary, err)
+			}
+		}
+	}
+
+	metadata, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return CoreEvent{Dump: dump, Err: err}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "coredump.json"), append(metadata, '\n'), 0o644); err != nil {
+		return CoreEvent{Dump: dump, Err: err}
+	}
+	return CoreEvent{Dump: dump}
+}
+
+func (w *CoreWatcher) downloadBinary(ctx context.Context, src, dst string) error {
+	f, err := w.client.NewFileClient()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Download(ctx, src, dst, TransferOptions{Symlinks: SymlinkFollow})
+}
+
+// readCoreInfo reads the PID of the crashed process from a core's
+// NT_PRSTATUS note, and the path of its executable from the first file in its
+// NT_FILE note, which is the lowest mapping.
+func readCoreInfo(name string) (int, string, error) {
+	f, err := elf.Open(name)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	if f.Type != elf.ET_CORE {
+		return 0, "", fmt.Errorf("%s is not a core file", name)
+	}
+
+	// The PID follows the signal info, the current signal and the pending
+	// and held signal sets, which are words.
+	wordSize, pidOffset := 8, 32
+	if f.Class == elf.ELFCLASS32 {
+		wordSize, pidOffset = 4, 24
+	}
+	var pid int
+	var executable string
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_NOTE {
+			continue
+		}
+		data, err := ioutil.ReadAll(prog.Open())
+		if err != nil {
+			return 0, "", err
+		}
+		for _, n := range parseNotes(data, f.ByteOrder) {
+			switch {
+			case n.typ == uint32(elf.NT_PRSTATUS) && pid == 0 && len(n.desc) >= pidOffset+4:
+				pid = int(f.ByteOrder.Uint32(n.desc[pidOffset:]))
+			case n.typ == ntFile && executable == "":
+				executable = firstMappedFile(n.desc, wordSize, f.ByteOrder)
+			}
+		}
+	}
+	return pid, executable, nil
+}
+
+type elfNote struct {
+	name string
+	typ  uint32
+	desc []byte
+}
+
+// parseNotes parses the notes in an ELF note segment or section.
+func parseNotes(data []byte, order binary.ByteOrder) []elfNote {
+	// The sizes come from the file, so they're checked against what's left
+	// before anything is aligned or sliced.
+	align := func(n uint64) uint64 { return (n + 3) &^ 3 }
+	var notes []elfNote
+	for len(data) >= 12 {
+		namesz, descsz, typ := uint64(order.Uint32(data)), uint64(order.Uint32(data[4:])), order.Uint32(data[8:])
+		data = data[12:]
+		left := uint64(len(data))
+		if namesz > left || descsz > left || align(namesz)+align(descsz) > left {
+			break
+		}
+		name := strings.TrimRight(string(data[:namesz]), "\x00")
+		data = data[align(namesz):]
+		notes = append(notes, elfNote{name: name, typ: typ, desc: data[:descsz]})
+		data = data[align(descsz):]
+	}
+	return notes
+}
+
+// firstMappedFile returns the path of the first file in an NT_FILE note: a
+// count and page size, then the start, end and offset of each mapping, then
+// their paths. It returns "" if the note is malformed.
+func firstMappedFile(desc []byte, wordSize int, order binary.ByteOrder) string {
+	size := uint64(wordSize)
+	if uint64(len(desc)) < 2*size {
+		return ""
+	}
+	var count uint64
+	if wordSize == 4 {
+		count = uint64(order.Uint32(desc))
+	} else {
+		count = order.Uint64(desc)
+	}
+	// Every mapping takes three words, which bounds the count.
+	if count == 0 || count > uint64(len(desc))/(3*size) {
+		return ""
+	}
+	names := 2*size + count*3*size
+	if names >= uint64(len(desc)) {
+		return ""
+	}
+	return string(bytes.SplitN(desc[names:], []byte{0}, 2)[0])
+}
+
+// readBuildID returns the GNU build ID of an ELF binary, or its Go build ID if
+// it has none.
+func readBuildID(name string) (string, error) {
+	f, err := elf.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	for _, section := range []string{".note.gnu.build-id", ".note.go.buildid"} {
+		s := f.Section(section)
+		if s == nil {
+			continue
+		}
+		data, err := s.Data()
+		if err != nil {
+			return "", err
+		}
+		for _, n := range parseNotes(data, f.ByteOrder) {
+			switch {
+			case n.name == "GNU" && n.typ == ntGNUBuildID:
+				return hex.EncodeToString(n.desc), nil
+			case n.name == "Go" && n.typ == ntGoBuildID:
+				return string(n.desc), nil
+			}
+		}
+	}
+	return "", errors.New("no build ID")
+}
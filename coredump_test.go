@@ -0,0 +1,326 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+)
+
+// writeCore writes a minimal 64-bit little-endian core file, with the notes
+// giving the PID of the crashed process and its mapped files.
+func writeCore(t *testing.T, name string, pid int, files ...string) {
+	t.Helper()
+	var notes bytes.Buffer
+	note := func(typ uint32, desc []byte) {
+		binary.Write(&notes, binary.LittleEndian, []uint32{5, uint32(len(desc)), typ})
+		notes.WriteString("CORE\x00\x00\x00\x00")
+		notes.Write(desc)
+		notes.Write(make([]byte, (4-len(desc)%4)%4))
+	}
+
+	prstatus := make([]byte, 336)
+	binary.LittleEndian.PutUint32(prstatus[32:], uint32(pid))
+	note(uint32(elf.NT_PRSTATUS), prstatus)
+
+	var mapped bytes.Buffer
+	binary.Write(&mapped, binary.LittleEndian, []uint64{uint64(len(files)), 4096})
+	for i := range files {
+		binary.Write(&mapped, binary.LittleEndian, []uint64{uint64(i+1) << 20, uint64(i+2) << 20, 0})
+	}
+	for _, f := range files {
+		mapped.WriteString(f + "\x00")
+	}
+	note(ntFile, mapped.Bytes())
+
+	const headerSize, progSize = 64, 56
+	var buf bytes.Buffer
+	header := elf.Header64{
+		Type:      uint16(elf.ET_CORE),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Phoff:     headerSize,
+		Ehsize:    headerSize,
+		Phentsize: progSize,
+		Phnum:     1,
+	}
+	copy(header.Ident[:], []byte{0x7f, 'E', 'L', 'F', byte(elf.ELFCLASS64), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT)})
+	binary.Write(&buf, binary.LittleEndian, header)
+	binary.Write(&buf, binary.LittleEndian, elf.Prog64{
+		Type:   uint32(elf.PT_NOTE),
+		Off:    headerSize + progSize,
+		Filesz: uint64(notes.Len()),
+	})
+	buf.Write(notes.Bytes())
+	if err := ioutil.WriteFile(name, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadCoreInfo(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "core")
+	writeCore(t, name, 1234, "/usr/bin/crasher", "/lib/libc.so.6")
+	pid, executable, err := readCoreInfo(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid != 1234 || executable != "/usr/bin/crasher" {
+		t.Errorf("got PID %d and executable %q, want 1234 and /usr/bin/crasher", pid, executable)
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := readCoreInfo(self); err == nil {
+		t.Errorf("expected an error reading a binary as a core")
+	}
+}
+
+func TestParseNotesMalformed(t *testing.T) {
+	note := func(namesz, descsz, typ uint32, rest ...byte) []byte {
+		b := make([]byte, 12)
+		binary.LittleEndian.PutUint32(b, namesz)
+		binary.LittleEndian.PutUint32(b[4:], descsz)
+		binary.LittleEndian.PutUint32(b[8:], typ)
+		return append(b, rest...)
+	}
+	valid := note(4, 4, 1, 'G', 'N', 'U', 0, 1, 2, 3, 4)
+	for _, test := range []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"valid", valid, 1},
+		{"truncated header", valid[:8], 0},
+		{"truncated desc", valid[:len(valid)-1], 0},
+		{"namesz wrapping when aligned", note(0xfffffffe, 0, 1, 0, 0, 0, 0), 0},
+		{"descsz wrapping when aligned", note(0, 0xffffffff, 1, 0, 0, 0, 0), 0},
+		{"oversized name", note(8, 0, 1, 'G', 'N', 'U', 0), 0},
+		{"valid then oversized", append(append([]byte(nil), valid...), note(0x7fffffff, 0x7fffffff, 1)...), 1},
+	} {
+		if got := parseNotes(test.data, binary.LittleEndian); len(got) != test.want {
+			t.Errorf("%s: got %d notes, want %d", test.name, len(got), test.want)
+		}
+	}
+
+	word := func(v uint64) []byte {
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint64(b, v)
+		return b
+	}
+	for _, test := range []struct {
+		name string
+		desc []byte
+	}{
+		{"empty", nil},
+		{"no mappings", append(word(0), word(4096)...)},
+		{"count overflowing the offset", append(word(1<<62), word(4096)...)},
+		{"count exceeding the note", append(append(word(2), word(4096)...), make([]byte, 24)...)},
+	} {
+		if got := firstMappedFile(test.desc, 8, binary.LittleEndian); got != "" {
+			t.Errorf("%s: got file %q from a malformed NT_FILE note", test.name, got)
+		}
+	}
+}
+
+func TestParseCoredumpList(t *testing.T) {
+	out := []byte(`Tue 2026-10-13 10:00:00 UTC 1234 0 0 SIGSEGV present /usr/bin/foo 1.2M
+Tue 2026-10-13 10:05:00 UTC 1300 0 0 SIGABRT missing /usr/bin/bar -
+`)
+	entries, err := parseCoredumpList(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []coredumpEntry{
+		{key: "Tue 2026-10-13 10:00:00 UTC 1234", pid: 1234, executable: "/usr/bin/foo", present: true},
+		{key: "Tue 2026-10-13 10:05:00 UTC 1300", pid: 1300, executable: "/usr/bin/bar"},
+	}
+	if fmt.Sprint(entries) != fmt.Sprint(want) {
+		t.Errorf("got entries %+v, want %+v", entries, want)
+	}
+	if _, err := parseCoredumpList([]byte("No coredumps found.\n")); err == nil {
+		t.Errorf("expected an error parsing an invalid entry")
+	}
+}
+
+// waitForCore waits for a core event.
+func waitForCore(t *testing.T, events <-chan CoreEvent) *CoreDump {
+	t.Helper()
+	select {
+	case e := <-events:
+		if e.Err != nil {
+			t.Fatal(e.Err)
+		}
+		return e.Dump
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for a core dump")
+	}
+	return nil
+}
+
+// checkCore checks that a dump was saved with the binary that crashed.
+func checkCore(t *testing.T, dump *CoreDump, want CoreDump) {
+	t.Helper()
+	if dump.PID != want.PID || dump.Executable != want.Executable || dump.Source != want.Source || dump.RemotePath != want.RemotePath {
+		t.Errorf("got dump %+v, want %+v", dump, want)
+	}
+	if dump.BuildID == "" {
+		t.Errorf("got no build ID")
+	}
+	if pid, _, err := readCoreInfo(dump.Core); err != nil || pid != want.PID {
+		t.Errorf("got core for PID %d (%v), want %d", pid, err, want.PID)
+	}
+	binary, err := ioutil.ReadFile(dump.Binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	self, err := ioutil.ReadFile(want.Executable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(binary, self) {
+		t.Errorf("downloaded binary doesn't match %s", want.Executable)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dump.Dir, "coredump.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var meta CoreDump
+	if err := json.Unmarshal(data, &meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta.PID != want.PID || meta.BuildID != dump.BuildID || meta.Host != dump.Host {
+		t.Errorf("got metadata %+v, want it to match %+v", meta, dump)
+	}
+}
+
+func TestWatchCoresFiles(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	cores := filepath.Join(dir, "cores")
+	if err := os.Mkdir(cores, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	pattern := filepath.Join(dir, "core_pattern")
+	if err := ioutil.WriteFile(pattern, []byte(filepath.Join(cores, "core.%e.%p")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(old string) { corePatternPath = old }(corePatternPath)
+	corePatternPath = pattern
+
+	// Existing cores and other files are ignored.
+	writeCore(t, filepath.Join(cores, "core.old.1"), 1, self)
+	if err := ioutil.WriteFile(filepath.Join(cores, "notes"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan CoreEvent, 10)
+	w, err := client.WatchCores(ctx, CoreWatcherOptions{
+		Dir:      filepath.Join(dir, "local"),
+		Interval: 50 * time.Millisecond,
+		Events:   func(e CoreEvent) { events <- e },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	remote := filepath.Join(cores, "core.crasher.42")
+	writeCore(t, remote, 42, self, "/lib/libc.so.6")
+	dump := waitForCore(t, events)
+	checkCore(t, dump, CoreDump{Source: coreSourceFile, RemotePath: remote, PID: 42, Executable: self})
+
+	w.Stop()
+	select {
+	case e := <-events:
+		t.Errorf("got unexpected event %+v", e)
+	default:
+	}
+}
+
+func TestWatchCoresSystemd(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	pattern := filepath.Join(dir, "core_pattern")
+	if err := ioutil.WriteFile(pattern, []byte("|/lib/systemd/systemd-coredump %P %u %g %s %t %c %h\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(old string) { corePatternPath = old }(corePatternPath)
+	corePatternPath = pattern
+
+	// The fake coredumpctl lists the entries in a file, and dumps the core
+	// saved for a PID.
+	list := filepath.Join(dir, "list")
+	script := fmt.Sprintf(`#!/bin/sh
+case $* in
+*list) [ -s %[1]s ] || { echo "No coredumps found." >&2; exit 1; }; cat %[1]s ;;
+*dump*) cp %[2]s/core.$5 "$3" ;;
+esac
+`, list, dir)
+	setUpPath(t, map[string]string{"coredumpctl": script}, "sh", "cat", "cp", "uname", "mktemp", "rm")
+
+	appendEntry := func(line string) {
+		f, err := os.OpenFile(list, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		fmt.Fprintln(f, line)
+	}
+
+	// An existing entry is ignored.
+	appendEntry("Tue 2026-10-13 10:00:00 UTC 7 0 0 SIGSEGV present /usr/bin/old 1.2M")
+
+	events := make(chan CoreEvent, 10)
+	w, err := client.WatchCores(ctx, CoreWatcherOptions{
+		Dir:      filepath.Join(dir, "local"),
+		Interval: 50 * time.Millisecond,
+		Events:   func(e CoreEvent) { events <- e },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	// Entries without stored cores are skipped.
+	appendEntry(fmt.Sprintf("Tue 2026-10-13 10:01:00 UTC 8 0 0 SIGSEGV missing %s -", self))
+	writeCore(t, filepath.Join(dir, "core.99"), 99, self)
+	appendEntry(fmt.Sprintf("Tue 2026-10-13 10:02:00 UTC 99 0 0 SIGABRT present %s 1.2M", self))
+	dump := waitForCore(t, events)
+	checkCore(t, dump, CoreDump{Source: coreSourceSystemd, PID: 99, Executable: self})
+
+	// The temporary directory on the remote is removed.
+	out, err := client.ShellOutput(ctx, `for d in "${TMPDIR:-/tmp}"/sshutil-core.*; do [ -e "$d" ] && echo "$d"; done; exit 0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 0 {
+		t.Errorf("remote core directories weren't removed: %s", out)
+	}
+}
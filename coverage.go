@@ -0,0 +1,137 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.fuchsia.dev/fuchsia/tools/lib/logger"
+)
+
+// CoverageCollector gathers the coverage data that binaries built with
+// `go build -cover` write to $GOCOVERDIR on remotes into a local directory.
+// It may be shared by many Clients, and used for many runs.
+//
+// Go names meta-data files by the hash of their contents and counter files
+// by the process and time that wrote them, so files from different runs can
+// share a directory, which `go tool covdata` then reads as the merged
+// coverage of all of them.
+type CoverageCollector struct {
+	// Dir is the local coverage directory.
+	Dir string
+
+	mu sync.Mutex
+}
+
+// NewCoverageCollector returns a collector writing to the local directory
+// dir, creating it if needed.
+func NewCoverageCollector(dir string) (*CoverageCollector, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &CoverageCollector{Dir: dir}, nil
+}
+
+// Run runs a command to completion on the remote like Run, with GOCOVERDIR
+// set to a temporary directory, and collects the coverage data written there
+// even if the command fails.
+func (cc *CoverageCollector) Run(ctx context.Context, c *Client, command []string, stdout io.Writer, stderr io.Writer) error {
+	dir, err := c.tempDir(ctx, "sshutil-cover")
+	if err != nil {
+		return err
+	}
+	defer cc.removeRemote(ctx, c, dir)
+
+	cmd := append([]string{"export", "GOCOVERDIR=" + ShellQuote(dir) + ";"}, command...)
+	runErr := c.Run(ctx, cmd, stdout, stderr)
+	if err := cc.collect(ctx, c, dir); err != nil {
+		return err
+	}
+	return runErr
+}
+
+func (cc *CoverageCollector) removeRemote(ctx context.Context, c *Client, dir string) {
+	if _, err := c.Output(ctx, "rm", "-rf", dir); err != nil {
+		logger.Warningf(ctx, "failed to remove %s: %v", dir, err)
+	}
+}
+
+// collect downloads the coverage files in the remote directory dir.
+func (cc *CoverageCollector) collect(ctx context.Context, c *Client, dir string) error {
+	f, err := c.NewFileClient()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	entries, err := f.SFTP().ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !e.Mode().IsRegular() {
+			continue
+		}
+		data, err := f.ReadFile(path.Join(dir, e.Name()))
+		if err != nil {
+			return err
+		}
+		if err := cc.add(e.Name(), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add writes a coverage file to the local directory. The caller must hold the
+// lock.
+func (cc *CoverageCollector) add(name string, data []byte) error {
+	for {
+		existing, err := ioutil.ReadFile(filepath.Join(cc.Dir, name))
+		if os.IsNotExist(err) {
+			return writeLocalFile(filepath.Join(cc.Dir, name), bytes.NewReader(data), 0o644)
+		} else if err != nil {
+			return err
+		}
+		if bytes.Equal(existing, data) {
+			// Meta-data files for the same binary are identical.
+			return nil
+		}
+		// Counter files from processes on different hosts can share a PID
+		// and time, so bump the time to find a free name.
+		next, err := nextCounterFileName(name)
+		if err != nil {
+			return err
+		}
+		name = next
+	}
+}
+
+// nextCounterFileName returns the name of a counter file written a
+// nanosecond later than the file name, which is of the form
+// covcounters.<hash>.<pid>.<nanotime>.
+func nextCounterFileName(name string) (string, error) {
+	i := strings.LastIndexByte(name, '.')
+	t, err := strconv.ParseUint(name[i+1:], 10, 64)
+	if !strings.HasPrefix(name, "covcounters.") || i < 0 || err != nil {
+		return "", fmt.Errorf("conflicting coverage file %s", name)
+	}
+	return fmt.Sprintf("%s.%d", name[:i], t+1), nil
+}
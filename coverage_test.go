@@ -0,0 +1,116 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+)
+
+func TestCoverageCollector(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+	other, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+
+	cc, err := NewCoverageCollector(filepath.Join(t.TempDir(), "cover"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each run writes the same meta-data file and a counter file whose name
+	// collides with the other run's.
+	write := func(counters string) []string {
+		return []string{"cd", "$GOCOVERDIR", "&&", "echo", "meta", ">", "covmeta.abc",
+			"&&", "echo", counters, ">", "covcounters.abc.42.1000", "&&", "false"}
+	}
+	if err := cc.Run(ctx, client, write("first"), nil, nil); err == nil {
+		t.Errorf("expected the command's failure to be returned")
+	}
+	if err := cc.Run(ctx, other, write("second"), nil, nil); err == nil {
+		t.Errorf("expected the command's failure to be returned")
+	}
+	// The same counters again are only kept once.
+	if err := cc.Run(ctx, other, write("second"), nil, nil); err == nil {
+		t.Errorf("expected the command's failure to be returned")
+	}
+
+	files := map[string]string{}
+	entries, err := ioutil.ReadDir(cc.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		data, err := ioutil.ReadFile(filepath.Join(cc.Dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[e.Name()] = strings.TrimSpace(string(data))
+	}
+	want := map[string]string{
+		"covmeta.abc":             "meta",
+		"covcounters.abc.42.1000": "first",
+		"covcounters.abc.42.1001": "second",
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("got files %v, want %v", files, want)
+	}
+
+	// The remote directories are removed.
+	out, err := client.ShellOutput(ctx, `ls -d "${TMPDIR:-/tmp}"/sshutil-cover.* 2>/dev/null; exit 0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 0 {
+		t.Errorf("remote coverage directories weren't removed: %s", out)
+	}
+}
+
+func TestRunGoTestCoverage(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "fake.test")
+	script := `#!/bin/sh
+for arg; do
+	case $arg in -test.gocoverdir=*) echo counters > "${arg#*=}/covcounters.abc.1.1" ;; esac
+done
+echo meta > "$GOCOVERDIR/covmeta.abc"
+echo PASS
+`
+	if err := ioutil.WriteFile(binary, []byte(script), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cc, err := NewCoverageCollector(filepath.Join(dir, "cover"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := client.RunGoTest(ctx, binary, GoTestOptions{Coverage: cc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Passed {
+		t.Errorf("got result %+v, want a pass", result)
+	}
+	entries, err := ioutil.ReadDir(cc.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	if want := []string{"covcounters.abc.1.1", "covmeta.abc"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got files %q, want %q", names, want)
+	}
+}
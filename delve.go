@@ -0,0 +1,229 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.fuchsia.dev/fuchsia/tools/lib/logger"
+)
+
+// MissingToolError is returned when a command needed on the remote isn't
+// installed, and no copy of it was given to upload.
+type MissingToolError struct {
+	Tool string
+}
+
+func (e MissingToolError) Error() string {
+	return fmt.Sprintf("%s is not installed on the remote", e.Tool)
+}
+
+// DelveOptions configure StartDelve. Exactly one of PID and Binary must be
+// set.
+type DelveOptions struct {
+	// PID is the remote process to attach to.
+	PID int
+
+	// Binary is the remote path of a program to start under the debugger,
+	// with Args.
+	Binary string
+	Args   []string
+
+	// Dlv is the local path of a dlv binary for the remote's architecture,
+	// uploaded to a temporary directory if dlv isn't on the remote's PATH.
+	Dlv string
+}
+
+// DelveSession is a headless Delve server on the remote, reachable through a
+// local port.
+type DelveSession struct {
+	// Addr is the local address to point a Delve client at, as in
+	// `dlv connect <Addr>`.
+	Addr string
+
+	client  *Client
+	session *Session
+	forward *Forward
+	pid     int
+	tempDir string
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// delveScript prints the shell's PID, which dlv inherits, then runs dlv.
+const delveScript = `echo $$; exec "$@"`
+
+// delveListeningPrefix starts the line dlv prints once its server is ready.
+const delveListeningPrefix = "API server listening at: "
+
+// StartDelve starts a headless Delve server on the remote's loopback
+// interface, attached to a process or running a binary, and forwards a local
+// port to it. The server accepts multiple clients, and runs until Close.
+func (c *Client) StartDelve(ctx context.Context, opts DelveOptions) (*DelveSession, error) {
+	if (opts.PID == 0) == (opts.Binary == "") {
+		return nil, errors.New("exactly one of a PID and a binary must be given")
+	}
+
+	d := &DelveSession{client: c}
+	dlv, err := d.findDelve(ctx, opts.Dlv)
+	if err != nil {
+		return nil, err
+	}
+
+	command := []string{"sh", "-c", delveScript, "sh", dlv}
+	if opts.PID != 0 {
+		command = append(command, "attach", strconv.Itoa(opts.PID))
+	} else {
+		command = append(command, "exec", opts.Binary)
+	}
+	command = append(command, "--headless", "--listen=127.0.0.1:0", "--api-version=2", "--accept-multiclient")
+	if len(opts.Args) > 0 {
+		command = append(command, "--")
+		command = append(command, opts.Args...)
+	}
+
+	// Only the first lines matter, so later ones are dropped.
+	lines := make(chan string, 16)
+	stdout := &lineWriter{line: func(line string) {
+		select {
+		case lines <- strings.TrimSpace(line):
+		default:
+		}
+	}}
+	var stderr syncBuffer
+	session, err := c.Start(ctx, shellQuoteArgs(command), stdout, &stderr)
+	if err != nil {
+		d.Close()
+		return nil, err
+	}
+	d.session = session
+	exited := make(chan error, 1)
+	go func() {
+		exited <- session.Wait(context.Background())
+	}()
+
+	remoteAddr, err := d.waitForServer(ctx, lines, exited)
+	if err != nil {
+		d.Close()
+		return nil, fmt.Errorf("failed to start dlv: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	d.forward, err = c.ForwardLocal(ctx, "127.0.0.1:0", remoteAddr)
+	if err != nil {
+		d.Close()
+		return nil, err
+	}
+	d.Addr = d.forward.Addr().String()
+	return d, nil
+}
+
+// findDelve returns the remote path of dlv, uploading it if it's missing.
+func (d *DelveSession) findDelve(ctx context.Context, local string) (string, error) {
+	c := d.client
+	out, err := c.ShellOutput(ctx, "command -v dlv || exit 0")
+	if err != nil {
+		return "", err
+	}
+	if dlv := strings.TrimSpace(string(out)); dlv != "" {
+		return dlv, nil
+	}
+	if local == "" {
+		return "", MissingToolError{Tool: "dlv"}
+	}
+
+	if d.tempDir, err = c.tempDir(ctx, "sshutil-dlv"); err != nil {
+		return "", err
+	}
+	f, err := c.NewFileClient()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	dlv := path.Join(d.tempDir, "dlv")
+	if err := f.Upload(ctx, local, dlv, TransferOptions{FileChmod: ChmodMask{Set: 0o700}}); err != nil {
+		return "", err
+	}
+	return dlv, nil
+}
+
+// waitForServer reads the PID of dlv and the address of its server from the
+// lines of its output.
+func (d *DelveSession) waitForServer(ctx context.Context, lines <-chan string, exited <-chan error) (string, error) {
+	for {
+		select {
+		case line := <-lines:
+			if d.pid == 0 {
+				pid, err := strconv.Atoi(line)
+				if err != nil {
+					return "", fmt.Errorf("unexpected output %q", line)
+				}
+				d.pid = pid
+			} else if strings.HasPrefix(line, delveListeningPrefix) {
+				return strings.TrimPrefix(line, delveListeningPrefix), nil
+			}
+		case err := <-exited:
+			if err == nil {
+				err = errors.New("dlv exited")
+			}
+			return "", err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// Close stops the Delve server, which detaches from a process it attached to
+// and kills one it started, closes the forwarded port, and removes dlv if it
+// was uploaded.
+func (d *DelveSession) Close() error {
+	d.closeOnce.Do(func() {
+		ctx := context.Background()
+		if d.forward != nil {
+			d.forward.Close()
+		}
+		if d.pid != 0 {
+			// A headless dlv shuts down cleanly on an interrupt. The kill
+			// fails if it has already exited, which is fine.
+			if _, err := d.client.Output(ctx, "kill", "-INT", strconv.Itoa(d.pid)); err != nil {
+				logger.Debugf(ctx, "failed to kill dlv: %v", err)
+			}
+		}
+		if d.session != nil {
+			d.session.Close()
+		}
+		if d.tempDir != "" {
+			if _, err := d.client.Output(ctx, "rm", "-rf", d.tempDir); err != nil {
+				d.closeErr = err
+			}
+		}
+	})
+	return d.closeErr
+}
+
+// syncBuffer is a bytes.Buffer that may be written and read concurrently.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
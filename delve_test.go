@@ -0,0 +1,117 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+)
+
+func TestStartDelve(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+
+	// The fake dlv pretends its server is the echo server.
+	echo := startEchoServer(t)
+	dir := t.TempDir()
+	fakeDlv := filepath.Join(dir, "fake-dlv")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$*\" > %s/args\necho '%s%s'\nexec sleep 60\n", dir, delveListeningPrefix, echo.Addr())
+	if err := ioutil.WriteFile(fakeDlv, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	bin := setUpPath(t, nil, "sh", "sleep", "mktemp", "rm", "sync", "mv")
+
+	// Check that Close stops dlv.
+	checkStopped := func(d *DelveSession) {
+		t.Helper()
+		if err := d.Close(); err != nil {
+			t.Fatal(err)
+		}
+		// Wait for the process to be reaped.
+		stopped := false
+		for i := 0; i < 50 && !stopped; i++ {
+			_, err := client.Output(ctx, "sh", "-c", fmt.Sprintf("kill -0 %d || exit 0; sleep 0.1; exit 1", d.pid))
+			stopped = err == nil
+		}
+		if !stopped {
+			t.Errorf("dlv is still running after Close")
+		}
+		if _, err := net.Dial("tcp", d.Addr); err == nil {
+			t.Errorf("the forwarded port is still open after Close")
+		}
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := client.StartDelve(ctx, DelveOptions{PID: 1})
+		var missing MissingToolError
+		if !errors.As(err, &missing) || missing.Tool != "dlv" {
+			t.Errorf("got error %v, want a MissingToolError", err)
+		}
+	})
+
+	t.Run("uploaded", func(t *testing.T) {
+		d, err := client.StartDelve(ctx, DelveOptions{Binary: "/bin/prog", Args: []string{"-v"}, Dlv: fakeDlv})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkEcho(t, d.Addr)
+		args, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "exec /bin/prog --headless --listen=127.0.0.1:0 --api-version=2 --accept-multiclient -- -v"
+		if got := strings.TrimSpace(string(args)); got != want {
+			t.Errorf("got args %q, want %q", got, want)
+		}
+		checkStopped(d)
+		if _, err := os.Stat(d.tempDir); !os.IsNotExist(err) {
+			t.Errorf("uploaded dlv wasn't removed: %v", err)
+		}
+	})
+
+	t.Run("installed", func(t *testing.T) {
+		if err := os.Symlink(fakeDlv, filepath.Join(bin, "dlv")); err != nil {
+			t.Fatal(err)
+		}
+		d, err := client.StartDelve(ctx, DelveOptions{PID: 1234})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.tempDir != "" {
+			t.Errorf("dlv was uploaded though it's installed")
+		}
+		checkEcho(t, d.Addr)
+		checkEcho(t, d.Addr)
+		args, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(args), "attach 1234 --headless") {
+			t.Errorf("got args %q, want to attach to 1234", args)
+		}
+		checkStopped(d)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		failing := filepath.Join(dir, "failing-dlv")
+		if err := ioutil.WriteFile(failing, []byte("#!/bin/sh\necho 'could not attach' >&2\nexit 1\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(filepath.Join(bin, "dlv"))
+		_, err := client.StartDelve(ctx, DelveOptions{PID: 1, Dlv: failing})
+		if err == nil || !strings.Contains(err.Error(), "could not attach") {
+			t.Errorf("got error %v, want dlv's error", err)
+		}
+	})
+}
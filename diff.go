@@ -0,0 +1,522 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Number of unchanged lines shown around each change in a unified diff.
+const diffContextLines = 3
+
+// How many lines may be inserted or deleted in a text file before it's
+// compared by hash instead, bounding the cost of diffing it.
+const maxDiffEdits = 2000
+
+// How much of a file is inspected when deciding if it is binary. This matches
+// the heuristic used by git.
+const binarySniffLength = 8000
+
+// DiffStatus describes how a file differs between the local machine and the
+// remote.
+type DiffStatus string
+
+const (
+	// DiffAdded means the file only exists locally.
+	DiffAdded DiffStatus = "added"
+
+	// DiffRemoved means the file only exists on the remote.
+	DiffRemoved DiffStatus = "removed"
+
+	// DiffModified means the file exists on both sides with different
+	// contents.
+	DiffModified DiffStatus = "modified"
+)
+
+// FileDiff describes a single file that differs between the local machine and
+// the remote. The remote is treated as the old side and local content as the
+// new side, so the diff shows what overwriting the remote would change.
+type FileDiff struct {
+	// Slash-separated path relative to the root of the compared trees, or ""
+	// when comparing single files.
+	Path   string
+	Status DiffStatus
+
+	// Binary files are compared by hash rather than by line, in which case
+	// Unified is empty. So are text files with too many changes to diff, for
+	// which TooManyChanges is set. Hashes are only set for files compared by
+	// hash.
+	Binary         bool
+	TooManyChanges bool
+	LocalSHA256    string
+	RemoteSHA256   string
+
+	// Unified is the unified diff of a text file, including headers.
+	Unified string
+}
+
+// DiffResult is the result of comparing local content with the remote.
+type DiffResult struct {
+	// Files that differ. Identical files are omitted.
+	Files []FileDiff
+}
+
+// Changed reports whether any file differs.
+func (d *DiffResult) Changed() bool {
+	return len(d.Files) > 0
+}
+
+// String returns all the differences in unified diff format.
+func (d *DiffResult) String() string {
+	var b strings.Builder
+	for _, f := range d.Files {
+		switch {
+		case f.Binary:
+			fmt.Fprintf(&b, "Binary files %s differ (remote sha256 %s, local sha256 %s)\n",
+				displayPath(f.Path), displayHash(f.RemoteSHA256), displayHash(f.LocalSHA256))
+			continue
+		case f.TooManyChanges:
+			fmt.Fprintf(&b, "Files %s differ by too many lines to diff (remote sha256 %s, local sha256 %s)\n",
+				displayPath(f.Path), displayHash(f.RemoteSHA256), displayHash(f.LocalSHA256))
+			continue
+		}
+		b.WriteString(f.Unified)
+	}
+	return b.String()
+}
+
+func displayPath(p string) string {
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+func displayHash(h string) string {
+	if h == "" {
+		return "(none)"
+	}
+	return h
+}
+
+// Diff compares the local file or directory tree at localPath with remotePath
+// on the remote. Directory trees are compared file by file, with hashes
+// computed on the remote so that only files that differ are fetched.
+// Symlinks are compared by their targets.
+func (c *Client) Diff(ctx context.Context, localPath, remotePath string) (*DiffResult, error) {
+	fi, err := os.Lstat(localPath)
+	if err != nil {
+		return nil, err
+	}
+	f, err := c.NewFileClient()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if fi.IsDir() {
+		return f.diffTree(ctx, localPath, remotePath)
+	}
+	local, err := readLocalContent(localPath, fi)
+	if err != nil {
+		return nil, err
+	}
+	return f.diffFile(localPath, local, remotePath)
+}
+
+// DiffBytes compares data with the contents of the file at remotePath on the
+// remote.
+func (c *Client) DiffBytes(ctx context.Context, data []byte, remotePath string) (*DiffResult, error) {
+	f, err := c.NewFileClient()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.diffFile("(local)", data, remotePath)
+}
+
+// DiffContents compares data with remote, the contents of the file at
+// remotePath on the remote, for callers that have already read it. If exists
+// is false, the file is taken not to exist.
+func DiffContents(data []byte, remotePath string, remote []byte, exists bool) *DiffResult {
+	result := &DiffResult{}
+	if fd := compareContent("", remotePath, "(local)", remote, exists, data, true); fd != nil {
+		result.Files = append(result.Files, *fd)
+	}
+	return result
+}
+
+// readLocalContent returns the contents of a file, or the target of a symlink.
+func readLocalContent(name string, fi os.FileInfo) ([]byte, error) {
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(name)
+		return []byte(symlinkContent(target)), err
+	}
+	return os.ReadFile(name)
+}
+
+func symlinkContent(target string) string {
+	return "symlink -> " + target + "\n"
+}
+
+// readRemoteContent returns the contents of a remote file, or the target of a
+// symlink. A missing file returns nil content and false.
+func (f *FileClient) readRemoteContent(name string) ([]byte, bool, error) {
+	fi, err := f.sftp.Lstat(name)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := f.sftp.ReadLink(name)
+		return []byte(symlinkContent(target)), true, err
+	}
+	data, err := f.ReadFile(name)
+	return data, true, err
+}
+
+func (f *FileClient) diffFile(localName string, local []byte, remoteName string) (*DiffResult, error) {
+	remote, exists, err := f.readRemoteContent(remoteName)
+	if err != nil {
+		return nil, err
+	}
+	result := &DiffResult{}
+	if fd := compareContent("", remoteName, localName, remote, exists, local, true); fd != nil {
+		result.Files = append(result.Files, *fd)
+	}
+	return result, nil
+}
+
+func (f *FileClient) diffTree(ctx context.Context, localRoot, remoteRoot string) (*DiffResult, error) {
+	local, err := walkTree(localFS{}, localRoot, SymlinkCopy)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := walkTree(f.sftp, remoteRoot, SymlinkCopy)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var remoteHashes map[string]string
+	if len(remote) > 0 {
+		if remoteHashes, err = f.client.sha256Tree(ctx, remoteRoot); err != nil {
+			return nil, err
+		}
+	}
+
+	// Directories have no content to compare, so only keep files and links.
+	paths := map[string]bool{}
+	localByPath := map[string]transferEntry{}
+	for _, e := range local {
+		if !e.isDir() {
+			localByPath[e.rel] = e
+			paths[e.rel] = true
+		}
+	}
+	remoteByPath := map[string]transferEntry{}
+	for _, e := range remote {
+		if !e.isDir() {
+			remoteByPath[e.rel] = e
+			paths[e.rel] = true
+		}
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	result := &DiffResult{}
+	for _, rel := range sorted {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		l, inLocal := localByPath[rel]
+		r, inRemote := remoteByPath[rel]
+
+		var localData []byte
+		if inLocal {
+			fi, err := os.Lstat(localPath(localRoot, rel))
+			if err != nil {
+				return nil, err
+			}
+			if localData, err = readLocalContent(localPath(localRoot, rel), fi); err != nil {
+				return nil, err
+			}
+		}
+
+		// Skip fetching files whose hashes already match.
+		if inLocal && inRemote && l.isRegular() && r.isRegular() {
+			if sum := sha256.Sum256(localData); hex.EncodeToString(sum[:]) == remoteHashes[rel] {
+				continue
+			}
+		}
+
+		var remoteData []byte
+		if inRemote {
+			if remoteData, _, err = f.readRemoteContent(joinRel(remoteRoot, rel)); err != nil {
+				return nil, err
+			}
+		}
+		fd := compareContent(rel, joinRel(remoteRoot, rel), localPath(localRoot, rel), remoteData, inRemote, localData, inLocal)
+		if fd != nil {
+			result.Files = append(result.Files, *fd)
+		}
+	}
+	return result, nil
+}
+
+// compareContent returns how the local content differs from the remote, or
+// nil if they're identical.
+func compareContent(rel, remoteName, localName string, remote []byte, inRemote bool, local []byte, inLocal bool) *FileDiff {
+	fd := &FileDiff{Path: rel, Status: DiffModified}
+	switch {
+	case !inRemote:
+		fd.Status = DiffAdded
+		remoteName = "/dev/null"
+	case !inLocal:
+		fd.Status = DiffRemoved
+		localName = "/dev/null"
+	case bytes.Equal(local, remote):
+		return nil
+	}
+
+	if isBinary(local) || isBinary(remote) {
+		fd.Binary = true
+	} else {
+		unified, ok := unifiedDiff(filepath.ToSlash(remoteName), filepath.ToSlash(localName), splitLines(string(remote)), splitLines(string(local)))
+		if ok {
+			fd.Unified = unified
+			return fd
+		}
+		fd.TooManyChanges = true
+	}
+	if inLocal {
+		sum := sha256.Sum256(local)
+		fd.LocalSHA256 = hex.EncodeToString(sum[:])
+	}
+	if inRemote {
+		sum := sha256.Sum256(remote)
+		fd.RemoteSHA256 = hex.EncodeToString(sum[:])
+	}
+	return fd
+}
+
+// isBinary guesses whether data is binary by looking for a NUL byte near the
+// start.
+func isBinary(data []byte) bool {
+	if len(data) > binarySniffLength {
+		data = data[:binarySniffLength]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// splitLines splits s into lines, keeping the trailing newline on each line so
+// that a missing newline at the end of the file counts as a difference.
+func splitLines(s string) []string {
+	var lines []string
+	for s != "" {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			lines = append(lines, s)
+			break
+		}
+		lines = append(lines, s[:i+1])
+		s = s[i+1:]
+	}
+	return lines
+}
+
+type diffOpKind byte
+
+const (
+	diffEqual  diffOpKind = ' '
+	diffDelete diffOpKind = '-'
+	diffInsert diffOpKind = '+'
+)
+
+type diffOp struct {
+	kind diffOpKind
+	line string
+}
+
+// diffLines returns an edit script turning a into b, using the Myers
+// algorithm. It gives up, returning false, when more than maxDiffEdits lines
+// must be inserted or deleted, which bounds its time by O((N+M)·D) and its
+// memory by O(D²) for a distance D under the limit.
+func diffLines(a, b []string) ([]diffOp, bool) {
+	n, m := len(a), len(b)
+	max := n + m
+	if max > maxDiffEdits {
+		max = maxDiffEdits
+	}
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// trace[d] holds the diagonals -(d-1) to d-1 of v before step d, which
+	// are the only ones backtracking through that step reads.
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		if d == 0 {
+			trace = append(trace, nil)
+		} else {
+			trace = append(trace, append([]int(nil), v[offset-d+1:offset+d]...))
+		}
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrackDiff(a, b, trace), true
+			}
+		}
+	}
+	return nil, false
+}
+
+func backtrackDiff(a, b []string, trace [][]int) []diffOp {
+	var ops []diffOp
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		snapshot := trace[d]
+		// v returns the furthest x reached on diagonal k before step d. At
+		// step 0 nothing has been reached yet.
+		v := func(k int) int {
+			if i := k + d - 1; i >= 0 && i < len(snapshot) {
+				return snapshot[i]
+			}
+			return 0
+		}
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v(k-1) < v(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{diffEqual, a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, diffOp{diffInsert, b[y-1]})
+				y--
+			} else {
+				ops = append(ops, diffOp{diffDelete, a[x-1]})
+				x--
+			}
+		}
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// unifiedDiff formats the differences between a and b as a unified diff, or
+// returns "" if there are none. It returns false if they differ by too much
+// to diff.
+func unifiedDiff(aName, bName string, a, b []string) (string, bool) {
+	ops, ok := diffLines(a, b)
+	if !ok {
+		return "", false
+	}
+
+	var out strings.Builder
+	// Walk the edit script, tracking line numbers on both sides, and emit a
+	// hunk for each group of changes within 2*context lines of each other.
+	for start := 0; start < len(ops); {
+		// Find the next change.
+		first := start
+		for first < len(ops) && ops[first].kind == diffEqual {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		// Extend the hunk until there's a long enough run of equal lines.
+		last := first
+		for i := first; i < len(ops); i++ {
+			if ops[i].kind != diffEqual {
+				last = i
+			} else if i-last > 2*diffContextLines {
+				break
+			}
+		}
+		hunkStart := first - diffContextLines
+		if hunkStart < 0 {
+			hunkStart = 0
+		}
+		hunkEnd := last + diffContextLines + 1
+		if hunkEnd > len(ops) {
+			hunkEnd = len(ops)
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+		}
+		aLine, bLine := 1, 1
+		for _, op := range ops[:hunkStart] {
+			if op.kind != diffInsert {
+				aLine++
+			}
+			if op.kind != diffDelete {
+				bLine++
+			}
+		}
+		var aCount, bCount int
+		for _, op := range ops[hunkStart:hunkEnd] {
+			if op.kind != diffInsert {
+				aCount++
+			}
+			if op.kind != diffDelete {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aLine, aCount), hunkRange(bLine, bCount))
+		for _, op := range ops[hunkStart:hunkEnd] {
+			out.WriteByte(byte(op.kind))
+			out.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = hunkEnd
+	}
+	return out.String(), true
+}
+
+// hunkRange formats the line range of one side of a hunk. An empty range is
+// numbered from the line before it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
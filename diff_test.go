@@ -0,0 +1,270 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "identical",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: "",
+		},
+		{
+			name: "single change with context",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n",
+			b:    "1\n2\n3\n4\nfive\n6\n7\n8\n",
+			want: `--- old
++++ new
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+`,
+		},
+		{
+			name: "separate hunks",
+			a:    "a\n1\n2\n3\n4\n5\n6\n7\n8\nb\n",
+			b:    "A\n1\n2\n3\n4\n5\n6\n7\n8\nB\n",
+			want: `--- old
++++ new
+@@ -1,4 +1,4 @@
+-a
++A
+ 1
+ 2
+ 3
+@@ -7,4 +7,4 @@
+ 6
+ 7
+ 8
+-b
++B
+`,
+		},
+		{
+			name: "from empty",
+			a:    "",
+			b:    "x\ny\n",
+			want: `--- old
++++ new
+@@ -0,0 +1,2 @@
++x
++y
+`,
+		},
+		{
+			name: "missing newline at end of file",
+			a:    "x\ny",
+			b:    "x\ny\n",
+			want: `--- old
++++ new
+@@ -1,2 +1,2 @@
+ x
+-y
+\ No newline at end of file
++y
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := unifiedDiff("old", "new", splitLines(tc.a), splitLines(tc.b))
+			if !ok || got != tc.want {
+				t.Errorf("unexpected diff:\ngot:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+
+	t.Run("single file", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTree(t, dir, map[string]string{
+			"local":  "PermitRootLogin no\nPort 22\n",
+			"remote": "PermitRootLogin yes\nPort 22\n",
+		})
+		result, err := client.Diff(ctx, filepath.Join(dir, "local"), filepath.Join(dir, "remote"))
+		if err != nil {
+			t.Fatalf("Diff failed: %v", err)
+		}
+		if !result.Changed() || !strings.Contains(result.String(), "-PermitRootLogin yes\n+PermitRootLogin no\n") {
+			t.Errorf("unexpected diff: %q", result.String())
+		}
+
+		result, err = client.DiffBytes(ctx, []byte("PermitRootLogin yes\nPort 22\n"), filepath.Join(dir, "remote"))
+		if err != nil {
+			t.Fatalf("DiffBytes failed: %v", err)
+		}
+		if result.Changed() {
+			t.Errorf("expected identical content to have no diff, got %q", result.String())
+		}
+
+		result, err = client.DiffBytes(ctx, []byte("new\n"), filepath.Join(dir, "missing"))
+		if err != nil {
+			t.Fatalf("DiffBytes failed: %v", err)
+		}
+		if len(result.Files) != 1 || result.Files[0].Status != DiffAdded {
+			t.Errorf("expected a missing remote file to be reported as added, got %+v", result.Files)
+		}
+
+		// Content that was already read is compared without reading it again.
+		result = DiffContents([]byte("new\n"), "/etc/motd", []byte("old\n"), true)
+		if len(result.Files) != 1 || result.Files[0].Status != DiffModified || !strings.Contains(result.Files[0].Unified, "-old\n+new\n") {
+			t.Errorf("expected the contents to differ, got %+v", result.Files)
+		}
+		if result := DiffContents([]byte("new\n"), "/etc/motd", nil, false); len(result.Files) != 1 || result.Files[0].Status != DiffAdded {
+			t.Errorf("expected a missing file to be reported as added, got %+v", result.Files)
+		}
+	})
+
+	t.Run("trees", func(t *testing.T) {
+		local := t.TempDir()
+		remote := t.TempDir()
+		writeTree(t, local, map[string]string{
+			"same.txt":    "same\n",
+			"changed.txt": "new\n",
+			"added.txt":   "added\n",
+			"bin":         "\x00\x01new",
+		})
+		writeTree(t, remote, map[string]string{
+			"same.txt":    "same\n",
+			"changed.txt": "old\n",
+			"removed.txt": "removed\n",
+			"bin":         "\x00\x01old",
+		})
+		if err := os.Symlink("same.txt", filepath.Join(local, "link")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink("changed.txt", filepath.Join(remote, "link")); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := client.Diff(ctx, local, remote)
+		if err != nil {
+			t.Fatalf("Diff failed: %v", err)
+		}
+		got := map[string]FileDiff{}
+		for _, fd := range result.Files {
+			got[fd.Path] = fd
+		}
+		for path, status := range map[string]DiffStatus{
+			"changed.txt": DiffModified,
+			"added.txt":   DiffAdded,
+			"removed.txt": DiffRemoved,
+			"bin":         DiffModified,
+			"link":        DiffModified,
+		} {
+			if got[path].Status != status {
+				t.Errorf("expected %s to be %s, got %+v", path, status, got[path])
+			}
+		}
+		if _, ok := got["same.txt"]; ok {
+			t.Errorf("identical files should not be reported")
+		}
+		if bin := got["bin"]; !bin.Binary || bin.LocalSHA256 == "" || bin.LocalSHA256 == bin.RemoteSHA256 {
+			t.Errorf("expected binary file to be compared by hash, got %+v", bin)
+		}
+		if !strings.Contains(got["link"].Unified, "+symlink -> same.txt") {
+			t.Errorf("expected symlink targets to be diffed, got %q", got["link"].Unified)
+		}
+	})
+}
+
+func TestIsBinary(t *testing.T) {
+	if isBinary([]byte("plain text\n")) {
+		t.Errorf("text should not be detected as binary")
+	}
+	if !isBinary([]byte("ELF\x00\x00")) {
+		t.Errorf("data with NUL bytes should be detected as binary")
+	}
+	if isBinary(append([]byte(strings.Repeat("a", binarySniffLength)), 0)) {
+		t.Errorf("only the start of the data should be inspected")
+	}
+}
+
+func TestDiffLinesReconstructsInputs(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, r.Intn(20))
+		for i := range lines {
+			lines[i] = string(rune('a'+r.Intn(4))) + "\n"
+		}
+		return lines
+	}
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+		var gotA, gotB []string
+		ops, ok := diffLines(a, b)
+		if !ok {
+			t.Fatalf("failed to diff %q -> %q", a, b)
+		}
+		for _, op := range ops {
+			if op.kind != diffInsert {
+				gotA = append(gotA, op.line)
+			}
+			if op.kind != diffDelete {
+				gotB = append(gotB, op.line)
+			}
+		}
+		if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+			t.Fatalf("edit script for %q -> %q does not reconstruct its inputs", a, b)
+		}
+	}
+}
+
+func TestDiffTooManyChanges(t *testing.T) {
+	var a, b []string
+	for i := 0; i < maxDiffEdits; i++ {
+		a = append(a, fmt.Sprintf("old %d\n", i))
+		b = append(b, fmt.Sprintf("new %d\n", i))
+	}
+	// Files of the same size can take half as many changes in each.
+	half := maxDiffEdits / 2
+	if _, ok := diffLines(a[:half], b[:half]); !ok {
+		t.Errorf("failed to diff %d changed lines", half)
+	}
+	if _, ok := diffLines(a[:half+1], b[:half]); ok {
+		t.Errorf("expected diffing more than %d changes to give up", maxDiffEdits)
+	}
+
+	// Lines in common don't count towards the limit.
+	same := append(append([]string(nil), a...), "changed\n")
+	if ops, ok := diffLines(a, same); !ok || len(ops) != len(same) {
+		t.Errorf("got %d ops, %t for a single inserted line", len(ops), ok)
+	}
+
+	fd := compareContent("f", "remote", "local", []byte(strings.Join(a, "")), true, []byte(strings.Join(b, "")), true)
+	if !fd.TooManyChanges || fd.Unified != "" || fd.LocalSHA256 == "" || fd.LocalSHA256 == fd.RemoteSHA256 {
+		t.Errorf("expected the file to be compared by hash, got %+v", fd)
+	}
+	result := DiffResult{Files: []FileDiff{*fd}}
+	if got := result.String(); !strings.HasPrefix(got, "Files f differ by too many lines to diff") {
+		t.Errorf("got %q", got)
+	}
+}
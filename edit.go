@@ -0,0 +1,362 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Format of the timestamp added to the name of backups made before editing a
+// file.
+const backupTimeFormat = "20060102T150405.000000000"
+
+// DefaultBlockMarker is the marker used to delimit blocks managed by
+// EnsureBlock when BlockEdit.Marker is empty.
+const DefaultBlockMarker = "# {mark} MANAGED BLOCK"
+
+// EditOptions controls how edits are written back to the remote.
+type EditOptions struct {
+	// Create the file if it doesn't exist, rather than failing.
+	Create bool
+
+	// Mode of the file if it is created.
+	CreateMode os.FileMode
+
+	// SkipBackup disables copying the original file aside before it is
+	// modified.
+	SkipBackup bool
+
+	// DryRun computes the result of the edit without writing anything.
+	DryRun bool
+}
+
+// EditResult describes the outcome of an edit.
+type EditResult struct {
+	// Changed is false if the file already had the desired content.
+	Changed bool
+
+	// Backup is the path of the copy of the original file, if one was made.
+	Backup string
+
+	// Diff is a unified diff of the change, or a note saying the file changed
+	// if too many lines changed to diff.
+	Diff string
+}
+
+// LineEdit describes the desired state of a single line in a file.
+type LineEdit struct {
+	// Regexp selects the lines to replace or remove. If nil, lines equal to
+	// Line are selected.
+	Regexp *regexp.Regexp
+
+	// Line is the desired content of the selected lines, without a trailing
+	// newline.
+	Line string
+
+	// Absent removes the selected lines rather than ensuring Line exists.
+	Absent bool
+
+	// InsertAfter and InsertBefore select where Line is inserted if no lines
+	// are selected: after the last line matching InsertAfter, or else before
+	// the first line matching InsertBefore. If neither is set or matches, Line
+	// is appended to the end of the file.
+	InsertAfter  *regexp.Regexp
+	InsertBefore *regexp.Regexp
+}
+
+// BlockEdit describes the desired state of a block of lines delimited by
+// marker lines.
+type BlockEdit struct {
+	// Block is the desired content between the markers.
+	Block string
+
+	// Marker is the template for the lines delimiting the block, where
+	// "{mark}" is replaced with BEGIN and END. Defaults to
+	// DefaultBlockMarker.
+	Marker string
+
+	// Absent removes the block and its markers.
+	Absent bool
+
+	// InsertAfter and InsertBefore select where a new block is inserted, as
+	// in LineEdit.
+	InsertAfter  *regexp.Regexp
+	InsertBefore *regexp.Regexp
+}
+
+// EnsureLine idempotently edits the remote file at name so that the lines
+// selected by edit have the desired value, or are absent. All selected lines
+// are replaced, so duplicate settings end up with the same value.
+func (f *FileClient) EnsureLine(ctx context.Context, name string, edit LineEdit, opts EditOptions) (*EditResult, error) {
+	return f.editFile(ctx, name, opts, func(lines []string) []string {
+		return applyLineEdit(lines, edit)
+	})
+}
+
+// EnsureBlock idempotently inserts, replaces or removes a block of lines
+// delimited by marker lines in the remote file at name.
+func (f *FileClient) EnsureBlock(ctx context.Context, name string, edit BlockEdit, opts EditOptions) (*EditResult, error) {
+	return f.editFile(ctx, name, opts, func(lines []string) []string {
+		return applyBlockEdit(lines, edit)
+	})
+}
+
+// editFile applies an edit to the lines of a remote file. If the content
+// changes, the original is backed up and the new content is written
+// atomically, keeping the file's mode and ownership.
+func (f *FileClient) editFile(ctx context.Context, name string, opts EditOptions, edit func([]string) []string) (*EditResult, error) {
+	name, fi, old, err := f.readForEdit(name, opts)
+	if err != nil {
+		return nil, err
+	}
+	return f.replaceContent(ctx, name, fi, old, []byte(joinLines(edit(splitLines(string(old))))), opts)
+}
+
+// readForEdit returns the path of the file that name links to, or name
+// itself if it isn't a symlink, with that file's FileInfo and content. The
+// FileInfo is nil if the file doesn't exist and opts allows creating it.
+// Editing the target keeps the link, rather than replacing it with a file.
+func (f *FileClient) readForEdit(name string, opts EditOptions) (string, os.FileInfo, []byte, error) {
+	name, err := f.resolveLinks(name)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	fi, err := f.sftp.Stat(name)
+	if err != nil {
+		if os.IsNotExist(err) && opts.Create {
+			return name, nil, nil, nil
+		}
+		return "", nil, nil, err
+	}
+	old, err := f.ReadFile(name)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return name, fi, old, nil
+}
+
+// resolveLinks follows name while it is a symlink, and returns the path it
+// ends at, which may not exist.
+func (f *FileClient) resolveLinks(name string) (string, error) {
+	for i := 0; i < maxSymlinkFollows; i++ {
+		fi, err := f.sftp.Lstat(name)
+		if os.IsNotExist(err) {
+			return name, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return name, nil
+		}
+		target, err := f.sftp.ReadLink(name)
+		if err != nil {
+			return "", err
+		}
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(name), target)
+		}
+		name = target
+	}
+	return "", fmt.Errorf("too many levels of symbolic links in %q", name)
+}
+
+// replaceContent writes updated content over a remote file that previously
+// had the given content and FileInfo, or didn't exist if fi is nil.
+func (f *FileClient) replaceContent(ctx context.Context, name string, fi os.FileInfo, old, updated []byte, opts EditOptions) (*EditResult, error) {
+	result := &EditResult{}
+	if fi != nil && bytes.Equal(old, updated) {
+		return result, nil
+	}
+	result.Changed = true
+	diff, ok := unifiedDiff(name, name, splitLines(string(old)), splitLines(string(updated)))
+	if !ok {
+		diff = fmt.Sprintf("File %s changed by too many lines to diff\n", name)
+	}
+	result.Diff = diff
+	if opts.DryRun {
+		return result, nil
+	}
+
+	mode := opts.CreateMode
+	if mode == 0 {
+		mode = 0o644
+	}
+	if fi != nil {
+		mode = fi.Mode().Perm()
+		if !opts.SkipBackup {
+			backup, err := f.backUp(ctx, name, fi)
+			if err != nil {
+				return nil, fmt.Errorf("failed to back up %q: %w", name, err)
+			}
+			result.Backup = backup
+		}
+	}
+
+	if err := f.writeFile(ctx, name, bytes.NewReader(updated), mode, fi); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// backUp copies the file at name aside, and returns the path of the copy.
+// The copy is created empty with the original's mode and owner before any
+// content is copied into it, as neither copy-data nor cp would keep them.
+func (f *FileClient) backUp(ctx context.Context, name string, fi os.FileInfo) (string, error) {
+	backup := fmt.Sprintf("%s.%s~", name, time.Now().Format(backupTimeFormat))
+	file, err := f.sftp.OpenFile(backup, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return "", err
+	}
+	err = file.Chmod(fi.Mode().Perm())
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		if uid, gid, ok := fileOwner(fi); ok {
+			err = f.sftp.Chown(backup, int(uid), int(gid))
+		}
+	}
+	if err == nil {
+		err = f.Copy(ctx, name, backup)
+	}
+	if err != nil {
+		f.sftp.Remove(backup)
+		return "", err
+	}
+	return backup, nil
+}
+
+func joinLines(lines []string) string {
+	return strings.Join(lines, "")
+}
+
+// trimNewline strips the line ending from a line produced by splitLines.
+func trimNewline(line string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+}
+
+func applyLineEdit(lines []string, edit LineEdit) []string {
+	selected := func(line string) bool {
+		if edit.Regexp != nil {
+			return edit.Regexp.MatchString(trimNewline(line))
+		}
+		return trimNewline(line) == edit.Line
+	}
+
+	var out []string
+	found := false
+	for _, line := range lines {
+		if !selected(line) {
+			out = append(out, line)
+			continue
+		}
+		found = true
+		if !edit.Absent {
+			out = append(out, edit.Line+lineEnding(line))
+		}
+	}
+	if found || edit.Absent {
+		return out
+	}
+	// A line equal to the desired one counts as present even if it doesn't
+	// match the regexp.
+	for _, line := range lines {
+		if trimNewline(line) == edit.Line {
+			return lines
+		}
+	}
+	return insertLines(lines, []string{edit.Line + "\n"}, edit.InsertAfter, edit.InsertBefore)
+}
+
+func applyBlockEdit(lines []string, edit BlockEdit) []string {
+	marker := edit.Marker
+	if marker == "" {
+		marker = DefaultBlockMarker
+	}
+	begin := strings.ReplaceAll(marker, "{mark}", "BEGIN")
+	end := strings.ReplaceAll(marker, "{mark}", "END")
+
+	beginIdx, endIdx := -1, -1
+	for i, line := range lines {
+		switch trimNewline(line) {
+		case begin:
+			if beginIdx < 0 {
+				beginIdx = i
+			}
+		case end:
+			if beginIdx >= 0 && endIdx < 0 {
+				endIdx = i
+			}
+		}
+	}
+
+	var block []string
+	if !edit.Absent {
+		block = append(block, begin+"\n")
+		for _, line := range splitLines(edit.Block) {
+			block = append(block, trimNewline(line)+"\n")
+		}
+		block = append(block, end+"\n")
+	}
+
+	if beginIdx >= 0 && endIdx >= 0 {
+		out := append([]string(nil), lines[:beginIdx]...)
+		out = append(out, block...)
+		return append(out, lines[endIdx+1:]...)
+	}
+	if edit.Absent {
+		return lines
+	}
+	return insertLines(lines, block, edit.InsertAfter, edit.InsertBefore)
+}
+
+// insertLines inserts new lines after the last line matching after, before
+// the first line matching before if after matches nothing, or at the end.
+func insertLines(lines, insert []string, after, before *regexp.Regexp) []string {
+	pos := -1
+	if after != nil {
+		for i, line := range lines {
+			if after.MatchString(trimNewline(line)) {
+				pos = i + 1
+			}
+		}
+	}
+	if pos < 0 && before != nil {
+		for i, line := range lines {
+			if before.MatchString(trimNewline(line)) {
+				pos = i
+				break
+			}
+		}
+	}
+	if pos < 0 {
+		pos = len(lines)
+	}
+
+	out := append([]string(nil), lines[:pos]...)
+	// Don't glue the new lines onto a final line with no newline.
+	if pos > 0 && !strings.HasSuffix(out[pos-1], "\n") {
+		out[pos-1] += "\n"
+	}
+	out = append(out, insert...)
+	return append(out, lines[pos:]...)
+}
+
+// lineEnding returns the line ending of line, defaulting to "\n" for a final
+// line that has none.
+func lineEnding(line string) string {
+	if strings.HasSuffix(line, "\r\n") {
+		return "\r\n"
+	}
+	return "\n"
+}
@@ -0,0 +1,212 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestApplyLineEdit(t *testing.T) {
+	const sshdConfig = "# comment\nPort 22\n#PermitRootLogin yes\nPasswordAuthentication yes\n"
+
+	for _, tc := range []struct {
+		name    string
+		content string
+		edit    LineEdit
+		want    string
+	}{
+		{
+			name:    "replace matching line",
+			content: sshdConfig,
+			edit: LineEdit{
+				Regexp: regexp.MustCompile(`^#?PermitRootLogin\s`),
+				Line:   "PermitRootLogin no",
+			},
+			want: "# comment\nPort 22\nPermitRootLogin no\nPasswordAuthentication yes\n",
+		},
+		{
+			name:    "append when nothing matches",
+			content: "Port 22",
+			edit: LineEdit{
+				Regexp: regexp.MustCompile(`^UseDNS\s`),
+				Line:   "UseDNS no",
+			},
+			want: "Port 22\nUseDNS no\n",
+		},
+		{
+			name:    "insert after",
+			content: sshdConfig,
+			edit: LineEdit{
+				Line:        "AddressFamily inet",
+				InsertAfter: regexp.MustCompile(`^Port `),
+			},
+			want: "# comment\nPort 22\nAddressFamily inet\n#PermitRootLogin yes\nPasswordAuthentication yes\n",
+		},
+		{
+			name:    "insert before",
+			content: sshdConfig,
+			edit: LineEdit{
+				Line:         "# managed",
+				InsertBefore: regexp.MustCompile(`^Port `),
+			},
+			want: "# comment\n# managed\nPort 22\n#PermitRootLogin yes\nPasswordAuthentication yes\n",
+		},
+		{
+			name:    "insert before when nothing matches after",
+			content: sshdConfig,
+			edit: LineEdit{
+				Line:         "# managed",
+				InsertAfter:  regexp.MustCompile(`^ListenAddress `),
+				InsertBefore: regexp.MustCompile(`^Port `),
+			},
+			want: "# comment\n# managed\nPort 22\n#PermitRootLogin yes\nPasswordAuthentication yes\n",
+		},
+		{
+			name:    "already present",
+			content: sshdConfig,
+			edit:    LineEdit{Line: "Port 22", Regexp: regexp.MustCompile(`^Port `)},
+			want:    sshdConfig,
+		},
+		{
+			name:    "remove lines",
+			content: sshdConfig,
+			edit:    LineEdit{Regexp: regexp.MustCompile(`Authentication`), Absent: true},
+			want:    "# comment\nPort 22\n#PermitRootLogin yes\n",
+		},
+		{
+			name:    "keeps CRLF line endings",
+			content: "a=1\r\nb=2\r\n",
+			edit:    LineEdit{Regexp: regexp.MustCompile(`^a=`), Line: "a=3"},
+			want:    "a=3\r\nb=2\r\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := joinLines(applyLineEdit(splitLines(tc.content), tc.edit))
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+			// Applying the same edit again must not change anything.
+			if again := joinLines(applyLineEdit(splitLines(got), tc.edit)); again != got {
+				t.Errorf("edit is not idempotent: %q became %q", got, again)
+			}
+		})
+	}
+}
+
+func TestApplyBlockEdit(t *testing.T) {
+	content := "a\nb\n"
+	edit := BlockEdit{Block: "x\ny", InsertAfter: regexp.MustCompile(`^a$`)}
+
+	got := joinLines(applyBlockEdit(splitLines(content), edit))
+	want := "a\n# BEGIN MANAGED BLOCK\nx\ny\n# END MANAGED BLOCK\nb\n"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	edit.Block = "z\n"
+	got = joinLines(applyBlockEdit(splitLines(got), edit))
+	want = "a\n# BEGIN MANAGED BLOCK\nz\n# END MANAGED BLOCK\nb\n"
+	if got != want {
+		t.Fatalf("replacing block: got %q, want %q", got, want)
+	}
+
+	got = joinLines(applyBlockEdit(splitLines(got), BlockEdit{Absent: true}))
+	if got != content {
+		t.Fatalf("removing block: got %q, want %q", got, content)
+	}
+}
+
+func TestEnsureLine(t *testing.T) {
+	ctx := context.Background()
+	f := setUpFileClient(ctx, t)
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "sshd_config")
+	writeTree(t, dir, map[string]string{"sshd_config": "PermitRootLogin yes\n"})
+	if err := os.Chmod(name, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chown(name, 1234, 5678); err != nil {
+		t.Fatal(err)
+	}
+
+	edit := LineEdit{Regexp: regexp.MustCompile(`^PermitRootLogin `), Line: "PermitRootLogin no"}
+
+	result, err := f.EnsureLine(ctx, name, edit, EditOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("EnsureLine failed: %v", err)
+	}
+	if !result.Changed || result.Diff == "" {
+		t.Errorf("expected dry run to report a change, got %+v", result)
+	}
+	assertFileContents(t, name, "PermitRootLogin yes\n")
+
+	result, err = f.EnsureLine(ctx, name, edit, EditOptions{})
+	if err != nil {
+		t.Fatalf("EnsureLine failed: %v", err)
+	}
+	if !result.Changed {
+		t.Errorf("expected the first edit to change the file")
+	}
+	assertFileContents(t, name, "PermitRootLogin no\n")
+	assertFileContents(t, result.Backup, "PermitRootLogin yes\n")
+	if fi, err := os.Stat(name); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("expected mode to be preserved, got %v (err: %v)", fi.Mode(), err)
+	}
+	if fi, err := os.Stat(name); err != nil {
+		t.Fatal(err)
+	} else if uid, gid, _ := fileOwner(fi); uid != 1234 || gid != 5678 {
+		t.Errorf("expected the owner to be preserved, got %d:%d", uid, gid)
+	}
+	if fi, err := os.Stat(result.Backup); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("expected the backup to have the original's mode, got %v (err: %v)", fi.Mode(), err)
+	}
+
+	result, err = f.EnsureLine(ctx, name, edit, EditOptions{})
+	if err != nil {
+		t.Fatalf("EnsureLine failed: %v", err)
+	}
+	if result.Changed || result.Backup != "" {
+		t.Errorf("expected the second edit to be a no-op, got %+v", result)
+	}
+
+	missing := filepath.Join(dir, "missing")
+	if _, err := f.EnsureBlock(ctx, missing, BlockEdit{Block: "x"}, EditOptions{}); err == nil {
+		t.Errorf("expected editing a missing file to fail without Create")
+	}
+	if _, err := f.EnsureBlock(ctx, missing, BlockEdit{Block: "x"}, EditOptions{Create: true}); err != nil {
+		t.Fatalf("EnsureBlock failed: %v", err)
+	}
+	assertFileContents(t, missing, "# BEGIN MANAGED BLOCK\nx\n# END MANAGED BLOCK\n")
+}
+
+func TestEnsureLineSymlink(t *testing.T) {
+	ctx := context.Background()
+	f := setUpFileClient(ctx, t)
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"conf.d/real": "a\n"})
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink("conf.d/real", link); err != nil {
+		t.Fatal(err)
+	}
+
+	// Editing through the link edits its target, and keeps the link.
+	result, err := f.EnsureLine(ctx, link, LineEdit{Line: "b"}, EditOptions{})
+	if err != nil {
+		t.Fatalf("EnsureLine failed: %v", err)
+	}
+	assertFileContents(t, filepath.Join(dir, "conf.d/real"), "a\nb\n")
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("expected %s to still be a symlink, got %v (err: %v)", link, fi.Mode(), err)
+	}
+	if filepath.Dir(result.Backup) != filepath.Join(dir, "conf.d") {
+		t.Errorf("got backup %s, want it beside the target", result.Backup)
+	}
+}
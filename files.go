@@ -0,0 +1,282 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/aucloud/go-sshutil/cmdparse"
+	"github.com/pkg/sftp"
+)
+
+// SFTP protocol extensions that FileClient takes advantage of when the server
+// supports them.
+const (
+	ExtStatVFS     = "statvfs@openssh.com"
+	ExtPosixRename = "posix-rename@openssh.com"
+	ExtHardlink    = "hardlink@openssh.com"
+	ExtFsync       = "fsync@openssh.com"
+	ExtCopyData    = "copy-data"
+)
+
+var fileExtensions = []string{
+	ExtStatVFS,
+	ExtPosixRename,
+	ExtHardlink,
+	ExtFsync,
+	ExtCopyData,
+}
+
+// FileClient is a higher-level file API on top of SFTP. It detects which
+// protocol extensions the server supports and uses them where possible,
+// falling back to running commands on the remote when they are missing.
+//
+// Like the SFTP client it wraps, a FileClient becomes unresponsive if the
+// underlying ssh connection is closed and/or refreshed.
+type FileClient struct {
+	client     *Client
+	sftp       *sftp.Client
+	extensions map[string]bool
+}
+
+// NewFileClient returns a FileClient that uses the currently underlying
+// ssh.Client.
+func (c *Client) NewFileClient() (*FileClient, error) {
+	sftpClient, err := c.NewSFTPClient()
+	if err != nil {
+		return nil, err
+	}
+	f := &FileClient{
+		client:     c,
+		sftp:       sftpClient,
+		extensions: make(map[string]bool),
+	}
+	for _, ext := range fileExtensions {
+		if _, ok := sftpClient.HasExtension(ext); ok {
+			f.extensions[ext] = true
+		}
+	}
+	return f, nil
+}
+
+// Close the underlying SFTP client.
+func (f *FileClient) Close() error {
+	return f.sftp.Close()
+}
+
+// SFTP returns the underlying SFTP client.
+func (f *FileClient) SFTP() *sftp.Client {
+	return f.sftp
+}
+
+// HasExtension reports whether the server supports the named SFTP extension.
+func (f *FileClient) HasExtension(name string) bool {
+	return f.extensions[name]
+}
+
+// Stat returns the FileInfo of the named remote file.
+func (f *FileClient) Stat(name string) (os.FileInfo, error) {
+	return f.sftp.Stat(name)
+}
+
+// ReadFile reads the whole of the named remote file.
+func (f *FileClient) ReadFile(name string) ([]byte, error) {
+	file, err := f.sftp.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var buf bytes.Buffer
+	if _, err := file.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Rename atomically renames oldname to newname, replacing newname if it
+// already exists. This uses the posix-rename@openssh.com extension if
+// supported, or `mv` otherwise.
+func (f *FileClient) Rename(ctx context.Context, oldname, newname string) error {
+	if f.extensions[ExtPosixRename] {
+		return f.sftp.PosixRename(oldname, newname)
+	}
+	_, err := f.client.Output(ctx, "mv", "-f", "--", oldname, newname)
+	return err
+}
+
+// Link creates newname as a hard link to oldname. This uses the
+// hardlink@openssh.com extension if supported, or `ln` otherwise.
+func (f *FileClient) Link(ctx context.Context, oldname, newname string) error {
+	if f.extensions[ExtHardlink] {
+		return f.sftp.Link(oldname, newname)
+	}
+	_, err := f.client.Output(ctx, "ln", "--", oldname, newname)
+	return err
+}
+
+// Copy copies the contents of src to dst on the remote without transferring
+// the data over the connection. This uses the copy-data extension if
+// supported, or `cp` otherwise.
+//
+// Only the contents are copied. An existing dst keeps its mode, while a new
+// one gets the server's default mode with copy-data, or src's mode less the
+// umask with `cp`, so callers that care should Chmod dst afterwards.
+func (f *FileClient) Copy(ctx context.Context, src, dst string) error {
+	// Opening dst would truncate src before copy-data reads it.
+	if f.sameFile(src, dst) {
+		return fmt.Errorf("%q and %q are the same file", src, dst)
+	}
+	if f.extensions[ExtCopyData] {
+		return copyData(ctx, f.client.Client(), src, dst)
+	}
+	_, err := f.client.Output(ctx, "cp", "--", src, dst)
+	return err
+}
+
+// FSStat describes the capacity of a remote filesystem.
+type FSStat struct {
+	// Total size of the filesystem in bytes.
+	Total uint64
+
+	// Free bytes, including any reserved for the superuser.
+	Free uint64
+
+	// Bytes available to unprivileged users.
+	Avail uint64
+}
+
+// sameFile reports whether the paths a and b resolve to the same file on the
+// remote.
+func (f *FileClient) sameFile(a, b string) bool {
+	if path.Clean(a) == path.Clean(b) {
+		return true
+	}
+	realA, err := f.sftp.RealPath(a)
+	if err != nil {
+		return false
+	}
+	realB, err := f.sftp.RealPath(b)
+	return err == nil && realA == realB
+}
+
+// StatFS returns the capacity of the filesystem containing name. This uses
+// the statvfs@openssh.com extension if supported, or `df` otherwise.
+func (f *FileClient) StatFS(ctx context.Context, name string) (*FSStat, error) {
+	if f.extensions[ExtStatVFS] {
+		st, err := f.sftp.StatVFS(name)
+		if err != nil {
+			return nil, err
+		}
+		return &FSStat{
+			Total: st.Blocks * st.Frsize,
+			Free:  st.Bfree * st.Frsize,
+			Avail: st.Bavail * st.Frsize,
+		}, nil
+	}
+
+	out, err := f.client.Output(ctx, "df", "-P", "-k", "--", name)
+	if err != nil {
+		return nil, err
+	}
+	filesystems, err := cmdparse.ParseDF(out)
+	if err != nil {
+		return nil, err
+	}
+	if len(filesystems) != 1 {
+		return nil, fmt.Errorf("unexpected df output: %q", out)
+	}
+	fs := filesystems[0]
+	free := fs.Available
+	if fs.Size > fs.Used {
+		free = fs.Size - fs.Used
+	}
+	return &FSStat{Total: fs.Size, Free: free, Avail: fs.Available}, nil
+}
+
+// WriteFile durably and atomically replaces the named remote file with the
+// contents of r. The data is written to a temporary file in the same
+// directory, flushed to stable storage and renamed over name, so readers see
+// either the old or the new contents and never a partial file.
+func (f *FileClient) WriteFile(ctx context.Context, name string, r io.Reader, perm os.FileMode) error {
+	return f.writeFile(ctx, name, r, perm, nil)
+}
+
+// writeFile is WriteFile, but also gives the new file the owner of owner if it
+// is non-nil. The owner is set before the rename so that name never has the
+// wrong one.
+func (f *FileClient) writeFile(ctx context.Context, name string, r io.Reader, perm os.FileMode, owner os.FileInfo) error {
+	tmp, err := TempName(name)
+	if err != nil {
+		return err
+	}
+
+	file, err := f.sftp.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			f.sftp.Remove(tmp)
+		}
+	}()
+
+	if owner != nil {
+		if uid, gid, ok := fileOwner(owner); ok {
+			if err := file.Chown(int(uid), int(gid)); err != nil {
+				file.Close()
+				return fmt.Errorf("failed to change ownership of %q: %w", tmp, err)
+			}
+		}
+	}
+	if err := f.writeAndSync(ctx, file, r, perm); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %q: %w", tmp, err)
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := f.Rename(ctx, tmp, name); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+func (f *FileClient) writeAndSync(ctx context.Context, file *sftp.File, r io.Reader, perm os.FileMode) error {
+	if err := file.Chmod(perm); err != nil {
+		return err
+	}
+	if _, err := file.ReadFrom(r); err != nil {
+		return err
+	}
+	if f.extensions[ExtFsync] {
+		return file.Sync()
+	}
+	_, err := f.client.Output(ctx, "sync")
+	return err
+}
+
+// TempName returns a hidden, randomly named sibling of name, for writing a
+// file that is then renamed over name.
+func TempName(name string) (string, error) {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	dir, base := path.Split(name)
+	if base == "" {
+		return "", errors.New("cannot write to a directory path")
+	}
+	return fmt.Sprintf("%s.%s.tmp-%x", dir, base, buf), nil
+}
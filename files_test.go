@@ -0,0 +1,213 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+	"github.com/pkg/sftp"
+)
+
+func setUpFileClient(ctx context.Context, t *testing.T) *FileClient {
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+	f, err := client.NewFileClient()
+	if err != nil {
+		t.Fatalf("failed to create file client: %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// withoutSFTPExtensions disables all extensions in the test sftp server for
+// the duration of the test.
+func withoutSFTPExtensions(t *testing.T) {
+	if err := sftp.SetSFTPExtensions(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		sftp.SetSFTPExtensions(ExtHardlink, ExtPosixRename, ExtStatVFS)
+	})
+}
+
+func TestFileClient(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name          string
+		disableExts   bool
+		expectPosixMv bool
+	}{
+		{name: "with extensions", expectPosixMv: true},
+		{name: "without extensions", disableExts: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.disableExts {
+				withoutSFTPExtensions(t)
+			}
+			f := setUpFileClient(ctx, t)
+			if got := f.HasExtension(ExtPosixRename); got != tc.expectPosixMv {
+				t.Errorf("HasExtension(%q) = %v, want %v", ExtPosixRename, got, tc.expectPosixMv)
+			}
+
+			dir := t.TempDir()
+			target := filepath.Join(dir, "target")
+			if err := ioutil.WriteFile(target, []byte("old"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := f.WriteFile(ctx, target, bytes.NewReader([]byte("new contents")), 0o640); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+			assertFileContents(t, target, "new contents")
+			if fi, err := os.Stat(target); err != nil || fi.Mode().Perm() != 0o640 {
+				t.Errorf("expected mode 0640 after WriteFile, got %v (err: %v)", fi.Mode(), err)
+			}
+			if entries, _ := ioutil.ReadDir(dir); len(entries) != 1 {
+				t.Errorf("WriteFile left temporary files behind: %v", entries)
+			}
+
+			copied := filepath.Join(dir, "copied")
+			if err := f.Copy(ctx, target, copied); err != nil {
+				t.Fatalf("Copy failed: %v", err)
+			}
+			assertFileContents(t, copied, "new contents")
+			if err := f.Copy(ctx, target, filepath.Join(dir, ".", "target")); err == nil {
+				t.Errorf("expected copying a file to itself to fail")
+			}
+			if err := os.Symlink(target, filepath.Join(dir, "symlink")); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Copy(ctx, filepath.Join(dir, "symlink"), target); err == nil {
+				t.Errorf("expected copying a file to itself through a symlink to fail")
+			}
+			os.Remove(filepath.Join(dir, "symlink"))
+			assertFileContents(t, target, "new contents")
+
+			linked := filepath.Join(dir, "linked")
+			if err := f.Link(ctx, target, linked); err != nil {
+				t.Fatalf("Link failed: %v", err)
+			}
+			assertFileContents(t, linked, "new contents")
+
+			if err := f.Rename(ctx, copied, linked); err != nil {
+				t.Fatalf("Rename failed: %v", err)
+			}
+			if _, err := os.Stat(copied); !os.IsNotExist(err) {
+				t.Errorf("Rename should have removed the source, got %v", err)
+			}
+
+			st, err := f.StatFS(ctx, dir)
+			if err != nil {
+				t.Fatalf("StatFS failed: %v", err)
+			}
+			if st.Total == 0 || st.Avail > st.Total || st.Free > st.Total {
+				t.Errorf("StatFS returned implausible results: %+v", st)
+			}
+		})
+	}
+}
+
+func TestCopyData(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnSessionChannel(map[string]func(io.ReadWriteCloser){
+		"sftp": serveCopyData,
+	}), nil)
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	if err := ioutil.WriteFile(src, []byte("copy me"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := copyData(ctx, client.Client(), src, dst); err != nil {
+		t.Fatalf("copyData failed: %v", err)
+	}
+	assertFileContents(t, dst, "copy me")
+
+	if err := copyData(ctx, client.Client(), filepath.Join(dir, "missing"), dst); err == nil {
+		t.Errorf("expected copyData of a missing file to fail")
+	}
+}
+
+func assertFileContents(t *testing.T, name string, want string) {
+	t.Helper()
+	got, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatalf("failed to read %q: %v", name, err)
+	}
+	if string(got) != want {
+		t.Errorf("expected %q to contain %q, got %q", name, want, got)
+	}
+}
+
+// serveCopyData is a fake sftp subsystem that implements only the requests
+// needed by copyData.
+func serveCopyData(rwc io.ReadWriteCloser) {
+	s := copyDataSession{rw: rwc}
+	files := map[string]*os.File{}
+	reply := func(typ byte, id uint32, payload []byte) {
+		s.writePacket(typ, append(appendUint32(nil, id), payload...))
+	}
+	status := func(id uint32, err error) {
+		var payload []byte
+		if err != nil {
+			payload = appendString(appendUint32(nil, 4), err.Error())
+		} else {
+			payload = appendString(appendUint32(nil, sftpStatusOK), "")
+		}
+		reply(sftpPacketStatus, id, appendString(payload, ""))
+	}
+
+	for {
+		typ, data, err := s.readPacket()
+		if err != nil {
+			return
+		}
+		if typ == sftpPacketInit {
+			payload := appendUint32(nil, sftpProtocolVersion)
+			payload = appendString(payload, ExtCopyData)
+			payload = appendString(payload, "1")
+			s.writePacket(sftpPacketVersion, payload)
+			continue
+		}
+		id := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		switch typ {
+		case sftpPacketOpen:
+			name, rest, _ := readString(data)
+			flags := binary.BigEndian.Uint32(rest)
+			var file *os.File
+			if flags&sftpOpenWrite != 0 {
+				file, err = os.Create(name)
+			} else {
+				file, err = os.Open(name)
+			}
+			if err != nil {
+				status(id, err)
+				continue
+			}
+			files[name] = file
+			reply(sftpPacketHandle, id, appendString(nil, name))
+		case sftpPacketClose:
+			handle, _, _ := readString(data)
+			status(id, files[handle].Close())
+			delete(files, handle)
+		case sftpPacketExtended:
+			_, rest, _ := readString(data)
+			src, rest, _ := readString(rest)
+			dst, _, _ := readString(rest[16:])
+			_, err := io.Copy(files[dst], files[src])
+			status(id, err)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+
+	"go.fuchsia.dev/fuchsia/tools/lib/logger"
+)
+
+// DialContext connects to addr as dialed from the remote, such as a service
+// only listening on the remote's loopback interface. The network must be
+// "tcp".
+func (c *Client) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	client := c.Client()
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		conn, err := client.Dial(network, addr)
+		ch <- result{conn, err}
+	}()
+	select {
+	case r := <-ch:
+		return r.conn, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-ch; r.conn != nil {
+				r.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// HTTPClient returns an HTTP client that connects to servers from the remote,
+// so that "http://127.0.0.1:6060/" refers to a server on the remote's
+// loopback interface. The clients share one transport, whose idle connections
+// are closed with the client.
+func (c *Client) HTTPClient() *http.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.transport == nil {
+		c.transport = &http.Transport{DialContext: c.DialContext}
+	}
+	return &http.Client{Transport: c.transport}
+}
+
+// Forward is a local port forwarded to an address reachable from the remote,
+// like `ssh -L`.
+type Forward struct {
+	listener net.Listener
+	remote   string
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// ForwardLocal listens on the local address localAddr, such as
+// "127.0.0.1:0", and forwards each connection to remoteAddr as dialed from
+// the remote, until the Forward is closed.
+func (c *Client) ForwardLocal(ctx context.Context, localAddr, remoteAddr string) (*Forward, error) {
+	listener, err := net.Listen("tcp", localAddr)
+	if err != nil {
+		return nil, err
+	}
+	f := &Forward{
+		listener: listener,
+		remote:   remoteAddr,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.serve(ctx, c)
+	}()
+	return f, nil
+}
+
+// Addr returns the local address connections are accepted on.
+func (f *Forward) Addr() net.Addr {
+	return f.listener.Addr()
+}
+
+func (f *Forward) serve(ctx context.Context, c *Client) {
+	for {
+		local, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		f.wg.Add(1)
+		go func() {
+			defer f.wg.Done()
+			if err := f.forward(c, local); err != nil {
+				logger.Debugf(ctx, "failed to forward connection to %s: %v", f.remote, err)
+			}
+		}()
+	}
+}
+
+// track adds conn to the connections closed by Close, or returns false if
+// the Forward is already closed.
+func (f *Forward) track(conn net.Conn) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return false
+	}
+	f.conns[conn] = struct{}{}
+	return true
+}
+
+func (f *Forward) untrack(conn net.Conn) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.conns, conn)
+}
+
+func (f *Forward) forward(c *Client, local net.Conn) error {
+	defer local.Close()
+	if !f.track(local) {
+		return nil
+	}
+	defer f.untrack(local)
+
+	remote, err := c.Client().Dial("tcp", f.remote)
+	if err != nil {
+		return err
+	}
+	defer remote.Close()
+	if !f.track(remote) {
+		return nil
+	}
+	defer f.untrack(remote)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+	// Either side closing ends the connection.
+	<-done
+	return nil
+}
+
+// Close stops accepting connections and closes those being forwarded.
+func (f *Forward) Close() error {
+	f.mu.Lock()
+	f.closed = true
+	for conn := range f.conns {
+		conn.Close()
+	}
+	f.mu.Unlock()
+
+	err := f.listener.Close()
+	f.wg.Wait()
+	return err
+}
@@ -0,0 +1,170 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+)
+
+// startEchoServer listens on a local port, echoing each line it receives.
+func startEchoServer(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					conn.Write([]byte(line))
+				}
+			}()
+		}
+	}()
+	return listener
+}
+
+// checkEcho checks that a line sent to addr is echoed back.
+func checkEcho(t *testing.T, addr string) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("ping\n")); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "ping\n" {
+		t.Errorf("got %q, want %q", line, "ping\n")
+	}
+}
+
+func TestForwardLocal(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+	echo := startEchoServer(t)
+
+	f, err := client.ForwardLocal(ctx, "127.0.0.1:0", echo.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEcho(t, f.Addr().String())
+	checkEcho(t, f.Addr().String())
+
+	// Closing the forward closes open connections.
+	conn, err := net.Dial("tcp", f.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("ping\n")); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	if _, err := r.ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := r.ReadString('\n'); err != io.EOF {
+		t.Errorf("got %v reading from a closed forward, want EOF", err)
+	}
+	if _, err := net.Dial("tcp", f.Addr().String()); err == nil {
+		t.Errorf("the forward still accepts connections after Close")
+	}
+
+	// Connections to unreachable addresses are closed.
+	f, err = client.ForwardLocal(ctx, "127.0.0.1:0", "127.0.0.1:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	conn, err = net.Dial("tcp", f.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("got %v reading from a failed forward, want EOF", err)
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "hello %s", r.URL.Path)
+	}))
+	var mu sync.Mutex
+	var conns int
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	// Each client reuses the same connection.
+	for i := 0; i < 3; i++ {
+		resp, err := client.HTTPClient().Get(server.URL + "/world")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != "hello /world" {
+			t.Errorf("got %q, want %q", body, "hello /world")
+		}
+	}
+	mu.Lock()
+	if conns != 1 {
+		t.Errorf("got %d connections for 3 requests, want 1", conns)
+	}
+	mu.Unlock()
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := client.DialContext(canceled, "tcp", server.Listener.Addr().String()); err != context.Canceled {
+		t.Errorf("got error %v dialing with a canceled context, want %v", err, context.Canceled)
+	}
+}
@@ -6,6 +6,7 @@ require (
 	github.com/pkg/sftp v1.13.4
 	go.fuchsia.dev/fuchsia/tools v0.0.0-20210227002403-8023e94b8b78
 	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,465 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.fuchsia.dev/fuchsia/tools/lib/logger"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// defaultGoTestTimeout is the default timeout of RunGoTest, which is the
+	// same as that of `go test`.
+	defaultGoTestTimeout = 10 * time.Minute
+
+	// goTestOutputEnv is the environment variable telling tests where to
+	// write files for RunGoTest to collect.
+	goTestOutputEnv = "TEST_OUTPUT_DIR"
+)
+
+// goTestKillGrace is how long RunGoTest waits past the timeout for a test
+// binary to exit by itself, by panicking with the goroutines' stacks, before
+// killing it.
+var goTestKillGrace = 30 * time.Second
+
+// tempDir creates a directory with a random name starting with prefix in the
+// remote's temporary directory.
+func (c *Client) tempDir(ctx context.Context, prefix string) (string, error) {
+	out, err := c.Output(ctx, "sh", "-c", `mktemp -d "${TMPDIR:-/tmp}/$1.XXXXXX"`, "sh", prefix)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// TestEvent is an event in the output of a Go test binary, in the format of
+// `go tool test2json`.
+type TestEvent struct {
+	Time    time.Time
+	Action  string
+	Package string  `json:",omitempty"`
+	Test    string  `json:",omitempty"`
+	Elapsed float64 `json:",omitempty"`
+	Output  string  `json:",omitempty"`
+}
+
+// GoTestOptions configure RunGoTest.
+type GoTestOptions struct {
+	// Testdata is a local directory uploaded as testdata alongside the test
+	// binary, which runs in the directory containing it.
+	Testdata string
+
+	// Args are extra arguments to the test binary, such as -test.run.
+	Args []string
+
+	// Timeout is passed to the test binary with -test.timeout. After it and
+	// a grace period, the binary is killed. It defaults to 10 minutes.
+	Timeout time.Duration
+
+	// OutputDir is a local directory to which the files the tests write to
+	// the directory named by $TEST_OUTPUT_DIR are downloaded.
+	OutputDir string
+
+	// Package is the package name used in events. It defaults to the name
+	// of the binary without its ".test" suffix.
+	Package string
+
+	// Events, if set, is called with each event as it is read.
+	Events func(TestEvent)
+
+	// Coverage, if set, collects the coverage data of a binary built with
+	// `go test -c -cover`, which is written to a temporary directory passed
+	// with -test.gocoverdir and $GOCOVERDIR.
+	Coverage *CoverageCollector
+}
+
+// TestResult is the outcome of a test or subtest.
+type TestResult struct {
+	Name string
+
+	// Action is "pass", "fail" or "skip", or empty if the test didn't
+	// finish, because the binary crashed or timed out.
+	Action  string
+	Elapsed time.Duration
+	Output  string
+}
+
+// GoTestResult is the result of RunGoTest.
+type GoTestResult struct {
+	// Passed is set if the binary reported success and exited cleanly.
+	Passed bool
+
+	// TimedOut is set if the binary panicked on reaching the timeout, or was
+	// killed after it.
+	TimedOut bool
+
+	// ExitStatus is the exit status of the binary, or -1 if it was killed.
+	ExitStatus int
+
+	// Tests are the results of the tests that ran, in the order they
+	// started.
+	Tests []TestResult
+}
+
+// Test returns the result of the named test, or nil if it didn't run.
+func (r *GoTestResult) Test(name string) *TestResult {
+	for i := range r.Tests {
+		if r.Tests[i].Name == name {
+			return &r.Tests[i]
+		}
+	}
+	return nil
+}
+
+// RunGoTest uploads a test binary built with `go test -c`, and its testdata,
+// to a temporary directory on the remote, runs it with -test.v=test2json, and
+// converts its output to events as test2json would. The binary must be built
+// with Go 1.20 or later, which marks the lines framing tests in that mode.
+// Test failures are reported in the result rather than as an error. The
+// temporary directory is removed afterwards.
+func (c *Client) RunGoTest(ctx context.Context, binary string, opts GoTestOptions) (*GoTestResult, error) {
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultGoTestTimeout
+	}
+	if opts.Package == "" {
+		opts.Package = strings.TrimSuffix(filepath.Base(binary), ".test")
+	}
+
+	dir, err := c.tempDir(ctx, "sshutil-gotest")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// Clean up even if ctx was cancelled.
+		ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
+		if _, err := c.Output(ctx, "rm", "-rf", dir); err != nil {
+			logger.Warningf(ctx, "failed to remove %s: %v", dir, err)
+		}
+	}()
+
+	f, err := c.NewFileClient()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	remoteBinary := path.Join(dir, filepath.Base(binary))
+	if err := f.Upload(ctx, binary, remoteBinary, TransferOptions{FileChmod: ChmodMask{Set: 0o700}}); err != nil {
+		return nil, err
+	}
+	if opts.Testdata != "" {
+		if err := f.Upload(ctx, opts.Testdata, path.Join(dir, "testdata"), TransferOptions{}); err != nil {
+			return nil, err
+		}
+	}
+	outDir := path.Join(dir, "out")
+	if err := f.SFTP().Mkdir(outDir); err != nil {
+		return nil, err
+	}
+
+	var coverDir string
+	if opts.Coverage != nil {
+		coverDir = path.Join(dir, "cover")
+		if err := f.SFTP().Mkdir(coverDir); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := c.runGoTestBinary(ctx, dir, remoteBinary, outDir, coverDir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Coverage != nil {
+		if err := opts.Coverage.collect(ctx, c, coverDir); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.OutputDir != "" {
+		if err := f.Download(ctx, outDir, opts.OutputDir, TransferOptions{}); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// goTestScript records the shell's PID so that it can be killed, and execs
+// the test binary in its directory.
+const goTestScript = `cd "$1" && echo $$ > pid && shift && exec "$@"`
+
+func (c *Client) runGoTestBinary(ctx context.Context, dir, binary, outDir, coverDir string, opts GoTestOptions) (*GoTestResult, error) {
+	conv := newTestConverter(opts.Package, opts.Events)
+	conv.start()
+	stdout, stderr := conv.writer(), conv.writer()
+
+	args := []string{"sh", "-c", goTestScript, "sh", dir, "env", goTestOutputEnv + "=" + outDir}
+	if opts.Coverage != nil {
+		args = append(args, "GOCOVERDIR="+coverDir)
+	}
+	args = append(args, binary, "-test.v=test2json", "-test.timeout="+opts.Timeout.String(), "-test.outputdir="+outDir)
+	if opts.Coverage != nil {
+		args = append(args, "-test.gocoverdir="+coverDir)
+	}
+	args = append(args, opts.Args...)
+
+	runCtx, cancel := context.WithTimeout(ctx, opts.Timeout+goTestKillGrace)
+	defer cancel()
+	err := c.Run(runCtx, shellQuoteArgs(args), stdout, stderr)
+	stdout.flush()
+	stderr.flush()
+
+	result := &GoTestResult{}
+	var exitErr *ssh.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		result.ExitStatus = exitErr.ExitStatus()
+	case ctx.Err() == nil && runCtx.Err() != nil:
+		result.TimedOut = true
+		result.ExitStatus = -1
+		if _, err := c.ShellOutput(ctx, fmt.Sprintf("kill -9 $(cat %s)", ShellQuote(path.Join(dir, "pid")))); err != nil {
+			logger.Warningf(ctx, "failed to kill timed out test binary: %v", err)
+		}
+	default:
+		return nil, err
+	}
+	var timedOut bool
+	result.Tests, result.Passed, timedOut = conv.finish(result.ExitStatus)
+	result.TimedOut = result.TimedOut || timedOut
+	return result, nil
+}
+
+var (
+	// testFrameRE matches the lines starting and pausing tests.
+	testFrameRE = regexp.MustCompile(`^=== (RUN|PAUSE|CONT|NAME)\s+(\S+)`)
+
+	// testEndRE matches the lines reporting the end of a test, which are
+	// indented for subtests.
+	testEndRE = regexp.MustCompile(`^\s*--- (PASS|FAIL|SKIP): (\S+) \(([0-9.]+)s\)`)
+)
+
+// testTimeoutPrefix starts the panic of a test binary reaching its timeout.
+const testTimeoutPrefix = "panic: test timed out after "
+
+// The control characters a test binary run with -test.v=test2json puts in its
+// output. Lines framing tests start with testMarkFraming, errors logged by
+// tests are delimited by testMarkErrBegin and testMarkErrEnd, and
+// testMarkEscape escapes the character following it.
+const (
+	testMarkFraming  = '\x16'
+	testMarkErrBegin = '\x0f'
+	testMarkErrEnd   = '\x0e'
+	testMarkEscape   = '\x1b'
+)
+
+// testConverter converts the output of a test binary run with -test.v to
+// events, as test2json does. Once a line marked as framing is seen, only
+// marked lines are taken to frame tests, so output that merely looks like
+// framing is kept as output. Until then, lines are recognized by their text.
+type testConverter struct {
+	pkg    string
+	events func(TestEvent)
+
+	mu       sync.Mutex
+	marked   bool
+	current  string
+	tests    []TestResult
+	index    map[string]int
+	status   string
+	timedOut bool
+	finished bool
+}
+
+func newTestConverter(pkg string, events func(TestEvent)) *testConverter {
+	return &testConverter{pkg: pkg, events: events, index: make(map[string]int)}
+}
+
+// emit sends an event. The caller must hold the lock.
+func (tc *testConverter) emit(e TestEvent) {
+	if tc.events == nil {
+		return
+	}
+	e.Time = time.Now()
+	e.Package = tc.pkg
+	tc.events(e)
+}
+
+// start emits the event starting the package.
+func (tc *testConverter) start() {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.emit(TestEvent{Action: "start"})
+}
+
+// unmarkTestOutput removes the error markers from a line of output, and the
+// escapes from the characters they escape.
+func unmarkTestOutput(line string) string {
+	if strings.IndexFunc(line, func(r rune) bool {
+		return r == testMarkErrBegin || r == testMarkErrEnd || r == testMarkEscape
+	}) < 0 {
+		return line
+	}
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case testMarkErrBegin, testMarkErrEnd:
+		case testMarkEscape:
+			if i+1 < len(line) {
+				i++
+				b.WriteByte(line[i])
+			}
+		default:
+			b.WriteByte(line[i])
+		}
+	}
+	return b.String()
+}
+
+// test returns the result of the named test, adding it if it's new. The
+// caller must hold the lock.
+func (tc *testConverter) test(name string) *TestResult {
+	i, ok := tc.index[name]
+	if !ok {
+		i = len(tc.tests)
+		tc.index[name] = i
+		tc.tests = append(tc.tests, TestResult{Name: name})
+	}
+	return &tc.tests[i]
+}
+
+// line handles a line of output, including its newline.
+func (tc *testConverter) line(line string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	if tc.finished {
+		return
+	}
+	framing := !tc.marked
+	if line[0] == testMarkFraming {
+		tc.marked = true
+		framing = true
+		line = line[1:]
+	}
+	line = unmarkTestOutput(line)
+	trimmed := strings.TrimRight(line, "\n")
+	if !framing {
+		tc.output(line, trimmed)
+		return
+	}
+	if m := testFrameRE.FindStringSubmatch(trimmed); m != nil {
+		name := m[2]
+		tc.current = name
+		if m[1] == "RUN" {
+			tc.test(name)
+		}
+		if m[1] != "NAME" {
+			tc.emit(TestEvent{Action: strings.ToLower(m[1]), Test: name})
+		}
+		tc.emit(TestEvent{Action: "output", Test: name, Output: line})
+		return
+	}
+	if m := testEndRE.FindStringSubmatch(trimmed); m != nil {
+		name := m[2]
+		elapsed, _ := strconv.ParseFloat(m[3], 64)
+		t := tc.test(name)
+		t.Action = strings.ToLower(m[1])
+		t.Elapsed = time.Duration(elapsed * float64(time.Second))
+		// Logs of the test follow the line reporting its end.
+		tc.current = name
+		tc.emit(TestEvent{Action: "output", Test: name, Output: line})
+		tc.emit(TestEvent{Action: t.Action, Test: name, Elapsed: elapsed})
+		return
+	}
+	if trimmed == "PASS" || trimmed == "FAIL" {
+		tc.current = ""
+		tc.status = strings.ToLower(trimmed)
+		tc.emit(TestEvent{Action: "output", Output: line})
+		return
+	}
+	tc.output(line, trimmed)
+}
+
+// output handles a line of output that doesn't frame a test. The caller must
+// hold the lock.
+func (tc *testConverter) output(line, trimmed string) {
+	if strings.HasPrefix(trimmed, testTimeoutPrefix) {
+		tc.timedOut = true
+	}
+	if tc.current != "" {
+		t := tc.test(tc.current)
+		t.Output += line
+	}
+	tc.emit(TestEvent{Action: "output", Test: tc.current, Output: line})
+}
+
+// finish emits the final event for the package, and returns the results of
+// the tests, whether the run passed and whether the binary reported timing
+// out.
+func (tc *testConverter) finish(exitStatus int) ([]TestResult, bool, bool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	passed := tc.status == "pass" && exitStatus == 0
+	action := "fail"
+	if passed {
+		action = "pass"
+	}
+	tc.emit(TestEvent{Action: action})
+	tc.finished = true
+	return append([]TestResult(nil), tc.tests...), passed, tc.timedOut
+}
+
+func (tc *testConverter) writer() *lineWriter {
+	return &lineWriter{line: tc.line}
+}
+
+// lineWriter calls line with each complete line written to it. It may be
+// written to and flushed concurrently, as a session can still be copying
+// output when Run returns on cancellation.
+type lineWriter struct {
+	line func(string)
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		w.line(string(w.buf.Next(i + 1)))
+	}
+	return len(p), nil
+}
+
+// flush passes on a final line without a newline.
+func (w *lineWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.buf.Len() > 0 {
+		w.line(w.buf.String() + "\n")
+		w.buf.Reset()
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+)
+
+const testGoTestOutput = `=== RUN   TestA
+    a_test.go:10: reading input
+--- PASS: TestA (0.01s)
+=== RUN   TestB
+=== PAUSE TestB
+=== RUN   TestC
+=== RUN   TestC/sub
+    c_test.go:5: oops
+=== NAME  TestC
+    c_test.go:7: after
+    --- FAIL: TestC/sub (0.00s)
+--- FAIL: TestC (0.00s)
+=== CONT  TestB
+--- SKIP: TestB (0.00s)
+    b_test.go:3: skipped
+FAIL
+`
+
+func TestTestConverter(t *testing.T) {
+	var events []TestEvent
+	conv := newTestConverter("pkg", func(e TestEvent) {
+		if e.Package != "pkg" || e.Time.IsZero() {
+			t.Errorf("event %+v is missing its package or time", e)
+		}
+		events = append(events, e)
+	})
+	w := conv.writer()
+	// Write in odd-sized chunks to check lines are reassembled.
+	for data := testGoTestOutput; data != ""; {
+		n := 7
+		if n > len(data) {
+			n = len(data)
+		}
+		w.Write([]byte(data[:n]))
+		data = data[n:]
+	}
+	w.flush()
+	tests, passed, timedOut := conv.finish(1)
+
+	if passed || timedOut {
+		t.Errorf("got passed %t and timed out %t, want neither", passed, timedOut)
+	}
+	want := []TestResult{
+		{Name: "TestA", Action: "pass", Elapsed: 10 * time.Millisecond, Output: "    a_test.go:10: reading input\n"},
+		{Name: "TestB", Action: "skip", Output: "    b_test.go:3: skipped\n"},
+		{Name: "TestC", Action: "fail", Output: "    c_test.go:7: after\n"},
+		{Name: "TestC/sub", Action: "fail", Output: "    c_test.go:5: oops\n"},
+	}
+	if !reflect.DeepEqual(tests, want) {
+		t.Errorf("got tests %+v\nwant %+v", tests, want)
+	}
+
+	var actions []string
+	for _, e := range events {
+		if e.Action != "output" {
+			actions = append(actions, e.Action+" "+e.Test)
+		}
+	}
+	wantActions := []string{
+		"run TestA", "pass TestA", "run TestB", "pause TestB", "run TestC", "run TestC/sub",
+		"fail TestC/sub", "fail TestC", "cont TestB", "skip TestB", "fail ",
+	}
+	if !reflect.DeepEqual(actions, wantActions) {
+		t.Errorf("got actions %q\nwant %q", actions, wantActions)
+	}
+	if last := events[len(events)-2]; last.Action != "output" || last.Test != "" || last.Output != "FAIL\n" {
+		t.Errorf("got final output event %+v", last)
+	}
+}
+
+func TestTestConverterMarked(t *testing.T) {
+	// Once framing is marked, only marked lines frame tests, and errors and
+	// escapes are unmarked in the output.
+	const output = "\x16=== RUN   TestA\n" +
+		"--- FAIL: TestFake (0.00s)\n" +
+		"    a_test.go:3: \x0fbad \x1b\x16value\x0e\n" +
+		"\x16--- PASS: TestA (0.00s)\n" +
+		"=== RUN   TestFake\n" +
+		"\x16PASS\n"
+	var events []TestEvent
+	conv := newTestConverter("pkg", func(e TestEvent) { events = append(events, e) })
+	conv.start()
+	w := conv.writer()
+	w.Write([]byte(output))
+	w.flush()
+	tests, passed, _ := conv.finish(0)
+
+	if !passed {
+		t.Errorf("expected the run to pass")
+	}
+	want := []TestResult{
+		{Name: "TestA", Action: "pass", Output: "--- FAIL: TestFake (0.00s)\n    a_test.go:3: bad \x16value\n=== RUN   TestFake\n"},
+	}
+	if !reflect.DeepEqual(tests, want) {
+		t.Errorf("got tests %+v\nwant %+v", tests, want)
+	}
+	var actions []string
+	for _, e := range events {
+		if e.Action != "output" {
+			actions = append(actions, e.Action+" "+e.Test)
+		}
+	}
+	wantActions := []string{"start ", "run TestA", "pass TestA", "pass "}
+	if !reflect.DeepEqual(actions, wantActions) {
+		t.Errorf("got actions %q\nwant %q", actions, wantActions)
+	}
+}
+
+func TestRunGoTest(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "fake.test")
+	script := `#!/bin/sh
+printf '\026=== RUN   TestA\n'
+echo "    a_test.go:10: reading $(cat testdata/input)"
+printf '\026--- PASS: TestA (0.01s)\n'
+printf '\026=== RUN   TestB\n'
+echo "$*" > "$TEST_OUTPUT_DIR/args"
+printf '\026--- FAIL: TestB (0.00s)\n'
+printf '\026FAIL\n'
+[ -d cover ] && echo "unexpected coverage directory"
+exit 1
+`
+	if err := ioutil.WriteFile(binary, []byte(script), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	testdata := filepath.Join(dir, "testdata")
+	if err := os.Mkdir(testdata, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(testdata, "input"), []byte("the input\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outDir := filepath.Join(dir, "out")
+
+	var events []TestEvent
+	result, err := client.RunGoTest(ctx, binary, GoTestOptions{
+		Testdata:  testdata,
+		Args:      []string{"-test.run", "Test"},
+		Timeout:   time.Minute,
+		OutputDir: outDir,
+		Events:    func(e TestEvent) { events = append(events, e) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Passed || result.TimedOut || result.ExitStatus != 1 {
+		t.Errorf("got result %+v, want a failure with exit status 1", result)
+	}
+	if len(result.Tests) != 2 {
+		t.Errorf("got tests %+v, want TestA and TestB", result.Tests)
+	}
+	if a := result.Test("TestA"); a == nil || a.Action != "pass" || a.Output != "    a_test.go:10: reading the input\n" {
+		t.Errorf("got TestA %+v", a)
+	}
+	if b := result.Test("TestB"); b == nil || b.Action != "fail" {
+		t.Errorf("got TestB %+v", b)
+	}
+	if len(events) == 0 || events[0].Package != "fake" || events[0].Action != "start" || events[len(events)-1].Action != "fail" {
+		t.Errorf("got events %+v", events)
+	}
+
+	args, err := ioutil.ReadFile(filepath.Join(outDir, "args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"-test.v=test2json", "-test.timeout=1m0s", "-test.run Test"} {
+		if !strings.Contains(string(args), want) {
+			t.Errorf("args %q don't contain %q", args, want)
+		}
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		oldGrace := goTestKillGrace
+		goTestKillGrace = 0
+		t.Cleanup(func() { goTestKillGrace = oldGrace })
+
+		if err := ioutil.WriteFile(binary, []byte("#!/bin/sh\necho '=== RUN   TestSlow'\nexec sleep 10\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		result, err := client.RunGoTest(ctx, binary, GoTestOptions{Timeout: 500 * time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.TimedOut || result.Passed || result.ExitStatus != -1 {
+			t.Errorf("got result %+v, want a timeout", result)
+		}
+		if s := result.Test("TestSlow"); s == nil || s.Action != "" {
+			t.Errorf("got TestSlow %+v, want it unfinished", s)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("took %v to time out", elapsed)
+		}
+	})
+}
@@ -0,0 +1,448 @@
+// Copyright 2020 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package sshtest is intended only for use by tests that want to spin up a
+// local ssh server to test ssh client code. It uses password authentication
+// rather than key-based authentication, making it insecure and inappropriate
+// for production use.
+package sshtest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	testServerUser = "testuser"
+)
+
+// Server is an ssh server on localhost for tests.
+type Server struct {
+	// The address (IP + port) that the server is running on.
+	Addr net.Addr
+
+	// The configuration that clients can use to connect to the server.
+	ClientConfig *ssh.ClientConfig
+
+	// The configuration used by the server when accepting new connections.
+	serverConfig *ssh.ServerConfig
+
+	// The server listens on this channel and shuts down when Stop() closes it.
+	stopping chan struct{}
+
+	// onNewChannel is a callback that gets called when the server receives a
+	// new channel.
+	onNewChannel func(ssh.NewChannel)
+
+	// onNewChannel is a callback that gets called when the server receives a
+	// new out-of-band request.
+	onRequest func(*ssh.Request)
+
+	// wg tracks all the current goroutines that are able to serve connections,
+	// or launch new goroutines that themselves are able to serve connections.
+	wg sync.WaitGroup
+}
+
+// start launches the server and sets the server's address. It launches a
+// goroutine that listens for new connections until Stop() is called.
+func (s *Server) start() error {
+	// We don't care which port the server runs on as long as it doesn't collide
+	// with another process. Specifying ":0" gives us any available port.
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return err
+	}
+	s.Addr = listener.Addr()
+
+	// This goroutine is capable of launching new server goroutines, so the
+	// server can't be considered shut down if this goroutine is still running.
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			if err := listener.Close(); err != nil {
+				log.Panicf("failed to close listener: %v", err)
+			}
+		}()
+
+		// Use buffered channels so that if this goroutine exits and stops
+		// reading from the channels, the listening goroutine doesn't block
+		// trying to send on one of the channels and leak.
+		tcpConns := make(chan net.Conn, 1)
+		listenerErrs := make(chan error, 1)
+
+		for {
+			go func() {
+				tcpConn, err := listener.Accept()
+				if err != nil {
+					listenerErrs <- err
+					return
+				}
+				tcpConns <- tcpConn
+			}()
+
+			select {
+			case <-s.stopping:
+				return
+			case err := <-listenerErrs:
+				log.Panicf("testserver listener error: %v\n", err)
+			case tcpConn := <-tcpConns:
+				conn, incomingChannels, incomingRequests, err := ssh.NewServerConn(tcpConn, s.serverConfig)
+				if err != nil {
+					log.Panicf("testserver connection error: %v\n", err)
+				}
+
+				s.wg.Add(1)
+				go func() {
+					defer s.wg.Done()
+					s.serveConnection(conn, incomingChannels, incomingRequests)
+				}()
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop shuts down the server.
+func (s *Server) Stop() {
+	select {
+	case <-s.stopping:
+		return // Server has already been stopped, no more work to do.
+	default:
+		close(s.stopping)
+	}
+	// Block until we know that no new handshakes can occur, and that any
+	// existing connections can no longer be served.
+	s.wg.Wait()
+}
+
+func (s *Server) serveConnection(
+	conn *ssh.ServerConn,
+	incomingChannels <-chan ssh.NewChannel,
+	incomingRequests <-chan *ssh.Request,
+) {
+	// This might err out if the client is closed first, so don't bother
+	// checking the return value.
+	defer conn.Close()
+
+	for {
+		select {
+		case <-s.stopping:
+			return
+		case newChannel, ok := <-incomingChannels:
+			if !ok {
+				return
+			}
+			if s.onNewChannel != nil {
+				s.onNewChannel(newChannel)
+			}
+		case req, ok := <-incomingRequests:
+			if !ok {
+				return
+			}
+			if s.onRequest != nil {
+				s.onRequest(req)
+			}
+		}
+	}
+}
+
+// Start starts an ssh server on localhost, at any available port.
+func Start(onNewChannel func(ssh.NewChannel), onRequest func(*ssh.Request)) (*Server, error) {
+	serverConfig, clientConfig, err := GenConfig()
+
+	server := &Server{
+		ClientConfig: clientConfig,
+		serverConfig: serverConfig,
+		stopping:     make(chan struct{}),
+		onNewChannel: onNewChannel,
+		onRequest:    onRequest,
+	}
+	if err = server.start(); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// GenConfig generates a server configuration with a random password and host
+// key, and the configuration a client needs to connect to it.
+func GenConfig() (*ssh.ServerConfig, *ssh.ClientConfig, error) {
+	clientPassword, err := genPassword(40)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate password: %w", err)
+	}
+	serverConfig := &ssh.ServerConfig{
+		MaxAuthTries: 1,
+		PasswordCallback: func(metadata ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if metadata.User() != testServerUser || string(password) != clientPassword {
+				return nil, errors.New("invalid user/password combination")
+			}
+			return nil, nil
+		},
+	}
+
+	serverKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error generating keypair: %w", err)
+	}
+	signer, err := ssh.NewSignerFromKey(serverKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	serverConfig.AddHostKey(signer)
+
+	clientConfig := &ssh.ClientConfig{
+		User:            testServerUser,
+		Auth:            []ssh.AuthMethod{ssh.Password(clientPassword)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+
+	return serverConfig, clientConfig, nil
+}
+
+func genPassword(length int) (string, error) {
+	buf := make([]byte, length)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", buf), nil
+}
+
+// OnExecChannel is a helper method for creating a Server.onNewChannel which
+// will call a callback if the new channel request is a session with a single
+// request to execute a command. Any other channel or request type will result
+// in a panic.
+func OnExecChannel(f func(cmd string, stdout io.Writer, stderr io.Writer) int) func(ssh.NewChannel) {
+	return func(newChannel ssh.NewChannel) {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			return
+		}
+
+		ch, reqs, err := newChannel.Accept()
+		if err != nil {
+			log.Panicf("error accepting channel: %v", err)
+		}
+
+		go func() {
+			defer ch.Close()
+
+			req := <-reqs
+			switch req.Type {
+			case "exec":
+				var execMsg struct{ Command string }
+				if err := ssh.Unmarshal(req.Payload, &execMsg); err != nil {
+					log.Panicf("failed to unmarshal payload: %v", err)
+				}
+				if err := req.Reply(true, nil); err != nil {
+					log.Panicf("failed to send reply: %v", err)
+				}
+
+				exitStatus := f(execMsg.Command, ch, ch.Stderr())
+
+				exitMsg := struct {
+					ExitStatus uint32
+				}{ExitStatus: uint32(exitStatus)}
+
+				if _, err := ch.SendRequest("exit-status", false, ssh.Marshal(&exitMsg)); err != nil {
+					log.Panicf("failed to send exit status: %v", err)
+				}
+			default:
+				log.Panicf("unexpected request type: %v", req.Type)
+			}
+		}()
+	}
+}
+
+// OnShellChannel is a helper method for creating a Server.onNewChannel
+// which runs exec requests with the local shell and serves the sftp subsystem
+// from the local filesystem. This lets tests exercise APIs that combine
+// commands and file transfers against a real, if local, "remote".
+func OnShellChannel() func(ssh.NewChannel) {
+	return OnSessionChannel(map[string]func(io.ReadWriteCloser){
+		"sftp": ServeSFTP,
+	})
+}
+
+// OnSessionChannel is like OnShellChannel, but serves the given
+// subsystems rather than just sftp.
+func OnSessionChannel(subsystems map[string]func(io.ReadWriteCloser)) func(ssh.NewChannel) {
+	return func(newChannel ssh.NewChannel) {
+		if newChannel.ChannelType() == "direct-tcpip" {
+			serveDirectTCPIP(newChannel)
+			return
+		}
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			return
+		}
+
+		ch, reqs, err := newChannel.Accept()
+		if err != nil {
+			log.Panicf("error accepting channel: %v", err)
+		}
+
+		go func() {
+			// Once a command starts, it closes the channel when it exits,
+			// and requests such as window changes are still served.
+			started := false
+			defer func() {
+				if !started {
+					ch.Close()
+				}
+			}()
+
+			var env []string
+			for req := range reqs {
+				switch req.Type {
+				case "env":
+					var envMsg struct{ Name, Value string }
+					if err := ssh.Unmarshal(req.Payload, &envMsg); err != nil {
+						log.Panicf("failed to unmarshal payload: %v", err)
+					}
+					env = append(env, envMsg.Name+"="+envMsg.Value)
+					req.Reply(true, nil)
+				case "pty-req":
+					// The command doesn't get a terminal, but sees the one
+					// asked for in its environment.
+					var ptyMsg struct {
+						Term                         string
+						Columns, Rows, Width, Height uint32
+						Modes                        string
+					}
+					if err := ssh.Unmarshal(req.Payload, &ptyMsg); err != nil {
+						log.Panicf("failed to unmarshal payload: %v", err)
+					}
+					env = append(env, "TERM="+ptyMsg.Term,
+						fmt.Sprintf("COLUMNS=%d", ptyMsg.Columns), fmt.Sprintf("LINES=%d", ptyMsg.Rows))
+					req.Reply(true, nil)
+				case "window-change":
+					req.Reply(true, nil)
+				case "exec", "shell":
+					command := "exec /bin/sh"
+					if req.Type == "exec" {
+						var execMsg struct{ Command string }
+						if err := ssh.Unmarshal(req.Payload, &execMsg); err != nil {
+							log.Panicf("failed to unmarshal payload: %v", err)
+						}
+						command = execMsg.Command
+					}
+					req.Reply(true, nil)
+
+					started = true
+					go func(env []string) {
+						defer ch.Close()
+						exitMsg := struct {
+							ExitStatus uint32
+						}{ExitStatus: uint32(runLocalShell(command, env, ch))}
+						ch.SendRequest("exit-status", false, ssh.Marshal(&exitMsg))
+					}(append([]string(nil), env...))
+				case "subsystem":
+					var subsystemMsg struct{ Name string }
+					if err := ssh.Unmarshal(req.Payload, &subsystemMsg); err != nil {
+						log.Panicf("failed to unmarshal payload: %v", err)
+					}
+					serve, ok := subsystems[subsystemMsg.Name]
+					req.Reply(ok, nil)
+					if ok {
+						serve(ch)
+					}
+					return
+				default:
+					req.Reply(false, nil)
+				}
+			}
+		}()
+	}
+}
+
+// runLocalShell runs cmd with `sh -c`, wiring its standard streams to the
+// channel, and returns its exit status.
+func runLocalShell(cmd string, env []string, ch ssh.Channel) int {
+	c := exec.Command("/bin/sh", "-c", cmd)
+	c.Env = append(os.Environ(), env...)
+	c.Stdout = ch
+	c.Stderr = ch.Stderr()
+	stdin, err := c.StdinPipe()
+	if err != nil {
+		log.Panicf("failed to create stdin pipe: %v", err)
+	}
+	if err := c.Start(); err != nil {
+		return 127
+	}
+	// Don't wait for the copy to finish, since the command may exit without
+	// consuming all of its input.
+	go func() {
+		io.Copy(stdin, ch)
+		stdin.Close()
+	}()
+	if err := c.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		return 255
+	}
+	return 0
+}
+
+// serveDirectTCPIP connects a channel opened to forward a port to the address
+// it asks for, as dialed from the local machine.
+func serveDirectTCPIP(newChannel ssh.NewChannel) {
+	var msg struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &msg); err != nil {
+		log.Panicf("failed to unmarshal payload: %v", err)
+	}
+	conn, err := net.Dial("tcp", net.JoinHostPort(msg.Host, fmt.Sprint(msg.Port)))
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	ch, reqs, err := newChannel.Accept()
+	if err != nil {
+		log.Panicf("error accepting channel: %v", err)
+	}
+	go ssh.DiscardRequests(reqs)
+	go func() {
+		defer ch.Close()
+		defer conn.Close()
+		done := make(chan struct{}, 2)
+		go func() {
+			io.Copy(conn, ch)
+			done <- struct{}{}
+		}()
+		go func() {
+			io.Copy(ch, conn)
+			done <- struct{}{}
+		}()
+		<-done
+	}()
+}
+
+// ServeSFTP serves the sftp subsystem from the local filesystem.
+func ServeSFTP(rwc io.ReadWriteCloser) {
+	server, err := sftp.NewServer(rwc)
+	if err != nil {
+		log.Panicf("failed to create sftp server: %v", err)
+	}
+	server.Serve()
+	server.Close()
+}
@@ -2,42 +2,35 @@
 // Use of this source code is governed by a BSD-style license that can be
 // found in the LICENSE file.
 
-package sshutil
+package sshtest
 
 import (
-	"context"
 	"testing"
+
+	"golang.org/x/crypto/ssh"
 )
 
 func TestRunMultipleServers(t *testing.T) {
 	count := 3
 	for i := 0; i < count; i++ {
-		server, err := startSSHServer(nil, nil)
+		server, err := Start(nil, nil)
 		if err != nil {
 			t.Fatalf("failed to start ssh server #%d: %v", i, err)
 		}
-		defer server.stop()
+		defer server.Stop()
 	}
 }
 
 func TestConnectAndClose(t *testing.T) {
-	server, err := startSSHServer(nil, nil)
+	server, err := Start(nil, nil)
 	if err != nil {
 		t.Fatalf("failed to start ssh server: %v", err)
 	}
-	defer server.stop()
+	defer server.Stop()
 
-	client, err := NewClient(
-		context.Background(),
-		ConstantAddrResolver{
-			Addr: server.addr,
-		},
-		server.clientConfig,
-		DefaultConnectBackoff(),
-	)
+	client, err := ssh.Dial("tcp", server.Addr.String(), server.ClientConfig)
 	if err != nil {
-		t.Errorf("failed to connect: %v", err)
+		t.Fatalf("failed to connect: %v", err)
 	}
-
 	client.Close()
 }
@@ -0,0 +1,325 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strings"
+
+	"go.fuchsia.dev/fuchsia/tools/lib/logger"
+)
+
+var (
+	// cgroupRoot is where the cgroup v2 hierarchy is mounted on the remote.
+	cgroupRoot = "/sys/fs/cgroup"
+
+	// systemdRunDir exists on the remote if systemd is the running init.
+	systemdRunDir = "/run/systemd/system"
+)
+
+// Limits are resource limits for a remote command. Zero values are unlimited.
+type Limits struct {
+	// CPU is the CPU time the command may use, as a fraction of one CPU, so
+	// 0.5 is half a CPU and 2 is two CPUs.
+	CPU float64
+
+	// Memory is the memory the command may use, in bytes.
+	Memory int64
+
+	// PIDs is the number of processes and threads the command may run. When
+	// it is enforced with ulimit, it counts every process of the user rather
+	// than just the command's.
+	PIDs int
+
+	// IOWeight is the command's share of block IO, from 1 to 10000, relative
+	// to the default of 100.
+	IOWeight int
+}
+
+// Limit is a kind of resource limit.
+type Limit string
+
+const (
+	LimitCPU    Limit = "cpu"
+	LimitMemory Limit = "memory"
+	LimitPIDs   Limit = "pids"
+	LimitIO     Limit = "io"
+)
+
+// requested returns the limits that are set, in a fixed order.
+func (l Limits) requested() []Limit {
+	var limits []Limit
+	if l.CPU > 0 {
+		limits = append(limits, LimitCPU)
+	}
+	if l.Memory > 0 {
+		limits = append(limits, LimitMemory)
+	}
+	if l.PIDs > 0 {
+		limits = append(limits, LimitPIDs)
+	}
+	if l.IOWeight > 0 {
+		limits = append(limits, LimitIO)
+	}
+	return limits
+}
+
+// LimitMethod is how RunLimited applied limits.
+type LimitMethod string
+
+const (
+	// LimitMethodSystemd runs the command in a transient scope with
+	// `systemd-run --scope`.
+	LimitMethodSystemd LimitMethod = "systemd-run"
+
+	// LimitMethodCgroup runs the command in a cgroup v2 group created for it.
+	LimitMethodCgroup LimitMethod = "cgroup"
+
+	// LimitMethodUlimit limits the command's address space and the user's
+	// processes with ulimit, and lowers its CPU and IO priority with nice and
+	// ionice. Since priorities don't bound usage, only the memory and process
+	// limits are enforced, and the process limit only if the user isn't root.
+	LimitMethodUlimit LimitMethod = "ulimit"
+)
+
+// LimitReport is what RunLimited enforced.
+type LimitReport struct {
+	// Method is how the limits were applied, or empty if none were requested.
+	Method LimitMethod
+
+	Enforced   []Limit
+	Unenforced []Limit
+}
+
+func (r *LimitReport) add(limit Limit, enforced bool) {
+	if enforced {
+		r.Enforced = append(r.Enforced, limit)
+	} else {
+		r.Unenforced = append(r.Unenforced, limit)
+	}
+}
+
+// limitSupport is what the remote offers for limiting commands.
+type limitSupport struct {
+	root        bool
+	systemd     bool
+	cgroup2     bool
+	controllers map[string]bool
+	nice        bool
+	ionice      bool
+}
+
+func (c *Client) detectLimitSupport(ctx context.Context) (*limitSupport, error) {
+	script := fmt.Sprintf(`id -u
+command -v systemd-run >/dev/null 2>&1 && [ -d %[1]s ] && echo systemd
+[ -f %[2]s/cgroup.controllers ] && echo cgroup2 $(cat %[2]s/cgroup.controllers)
+command -v nice >/dev/null 2>&1 && echo nice
+command -v ionice >/dev/null 2>&1 && echo ionice
+exit 0`, ShellQuote(systemdRunDir), ShellQuote(cgroupRoot))
+	out, err := c.ShellOutput(ctx, script)
+	if err != nil {
+		return nil, err
+	}
+	s := &limitSupport{controllers: make(map[string]bool)}
+	for i, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if i == 0 {
+			s.root = fields[0] == "0"
+			continue
+		}
+		switch fields[0] {
+		case "systemd":
+			s.systemd = true
+		case "cgroup2":
+			s.cgroup2 = true
+			for _, c := range fields[1:] {
+				s.controllers[c] = true
+			}
+		case "nice":
+			s.nice = true
+		case "ionice":
+			s.ionice = true
+		}
+	}
+	return s, nil
+}
+
+// RunLimited runs a command to completion like Run, under limits on the
+// resources it may use. The limits are applied with `systemd-run --scope` if
+// the remote runs systemd, by creating a cgroup v2 group otherwise, and with
+// ulimit, nice and ionice where neither is possible, which is the case unless
+// the user is root. The report lists which limits were enforced, and is
+// returned whenever the command ran, even if it failed.
+//
+// As with Run, command is joined with spaces and interpreted by the remote
+// shell.
+func (c *Client) RunLimited(ctx context.Context, command []string, limits Limits, stdout io.Writer, stderr io.Writer) (*LimitReport, error) {
+	report := &LimitReport{}
+	requested := limits.requested()
+	if len(requested) == 0 {
+		return report, c.Run(ctx, command, stdout, stderr)
+	}
+
+	support, err := c.detectLimitSupport(ctx)
+	if err != nil {
+		return nil, err
+	}
+	script := strings.Join(command, " ")
+	switch {
+	case support.root && support.systemd:
+		report.Method = LimitMethodSystemd
+		return report, c.runSystemdScope(ctx, script, limits, support, report, stdout, stderr)
+	case support.root && support.cgroup2:
+		report.Method = LimitMethodCgroup
+		return c.runInCgroup(ctx, script, limits, report, stdout, stderr)
+	default:
+		report.Method = LimitMethodUlimit
+		return report, c.runUlimited(ctx, script, limits, support, report, stdout, stderr)
+	}
+}
+
+func (c *Client) runSystemdScope(ctx context.Context, script string, limits Limits, support *limitSupport, report *LimitReport, stdout, stderr io.Writer) error {
+	cmd := []string{"systemd-run", "--scope", "--quiet"}
+	for _, limit := range limits.requested() {
+		switch limit {
+		case LimitCPU:
+			cmd = append(cmd, "-p", fmt.Sprintf("CPUQuota=%d%%", int(math.Ceil(limits.CPU*100))))
+		case LimitMemory:
+			cmd = append(cmd, "-p", fmt.Sprintf("MemoryMax=%d", limits.Memory))
+		case LimitPIDs:
+			cmd = append(cmd, "-p", fmt.Sprintf("TasksMax=%d", limits.PIDs))
+		case LimitIO:
+			cmd = append(cmd, "-p", fmt.Sprintf("IOWeight=%d", limits.IOWeight))
+		}
+		// On the legacy hierarchy, systemd enables the controllers itself.
+		report.add(limit, !support.cgroup2 || support.controllers[string(limit)])
+	}
+	cmd = append(cmd, "--", "sh", "-c", ShellQuote(script))
+	return c.Run(ctx, cmd, stdout, stderr)
+}
+
+// cgroupCPUPeriod is the period, in microseconds, over which a cgroup's CPU
+// quota is enforced. cgroupMinCPUQuota is the smallest quota the kernel
+// accepts.
+const (
+	cgroupCPUPeriod   = 100000
+	cgroupMinCPUQuota = 1000
+)
+
+// cgroupCPUQuota returns the quota in microseconds per cgroupCPUPeriod for a
+// fraction of a CPU, rounded up so that small fractions aren't unlimited or
+// refused.
+func cgroupCPUQuota(cpu float64) int {
+	quota := int(math.Ceil(cpu * cgroupCPUPeriod))
+	if quota < cgroupMinCPUQuota {
+		quota = cgroupMinCPUQuota
+	}
+	return quota
+}
+
+// cgroupSetupScript creates the group $1, enables the controllers its limits
+// need in the parent, and writes each following "file=value" argument to the
+// group, printing the names of the files it could write.
+const cgroupSetupScript = `dir=$1
+shift
+for kv; do
+	f=${kv%%=*}
+	echo "+${f%%.*}" > "${dir%/*}/cgroup.subtree_control" 2>/dev/null
+done
+mkdir "$dir" || exit 1
+for kv; do
+	f=${kv%%=*}
+	echo "${kv#*=}" 2>/dev/null > "$dir/$f" && echo "$f"
+done
+exit 0`
+
+// cgroupRunScript moves the shell into the group $1 and runs the script $2.
+const cgroupRunScript = `echo $$ > "$1/cgroup.procs" || exit 125
+exec sh -c "$2"`
+
+func (c *Client) runInCgroup(ctx context.Context, script string, limits Limits, report *LimitReport, stdout, stderr io.Writer) (*LimitReport, error) {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return nil, err
+	}
+	dir := path.Join(cgroupRoot, fmt.Sprintf("sshutil-%x", buf))
+
+	files := map[Limit]string{
+		LimitCPU:    fmt.Sprintf("cpu.max=%d %d", cgroupCPUQuota(limits.CPU), cgroupCPUPeriod),
+		LimitMemory: fmt.Sprintf("memory.max=%d", limits.Memory),
+		LimitPIDs:   fmt.Sprintf("pids.max=%d", limits.PIDs),
+		LimitIO:     fmt.Sprintf("io.weight=%d", limits.IOWeight),
+	}
+	args := []string{"sh", "-c", cgroupSetupScript, "sh", dir}
+	for _, limit := range limits.requested() {
+		args = append(args, files[limit])
+	}
+	out, err := c.Output(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	written := make(map[string]bool)
+	for _, f := range strings.Fields(string(out)) {
+		written[f] = true
+	}
+	for _, limit := range limits.requested() {
+		f := files[limit]
+		report.add(limit, written[f[:strings.IndexByte(f, '=')]])
+	}
+
+	err = c.Run(ctx, []string{"sh", "-c", ShellQuote(cgroupRunScript), "sh", ShellQuote(dir), ShellQuote(script)}, stdout, stderr)
+	// The group can only be removed once every process in it has exited, so
+	// one left running in the background keeps it around. It's removed even
+	// if ctx was cancelled, which is what ended the command.
+	rmCtx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+	if _, rmErr := c.Output(rmCtx, "rmdir", dir); rmErr != nil {
+		logger.Warningf(ctx, "failed to remove cgroup %s: %v", dir, rmErr)
+	}
+	return report, err
+}
+
+func (c *Client) runUlimited(ctx context.Context, script string, limits Limits, support *limitSupport, report *LimitReport, stdout, stderr io.Writer) error {
+	// The ulimit builtin applies to the shell that goes on to exec the
+	// command, under nice and ionice if they're needed.
+	var ulimit string
+	wrapper := []string{"exec"}
+	for _, limit := range limits.requested() {
+		switch limit {
+		case LimitMemory:
+			ulimit += fmt.Sprintf("ulimit -v %d || exit 125; ", (limits.Memory+1023)/1024)
+			report.add(limit, true)
+			continue
+		case LimitPIDs:
+			// RLIMIT_NPROC doesn't apply to root. Shells disagree on the
+			// option that sets it: dash uses -p, which is the pipe size in
+			// bash.
+			if !support.root {
+				ulimit += fmt.Sprintf("{ ulimit -u %[1]d || ulimit -p %[1]d; } 2>/dev/null || exit 125; ", limits.PIDs)
+				report.add(limit, true)
+				continue
+			}
+		case LimitCPU:
+			if support.nice {
+				wrapper = append(wrapper, "nice", "-n", "10")
+			}
+		case LimitIO:
+			if support.ionice && limits.IOWeight < 100 {
+				wrapper = append(wrapper, "ionice", "-c", "2", "-n", "7")
+			}
+		}
+		report.add(limit, false)
+	}
+	wrapper = append(wrapper, "sh", "-c", `"$1"`)
+	return c.Run(ctx, []string{"sh", "-c", ShellQuote(ulimit + strings.Join(wrapper, " ")), "sh", ShellQuote(script)}, stdout, stderr)
+}
@@ -0,0 +1,214 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+)
+
+// setUpLimitTools puts fake versions of tools on the PATH and points the
+// cgroup hierarchy at a directory offering controllers. The fake id reports
+// uid, and the other fakes log their arguments and run the command following
+// their options.
+func setUpLimitTools(t *testing.T, uid string, controllers string, tools ...string) (dir, cgroups string) {
+	all := map[string]string{
+		"id":          "#!/bin/sh\necho " + uid + "\n",
+		"systemd-run": "#!/bin/sh\necho \"systemd-run $*\" >> \"${0%/*}/log\"\nwhile [ \"$1\" != -- ]; do shift; done\nshift\nexec \"$@\"\n",
+		"nice":        "#!/bin/sh\necho \"nice $*\" >> \"${0%/*}/log\"\nshift 2\nexec \"$@\"\n",
+		"ionice":      "#!/bin/sh\necho \"ionice $*\" >> \"${0%/*}/log\"\nshift 4\nexec \"$@\"\n",
+		"rmdir":       "#!/bin/sh\nexec rm -r \"$@\"\n",
+	}
+	fakes := make(map[string]string)
+	for _, tool := range append([]string{"id", "rmdir"}, tools...) {
+		fakes[tool] = all[tool]
+	}
+	dir = setUpPath(t, fakes, "sh", "cat", "mkdir", "rm")
+
+	cgroups = filepath.Join(dir, "cgroup")
+	if err := os.Mkdir(cgroups, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if controllers != "" {
+		if err := ioutil.WriteFile(filepath.Join(cgroups, "cgroup.controllers"), []byte(controllers+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldRoot, oldRunDir := cgroupRoot, systemdRunDir
+	cgroupRoot, systemdRunDir = cgroups, filepath.Join(dir, "no-systemd")
+	t.Cleanup(func() { cgroupRoot, systemdRunDir = oldRoot, oldRunDir })
+	return dir, cgroups
+}
+
+func readLog(t *testing.T, dir string) string {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "log"))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func TestRunLimited(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+
+	limits := Limits{CPU: 0.5, Memory: 64 << 20, PIDs: 32, IOWeight: 50}
+
+	t.Run("systemd", func(t *testing.T) {
+		dir, _ := setUpLimitTools(t, "0", "cpu memory pids", "systemd-run")
+		systemdRunDir = dir
+
+		var stdout bytes.Buffer
+		report, err := client.RunLimited(ctx, []string{"echo", "a", "|", "cat"}, limits, &stdout, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stdout.String() != "a\n" {
+			t.Errorf("got output %q, want %q", stdout.String(), "a\n")
+		}
+		want := &LimitReport{
+			Method:     LimitMethodSystemd,
+			Enforced:   []Limit{LimitCPU, LimitMemory, LimitPIDs},
+			Unenforced: []Limit{LimitIO},
+		}
+		if !reflect.DeepEqual(report, want) {
+			t.Errorf("got report %+v, want %+v", report, want)
+		}
+		wantLog := "systemd-run --scope --quiet -p CPUQuota=50% -p MemoryMax=67108864 -p TasksMax=32 -p IOWeight=50 -- sh -c echo a | cat"
+		if got := readLog(t, dir); got != wantLog {
+			t.Errorf("got command %q, want %q", got, wantLog)
+		}
+	})
+
+	t.Run("cgroup", func(t *testing.T) {
+		_, cgroups := setUpLimitTools(t, "0", "cpu memory pids io")
+
+		// The group's name is random, so find it with a glob.
+		var stdout bytes.Buffer
+		var files []string
+		for _, f := range []string{"cpu.max", "memory.max", "pids.max", "io.weight", "cgroup.procs"} {
+			files = append(files, filepath.Join(cgroups, "sshutil-*", f))
+		}
+		report, err := client.RunLimited(ctx, append([]string{"cat"}, files...), limits, &stdout, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		if len(lines) != 5 || !reflect.DeepEqual(lines[:4], []string{"50000 100000", "67108864", "32", "50"}) {
+			t.Errorf("got group files %q", lines)
+		}
+		want := &LimitReport{
+			Method:   LimitMethodCgroup,
+			Enforced: []Limit{LimitCPU, LimitMemory, LimitPIDs, LimitIO},
+		}
+		if !reflect.DeepEqual(report, want) {
+			t.Errorf("got report %+v, want %+v", report, want)
+		}
+		if got := readLog(t, filepath.Dir(cgroups)); got != "" {
+			t.Errorf("unexpected commands: %s", got)
+		}
+		subtree, err := ioutil.ReadFile(filepath.Join(cgroups, "cgroup.subtree_control"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(subtree) != "+io\n" {
+			t.Errorf("got subtree_control %q, want the last controller written", subtree)
+		}
+		entries, err := ioutil.ReadDir(cgroups)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				t.Errorf("group %s wasn't removed", e.Name())
+			}
+		}
+	})
+
+	t.Run("cgroup removed after cancellation", func(t *testing.T) {
+		_, cgroups := setUpLimitTools(t, "0", "pids")
+
+		// The command's output cancels the context, as if the caller gave up
+		// on it while it ran.
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stdout := writerFunc(func(p []byte) (int, error) {
+			cancel()
+			return len(p), nil
+		})
+		client.RunLimited(ctx, []string{"echo", "hi"}, Limits{PIDs: 32}, stdout, nil)
+		entries, err := ioutil.ReadDir(cgroups)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				t.Errorf("group %s wasn't removed", e.Name())
+			}
+		}
+	})
+
+	t.Run("ulimit", func(t *testing.T) {
+		dir, _ := setUpLimitTools(t, "1000", "", "nice", "ionice")
+
+		var stdout bytes.Buffer
+		report, err := client.RunLimited(ctx, []string{"ulimit", "-v"}, limits, &stdout, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(stdout.String()); got != "65536" {
+			t.Errorf("got address space limit %q, want 65536", got)
+		}
+		want := &LimitReport{
+			Method:     LimitMethodUlimit,
+			Enforced:   []Limit{LimitMemory, LimitPIDs},
+			Unenforced: []Limit{LimitCPU, LimitIO},
+		}
+		if !reflect.DeepEqual(report, want) {
+			t.Errorf("got report %+v, want %+v", report, want)
+		}
+		wantLog := "nice -n 10 ionice -c 2 -n 7 sh -c ulimit -v\nionice -c 2 -n 7 sh -c ulimit -v"
+		if got := readLog(t, dir); got != wantLog {
+			t.Errorf("got commands %q, want %q", got, wantLog)
+		}
+	})
+
+	t.Run("no limits", func(t *testing.T) {
+		var stdout bytes.Buffer
+		report, err := client.RunLimited(ctx, []string{"echo", "hi"}, Limits{}, &stdout, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Method != "" || stdout.String() != "hi\n" {
+			t.Errorf("got report %+v and output %q", report, stdout.String())
+		}
+	})
+}
+
+func TestCgroupCPUQuota(t *testing.T) {
+	for cpu, want := range map[float64]int{
+		2:      200000,
+		0.5:    50000,
+		0.0001: 1000,
+		0.0123: 1230,
+	} {
+		if got := cgroupCPUQuota(cpu); got != want {
+			t.Errorf("cgroupCPUQuota(%v) = %d, want %d", cpu, got, want)
+		}
+	}
+}
+
+// writerFunc adapts a function to io.Writer.
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
@@ -0,0 +1,231 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ManifestEntry describes a single file, directory or symlink in a tree.
+type ManifestEntry struct {
+	// Slash-separated path relative to the root of the tree. The root itself
+	// is "".
+	Path string      `json:"path"`
+	Mode os.FileMode `json:"mode"`
+
+	// Size and SHA256 are only set for regular files.
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+
+	// Target is only set for symlinks.
+	Target string `json:"target,omitempty"`
+}
+
+// Manifest lists the expected contents of a tree. It serializes to JSON so it
+// can be stored alongside a deployment.
+type Manifest struct {
+	Entries []ManifestEntry `json:"entries"`
+}
+
+// GenerateManifest builds a manifest of the local file or directory tree at
+// root. Symlinks are recorded as links and not followed.
+func GenerateManifest(root string) (*Manifest, error) {
+	entries, err := walkTree(localFS{}, root, SymlinkCopy)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	for _, e := range entries {
+		me := ManifestEntry{Path: e.rel, Mode: e.mode, Target: e.link}
+		if e.isRegular() {
+			me.Size = e.size
+			if me.SHA256, err = hashLocalFile(localPath(root, e.rel)); err != nil {
+				return nil, err
+			}
+		}
+		m.Entries = append(m.Entries, me)
+	}
+	return m, nil
+}
+
+func hashLocalFile(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ManifestMismatch is an entry that exists both in the manifest and the tree,
+// but differs.
+type ManifestMismatch struct {
+	Path     string
+	Expected ManifestEntry
+	Actual   ManifestEntry
+
+	// The fields that differ: any of "type", "mode", "size", "sha256" and
+	// "target". A file that couldn't be read on the remote differs in
+	// "sha256", with an empty hash in Actual.
+	Fields []string
+}
+
+// ManifestDiff is the result of verifying a tree against a manifest.
+type ManifestDiff struct {
+	// Entries in the manifest that don't exist in the tree.
+	Missing []ManifestEntry
+
+	// Entries in the tree that aren't in the manifest.
+	Extra []ManifestEntry
+
+	// Entries that differ between the manifest and the tree.
+	Modified []ManifestMismatch
+}
+
+// Empty reports whether the tree exactly matched the manifest.
+func (d *ManifestDiff) Empty() bool {
+	return len(d.Missing) == 0 && len(d.Extra) == 0 && len(d.Modified) == 0
+}
+
+// VerifyManifest checks the remote tree at root against a manifest. File
+// hashes are computed on the remote in a single command, so no file contents
+// are downloaded.
+func (f *FileClient) VerifyManifest(ctx context.Context, root string, m *Manifest) (*ManifestDiff, error) {
+	entries, err := walkTree(f.sftp, root, SymlinkCopy)
+	if err != nil {
+		return nil, err
+	}
+	hashes, err := f.client.sha256Tree(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+
+	actual := make(map[string]ManifestEntry, len(entries))
+	for _, e := range entries {
+		me := ManifestEntry{Path: e.rel, Mode: e.mode, Target: e.link}
+		if e.isRegular() {
+			me.Size = e.size
+			me.SHA256 = hashes[e.rel]
+		}
+		actual[e.rel] = me
+	}
+	return diffManifest(m, actual), nil
+}
+
+func diffManifest(m *Manifest, actual map[string]ManifestEntry) *ManifestDiff {
+	diff := &ManifestDiff{}
+	expected := make(map[string]bool, len(m.Entries))
+	for _, want := range m.Entries {
+		expected[want.Path] = true
+		got, ok := actual[want.Path]
+		if !ok {
+			diff.Missing = append(diff.Missing, want)
+			continue
+		}
+		var fields []string
+		if want.Mode.Type() != got.Mode.Type() {
+			fields = append(fields, "type")
+		} else {
+			if want.Mode.Perm() != got.Mode.Perm() && want.Mode&os.ModeSymlink == 0 {
+				fields = append(fields, "mode")
+			}
+			if want.Size != got.Size {
+				fields = append(fields, "size")
+			}
+			if want.SHA256 != got.SHA256 {
+				fields = append(fields, "sha256")
+			}
+			if want.Target != got.Target {
+				fields = append(fields, "target")
+			}
+		}
+		if len(fields) > 0 {
+			diff.Modified = append(diff.Modified, ManifestMismatch{
+				Path:     want.Path,
+				Expected: want,
+				Actual:   got,
+				Fields:   fields,
+			})
+		}
+	}
+	for p, got := range actual {
+		if !expected[p] {
+			diff.Extra = append(diff.Extra, got)
+		}
+	}
+	sort.Slice(diff.Extra, func(i, j int) bool { return diff.Extra[i].Path < diff.Extra[j].Path })
+	return diff
+}
+
+// sha256TreeScript hashes the regular files beneath $1. Files that can't be
+// read are left out rather than failing the script, but a missing sha256sum
+// fails it.
+const sha256TreeScript = `command -v sha256sum >/dev/null || exit 127
+find "$1" -type f -exec sha256sum {} + 2>/dev/null
+exit 0`
+
+// sha256Tree hashes every regular file beneath root on the remote, returning
+// the hashes keyed by slash-separated path relative to root. Files that can't
+// be read have no hash.
+func (c *Client) sha256Tree(ctx context.Context, root string) (map[string]string, error) {
+	root = path.Clean(root)
+	out, err := c.Output(ctx, "sh", "-c", sha256TreeScript, "sh", root)
+	if err != nil {
+		return nil, err
+	}
+	prefix := root
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	hashes := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		sum, name, err := parseSHA256SumLine(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		if name == root {
+			hashes[""] = sum
+		} else {
+			hashes[strings.TrimPrefix(name, prefix)] = sum
+		}
+	}
+	return hashes, scanner.Err()
+}
+
+// parseSHA256SumLine parses a line of `sha256sum` output. GNU coreutils
+// escapes names containing a backslash, newline or carriage return, marking
+// the line with a leading backslash.
+func parseSHA256SumLine(line string) (sum, name string, err error) {
+	escaped := strings.HasPrefix(line, `\`)
+	if escaped {
+		line = line[1:]
+	}
+	if len(line) < 66 || line[64] != ' ' {
+		return "", "", fmt.Errorf("unexpected sha256sum output: %q", line)
+	}
+	// The separator is two spaces in text mode, or a space and an asterisk
+	// in binary mode.
+	sum, name = line[:64], line[66:]
+	if escaped {
+		name = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\r`, "\r").Replace(name)
+	}
+	return sum, name, nil
+}
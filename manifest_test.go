@@ -0,0 +1,145 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	ctx := context.Background()
+	f := setUpFileClient(ctx, t)
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"a.txt":          "a",
+		"sub/b.txt":      "b",
+		"sub/c.txt":      "c",
+		"with\nnewline":  "n",
+		`back\slash.txt`: "s",
+	})
+	if err := os.Symlink("a.txt", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := GenerateManifest(root)
+	if err != nil {
+		t.Fatalf("GenerateManifest failed: %v", err)
+	}
+
+	// Manifests should survive a round trip through JSON.
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Manifest
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*m, decoded) {
+		t.Errorf("manifest changed after JSON round trip")
+	}
+
+	diff, err := f.VerifyManifest(ctx, root, m)
+	if err != nil {
+		t.Fatalf("VerifyManifest failed: %v", err)
+	}
+	if !diff.Empty() {
+		t.Fatalf("expected an unmodified tree to match its manifest, got %+v", diff)
+	}
+
+	// Same size, different contents.
+	writeTree(t, root, map[string]string{"a.txt": "A", "extra.txt": "e"})
+	if err := os.Remove(filepath.Join(root, "sub", "c.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(root, "sub", "b.txt"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err = f.VerifyManifest(ctx, root, m)
+	if err != nil {
+		t.Fatalf("VerifyManifest failed: %v", err)
+	}
+	if len(diff.Missing) != 1 || diff.Missing[0].Path != "sub/c.txt" {
+		t.Errorf("expected sub/c.txt to be missing, got %+v", diff.Missing)
+	}
+	if len(diff.Extra) != 1 || diff.Extra[0].Path != "extra.txt" {
+		t.Errorf("expected extra.txt to be extra, got %+v", diff.Extra)
+	}
+	modified := map[string][]string{}
+	for _, mm := range diff.Modified {
+		modified[mm.Path] = mm.Fields
+	}
+	want := map[string][]string{
+		"a.txt":     {"sha256"},
+		"sub/b.txt": {"mode"},
+	}
+	if !reflect.DeepEqual(modified, want) {
+		t.Errorf("expected modified entries %v, got %v", want, modified)
+	}
+
+	// A file that can't be read is reported as modified rather than failing
+	// the verification. The tests run as root, so a fake sha256sum refuses
+	// to read it.
+	sha256sum, err := exec.LookPath("sha256sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := `#!/bin/sh
+st=0
+for f; do
+	case $f in
+	*/unreadable.txt) echo "sha256sum: $f: Permission denied" >&2; st=1 ;;
+	*) ` + sha256sum + ` "$f" ;;
+	esac
+done
+exit $st
+`
+	setUpPath(t, map[string]string{"sha256sum": fake}, "sh", "find")
+	writeTree(t, root, map[string]string{"unreadable.txt": "u"})
+	if m, err = GenerateManifest(root); err != nil {
+		t.Fatal(err)
+	}
+	diff, err = f.VerifyManifest(ctx, root, m)
+	if err != nil {
+		t.Fatalf("VerifyManifest failed: %v", err)
+	}
+	if len(diff.Modified) != 1 || diff.Modified[0].Path != "unreadable.txt" || !reflect.DeepEqual(diff.Modified[0].Fields, []string{"sha256"}) {
+		t.Errorf("expected only unreadable.txt to differ in its hash, got %+v", diff.Modified)
+	}
+}
+
+func TestParseSHA256SumLine(t *testing.T) {
+	const sum = "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb"
+	for _, tc := range []struct {
+		line string
+		name string
+	}{
+		{line: sum + "  plain", name: "plain"},
+		{line: sum + " *binary mode", name: "binary mode"},
+		{line: `\` + sum + `  new\nline\\`, name: "new\nline\\"},
+		{line: `\` + sum + `  carriage\rreturn\\r`, name: "carriage\rreturn\\r"},
+	} {
+		gotSum, gotName, err := parseSHA256SumLine(tc.line)
+		if err != nil {
+			t.Errorf("failed to parse %q: %v", tc.line, err)
+			continue
+		}
+		if gotSum != sum || gotName != tc.name {
+			t.Errorf("parsing %q: got (%q, %q), want (%q, %q)", tc.line, gotSum, gotName, sum, tc.name)
+		}
+	}
+
+	if _, _, err := parseSHA256SumLine("sha256sum: missing: No such file or directory"); err == nil {
+		t.Errorf("expected an error for malformed output")
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/sftp"
+)
+
+// OwnershipPolicy controls how transfers set the owner and group of the
+// entries they create.
+type OwnershipPolicy int
+
+const (
+	// OwnershipIgnore leaves ownership to the defaults of the destination,
+	// usually the user doing the transfer.
+	OwnershipIgnore OwnershipPolicy = iota
+
+	// OwnershipPreserve gives entries the same numeric uid and gid as the
+	// source.
+	OwnershipPreserve
+
+	// OwnershipByName gives entries the uid and gid of the user and group with
+	// the same names as the owners of the source. Ids with no matching name on
+	// either side are preserved numerically.
+	OwnershipByName
+
+	// OwnershipMap translates uids and gids using TransferOptions.UIDMap and
+	// TransferOptions.GIDMap.
+	OwnershipMap
+)
+
+// fileOwner returns the numeric owner and group of a local or remote file, if
+// known.
+func fileOwner(fi os.FileInfo) (uid, gid uint32, ok bool) {
+	if st, ok := fi.Sys().(*sftp.FileStat); ok {
+		return st.UID, st.GID, true
+	}
+	return sysFileOwner(fi)
+}
+
+// owner returns the uid and gid that a transferred entry should have at the
+// destination, or false if ownership should be left alone.
+func (t *transfer) owner(e transferEntry) (uid, gid uint32, ok bool) {
+	if !e.hasOwner || e.isSymlink() {
+		return 0, 0, false
+	}
+	uid, gid = e.uid, e.gid
+	switch t.opts.Ownership {
+	case OwnershipPreserve:
+	case OwnershipByName:
+		if name, ok := t.srcAccounts.userName(uid); ok {
+			if id, ok := t.dstAccounts.userID(name); ok {
+				uid = id
+			}
+		}
+		if name, ok := t.srcAccounts.groupName(gid); ok {
+			if id, ok := t.dstAccounts.groupID(name); ok {
+				gid = id
+			}
+		}
+	case OwnershipMap:
+		if id, ok := t.opts.UIDMap[uid]; ok {
+			uid = id
+		}
+		if id, ok := t.opts.GIDMap[gid]; ok {
+			gid = id
+		}
+	default:
+		return 0, 0, false
+	}
+	return uid, gid, true
+}
+
+// accounts resolves between the names and ids of users and groups on one side
+// of a transfer.
+type accounts interface {
+	userName(uid uint32) (string, bool)
+	groupName(gid uint32) (string, bool)
+	userID(name string) (uint32, bool)
+	groupID(name string) (uint32, bool)
+}
+
+// localAccounts resolves accounts on the local machine.
+type localAccounts struct{}
+
+func (localAccounts) userName(uid uint32) (string, bool) {
+	u, err := user.LookupId(strconv.FormatUint(uint64(uid), 10))
+	if err != nil {
+		return "", false
+	}
+	return u.Username, true
+}
+
+func (localAccounts) groupName(gid uint32) (string, bool) {
+	g, err := user.LookupGroupId(strconv.FormatUint(uint64(gid), 10))
+	if err != nil {
+		return "", false
+	}
+	return g.Name, true
+}
+
+func (localAccounts) userID(name string) (uint32, bool) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(u.Uid, 10, 32)
+	return uint32(id), err == nil
+}
+
+func (localAccounts) groupID(name string) (uint32, bool) {
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(g.Gid, 10, 32)
+	return uint32(id), err == nil
+}
+
+// accountDB resolves accounts from the contents of passwd and group files.
+type accountDB struct {
+	userNames  map[uint32]string
+	groupNames map[uint32]string
+	userIDs    map[string]uint32
+	groupIDs   map[string]uint32
+}
+
+// parseAccountDB parses files in the format of /etc/passwd and /etc/group.
+// Both formats start with name:password:id, which is all we need.
+func parseAccountDB(passwd, group []byte) *accountDB {
+	db := &accountDB{
+		userNames:  make(map[uint32]string),
+		groupNames: make(map[uint32]string),
+		userIDs:    make(map[string]uint32),
+		groupIDs:   make(map[string]uint32),
+	}
+	parseIDFile(passwd, db.userNames, db.userIDs)
+	parseIDFile(group, db.groupNames, db.groupIDs)
+	return db
+}
+
+func parseIDFile(data []byte, names map[uint32]string, ids map[string]uint32) {
+	for _, fields := range accountFields(data) {
+		id, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			continue
+		}
+		// The first entry wins, matching the behavior of getpwnam(3).
+		if _, ok := names[uint32(id)]; !ok {
+			names[uint32(id)] = fields[0]
+		}
+		if _, ok := ids[fields[0]]; !ok {
+			ids[fields[0]] = uint32(id)
+		}
+	}
+}
+
+// accountFields splits the lines of /etc/passwd or /etc/group into fields,
+// skipping blank lines, comments and lines without at least a name, password
+// and id.
+func accountFields(data []byte) [][]string {
+	var entries [][]string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) < 3 {
+			continue
+		}
+		entries = append(entries, fields)
+	}
+	return entries
+}
+
+func (db *accountDB) userName(uid uint32) (string, bool) {
+	name, ok := db.userNames[uid]
+	return name, ok
+}
+
+func (db *accountDB) groupName(gid uint32) (string, bool) {
+	name, ok := db.groupNames[gid]
+	return name, ok
+}
+
+func (db *accountDB) userID(name string) (uint32, bool) {
+	id, ok := db.userIDs[name]
+	return id, ok
+}
+
+func (db *accountDB) groupID(name string) (uint32, bool) {
+	id, ok := db.groupIDs[name]
+	return id, ok
+}
+
+// remoteAccounts reads the account database of the remote.
+func (f *FileClient) remoteAccounts() (*accountDB, error) {
+	passwd, err := f.ReadFile(passwdPath)
+	if err != nil {
+		return nil, err
+	}
+	group, err := f.ReadFile(groupPath)
+	if err != nil {
+		return nil, err
+	}
+	return parseAccountDB(passwd, group), nil
+}
+
+// LookupUser returns the ID of the named user on the remote, from its
+// /etc/passwd.
+func (f *FileClient) LookupUser(name string) (uint32, error) {
+	db, err := f.remoteAccounts()
+	if err != nil {
+		return 0, err
+	}
+	uid, ok := db.userID(name)
+	if !ok {
+		return 0, UnknownUserError{Name: name}
+	}
+	return uid, nil
+}
+
+// LookupGroup returns the ID of the named group on the remote, from its
+// /etc/group.
+func (f *FileClient) LookupGroup(name string) (uint32, error) {
+	db, err := f.remoteAccounts()
+	if err != nil {
+		return 0, err
+	}
+	gid, ok := db.groupID(name)
+	if !ok {
+		return 0, UnknownGroupError{Name: name}
+	}
+	return gid, nil
+}
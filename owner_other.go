@@ -0,0 +1,15 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+//go:build windows || plan9
+// +build windows plan9
+
+package sshutil
+
+import "os"
+
+// Local files have no numeric ownership on this platform.
+func sysFileOwner(fi os.FileInfo) (uid, gid uint32, ok bool) {
+	return 0, 0, false
+}
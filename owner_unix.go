@@ -0,0 +1,20 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package sshutil
+
+import (
+	"os"
+	"syscall"
+)
+
+func sysFileOwner(fi os.FileInfo) (uid, gid uint32, ok bool) {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return st.Uid, st.Gid, true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,401 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.fuchsia.dev/fuchsia/tools/lib/logger"
+	"go.fuchsia.dev/fuchsia/tools/lib/retry"
+)
+
+// PackageManager identifies a remote package manager.
+type PackageManager string
+
+const (
+	PackageManagerApt  PackageManager = "apt"
+	PackageManagerDnf  PackageManager = "dnf"
+	PackageManagerYum  PackageManager = "yum"
+	PackageManagerApk  PackageManager = "apk"
+	PackageManagerOpkg PackageManager = "opkg"
+)
+
+// packageManagerCommands maps the command detected on the remote to the
+// package manager it belongs to, in order of preference.
+var packageManagerCommands = []struct {
+	command string
+	manager PackageManager
+}{
+	{"apt-get", PackageManagerApt},
+	{"dnf", PackageManagerDnf},
+	{"yum", PackageManagerYum},
+	{"apk", PackageManagerApk},
+	{"opkg", PackageManagerOpkg},
+}
+
+const (
+	// defaultPackageLockTimeout is how long package operations wait for
+	// another process to release the package database.
+	defaultPackageLockTimeout = 5 * time.Minute
+)
+
+// packageLockRetryInterval is how often a locked package database is retried.
+// It is a variable so tests can shorten it.
+var packageLockRetryInterval = 5 * time.Second
+
+// NoPackageManagerError is returned when the remote has none of the
+// supported package managers.
+type NoPackageManagerError struct{}
+
+func (e NoPackageManagerError) Error() string {
+	return "no supported package manager found"
+}
+
+// PackageNotFoundError is returned when a package to install isn't known to
+// the package manager.
+type PackageNotFoundError struct {
+	Manager  PackageManager
+	Packages []string
+	Err      error
+}
+
+func (e PackageNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+func (e PackageNotFoundError) Error() string {
+	return fmt.Sprintf("%s: package not found: %s", e.Manager, strings.Join(e.Packages, " "))
+}
+
+// PackageLockError is returned when the package database stays locked by
+// another process for longer than the lock timeout.
+type PackageLockError struct {
+	Manager PackageManager
+	Err     error
+}
+
+func (e PackageLockError) Unwrap() error {
+	return e.Err
+}
+
+func (e PackageLockError) Error() string {
+	return fmt.Sprintf("%s: package database is locked: %v", e.Manager, e.Err)
+}
+
+// Package is a package installed on the remote.
+type Package struct {
+	Name    string
+	Version string
+}
+
+// PackageClient installs, removes and lists packages with the package manager
+// of the remote. Installing and removing packages usually requires the client
+// to be connected as root.
+type PackageClient struct {
+	client  *Client
+	manager PackageManager
+
+	// LockTimeout is how long to keep retrying operations while another
+	// process holds the package database lock. It defaults to five minutes.
+	LockTimeout time.Duration
+}
+
+// NewPackageClient detects the package manager of the remote and returns a
+// PackageClient that uses it. It returns a NoPackageManagerError if the
+// remote has no supported package manager.
+func (c *Client) NewPackageClient(ctx context.Context) (*PackageClient, error) {
+	var script strings.Builder
+	for _, m := range packageManagerCommands {
+		fmt.Fprintf(&script, "if command -v %s >/dev/null 2>&1; then echo %s; exit 0; fi\n", m.command, m.manager)
+	}
+	out, err := c.ShellOutput(ctx, script.String())
+	if err != nil {
+		return nil, err
+	}
+	manager := PackageManager(strings.TrimSpace(string(out)))
+	if manager == "" {
+		return nil, NoPackageManagerError{}
+	}
+	return c.NewPackageClientFor(manager)
+}
+
+// NewPackageClientFor returns a PackageClient that uses the given package
+// manager without checking whether the remote has it.
+func (c *Client) NewPackageClientFor(manager PackageManager) (*PackageClient, error) {
+	if _, err := packageCommands(manager); err != nil {
+		return nil, err
+	}
+	return &PackageClient{client: c, manager: manager}, nil
+}
+
+// Manager returns the package manager used by the client.
+func (p *PackageClient) Manager() PackageManager {
+	return p.manager
+}
+
+// List returns the installed packages, sorted by name.
+func (p *PackageClient) List(ctx context.Context) ([]Package, error) {
+	cmds, _ := packageCommands(p.manager)
+	out, err := p.client.Output(ctx, cmds.list...)
+	if err != nil {
+		return nil, err
+	}
+	pkgs := cmds.parse(out)
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Name < pkgs[j].Name })
+	return pkgs, nil
+}
+
+// Installed returns the installed version of a package, or false if it isn't
+// installed.
+func (p *PackageClient) Installed(ctx context.Context, name string) (Package, bool, error) {
+	installed, err := p.installed(ctx)
+	if err != nil {
+		return Package{}, false, err
+	}
+	pkg, ok := installed[name]
+	return pkg, ok, nil
+}
+
+func (p *PackageClient) installed(ctx context.Context) (map[string]Package, error) {
+	pkgs, err := p.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	installed := make(map[string]Package, len(pkgs))
+	for _, pkg := range pkgs {
+		installed[pkg.Name] = pkg
+	}
+	return installed, nil
+}
+
+// Install installs the packages that aren't already installed and returns
+// their names. Installing packages that are all installed does nothing.
+func (p *PackageClient) Install(ctx context.Context, names ...string) ([]string, error) {
+	installed, err := p.installed(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, name := range names {
+		if _, ok := installed[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil, nil
+	}
+	cmds, _ := packageCommands(p.manager)
+	if err := p.run(ctx, append(cmds.install, missing...), missing); err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
+
+// Remove removes the packages that are installed and returns their names.
+// Removing packages that aren't installed does nothing.
+func (p *PackageClient) Remove(ctx context.Context, names ...string) ([]string, error) {
+	installed, err := p.installed(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var present []string
+	for _, name := range names {
+		if _, ok := installed[name]; ok {
+			present = append(present, name)
+		}
+	}
+	if len(present) == 0 {
+		return nil, nil
+	}
+	cmds, _ := packageCommands(p.manager)
+	if err := p.run(ctx, append(cmds.remove, present...), present); err != nil {
+		return nil, err
+	}
+	return present, nil
+}
+
+// Update refreshes the package manager's index of available packages.
+func (p *PackageClient) Update(ctx context.Context) error {
+	cmds, _ := packageCommands(p.manager)
+	return p.run(ctx, cmds.update, nil)
+}
+
+// run runs a package manager command, retrying while the package database is
+// locked and translating failures into typed errors.
+func (p *PackageClient) run(ctx context.Context, command, packages []string) error {
+	cmds, _ := packageCommands(p.manager)
+	timeout := p.LockTimeout
+	if timeout == 0 {
+		timeout = defaultPackageLockTimeout
+	}
+	backoff := retry.WithMaxDuration(retry.NewConstantBackoff(packageLockRetryInterval), timeout)
+
+	var result error
+	err := retry.Retry(ctx, backoff, func() error {
+		_, result = p.client.Output(ctx, command...)
+		var cmdErr CommandError
+		if !errors.As(result, &cmdErr) {
+			return nil
+		}
+		switch {
+		case containsAny(cmdErr.Stderr, cmds.lockMessages):
+			logger.Debugf(ctx, "%s: package database is locked, retrying", p.manager)
+			result = PackageLockError{Manager: p.manager, Err: result}
+			return result
+		case packages != nil && containsAny(cmdErr.Stderr, cmds.notFoundMessages):
+			result = PackageNotFoundError{Manager: p.manager, Packages: packages, Err: result}
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return err
+	}
+	return result
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// packageCommandSet holds the commands of a package manager, and the messages
+// it prints when the package database is locked or a package doesn't exist.
+type packageCommandSet struct {
+	list                    []string
+	parse                   func([]byte) []Package
+	install, remove, update []string
+	lockMessages            []string
+	notFoundMessages        []string
+}
+
+func packageCommands(manager PackageManager) (*packageCommandSet, error) {
+	switch manager {
+	case PackageManagerApt:
+		return &packageCommandSet{
+			list:    []string{"dpkg-query", "-W", "-f", `${db:Status-Status}\t${Package}\t${Version}\n`},
+			parse:   parseDpkgPackages,
+			install: []string{"env", "DEBIAN_FRONTEND=noninteractive", "apt-get", "install", "-y"},
+			remove:  []string{"env", "DEBIAN_FRONTEND=noninteractive", "apt-get", "remove", "-y"},
+			update:  []string{"env", "DEBIAN_FRONTEND=noninteractive", "apt-get", "update"},
+			lockMessages: []string{
+				"Could not get lock",
+				"Unable to lock",
+				"Unable to acquire the dpkg frontend lock",
+			},
+			notFoundMessages: []string{"Unable to locate package", "has no installation candidate"},
+		}, nil
+	case PackageManagerDnf, PackageManagerYum:
+		return &packageCommandSet{
+			list:    []string{"rpm", "-qa", "--qf", `%{NAME}\t%{VERSION}-%{RELEASE}\n`},
+			parse:   parseTabPackages,
+			install: []string{string(manager), "install", "-y"},
+			remove:  []string{string(manager), "remove", "-y"},
+			update:  []string{string(manager), "makecache"},
+			lockMessages: []string{
+				"Another app is currently holding the yum lock",
+				"Failed to obtain rpm transaction lock",
+				"Waiting for process with pid",
+			},
+			notFoundMessages: []string{"No match for argument", "Unable to find a match", "No package"},
+		}, nil
+	case PackageManagerApk:
+		return &packageCommandSet{
+			list:             []string{"apk", "info", "-v"},
+			parse:            parseApkPackages,
+			install:          []string{"apk", "add", "--no-progress"},
+			remove:           []string{"apk", "del", "--no-progress"},
+			update:           []string{"apk", "update", "--no-progress"},
+			lockMessages:     []string{"Unable to lock database", "unable to obtain lock"},
+			notFoundMessages: []string{"unable to select packages", "no such package"},
+		}, nil
+	case PackageManagerOpkg:
+		return &packageCommandSet{
+			list:             []string{"opkg", "list-installed"},
+			parse:            parseOpkgPackages,
+			install:          []string{"opkg", "install"},
+			remove:           []string{"opkg", "remove"},
+			update:           []string{"opkg", "update"},
+			lockMessages:     []string{"Could not lock", "opkg_lock"},
+			notFoundMessages: []string{"Unknown package"},
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported package manager %q", manager)
+}
+
+// parseDpkgPackages parses lines of "status\tname\tversion", keeping only
+// installed packages.
+func parseDpkgPackages(out []byte) []Package {
+	var pkgs []Package
+	for _, pkg := range parsePackageLines(out, "\t", 3) {
+		fields := strings.SplitN(pkg, "\t", 3)
+		if fields[0] == "installed" {
+			pkgs = append(pkgs, Package{Name: fields[1], Version: fields[2]})
+		}
+	}
+	return pkgs
+}
+
+// parseTabPackages parses lines of "name\tversion".
+func parseTabPackages(out []byte) []Package {
+	var pkgs []Package
+	for _, line := range parsePackageLines(out, "\t", 2) {
+		fields := strings.SplitN(line, "\t", 2)
+		pkgs = append(pkgs, Package{Name: fields[0], Version: fields[1]})
+	}
+	return pkgs
+}
+
+// parseApkPackages parses lines of "name-version-rN", where the name may
+// itself contain dashes.
+func parseApkPackages(out []byte) []Package {
+	var pkgs []Package
+	for _, line := range parsePackageLines(out, "-", 3) {
+		if strings.HasPrefix(line, "WARNING:") {
+			continue
+		}
+		i := strings.LastIndex(line, "-")
+		j := strings.LastIndex(line[:i], "-")
+		if j <= 0 {
+			continue
+		}
+		pkgs = append(pkgs, Package{Name: line[:j], Version: line[j+1:]})
+	}
+	return pkgs
+}
+
+// parseOpkgPackages parses lines of "name - version".
+func parseOpkgPackages(out []byte) []Package {
+	var pkgs []Package
+	for _, line := range parsePackageLines(out, " - ", 2) {
+		fields := strings.SplitN(line, " - ", 3)
+		pkgs = append(pkgs, Package{Name: fields[0], Version: fields[1]})
+	}
+	return pkgs
+}
+
+// parsePackageLines returns the non-empty lines of out that contain at least
+// n fields separated by sep.
+func parsePackageLines(out []byte, sep string, n int) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.Count(line, sep) >= n-1 && line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,198 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+)
+
+// fakeApk is an apk that keeps its database in a file next to it. It only uses
+// shell builtins so that the test can run with only sh beside it on the PATH. The
+// first $locks operations fail as if another process held the lock.
+const fakeApk = `#!/bin/sh
+dir=$(cd "${0%/*}" && pwd)
+echo "$*" >> "$dir/log"
+case "$1" in
+info)
+	while read -r p; do echo "$p"; done < "$dir/db"
+	exit 0
+	;;
+esac
+read -r locks < "$dir/locks"
+if [ "$locks" -gt 0 ]; then
+	echo $((locks - 1)) > "$dir/locks"
+	echo "ERROR: Unable to lock database: temporary error (try again later)" >&2
+	exit 2
+fi
+cmd=$1
+shift
+shift
+case "$cmd" in
+add)
+	for p; do
+		if [ "$p" = missing ]; then
+			echo "ERROR: unable to select packages:" >&2
+			echo "  missing (no such package):" >&2
+			exit 1
+		fi
+	done
+	for p; do echo "$p-1.0-r0" >> "$dir/db"; done
+	;;
+del)
+	out=
+	while read -r line; do
+		keep=1
+		for p; do
+			case "$line" in "$p"-*) keep=0 ;; esac
+		done
+		if [ $keep = 1 ]; then out="$out$line
+"; fi
+	done < "$dir/db"
+	printf %s "$out" > "$dir/db"
+	;;
+esac
+`
+
+func TestPackageClient(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+
+	dir := setUpPath(t, map[string]string{"apk": fakeApk}, "sh")
+	for name, content := range map[string]string{
+		"db":    "busybox-1.36.1-r5\nca-certificates-bundle-20230506-r0\n",
+		"locks": "0\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(interval time.Duration) { packageLockRetryInterval = interval }(packageLockRetryInterval)
+	packageLockRetryInterval = time.Millisecond
+
+	p, err := client.NewPackageClient(ctx)
+	if err != nil {
+		t.Fatalf("failed to detect package manager: %v", err)
+	}
+	if p.Manager() != PackageManagerApk {
+		t.Fatalf("detected %q, want apk", p.Manager())
+	}
+
+	pkgs, err := p.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Package{
+		{Name: "busybox", Version: "1.36.1-r5"},
+		{Name: "ca-certificates-bundle", Version: "20230506-r0"},
+	}
+	if !reflect.DeepEqual(pkgs, want) {
+		t.Errorf("got packages %+v, want %+v", pkgs, want)
+	}
+
+	setLocks := func(n string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, "locks"), []byte(n+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Installing waits for the lock and only installs missing packages.
+	setLocks("2")
+	changed, err := p.Install(ctx, "busybox", "strace")
+	if err != nil {
+		t.Fatalf("failed to install: %v", err)
+	}
+	if !reflect.DeepEqual(changed, []string{"strace"}) {
+		t.Errorf("installed %v, want [strace]", changed)
+	}
+	if pkg, ok, err := p.Installed(ctx, "strace"); err != nil || !ok || pkg.Version != "1.0-r0" {
+		t.Errorf("expected strace 1.0-r0 to be installed, got %+v, %v (err: %v)", pkg, ok, err)
+	}
+	if changed, err := p.Install(ctx, "strace"); err != nil || changed != nil {
+		t.Errorf("expected installing again to do nothing, got %v (err: %v)", changed, err)
+	}
+
+	changed, err = p.Remove(ctx, "strace", "gdb")
+	if err != nil || !reflect.DeepEqual(changed, []string{"strace"}) {
+		t.Errorf("removed %v, want [strace] (err: %v)", changed, err)
+	}
+	if changed, err := p.Remove(ctx, "strace"); err != nil || changed != nil {
+		t.Errorf("expected removing again to do nothing, got %v (err: %v)", changed, err)
+	}
+
+	_, err = p.Install(ctx, "missing")
+	var notFound PackageNotFoundError
+	if !errors.As(err, &notFound) || !reflect.DeepEqual(notFound.Packages, []string{"missing"}) {
+		t.Errorf("expected a PackageNotFoundError, got %v", err)
+	}
+
+	setLocks("1000")
+	p.LockTimeout = 20 * time.Millisecond
+	_, err = p.Install(ctx, "gdb")
+	var lockErr PackageLockError
+	if !errors.As(err, &lockErr) {
+		t.Errorf("expected a PackageLockError, got %v", err)
+	}
+
+	log, err := ioutil.ReadFile(filepath.Join(dir, "log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(log), "add --no-progress busybox") {
+		t.Errorf("expected installed packages not to be reinstalled:\n%s", log)
+	}
+
+	setUpPath(t, nil, "sh")
+	if _, err := client.NewPackageClient(ctx); !errors.As(err, &NoPackageManagerError{}) {
+		t.Errorf("expected a NoPackageManagerError, got %v", err)
+	}
+}
+
+func TestParsePackages(t *testing.T) {
+	for _, tc := range []struct {
+		manager PackageManager
+		out     string
+		want    []Package
+	}{
+		{
+			manager: PackageManagerApt,
+			out:     "installed\tbash\t5.2.15-2+b2\nconfig-files\told\t1.0\nnot-installed\tgdb\t\n",
+			want:    []Package{{Name: "bash", Version: "5.2.15-2+b2"}},
+		},
+		{
+			manager: PackageManagerDnf,
+			out:     "bash\t5.2.26-3.fc40\nglibc\t2.39-8.fc40\n",
+			want:    []Package{{Name: "bash", Version: "5.2.26-3.fc40"}, {Name: "glibc", Version: "2.39-8.fc40"}},
+		},
+		{
+			manager: PackageManagerApk,
+			out:     "WARNING: opening /var/cache/apk: No such file\nmusl-1.2.4-r2\nlibc-utils-0.7.2-r5\n",
+			want:    []Package{{Name: "musl", Version: "1.2.4-r2"}, {Name: "libc-utils", Version: "0.7.2-r5"}},
+		},
+		{
+			manager: PackageManagerOpkg,
+			out:     "busybox - 1.36.1-1\nkmod-nf-conntrack - 5.15.150-1\n",
+			want:    []Package{{Name: "busybox", Version: "1.36.1-1"}, {Name: "kmod-nf-conntrack", Version: "5.15.150-1"}},
+		},
+	} {
+		t.Run(string(tc.manager), func(t *testing.T) {
+			cmds, err := packageCommands(tc.manager)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cmds.parse([]byte(tc.out)); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
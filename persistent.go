@@ -0,0 +1,430 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"go.fuchsia.dev/fuchsia/tools/lib/logger"
+	"golang.org/x/crypto/ssh"
+)
+
+// Multiplexer is a terminal multiplexer, which keeps shells running on the
+// remote while no client is attached.
+type Multiplexer string
+
+const (
+	MultiplexerTmux   Multiplexer = "tmux"
+	MultiplexerScreen Multiplexer = "screen"
+)
+
+// PersistentShellOptions configure AttachPersistentShell.
+type PersistentShellOptions struct {
+	// Name is the name of the multiplexer's session, of letters, digits, '-'
+	// and '_'. The session is created if it doesn't exist.
+	Name string
+
+	// Multiplexer defaults to tmux if it's installed, and otherwise screen.
+	Multiplexer Multiplexer
+
+	PTY PTYOptions
+
+	// Stdin is the terminal's input, and Stdout its output.
+	Stdin  io.Reader
+	Stdout io.Writer
+}
+
+// PersistentShellInfo describes a multiplexer session on the remote.
+type PersistentShellInfo struct {
+	Name        string
+	Multiplexer Multiplexer
+	Attached    bool
+}
+
+// PersistentShell is a terminal attached to a shell running in a named tmux
+// or screen session on the remote, which survives the connection dropping.
+type PersistentShell struct {
+	client *Client
+	opts   PersistentShellOptions
+	cancel context.CancelFunc
+
+	mu          sync.Mutex
+	pty         PTYOptions
+	session     *Session
+	stdin       io.WriteCloser
+	stdinClosed bool
+	// attached is closed, and replaced, when the shell is reattached.
+	attached    chan struct{}
+	reconnected <-chan struct{}
+
+	done chan struct{}
+	err  error
+}
+
+// validSessionName reports whether name is usable by both tmux and screen.
+func validSessionName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// detectMultiplexer returns the multiplexer to use if none was given.
+func (c *Client) detectMultiplexer(ctx context.Context, m Multiplexer) (Multiplexer, error) {
+	if m != "" {
+		return m, nil
+	}
+	missing, err := c.MissingTools(ctx, string(MultiplexerTmux), string(MultiplexerScreen))
+	if err != nil {
+		return "", err
+	}
+	switch len(missing) {
+	case 2:
+		return "", MissingToolError{Tool: string(MultiplexerTmux)}
+	case 1:
+		if missing[0] == string(MultiplexerTmux) {
+			return MultiplexerScreen, nil
+		}
+	}
+	return MultiplexerTmux, nil
+}
+
+// AttachPersistentShell attaches a terminal to the shell in a named tmux or
+// screen session, creating it if needed. If the connection drops, the shell
+// keeps running on the remote, and is reattached once the client reconnects.
+// Input read while detached is sent once reattached.
+func (c *Client) AttachPersistentShell(ctx context.Context, opts PersistentShellOptions) (*PersistentShell, error) {
+	if !validSessionName(opts.Name) {
+		return nil, fmt.Errorf("invalid session name %q", opts.Name)
+	}
+	m, err := c.detectMultiplexer(ctx, opts.Multiplexer)
+	if err != nil {
+		return nil, err
+	}
+	opts.Multiplexer = m
+	if opts.Stdout == nil {
+		opts.Stdout = ioutil.Discard
+	}
+	opts.Stdout = &syncWriter{w: opts.Stdout}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &PersistentShell{
+		client:   c,
+		opts:     opts,
+		cancel:   cancel,
+		pty:      opts.PTY,
+		attached: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if err := s.attach(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+	go s.run(ctx)
+	if opts.Stdin != nil {
+		go s.pump()
+	}
+	return s, nil
+}
+
+// attachCommand returns the command that attaches to a session, or creates
+// it.
+func attachCommand(m Multiplexer, name string) ([]string, error) {
+	switch m {
+	case MultiplexerTmux:
+		return []string{"exec", "tmux", "new-session", "-A", "-s", name}, nil
+	case MultiplexerScreen:
+		return []string{"exec", "screen", "-D", "-R", "-S", name}, nil
+	}
+	return nil, fmt.Errorf("unknown multiplexer %q", m)
+}
+
+func (s *PersistentShell) attach(ctx context.Context) error {
+	command, err := attachCommand(s.opts.Multiplexer, s.opts.Name)
+	if err != nil {
+		return err
+	}
+	// Note the next reconnection before attaching, so that it isn't missed.
+	reconnected := s.client.reconnectedChan()
+
+	s.mu.Lock()
+	pty := s.pty
+	s.mu.Unlock()
+	session, stdin, err := s.client.startPTYWithStdinPipe(ctx, shellQuoteArgs(command), pty, s.opts.Stdout, s.opts.Stdout)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.session = session
+	s.stdin = stdin
+	if s.stdinClosed || s.opts.Stdin == nil {
+		stdin.Close()
+	}
+	s.reconnected = reconnected
+	close(s.attached)
+	s.attached = make(chan struct{})
+	return nil
+}
+
+// detach forgets the current attachment, once its session has ended.
+func (s *PersistentShell) detach() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.session.Close()
+	s.stdin = nil
+}
+
+// run reattaches the shell each time the client reconnects, until it exits.
+func (s *PersistentShell) run(ctx context.Context) {
+	defer close(s.done)
+	for {
+		s.mu.Lock()
+		session, reconnected := s.session, s.reconnected
+		s.mu.Unlock()
+
+		err := session.Wait(ctx)
+		s.detach()
+		if ctx.Err() != nil {
+			return
+		}
+		var exitErr *ssh.ExitError
+		if err == nil || errors.As(err, &exitErr) {
+			// The shell exited, or was detached by the user.
+			s.err = err
+			return
+		}
+		logger.Debugf(ctx, "lost the connection to session %s: %v; waiting to reattach", s.opts.Name, err)
+
+		for {
+			select {
+			case <-reconnected:
+			case <-ctx.Done():
+				return
+			}
+			reconnected = s.client.reconnectedChan()
+			// Failing to start the session means the connection dropped
+			// again, as the multiplexer failing ends the session instead.
+			err := s.attach(ctx)
+			if err == nil {
+				break
+			}
+			logger.Debugf(ctx, "failed to reattach session %s: %v", s.opts.Name, err)
+		}
+	}
+}
+
+// pump copies the input to the shell, holding on to it while detached.
+func (s *PersistentShell) pump() {
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := s.opts.Stdin.Read(buf)
+		if n > 0 && !s.write(buf[:n]) {
+			return
+		}
+		if err != nil {
+			s.mu.Lock()
+			s.stdinClosed = true
+			if s.stdin != nil {
+				s.stdin.Close()
+			}
+			s.mu.Unlock()
+			return
+		}
+	}
+}
+
+// write writes p to the attached shell, waiting for it to be reattached if
+// needed. It returns false if the shell has exited.
+func (s *PersistentShell) write(p []byte) bool {
+	for len(p) > 0 {
+		s.mu.Lock()
+		w, attached := s.stdin, s.attached
+		s.mu.Unlock()
+		if w != nil {
+			n, err := w.Write(p)
+			p = p[n:]
+			if err == nil {
+				continue
+			}
+		}
+		select {
+		case <-attached:
+		case <-s.done:
+			return false
+		}
+	}
+	return true
+}
+
+// Resize changes the size of the terminal, including when reattached.
+func (s *PersistentShell) Resize(width, height int) error {
+	s.mu.Lock()
+	s.pty.Width, s.pty.Height = width, height
+	session := s.session
+	s.mu.Unlock()
+	return session.WindowChange(width, height)
+}
+
+// Wait waits for the shell to exit or be detached by the user, or for the
+// PersistentShell to be closed.
+func (s *PersistentShell) Wait() error {
+	<-s.done
+	return s.err
+}
+
+// Close detaches from the shell, which keeps running on the remote. A read of
+// Stdin in progress isn't interrupted.
+func (s *PersistentShell) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+// PersistentShells lists the sessions of a multiplexer on the remote, or of
+// both tmux and screen if none is given.
+func (c *Client) PersistentShells(ctx context.Context, m Multiplexer) ([]PersistentShellInfo, error) {
+	multiplexers := []Multiplexer{m}
+	if m == "" {
+		missing, err := c.MissingTools(ctx, string(MultiplexerTmux), string(MultiplexerScreen))
+		if err != nil {
+			return nil, err
+		}
+		multiplexers = nil
+		for _, m := range []Multiplexer{MultiplexerTmux, MultiplexerScreen} {
+			if !containsString(missing, string(m)) {
+				multiplexers = append(multiplexers, m)
+			}
+		}
+	}
+	var infos []PersistentShellInfo
+	for _, m := range multiplexers {
+		var list []PersistentShellInfo
+		var err error
+		switch m {
+		case MultiplexerTmux:
+			list, err = c.tmuxSessions(ctx)
+		case MultiplexerScreen:
+			list, err = c.screenSessions(ctx)
+		default:
+			err = fmt.Errorf("unknown multiplexer %q", m)
+		}
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, list...)
+	}
+	return infos, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Client) tmuxSessions(ctx context.Context) ([]PersistentShellInfo, error) {
+	out, err := c.Output(ctx, "tmux", "list-sessions", "-F", "#{session_name} #{session_attached}")
+	var cmdErr CommandError
+	if errors.As(err, &cmdErr) && (strings.Contains(cmdErr.Stderr, "no server running") ||
+		strings.Contains(cmdErr.Stderr, "error connecting")) {
+		// There are no sessions without a server.
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var infos []PersistentShellInfo
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		infos = append(infos, PersistentShellInfo{
+			Name:        fields[0],
+			Multiplexer: MultiplexerTmux,
+			Attached:    fields[1] != "0",
+		})
+	}
+	return infos, nil
+}
+
+// screenSessions parses `screen -ls`, which lists sessions on indented lines
+// as "<pid>.<name>", with their state in parentheses, and which exits with a
+// failure even when there are sessions.
+func (c *Client) screenSessions(ctx context.Context) ([]PersistentShellInfo, error) {
+	out, err := c.ShellOutput(ctx, "screen -ls || true")
+	if err != nil {
+		return nil, err
+	}
+	var infos []PersistentShellInfo
+	for _, line := range strings.Split(string(out), "\n") {
+		if !strings.HasPrefix(line, "\t") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		i := strings.IndexByte(fields[0], '.')
+		if i < 0 {
+			continue
+		}
+		infos = append(infos, PersistentShellInfo{
+			Name:        fields[0][i+1:],
+			Multiplexer: MultiplexerScreen,
+			Attached:    strings.Contains(line, "(Attached)"),
+		})
+	}
+	return infos, nil
+}
+
+// KillPersistentShell ends a multiplexer session, and the shell in it. The
+// multiplexer defaults as for AttachPersistentShell.
+func (c *Client) KillPersistentShell(ctx context.Context, m Multiplexer, name string) error {
+	if !validSessionName(name) {
+		return fmt.Errorf("invalid session name %q", name)
+	}
+	m, err := c.detectMultiplexer(ctx, m)
+	if err != nil {
+		return err
+	}
+	switch m {
+	case MultiplexerTmux:
+		// "=" matches the name exactly, rather than as a prefix.
+		_, err = c.Output(ctx, "tmux", "kill-session", "-t", "="+name)
+	case MultiplexerScreen:
+		_, err = c.Output(ctx, "screen", "-S", name, "-X", "quit")
+	default:
+		err = fmt.Errorf("unknown multiplexer %q", m)
+	}
+	return err
+}
+
+// syncWriter serializes writes to w.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
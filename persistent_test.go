@@ -0,0 +1,175 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+	"golang.org/x/crypto/ssh"
+)
+
+// setUpMultiplexers puts fake tmux and screen on the PATH. Attaching prints
+// the session's name and terminal size, then echoes the input; the sessions
+// listed are in a file, and killed ones are added to another.
+func setUpMultiplexers(t *testing.T, tools ...string) (listed, killed string) {
+	t.Helper()
+	dir := t.TempDir()
+	listed, killed = filepath.Join(dir, "listed"), filepath.Join(dir, "killed")
+	scripts := map[string]string{
+		"tmux": fmt.Sprintf(`#!/bin/sh
+case $1 in
+new-session) echo "attached $4 $TERM $COLUMNS $LINES"; exec cat ;;
+list-sessions) [ -e %[1]s ] || { echo "no server running on /tmp/tmux-0/default" >&2; exit 1; }; cat %[1]s ;;
+kill-session) echo "tmux $3" >> %[2]s ;;
+esac
+`, listed, killed),
+		"screen": fmt.Sprintf(`#!/bin/sh
+case $1 in
+-D) echo "attached $4 $TERM $COLUMNS $LINES"; exec cat ;;
+-ls) printf 'There are screens on:\n\t123.one\t(10/16/2026 10:00:00 AM)\t(Attached)\n\t456.two\t(Detached)\n2 Sockets in /run/screen/S-root.\n'; exit 1 ;;
+-S) echo "screen $2 $4" >> %[1]s ;;
+esac
+`, killed),
+	}
+	fakes := make(map[string]string)
+	for _, tool := range tools {
+		fakes[tool] = scripts[tool]
+	}
+	setUpPath(t, fakes, "sh", "cat")
+	return listed, killed
+}
+
+// waitForOutput waits for out to contain want.
+func waitForOutput(t *testing.T, out *syncBuffer, want string) {
+	t.Helper()
+	for start := time.Now(); !strings.Contains(out.String(), want); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 10*time.Second {
+			t.Fatalf("timed out waiting for %q in output %q", want, out.String())
+		}
+	}
+}
+
+func TestPersistentShell(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), func(req *ssh.Request) {
+		req.Reply(true, nil)
+	})
+	setUpMultiplexers(t, "tmux", "screen")
+
+	if _, err := client.AttachPersistentShell(ctx, PersistentShellOptions{Name: "bad name"}); err == nil {
+		t.Errorf("expected an error for an invalid name")
+	}
+
+	stdin, input := io.Pipe()
+	var out syncBuffer
+	shell, err := client.AttachPersistentShell(ctx, PersistentShellOptions{
+		Name:   "dev",
+		PTY:    PTYOptions{Width: 100, Height: 30},
+		Stdin:  stdin,
+		Stdout: &out,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shell.Close()
+	waitForOutput(t, &out, "attached dev xterm-256color 100 30\n")
+	fmt.Fprintln(input, "one")
+	waitForOutput(t, &out, "one\n")
+
+	// After the connection drops and the client reconnects, the shell is
+	// reattached at its new size, and input typed meanwhile isn't lost.
+	if err := shell.Resize(120, 40); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+	go fmt.Fprintln(input, "two")
+	if err := client.Reconnect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitForOutput(t, &out, "attached dev xterm-256color 120 40\n")
+	waitForOutput(t, &out, "two\n")
+
+	// The shell exiting ends it.
+	input.Close()
+	if err := shell.Wait(); err != nil {
+		t.Errorf("shell failed: %v", err)
+	}
+}
+
+func TestPersistentShellScreen(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+	setUpMultiplexers(t, "screen")
+
+	var out syncBuffer
+	shell, err := client.AttachPersistentShell(ctx, PersistentShellOptions{Name: "dev", Stdout: &out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shell.Close()
+	waitForOutput(t, &out, "attached dev xterm-256color 80 24\n")
+	if err := shell.Close(); err != nil {
+		t.Errorf("failed to close the shell: %v", err)
+	}
+}
+
+func TestPersistentShells(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+	listed, killed := setUpMultiplexers(t, "tmux", "screen")
+
+	want := []PersistentShellInfo{
+		{Name: "one", Multiplexer: MultiplexerScreen, Attached: true},
+		{Name: "two", Multiplexer: MultiplexerScreen},
+	}
+	infos, err := client.PersistentShells(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(infos, want) {
+		t.Errorf("got sessions %+v without a tmux server, want %+v", infos, want)
+	}
+
+	if err := ioutil.WriteFile(listed, []byte("dev 1\nbuild 0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	infos, err = client.PersistentShells(ctx, MultiplexerTmux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []PersistentShellInfo{
+		{Name: "dev", Multiplexer: MultiplexerTmux, Attached: true},
+		{Name: "build", Multiplexer: MultiplexerTmux},
+	}
+	if !reflect.DeepEqual(infos, want) {
+		t.Errorf("got tmux sessions %+v, want %+v", infos, want)
+	}
+
+	if err := client.KillPersistentShell(ctx, "", "dev"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.KillPersistentShell(ctx, MultiplexerScreen, "two"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.KillPersistentShell(ctx, "", "a;b"); err == nil {
+		t.Errorf("expected an error for an invalid name")
+	}
+	data, err := ioutil.ReadFile(killed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "tmux =dev\nscreen two quit\n"; got != want {
+		t.Errorf("killed %q, want %q", got, want)
+	}
+}
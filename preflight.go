@@ -0,0 +1,143 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+)
+
+// InsufficientSpaceError is returned by a transfer preflight when the
+// destination filesystem doesn't have room for the transfer.
+type InsufficientSpaceError struct {
+	Path      string
+	Required  uint64
+	Available uint64
+}
+
+func (e InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("insufficient space on %q: %d bytes required, %d bytes available", e.Path, e.Required, e.Available)
+}
+
+// NotWritableError is returned by a transfer preflight when the destination
+// directory can't be written to.
+type NotWritableError struct {
+	Path string
+	Err  error
+}
+
+func (e NotWritableError) Unwrap() error {
+	return e.Err
+}
+
+func (e NotWritableError) Error() string {
+	return fmt.Sprintf("%q is not writable: %v", e.Path, e.Err)
+}
+
+// PathConflictError is returned by a transfer preflight when an existing
+// remote path is in the way of the transfer, such as a file where a directory
+// needs to be created.
+type PathConflictError struct {
+	Path   string
+	Reason string
+}
+
+func (e PathConflictError) Error() string {
+	return fmt.Sprintf("conflicting path %q: %s", e.Path, e.Reason)
+}
+
+// preflight checks that entries can be written beneath dst without running out
+// of space or tripping over existing paths, so that transfers fail before they
+// start rather than halfway through.
+func (f *FileClient) preflight(ctx context.Context, dst string, entries []transferEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	dir, err := f.existingDir(dst)
+	if err != nil {
+		return err
+	}
+
+	var required uint64
+	for _, e := range entries {
+		target := joinRel(dst, e.rel)
+		fi, err := f.sftp.Lstat(target)
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			return err
+		case e.isDir() && !fi.IsDir():
+			return PathConflictError{Path: target, Reason: "exists and is not a directory"}
+		case !e.isDir() && fi.IsDir():
+			return PathConflictError{Path: target, Reason: "exists and is a directory"}
+		}
+		if e.isRegular() {
+			required += uint64(e.size)
+		}
+	}
+
+	if err := f.checkWritable(dir); err != nil {
+		return err
+	}
+	st, err := f.StatFS(ctx, dir)
+	if err != nil {
+		return err
+	}
+	if st.Avail < required {
+		return InsufficientSpaceError{Path: dir, Required: required, Available: st.Avail}
+	}
+	return nil
+}
+
+// existingDir returns the directory that will receive writes to name: name
+// itself if it is an existing directory, or its nearest existing ancestor.
+func (f *FileClient) existingDir(name string) (string, error) {
+	name = path.Clean(name)
+	for first := true; ; first = false {
+		fi, err := f.sftp.Stat(name)
+		if err == nil {
+			if fi.IsDir() {
+				return name, nil
+			}
+			if first && fi.Mode().IsRegular() {
+				// An existing file will be replaced by the transfer, so the
+				// directory that receives the write is its parent.
+				parent := path.Dir(name)
+				if pfi, err := f.sftp.Stat(parent); err == nil && pfi.IsDir() {
+					return parent, nil
+				}
+			}
+			return "", PathConflictError{Path: name, Reason: "exists and is not a directory"}
+		}
+		// Keep walking up on any error, not just if name doesn't exist, so
+		// that a file ancestor (ENOTDIR) is reported as a conflict.
+		parent := path.Dir(name)
+		if parent == name {
+			return "", err
+		}
+		name = parent
+	}
+}
+
+// checkWritable checks whether files can be created in dir by creating and
+// removing a probe file.
+func (f *FileClient) checkWritable(dir string) error {
+	probe, err := TempName(path.Join(dir, "sshutil-preflight"))
+	if err != nil {
+		return err
+	}
+	file, err := f.sftp.OpenFile(probe, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return NotWritableError{Path: dir, Err: err}
+	}
+	file.Close()
+	if err := f.sftp.Remove(probe); err != nil {
+		return NotWritableError{Path: dir, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPreflight(t *testing.T) {
+	ctx := context.Background()
+	f := setUpFileClient(ctx, t)
+
+	t.Run("insufficient space", func(t *testing.T) {
+		src := t.TempDir()
+		// A sparse file lets us claim far more data than any disk holds
+		// without actually writing it.
+		huge, err := os.Create(filepath.Join(src, "huge"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := huge.Truncate(1 << 60); err != nil {
+			t.Skipf("filesystem does not support large sparse files: %v", err)
+		}
+		huge.Close()
+
+		dst := filepath.Join(t.TempDir(), "dst")
+		err = f.Upload(ctx, src, dst, TransferOptions{})
+		var spaceErr InsufficientSpaceError
+		if !errors.As(err, &spaceErr) {
+			t.Fatalf("expected InsufficientSpaceError, got %v", err)
+		}
+		if spaceErr.Required != 1<<60 || spaceErr.Available >= spaceErr.Required {
+			t.Errorf("unexpected required/available bytes: %+v", spaceErr)
+		}
+		if _, err := os.Stat(dst); !os.IsNotExist(err) {
+			t.Errorf("preflight failure should not have written anything, got %v", err)
+		}
+	})
+
+	t.Run("conflicting paths", func(t *testing.T) {
+		src := t.TempDir()
+		writeTree(t, src, map[string]string{"sub/file.txt": "x"})
+
+		dst := t.TempDir()
+		writeTree(t, dst, map[string]string{"sub": "i am a file"})
+
+		err := f.Upload(ctx, src, dst, TransferOptions{})
+		var conflictErr PathConflictError
+		if !errors.As(err, &conflictErr) {
+			t.Fatalf("expected PathConflictError, got %v", err)
+		}
+		if conflictErr.Path != filepath.Join(dst, "sub") {
+			t.Errorf("expected conflict on %q, got %q", filepath.Join(dst, "sub"), conflictErr.Path)
+		}
+
+		err = f.Upload(ctx, src, filepath.Join(dst, "sub", "below"), TransferOptions{})
+		if !errors.As(err, &conflictErr) {
+			t.Fatalf("expected PathConflictError for a file ancestor, got %v", err)
+		}
+	})
+
+	t.Run("not writable", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("permissions are not enforced for root")
+		}
+		src := t.TempDir()
+		writeTree(t, src, map[string]string{"file.txt": "x"})
+
+		dst := t.TempDir()
+		if err := os.Chmod(dst, 0o500); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.Chmod(dst, 0o700) })
+
+		err := f.Upload(ctx, src, filepath.Join(dst, "new"), TransferOptions{})
+		var writeErr NotWritableError
+		if !errors.As(err, &writeErr) {
+			t.Fatalf("expected NotWritableError, got %v", err)
+		}
+	})
+}
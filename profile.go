@@ -0,0 +1,236 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.fuchsia.dev/fuchsia/tools/lib/logger"
+)
+
+const (
+	// defaultProfileDuration is how long CPU profiles are captured for by
+	// default, which is the default of net/http/pprof.
+	defaultProfileDuration = 30 * time.Second
+
+	// profileTimeFormat is the format of the timestamps in profile names.
+	profileTimeFormat = "20060102T150405Z"
+)
+
+// ProfileKind is a kind of profile.
+type ProfileKind string
+
+const (
+	// ProfileCPU is a Go CPU profile, captured for a duration.
+	ProfileCPU ProfileKind = "cpu"
+
+	// ProfileHeap, ProfileAllocs and ProfileGoroutine are snapshots of a Go
+	// process's heap, allocations and goroutines.
+	ProfileHeap      ProfileKind = "heap"
+	ProfileAllocs    ProfileKind = "allocs"
+	ProfileGoroutine ProfileKind = "goroutine"
+
+	// ProfilePerf is a `perf record` capture of a process, or of the whole
+	// system, for a duration.
+	ProfilePerf ProfileKind = "perf"
+)
+
+// ProfileOptions configure CaptureProfile.
+type ProfileOptions struct {
+	Kind ProfileKind
+
+	// Addr is the address of the net/http/pprof server of a Go process, as
+	// reached from the remote, such as "127.0.0.1:6060".
+	Addr string
+
+	// PID is the process to profile with perf. If zero, the whole system is
+	// profiled.
+	PID int
+
+	// Duration is how long to profile for. It defaults to 30 seconds.
+	Duration time.Duration
+
+	// Dir is the local directory the profile is saved in.
+	Dir string
+}
+
+// Profile is a saved profile. It's saved alongside the profile, with a
+// ".json" suffix.
+type Profile struct {
+	// Path is the local path of the profile.
+	Path string `json:"-"`
+
+	Kind ProfileKind
+
+	// Host is the remote's hostname, and RemoteAddr the address the client
+	// is connected to.
+	Host       string
+	RemoteAddr string
+
+	// Source is the pprof server or process profiled, or empty for a
+	// system-wide perf profile.
+	Source string `json:",omitempty"`
+
+	// Time is when the capture started.
+	Time     time.Time
+	Duration time.Duration `json:",omitempty"`
+}
+
+// CaptureProfile captures a profile from the remote, either from a Go
+// process's pprof endpoints through an HTTP client connecting from the
+// remote, or by running `perf record`, and saves it in a local directory with
+// its metadata. Profiles are named after the host, kind and time, with a
+// number added if a profile with that name already exists.
+func (c *Client) CaptureProfile(ctx context.Context, opts ProfileOptions) (*Profile, error) {
+	if opts.Duration == 0 {
+		opts.Duration = defaultProfileDuration
+	}
+	host, err := c.Output(ctx, "uname", "-n")
+	if err != nil {
+		return nil, err
+	}
+	p := &Profile{
+		Kind:       opts.Kind,
+		Host:       strings.TrimSpace(string(host)),
+		RemoteAddr: c.Client().RemoteAddr().String(),
+		Time:       time.Now().UTC(),
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%s-%s-%s", sanitizeFileName(p.Host), p.Kind, p.Time.Format(profileTimeFormat))
+
+	var ext string
+	switch opts.Kind {
+	case ProfileCPU, ProfileHeap, ProfileAllocs, ProfileGoroutine:
+		ext = ".pb.gz"
+	case ProfilePerf:
+		ext = ".perf.data"
+	default:
+		return nil, fmt.Errorf("unknown profile kind %q", opts.Kind)
+	}
+	// Claim the name first, so profiles captured in the same second don't
+	// overwrite each other.
+	f, err := createNumbered(filepath.Join(opts.Dir, name), ext)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	p.Path = f.Name()
+
+	if opts.Kind == ProfilePerf {
+		if opts.PID != 0 {
+			p.Source = strconv.Itoa(opts.PID)
+		}
+		p.Duration = opts.Duration
+		err = c.recordPerf(ctx, opts, p.Path)
+	} else {
+		p.Source = opts.Addr
+		if opts.Kind == ProfileCPU {
+			p.Duration = opts.Duration
+		}
+		err = c.fetchPprof(ctx, opts, p.Path)
+	}
+	if err != nil {
+		os.Remove(p.Path)
+		return nil, err
+	}
+
+	metadata, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(p.Path+".json", append(metadata, '\n'), 0o644); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// sanitizeFileName replaces the characters of name that don't belong in a
+// file name.
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+func (c *Client) fetchPprof(ctx context.Context, opts ProfileOptions, dst string) error {
+	url := fmt.Sprintf("http://%s/debug/pprof/%s", opts.Addr, opts.Kind)
+	if opts.Kind == ProfileCPU {
+		// The endpoint takes whole seconds, and treats zero as the default.
+		seconds := int(opts.Duration.Seconds())
+		if seconds < 1 {
+			seconds = 1
+		}
+		url = fmt.Sprintf("http://%s/debug/pprof/profile?seconds=%d", opts.Addr, seconds)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.HTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to fetch %s: %s: %s", url, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return writeLocalFile(dst, resp.Body, 0o644)
+}
+
+func (c *Client) recordPerf(ctx context.Context, opts ProfileOptions, dst string) error {
+	if _, err := c.ShellOutput(ctx, "command -v perf"); err != nil {
+		var cmdErr CommandError
+		if errors.As(err, &cmdErr) {
+			return MissingToolError{Tool: "perf"}
+		}
+		return err
+	}
+	dir, err := c.tempDir(ctx, "sshutil-perf")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if _, err := c.Output(ctx, "rm", "-rf", dir); err != nil {
+			logger.Warningf(ctx, "failed to remove %s: %v", dir, err)
+		}
+	}()
+
+	data := path.Join(dir, "perf.data")
+	args := []string{"perf", "record", "-g", "-o", data}
+	if opts.PID != 0 {
+		args = append(args, "-p", strconv.Itoa(opts.PID))
+	} else {
+		args = append(args, "-a")
+	}
+	args = append(args, "--", "sleep", strconv.FormatFloat(opts.Duration.Seconds(), 'f', -1, 64))
+	if _, err := c.Output(ctx, args...); err != nil {
+		return err
+	}
+
+	f, err := c.NewFileClient()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Download(ctx, data, dst, TransferOptions{})
+}
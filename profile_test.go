@@ -0,0 +1,140 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+)
+
+func TestCaptureProfile(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/profile", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "cpu for %s", r.URL.Query().Get("seconds"))
+	})
+	mux.HandleFunc("/debug/pprof/heap", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "heap")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+
+	// check checks the profile's contents and metadata.
+	check := func(p *Profile, wantData string, wantMeta Profile) {
+		t.Helper()
+		data, err := ioutil.ReadFile(p.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != wantData {
+			t.Errorf("got profile %q, want %q", data, wantData)
+		}
+		want := fmt.Sprintf("%s-%s-%s", hostname, p.Kind, p.Time.Format(profileTimeFormat))
+		if !strings.HasPrefix(filepath.Base(p.Path), want) {
+			t.Errorf("got name %s, want it to start with %s", filepath.Base(p.Path), want)
+		}
+		metaData, err := ioutil.ReadFile(p.Path + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var meta Profile
+		if err := json.Unmarshal(metaData, &meta); err != nil {
+			t.Fatal(err)
+		}
+		wantMeta.Host = hostname
+		wantMeta.RemoteAddr = client.Client().RemoteAddr().String()
+		wantMeta.Time = p.Time
+		if !meta.Time.Equal(wantMeta.Time) {
+			t.Errorf("got time %v in metadata, want %v", meta.Time, wantMeta.Time)
+		}
+		meta.Time = wantMeta.Time
+		if meta != wantMeta {
+			t.Errorf("got metadata %+v, want %+v", meta, wantMeta)
+		}
+	}
+
+	dir := t.TempDir()
+	p, err := client.CaptureProfile(ctx, ProfileOptions{Kind: ProfileCPU, Addr: addr, Duration: 5 * time.Second, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(p, "cpu for 5", Profile{Kind: ProfileCPU, Source: addr, Duration: 5 * time.Second})
+
+	p, err = client.CaptureProfile(ctx, ProfileOptions{Kind: ProfileHeap, Addr: addr, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(p, "heap", Profile{Kind: ProfileHeap, Source: addr})
+
+	// Profiles captured in the same second don't overwrite each other.
+	p2, err := client.CaptureProfile(ctx, ProfileOptions{Kind: ProfileHeap, Addr: addr, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(p2, "heap", Profile{Kind: ProfileHeap, Source: addr})
+	if p2.Path == p.Path {
+		t.Errorf("second profile overwrote %s", p.Path)
+	}
+	check(p, "heap", Profile{Kind: ProfileHeap, Source: addr})
+
+	if _, err := client.CaptureProfile(ctx, ProfileOptions{Kind: ProfileGoroutine, Addr: addr, Dir: dir}); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("got error %v, want a 404", err)
+	}
+
+	t.Run("perf", func(t *testing.T) {
+		perf := `#!/bin/sh
+while [ "$1" != -- ]; do
+	[ "$1" = -o ] && out=$2
+	args="$args $1"
+	shift
+done
+echo "perf$args" > "$out"
+`
+		bin := setUpPath(t, map[string]string{"perf": perf}, "sh", "uname", "mktemp", "rm")
+
+		p, err := client.CaptureProfile(ctx, ProfileOptions{Kind: ProfilePerf, PID: 42, Duration: time.Second, Dir: dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(p.Path, ".perf.data") {
+			t.Errorf("got path %s, want a .perf.data file", p.Path)
+		}
+		data, err := ioutil.ReadFile(p.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(data), "perf record -g -o ") || !strings.HasSuffix(string(data), " -p 42\n") {
+			t.Errorf("got perf data %q", data)
+		}
+		check(p, string(data), Profile{Kind: ProfilePerf, Source: "42", Duration: time.Second})
+
+		os.Remove(filepath.Join(bin, "perf"))
+		_, err = client.CaptureProfile(ctx, ProfileOptions{Kind: ProfilePerf, Dir: dir})
+		var missing MissingToolError
+		if !errors.As(err, &missing) || missing.Tool != "perf" {
+			t.Errorf("got error %v, want a MissingToolError", err)
+		}
+	})
+}
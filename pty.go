@@ -0,0 +1,141 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"io"
+
+	"go.fuchsia.dev/fuchsia/tools/lib/logger"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	defaultTerm   = "xterm-256color"
+	defaultWidth  = 80
+	defaultHeight = 24
+)
+
+// PTYOptions describe the terminal of an interactive session.
+type PTYOptions struct {
+	// Term is the terminal type. It defaults to "xterm-256color".
+	Term string
+
+	// Width and Height are the size of the terminal in characters. They
+	// default to 80 by 24.
+	Width, Height int
+}
+
+func (o PTYOptions) withDefaults() PTYOptions {
+	if o.Term == "" {
+		o.Term = defaultTerm
+	}
+	if o.Width == 0 {
+		o.Width = defaultWidth
+	}
+	if o.Height == 0 {
+		o.Height = defaultHeight
+	}
+	return o
+}
+
+// StartPTY starts a command on the remote device with a terminal, or the
+// user's login shell if command is empty. The terminal merges the command's
+// standard error into its output, so stderr only gets what the remote sends
+// separately.
+func (c *Conn) StartPTY(ctx context.Context, command []string, pty PTYOptions, stdin io.Reader, stdout io.Writer, stderr io.Writer) (*Session, error) {
+	session, err := c.makeSession(ctx, stdin, stdout, stderr)
+	if err != nil {
+		return nil, err
+	}
+	if err := session.startPTY(ctx, command, pty); err != nil {
+		session.Close()
+		return nil, err
+	}
+	return session, nil
+}
+
+// startPTY requests a terminal for the session, and starts the command or
+// shell in it.
+func (s *Session) startPTY(ctx context.Context, command []string, pty PTYOptions) error {
+	pty = pty.withDefaults()
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          1,
+		ssh.TTY_OP_ISPEED: 38400,
+		ssh.TTY_OP_OSPEED: 38400,
+	}
+	if err := s.session.RequestPty(pty.Term, pty.Height, pty.Width, modes); err != nil {
+		return err
+	}
+
+	if len(command) == 0 {
+		logger.Debugf(ctx, "starting a shell over ssh")
+		return s.Shell(ctx)
+	}
+	logger.Debugf(ctx, "starting with a terminal over ssh: %s", command)
+	return s.Start(ctx, command)
+}
+
+// StartPTY starts a command on the remote device with a terminal, or the
+// user's login shell if command is empty, like Conn.StartPTY.
+func (c *Client) StartPTY(ctx context.Context, command []string, pty PTYOptions, stdin io.Reader, stdout io.Writer, stderr io.Writer) (*Session, error) {
+	c.mu.Lock()
+	conn := c.conn
+	toolsDir := c.toolsDir
+	c.mu.Unlock()
+
+	if len(command) > 0 {
+		command = withTools(command, toolsDir)
+	}
+	return conn.StartPTY(ctx, command, pty, stdin, stdout, stderr)
+}
+
+// startPTYWithStdinPipe starts a command with a terminal like StartPTY, with
+// a pipe to its standard input. Unlike with a reader, input written to the
+// pipe after the connection drops fails rather than being lost.
+func (c *Client) startPTYWithStdinPipe(ctx context.Context, command []string, pty PTYOptions, stdout io.Writer, stderr io.Writer) (*Session, io.WriteCloser, error) {
+	c.mu.Lock()
+	conn := c.conn
+	toolsDir := c.toolsDir
+	c.mu.Unlock()
+
+	if len(command) > 0 {
+		command = withTools(command, toolsDir)
+	}
+	session, err := conn.makeSession(ctx, nil, stdout, stderr)
+	if err != nil {
+		return nil, nil, err
+	}
+	stdin, err := session.session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, nil, err
+	}
+	if err := session.startPTY(ctx, command, pty); err != nil {
+		session.Close()
+		return nil, nil, err
+	}
+	return session, stdin, nil
+}
+
+// Shell starts the user's login shell.
+func (s *Session) Shell(ctx context.Context) error {
+	ch := make(chan error, 1)
+	go func() {
+		ch <- s.session.Shell()
+	}()
+
+	select {
+	case err := <-ch:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WindowChange tells the remote that the session's terminal was resized.
+func (s *Session) WindowChange(width, height int) error {
+	return s.session.WindowChange(height, width)
+}
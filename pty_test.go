@@ -0,0 +1,49 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+)
+
+func TestStartPTY(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+
+	// Without a command, the shell reads its commands from the input.
+	var out syncBuffer
+	session, err := client.StartPTY(ctx, nil, PTYOptions{Term: "vt100", Width: 132},
+		strings.NewReader(`echo "$TERM $COLUMNS $LINES"`+"\n"), &out, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	if err := session.WindowChange(100, 50); err != nil {
+		t.Errorf("failed to resize the terminal: %v", err)
+	}
+	if err := session.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "vt100 132 24\n"; got != want {
+		t.Errorf("got output %q, want %q", got, want)
+	}
+
+	var cmdOut syncBuffer
+	session, err = client.StartPTY(ctx, []string{"echo", "$TERM"}, PTYOptions{}, nil, &cmdOut, &cmdOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	if err := session.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cmdOut.String(), "xterm-256color\n"; got != want {
+		t.Errorf("got output %q, want %q", got, want)
+	}
+}
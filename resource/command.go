@@ -0,0 +1,87 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package resource
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Command is a command that is run unless one of its guards shows it isn't
+// needed. Commands without guards run every time.
+type Command struct {
+	// Name identifies the command. Defaults to the command line.
+	Name string
+
+	// Args is the command and its arguments. Each argument reaches the
+	// command unmodified.
+	Args []string
+
+	// Creates is a path the command creates. The command isn't run if it
+	// exists.
+	Creates string
+
+	// Unless is a shell script. The command isn't run if it exits
+	// successfully.
+	Unless string
+
+	// RefreshOnly only runs the command when it is notified. The guards
+	// still apply.
+	RefreshOnly bool
+}
+
+func (r Command) ID() string {
+	if r.Name != "" {
+		return "command:" + r.Name
+	}
+	return "command:" + strings.Join(r.Args, " ")
+}
+
+func (r Command) Plan(ctx context.Context, h *Host) (*Plan, error) {
+	if r.RefreshOnly {
+		return &Plan{}, nil
+	}
+	return r.plan(ctx, h)
+}
+
+// Refresh runs the command, subject to its guards.
+func (r Command) Refresh(ctx context.Context, h *Host) (*Plan, error) {
+	return r.plan(ctx, h)
+}
+
+func (r Command) plan(ctx context.Context, h *Host) (*Plan, error) {
+	if len(r.Args) == 0 {
+		return nil, errors.New("command has no arguments")
+	}
+	if r.Creates != "" {
+		f, err := h.Files()
+		if err != nil {
+			return nil, err
+		}
+		fi, err := lstat(f, r.Creates)
+		if err != nil {
+			return nil, err
+		}
+		if fi != nil {
+			return &Plan{}, nil
+		}
+	}
+	if r.Unless != "" {
+		ok, err := h.check(ctx, "sh", "-c", r.Unless)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return &Plan{}, nil
+		}
+	}
+	return &Plan{
+		Changes: []string{"run " + strings.Join(r.Args, " ")},
+		Apply: func(ctx context.Context) error {
+			return h.run(ctx, r.Args...)
+		},
+	}, nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package resource
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHost(ctx, t)
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "marker")
+	log := filepath.Join(dir, "log")
+
+	applyTwice(ctx, t, h, Command{Args: []string{"touch", marker}, Creates: marker})
+	applyTwice(ctx, t, h, Command{
+		Args:   []string{"sh", "-c", "echo initialized > " + log},
+		Unless: "grep -q initialized " + log,
+	})
+
+	// A refresh-only command runs when a changed resource notifies it.
+	tasks := []Task{
+		{Resource: File{Path: filepath.Join(dir, "config"), Content: []byte("x")}, Notify: []string{"command:reload"}},
+		{Resource: Command{Name: "reload", Args: []string{"sh", "-c", "echo reloaded >> " + log}, RefreshOnly: true}},
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := Apply(ctx, h, tasks, Options{}); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+	}
+	got, err := ioutil.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "initialized\nreloaded\n"; string(got) != want {
+		t.Errorf("log contains %q, want %q", got, want)
+	}
+
+	report, err := Apply(ctx, h, []Task{{Resource: Command{Args: []string{"false"}}}}, Options{})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if !report.Failed() {
+		t.Errorf("expected a failing command to fail")
+	}
+}
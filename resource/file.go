@@ -0,0 +1,344 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package resource
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/aucloud/go-sshutil"
+	"github.com/pkg/sftp"
+)
+
+// File is a regular file with the given content.
+type File struct {
+	Path    string
+	Content []byte
+
+	// Mode is the permission bits of the file. If zero, existing files keep
+	// their mode and new files get 0644.
+	Mode os.FileMode
+
+	// Owner and Group are the names of the file's owner and group. If empty,
+	// existing files keep theirs and new files belong to the ssh user.
+	Owner string
+	Group string
+
+	// Absent removes the file rather than creating it.
+	Absent bool
+}
+
+func (r File) ID() string {
+	return "file:" + r.Path
+}
+
+func (r File) Plan(ctx context.Context, h *Host) (*Plan, error) {
+	f, err := h.Files()
+	if err != nil {
+		return nil, err
+	}
+	fi, err := lstat(f, r.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Absent {
+		if fi == nil {
+			return &Plan{}, nil
+		}
+		if fi.IsDir() {
+			return nil, fmt.Errorf("%s is a directory", r.Path)
+		}
+		return &Plan{
+			Changes: []string{"remove " + r.Path},
+			Apply: func(ctx context.Context) error {
+				return f.SFTP().Remove(r.Path)
+			},
+		}, nil
+	}
+	if fi != nil && !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s exists and is not a regular file", r.Path)
+	}
+
+	plan := &Plan{}
+	var old []byte
+	writeContent := false
+	if fi == nil {
+		plan.Changes = append(plan.Changes, "create "+r.Path)
+		writeContent = true
+	} else {
+		if old, err = f.ReadFile(r.Path); err != nil {
+			return nil, err
+		}
+		writeContent = !bytes.Equal(old, r.Content)
+		if writeContent {
+			plan.Changes = append(plan.Changes, "update content of "+r.Path)
+		}
+	}
+	if writeContent {
+		plan.Diff = sshutil.DiffContents(r.Content, r.Path, old, fi != nil).String()
+	}
+
+	attrs, err := planAttributes(f, r.Path, fi, r.Mode, 0o644, r.Owner, r.Group)
+	if err != nil {
+		return nil, err
+	}
+	plan.Changes = append(plan.Changes, attrs.changes...)
+	plan.Apply = func(ctx context.Context) error {
+		if writeContent {
+			if err := f.WriteFile(ctx, r.Path, bytes.NewReader(r.Content), attrs.mode); err != nil {
+				return err
+			}
+		}
+		// A rewritten file is a new file, so its attributes always need to
+		// be set if it existed before.
+		return attrs.apply(f, r.Path, writeContent && fi != nil)
+	}
+	return plan, nil
+}
+
+// Directory is a directory, created along with any missing parents.
+type Directory struct {
+	Path string
+
+	// Mode is the permission bits of the directory. If zero, existing
+	// directories keep their mode and new directories get 0755.
+	Mode os.FileMode
+
+	// Owner and Group are as for File.
+	Owner string
+	Group string
+
+	// Absent removes the directory and everything in it.
+	Absent bool
+}
+
+func (r Directory) ID() string {
+	return "directory:" + r.Path
+}
+
+func (r Directory) Plan(ctx context.Context, h *Host) (*Plan, error) {
+	f, err := h.Files()
+	if err != nil {
+		return nil, err
+	}
+	fi, err := lstat(f, r.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Absent {
+		if fi == nil {
+			return &Plan{}, nil
+		}
+		if !fi.IsDir() {
+			return nil, fmt.Errorf("%s is not a directory", r.Path)
+		}
+		return &Plan{
+			Changes: []string{"remove " + r.Path},
+			Apply: func(ctx context.Context) error {
+				return h.run(ctx, "rm", "-rf", "--", r.Path)
+			},
+		}, nil
+	}
+	if fi != nil && !fi.IsDir() {
+		return nil, fmt.Errorf("%s exists and is not a directory", r.Path)
+	}
+
+	plan := &Plan{}
+	if fi == nil {
+		plan.Changes = append(plan.Changes, "create "+r.Path)
+	}
+	attrs, err := planAttributes(f, r.Path, fi, r.Mode, 0o755, r.Owner, r.Group)
+	if err != nil {
+		return nil, err
+	}
+	plan.Changes = append(plan.Changes, attrs.changes...)
+	plan.Apply = func(ctx context.Context) error {
+		if fi == nil {
+			if err := f.SFTP().MkdirAll(r.Path); err != nil {
+				return err
+			}
+		}
+		// New directories are subject to the remote umask, so always set
+		// their mode.
+		return attrs.apply(f, r.Path, fi == nil)
+	}
+	return plan, nil
+}
+
+// Symlink is a symbolic link pointing at Target.
+type Symlink struct {
+	Path   string
+	Target string
+
+	// Absent removes the link rather than creating it.
+	Absent bool
+}
+
+func (r Symlink) ID() string {
+	return "symlink:" + r.Path
+}
+
+func (r Symlink) Plan(ctx context.Context, h *Host) (*Plan, error) {
+	f, err := h.Files()
+	if err != nil {
+		return nil, err
+	}
+	fi, err := lstat(f, r.Path)
+	if err != nil {
+		return nil, err
+	}
+	isLink := fi != nil && fi.Mode()&os.ModeSymlink != 0
+
+	if r.Absent {
+		if fi == nil {
+			return &Plan{}, nil
+		}
+		if !isLink {
+			return nil, fmt.Errorf("%s is not a symlink", r.Path)
+		}
+		return &Plan{
+			Changes: []string{"remove " + r.Path},
+			Apply: func(ctx context.Context) error {
+				return f.SFTP().Remove(r.Path)
+			},
+		}, nil
+	}
+
+	var change string
+	switch {
+	case fi == nil:
+		change = fmt.Sprintf("create %s -> %s", r.Path, r.Target)
+	case fi.IsDir():
+		return nil, fmt.Errorf("%s exists and is a directory", r.Path)
+	case !isLink:
+		change = fmt.Sprintf("replace %s with a link to %s", r.Path, r.Target)
+	default:
+		old, err := f.SFTP().ReadLink(r.Path)
+		if err != nil {
+			return nil, err
+		}
+		if old == r.Target {
+			return &Plan{}, nil
+		}
+		change = fmt.Sprintf("point %s at %s instead of %s", r.Path, r.Target, old)
+	}
+	return &Plan{
+		Changes: []string{change},
+		Apply: func(ctx context.Context) error {
+			// Create the link next to its final path and rename it into
+			// place, so the path always exists.
+			tmp, err := sshutil.TempName(r.Path)
+			if err != nil {
+				return err
+			}
+			if err := f.SFTP().Symlink(r.Target, tmp); err != nil {
+				return err
+			}
+			if err := f.Rename(ctx, tmp, r.Path); err != nil {
+				f.SFTP().Remove(tmp)
+				return err
+			}
+			return nil
+		},
+	}, nil
+}
+
+// lstat returns the FileInfo of name, or nil if it doesn't exist.
+func lstat(f *sshutil.FileClient, name string) (os.FileInfo, error) {
+	fi, err := f.SFTP().Lstat(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return fi, err
+}
+
+// attributes are the desired mode and ownership of a file or directory.
+type attributes struct {
+	mode     os.FileMode
+	uid, gid int
+	// Which attributes differ from the existing file.
+	chmod, chown bool
+	changes      []string
+}
+
+// planAttributes compares the mode and ownership of an existing file, or nil
+// for a new file, with the desired ones.
+func planAttributes(f *sshutil.FileClient, name string, fi os.FileInfo, mode, defaultMode os.FileMode, owner, group string) (*attributes, error) {
+	a := &attributes{mode: mode.Perm(), uid: -1, gid: -1}
+	if mode == 0 {
+		a.mode = defaultMode
+		if fi != nil {
+			a.mode = fi.Mode().Perm()
+		}
+	}
+	if fi != nil && fi.Mode().Perm() != a.mode {
+		a.chmod = true
+		a.changes = append(a.changes, fmt.Sprintf("change mode of %s from %#o to %#o", name, fi.Mode().Perm(), a.mode))
+	}
+
+	// Start from the existing owner, so that rewritten files keep it.
+	if fi != nil {
+		if stat, ok := fi.Sys().(*sftp.FileStat); ok {
+			a.uid, a.gid = int(stat.UID), int(stat.GID)
+		}
+	}
+	if owner != "" {
+		uid, err := f.LookupUser(owner)
+		if err != nil {
+			return nil, err
+		}
+		if int(uid) != a.uid {
+			a.chown = true
+			a.changes = append(a.changes, fmt.Sprintf("change owner of %s to %s", name, owner))
+		}
+		a.uid = int(uid)
+	}
+	if group != "" {
+		gid, err := f.LookupGroup(group)
+		if err != nil {
+			return nil, err
+		}
+		if int(gid) != a.gid {
+			a.chown = true
+			a.changes = append(a.changes, fmt.Sprintf("change group of %s to %s", name, group))
+		}
+		a.gid = int(gid)
+	}
+	return a, nil
+}
+
+// apply sets the attributes that changed, or all of them if all is set.
+func (a *attributes) apply(f *sshutil.FileClient, name string, all bool) error {
+	if a.chmod || all {
+		if err := f.SFTP().Chmod(name, a.mode); err != nil {
+			return err
+		}
+	}
+	if !a.chown && !(all && a.uid >= 0) {
+		return nil
+	}
+	uid, gid := a.uid, a.gid
+	if uid < 0 || gid < 0 {
+		// Only one of the owner and group of a new file was given, so keep
+		// the other as it was created.
+		fi, err := f.SFTP().Stat(name)
+		if err != nil {
+			return err
+		}
+		if stat, ok := fi.Sys().(*sftp.FileStat); ok {
+			if uid < 0 {
+				uid = int(stat.UID)
+			}
+			if gid < 0 {
+				gid = int(stat.GID)
+			}
+		}
+	}
+	return f.SFTP().Chown(name, uid, gid)
+}
@@ -0,0 +1,126 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package resource
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// applyTwice applies a resource, which must change, and then applies it again,
+// which must not.
+func applyTwice(ctx context.Context, t *testing.T, h *Host, r Resource) Result {
+	t.Helper()
+	report, err := Apply(ctx, h, []Task{{Resource: r}}, Options{})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	first := report.Results[0]
+	if first.Status != StatusChanged {
+		t.Fatalf("expected %s to change, got %+v", r.ID(), first)
+	}
+	report, err = Apply(ctx, h, []Task{{Resource: r}}, Options{})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if second := report.Results[0]; second.Status != StatusOK {
+		t.Fatalf("expected applying %s again to be a no-op, got %+v", r.ID(), second)
+	}
+	return first
+}
+
+func TestFile(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHost(ctx, t)
+	dir := t.TempDir()
+	name := filepath.Join(dir, "motd")
+
+	file := File{Path: name, Content: []byte("hello\n"), Mode: 0o600}
+	report, err := Apply(ctx, h, []Task{{Resource: file}}, Options{Check: true})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if report.Results[0].Status != StatusChanged {
+		t.Errorf("expected check mode to report a change, got %+v", report.Results[0])
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Fatalf("check mode created the file")
+	}
+
+	applyTwice(ctx, t, h, file)
+	assertFile(t, name, "hello\n", 0o600)
+
+	file.Content = []byte("goodbye\n")
+	file.Owner = "root"
+	result := applyTwice(ctx, t, h, file)
+	assertFile(t, name, "goodbye\n", 0o600)
+	if result.Diff == "" {
+		t.Errorf("expected a diff of the content")
+	}
+
+	applyTwice(ctx, t, h, File{Path: name, Absent: true})
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("expected the file to be removed")
+	}
+}
+
+func TestDirectory(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHost(ctx, t)
+	name := filepath.Join(t.TempDir(), "a", "b")
+
+	applyTwice(ctx, t, h, Directory{Path: name, Mode: 0o700})
+	if fi, err := os.Stat(name); err != nil || !fi.IsDir() || fi.Mode().Perm() != 0o700 {
+		t.Fatalf("expected a directory with mode 0700, got %v (err: %v)", fi, err)
+	}
+	applyTwice(ctx, t, h, Directory{Path: name, Mode: 0o755})
+
+	if err := ioutil.WriteFile(filepath.Join(name, "f"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	applyTwice(ctx, t, h, Directory{Path: name, Absent: true})
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("expected the directory to be removed")
+	}
+}
+
+func TestSymlink(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHost(ctx, t)
+	dir := t.TempDir()
+	name := filepath.Join(dir, "current")
+
+	applyTwice(ctx, t, h, Symlink{Path: name, Target: "v1"})
+	applyTwice(ctx, t, h, Symlink{Path: name, Target: "v2"})
+	if target, err := os.Readlink(name); err != nil || target != "v2" {
+		t.Errorf("expected link to v2, got %q (err: %v)", target, err)
+	}
+	applyTwice(ctx, t, h, Symlink{Path: name, Absent: true})
+
+	report, err := Apply(ctx, h, []Task{{Resource: Symlink{Path: dir, Target: "x"}}}, Options{})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if report.Results[0].Status != StatusFailed {
+		t.Errorf("expected replacing a directory with a link to fail, got %+v", report.Results[0])
+	}
+}
+
+func assertFile(t *testing.T, name, content string, mode os.FileMode) {
+	t.Helper()
+	got, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Errorf("%s contains %q, want %q", name, got, content)
+	}
+	if fi, err := os.Stat(name); err != nil || fi.Mode().Perm() != mode {
+		t.Errorf("%s has mode %v, want %v (err: %v)", name, fi.Mode().Perm(), mode, err)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package resource
+
+import (
+	"context"
+
+	"github.com/aucloud/go-sshutil"
+)
+
+// Package is a package installed with the host's package manager, which may
+// be apt, dnf, yum, apk or opkg.
+type Package struct {
+	Name string
+
+	// Absent removes the package rather than installing it.
+	Absent bool
+}
+
+func (r Package) ID() string {
+	return "package:" + r.Name
+}
+
+func (r Package) Plan(ctx context.Context, h *Host) (*Plan, error) {
+	p, err := packageClient(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	_, installed, err := p.Installed(ctx, r.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !r.Absent && !installed:
+		return &Plan{
+			Changes: []string{"install " + r.Name},
+			Apply: func(ctx context.Context) error {
+				_, err := p.Install(ctx, r.Name)
+				return err
+			},
+		}, nil
+	case r.Absent && installed:
+		return &Plan{
+			Changes: []string{"remove " + r.Name},
+			Apply: func(ctx context.Context) error {
+				_, err := p.Remove(ctx, r.Name)
+				return err
+			},
+		}, nil
+	}
+	return &Plan{}, nil
+}
+
+// packageClient returns a PackageClient for the package manager of the host,
+// which is only detected once.
+func packageClient(ctx context.Context, h *Host) (*sshutil.PackageClient, error) {
+	manager, err := h.fact("package-manager", func() (string, error) {
+		p, err := h.Client.NewPackageClient(ctx)
+		if err != nil {
+			return "", err
+		}
+		return string(p.Manager()), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h.Client.NewPackageClientFor(sshutil.PackageManager(manager))
+}
@@ -0,0 +1,435 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package resource idempotently applies desired state to remote hosts, in the
+// style of configuration management tools such as Ansible or Puppet.
+//
+// A Resource describes the desired state of one thing on a host, such as a
+// file or a service. Apply plans each resource against the host, makes the
+// changes the plan calls for unless running in check mode, and reports
+// whether each resource was already ok, changed, failed or was skipped.
+// Tasks can require other resources to be applied first, and notify
+// resources to refresh, such as restarting a service, when they change.
+package resource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aucloud/go-sshutil"
+	"go.fuchsia.dev/fuchsia/tools/lib/logger"
+)
+
+// Resource is the desired state of something on a remote host.
+type Resource interface {
+	// ID identifies the resource, such as "file:/etc/motd". It is used to
+	// refer to the resource in Task.Requires and Task.Notify, and must be
+	// unique within a call to Apply.
+	ID() string
+
+	// Plan compares the desired state with the state of the host, and
+	// returns the changes needed to reach the desired state.
+	Plan(ctx context.Context, h *Host) (*Plan, error)
+}
+
+// Notifiable is implemented by resources that can be notified when another
+// resource changes, such as a service that restarts when its configuration
+// file changes.
+type Notifiable interface {
+	Resource
+
+	// Refresh returns the plan to run when the resource is notified.
+	Refresh(ctx context.Context, h *Host) (*Plan, error)
+}
+
+// Plan is the set of changes needed to bring a resource to its desired state.
+type Plan struct {
+	// Changes describes each change. It is empty if the resource is already
+	// in the desired state.
+	Changes []string
+
+	// Diff is an optional unified diff of the change.
+	Diff string
+
+	// Apply makes the changes. It must be set if there are any.
+	Apply func(ctx context.Context) error
+}
+
+// Empty reports whether the plan has no changes.
+func (p *Plan) Empty() bool {
+	return p == nil || len(p.Changes) == 0
+}
+
+// Host is a remote host that resources are applied to.
+type Host struct {
+	Client *sshutil.Client
+
+	mu    sync.Mutex
+	files *sshutil.FileClient
+	facts map[string]string
+}
+
+// NewHost returns a Host using client. Call Close when done with it.
+func NewHost(client *sshutil.Client) *Host {
+	return &Host{Client: client}
+}
+
+// Files returns a FileClient for the host, which is created on first use and
+// shared by all resources.
+func (h *Host) Files() (*sshutil.FileClient, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.files == nil {
+		f, err := h.Client.NewFileClient()
+		if err != nil {
+			return nil, err
+		}
+		h.files = f
+	}
+	return h.files, nil
+}
+
+// Close closes the host's FileClient, if one was created. It does not close
+// the Client.
+func (h *Host) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.files == nil {
+		return nil
+	}
+	err := h.files.Close()
+	h.files = nil
+	return err
+}
+
+// fact returns the result of detect, which is only called once per key for the
+// lifetime of the host. It is used for things like the init system, which
+// don't change between resources.
+func (h *Host) fact(key string, detect func() (string, error)) (string, error) {
+	h.mu.Lock()
+	v, ok := h.facts[key]
+	h.mu.Unlock()
+	if ok {
+		return v, nil
+	}
+	v, err := detect()
+	if err != nil {
+		return "", err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.facts == nil {
+		h.facts = make(map[string]string)
+	}
+	h.facts[key] = v
+	return v, nil
+}
+
+// check runs a command on the host and reports whether it exited
+// successfully. Only failures to run the command are returned as errors.
+func (h *Host) check(ctx context.Context, args ...string) (bool, error) {
+	_, err := h.Client.Output(ctx, args...)
+	var cmdErr sshutil.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.ExitStatus() >= 0 {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// run runs a command on the host.
+func (h *Host) run(ctx context.Context, args ...string) error {
+	_, err := h.Client.Output(ctx, args...)
+	return err
+}
+
+// Task is a resource to apply, along with its relationships to other
+// resources.
+type Task struct {
+	Resource Resource
+
+	// Requires lists the IDs of resources that must be applied before this
+	// one. If any of them fails or is skipped, this one is skipped.
+	Requires []string
+
+	// Notify lists the IDs of resources that are refreshed if this one
+	// changes. They must implement Notifiable, and are applied after this
+	// one.
+	Notify []string
+}
+
+// Options controls how resources are applied.
+type Options struct {
+	// Check only plans the resources, reporting what would change without
+	// changing anything.
+	Check bool
+}
+
+// Status is the outcome of applying a resource.
+type Status int
+
+const (
+	// StatusOK means the resource was already in the desired state.
+	StatusOK Status = iota
+
+	// StatusChanged means the resource was changed, or in check mode, that
+	// it would have been.
+	StatusChanged
+
+	// StatusFailed means planning or applying the resource failed.
+	StatusFailed
+
+	// StatusSkipped means a resource the resource requires failed or was
+	// skipped.
+	StatusSkipped
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusOK:
+		return "ok"
+	case StatusChanged:
+		return "changed"
+	case StatusFailed:
+		return "failed"
+	case StatusSkipped:
+		return "skipped"
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
+// Result is the outcome of applying a single resource.
+type Result struct {
+	ID     string
+	Status Status
+
+	// Changes and Diff are taken from the resource's plan, followed by the
+	// changes of its refresh if it was notified.
+	Changes []string
+	Diff    string
+
+	// Refreshed is true if the resource was notified and refreshed.
+	Refreshed bool
+
+	// Err is set if Status is StatusFailed.
+	Err error
+}
+
+// Report is the outcome of a call to Apply, with a Result for every task in
+// the order they were applied.
+type Report struct {
+	Results []Result
+}
+
+// Count returns the number of results with the given status.
+func (r *Report) Count(s Status) int {
+	n := 0
+	for _, result := range r.Results {
+		if result.Status == s {
+			n++
+		}
+	}
+	return n
+}
+
+// Failed reports whether any resource failed.
+func (r *Report) Failed() bool {
+	return r.Count(StatusFailed) > 0
+}
+
+// Changed reports whether any resource changed.
+func (r *Report) Changed() bool {
+	return r.Count(StatusChanged) > 0
+}
+
+// Result returns the result for the resource with the given ID.
+func (r *Report) Result(id string) (Result, bool) {
+	for _, result := range r.Results {
+		if result.ID == id {
+			return result, true
+		}
+	}
+	return Result{}, false
+}
+
+// String summarizes the report, like "ok=3 changed=1 failed=0 skipped=0".
+func (r *Report) String() string {
+	var parts []string
+	for _, s := range []Status{StatusOK, StatusChanged, StatusFailed, StatusSkipped} {
+		parts = append(parts, fmt.Sprintf("%s=%d", s, r.Count(s)))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Apply brings the host to the state described by tasks.
+//
+// Tasks are applied in the order given, except that a task is always applied
+// after the resources it requires and the resources notifying it. A failing
+// resource doesn't stop the run: only the resources requiring it are skipped.
+// The error is only set if the tasks themselves are invalid, for example if
+// they refer to unknown IDs or depend on each other in a cycle.
+func Apply(ctx context.Context, h *Host, tasks []Task, opts Options) (*Report, error) {
+	order, err := orderTasks(tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	status := make(map[string]Status)
+	notified := make(map[string]bool)
+	for _, i := range order {
+		task := tasks[i]
+		id := task.Resource.ID()
+		result := applyTask(ctx, h, task, status, notified[id], opts)
+		status[id] = result.Status
+		if result.Status == StatusChanged {
+			for _, n := range task.Notify {
+				notified[n] = true
+			}
+		}
+		if result.Err != nil {
+			logger.Errorf(ctx, "resource %s failed: %v", id, result.Err)
+		} else {
+			logger.Debugf(ctx, "resource %s: %s", id, result.Status)
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+func applyTask(ctx context.Context, h *Host, task Task, status map[string]Status, notified bool, opts Options) Result {
+	result := Result{ID: task.Resource.ID()}
+	for _, req := range task.Requires {
+		if s := status[req]; s == StatusFailed || s == StatusSkipped {
+			result.Status = StatusSkipped
+			return result
+		}
+	}
+
+	fail := func(err error) Result {
+		result.Status = StatusFailed
+		result.Err = err
+		return result
+	}
+
+	plan, err := task.Resource.Plan(ctx, h)
+	if err != nil {
+		return fail(fmt.Errorf("failed to plan: %w", err))
+	}
+	if !plan.Empty() {
+		if plan.Apply == nil {
+			return fail(errors.New("plan has changes but no Apply function"))
+		}
+		result.Status = StatusChanged
+		result.Changes = plan.Changes
+		result.Diff = plan.Diff
+		if !opts.Check {
+			if err := plan.Apply(ctx); err != nil {
+				return fail(err)
+			}
+		}
+	}
+
+	if !notified {
+		return result
+	}
+	// In check mode, the refresh is planned against the current state of the
+	// host rather than the state after applying the resource's own plan, but
+	// that's the best we can do without changing anything.
+	refresh, err := task.Resource.(Notifiable).Refresh(ctx, h)
+	if err != nil {
+		return fail(fmt.Errorf("failed to plan refresh: %w", err))
+	}
+	if !refresh.Empty() {
+		if refresh.Apply == nil {
+			return fail(errors.New("refresh plan has changes but no Apply function"))
+		}
+		result.Status = StatusChanged
+		result.Refreshed = true
+		result.Changes = append(result.Changes, refresh.Changes...)
+		if !opts.Check {
+			if err := refresh.Apply(ctx); err != nil {
+				return fail(err)
+			}
+		}
+	}
+	return result
+}
+
+// orderTasks validates the tasks and returns the order to apply them in: the
+// given order, except that tasks come after the tasks they require and the
+// tasks that notify them.
+func orderTasks(tasks []Task) ([]int, error) {
+	index := make(map[string]int, len(tasks))
+	for i, task := range tasks {
+		if task.Resource == nil {
+			return nil, fmt.Errorf("task %d has no resource", i)
+		}
+		id := task.Resource.ID()
+		if _, ok := index[id]; ok {
+			return nil, fmt.Errorf("duplicate resource %q", id)
+		}
+		index[id] = i
+	}
+
+	// deps[i] lists the tasks that must be applied before task i.
+	deps := make([][]int, len(tasks))
+	for i, task := range tasks {
+		for _, req := range task.Requires {
+			j, ok := index[req]
+			if !ok {
+				return nil, fmt.Errorf("%q requires unknown resource %q", task.Resource.ID(), req)
+			}
+			deps[i] = append(deps[i], j)
+		}
+		for _, n := range task.Notify {
+			j, ok := index[n]
+			if !ok {
+				return nil, fmt.Errorf("%q notifies unknown resource %q", task.Resource.ID(), n)
+			}
+			if _, ok := tasks[j].Resource.(Notifiable); !ok {
+				return nil, fmt.Errorf("%q notifies %q, which can't be refreshed", task.Resource.ID(), n)
+			}
+			deps[j] = append(deps[j], i)
+		}
+	}
+
+	// Repeatedly pick the first task whose dependencies have all been
+	// applied, which keeps the given order wherever possible.
+	order := make([]int, 0, len(tasks))
+	done := make([]bool, len(tasks))
+	for len(order) < len(tasks) {
+		next := -1
+		for i := range tasks {
+			if done[i] {
+				continue
+			}
+			ready := true
+			for _, j := range deps[i] {
+				if !done[j] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			var cycle []string
+			for i, task := range tasks {
+				if !done[i] {
+					cycle = append(cycle, task.Resource.ID())
+				}
+			}
+			return nil, fmt.Errorf("dependency cycle between %s", strings.Join(cycle, ", "))
+		}
+		done[next] = true
+		order = append(order, next)
+	}
+	return order, nil
+}
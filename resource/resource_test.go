@@ -0,0 +1,193 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package resource
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.fuchsia.dev/fuchsia/tools/lib/retry"
+
+	"github.com/aucloud/go-sshutil"
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+	"golang.org/x/crypto/ssh"
+)
+
+// newTestHost returns a Host whose "remote" is the local machine.
+func newTestHost(ctx context.Context, t *testing.T) *Host {
+	// Answer keepalives, so the client stays connected in long tests.
+	server, err := sshtest.Start(sshtest.OnShellChannel(), func(req *ssh.Request) {
+		req.Reply(true, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Stop)
+	client, err := sshutil.NewClient(ctx, sshutil.ConstantAddrResolver{Addr: server.Addr}, server.ClientConfig, retry.NoRetries())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	h := NewHost(client)
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+// fakeResource records the order it was applied and refreshed in.
+type fakeResource struct {
+	id      string
+	changes []string
+	planErr error
+	applied *[]string
+}
+
+func (r fakeResource) ID() string {
+	return r.id
+}
+
+func (r fakeResource) Plan(ctx context.Context, h *Host) (*Plan, error) {
+	if r.planErr != nil {
+		return nil, r.planErr
+	}
+	return &Plan{
+		Changes: r.changes,
+		Apply: func(ctx context.Context) error {
+			*r.applied = append(*r.applied, r.id)
+			return nil
+		},
+	}, nil
+}
+
+func (r fakeResource) Refresh(ctx context.Context, h *Host) (*Plan, error) {
+	return &Plan{
+		Changes: []string{"refresh " + r.id},
+		Apply: func(ctx context.Context) error {
+			*r.applied = append(*r.applied, "refresh "+r.id)
+			return nil
+		},
+	}, nil
+}
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	var applied []string
+	fake := func(id string, changed bool) fakeResource {
+		r := fakeResource{id: id, applied: &applied}
+		if changed {
+			r.changes = []string{"change " + id}
+		}
+		return r
+	}
+	tasks := []Task{
+		{Resource: fake("service", false)},
+		{Resource: fake("config", true), Requires: []string{"package"}, Notify: []string{"service"}},
+		{Resource: fake("package", true)},
+		{Resource: fake("unchanged", false), Notify: []string{"other"}},
+		{Resource: fake("other", false)},
+		{Resource: fakeResource{id: "broken", planErr: errors.New("oops")}},
+		{Resource: fake("dependent", true), Requires: []string{"broken"}},
+	}
+
+	t.Run("check mode", func(t *testing.T) {
+		applied = nil
+		report, err := Apply(ctx, nil, tasks, Options{Check: true})
+		if err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+		if len(applied) != 0 {
+			t.Errorf("check mode applied %v", applied)
+		}
+		if r, _ := report.Result("service"); r.Status != StatusChanged || !r.Refreshed {
+			t.Errorf("expected the service to be reported as refreshed, got %+v", r)
+		}
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		applied = nil
+		report, err := Apply(ctx, nil, tasks, Options{})
+		if err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+		want := []string{"package", "config", "refresh service"}
+		if !reflect.DeepEqual(applied, want) {
+			t.Errorf("applied %v, want %v", applied, want)
+		}
+
+		var order []string
+		for _, r := range report.Results {
+			order = append(order, r.ID)
+		}
+		wantOrder := []string{"package", "config", "service", "unchanged", "other", "broken", "dependent"}
+		if !reflect.DeepEqual(order, wantOrder) {
+			t.Errorf("applied in order %v, want %v", order, wantOrder)
+		}
+
+		for id, status := range map[string]Status{
+			"package":   StatusChanged,
+			"config":    StatusChanged,
+			"service":   StatusChanged,
+			"unchanged": StatusOK,
+			"other":     StatusOK,
+			"broken":    StatusFailed,
+			"dependent": StatusSkipped,
+		} {
+			if r, _ := report.Result(id); r.Status != status {
+				t.Errorf("expected %s to be %s, got %+v", id, status, r)
+			}
+		}
+		if got, want := report.String(), "ok=2 changed=3 failed=1 skipped=1"; got != want {
+			t.Errorf("got summary %q, want %q", got, want)
+		}
+	})
+}
+
+func TestApplyPlanWithoutApply(t *testing.T) {
+	report, err := Apply(context.Background(), nil, []Task{{Resource: incompleteResource{"x"}}}, Options{})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if r, _ := report.Result("x"); r.Status != StatusFailed || r.Err == nil {
+		t.Errorf("expected a plan without Apply to fail, got %+v", r)
+	}
+}
+
+// incompleteResource plans a change without saying how to apply it.
+type incompleteResource struct{ id string }
+
+func (r incompleteResource) ID() string { return r.id }
+
+func (r incompleteResource) Plan(ctx context.Context, h *Host) (*Plan, error) {
+	return &Plan{Changes: []string{"change " + r.id}}, nil
+}
+
+type plainResource struct{ id string }
+
+func (r plainResource) ID() string { return r.id }
+
+func (r plainResource) Plan(ctx context.Context, h *Host) (*Plan, error) { return &Plan{}, nil }
+
+func TestApplyInvalidTasks(t *testing.T) {
+	a := fakeResource{id: "a"}
+	b := fakeResource{id: "b"}
+	for _, tc := range []struct {
+		name  string
+		tasks []Task
+	}{
+		{"duplicate IDs", []Task{{Resource: a}, {Resource: a}}},
+		{"unknown requirement", []Task{{Resource: a, Requires: []string{"x"}}}},
+		{"unknown notification", []Task{{Resource: a, Notify: []string{"x"}}}},
+		{"notifying a resource that can't be refreshed", []Task{{Resource: a, Notify: []string{"p"}}, {Resource: plainResource{"p"}}}},
+		{"cycle", []Task{{Resource: a, Requires: []string{"b"}, Notify: []string{"b"}}, {Resource: b}}},
+		{"missing resource", []Task{{}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Apply(context.Background(), nil, tc.tasks, Options{}); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package resource
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ServiceState is whether a service is running.
+type ServiceState string
+
+const (
+	ServiceRunning ServiceState = "running"
+	ServiceStopped ServiceState = "stopped"
+)
+
+// BootState is whether a service is started at boot.
+type BootState string
+
+const (
+	BootEnabled  BootState = "enabled"
+	BootDisabled BootState = "disabled"
+)
+
+// ServiceAction is an action taken on a service.
+type ServiceAction string
+
+const (
+	ServiceRestart ServiceAction = "restart"
+	ServiceReload  ServiceAction = "reload"
+)
+
+// Init systems that services can be managed with.
+const (
+	initSystemd = "systemd"
+	initOpenRC  = "openrc"
+	initSysV    = "sysv"
+)
+
+// Service is a service managed by systemd, OpenRC or SysV init scripts,
+// whichever the host uses.
+type Service struct {
+	Name string
+
+	// State is whether the service should be running. If empty, it is left
+	// as it is.
+	State ServiceState
+
+	// Boot is whether the service should start at boot. If empty, it is left
+	// as it is. SysV init scripts don't support it.
+	Boot BootState
+
+	// OnRefresh is the action taken when the service is notified. Defaults
+	// to ServiceRestart. Stopped services are never restarted or reloaded.
+	OnRefresh ServiceAction
+}
+
+func (r Service) ID() string {
+	return "service:" + r.Name
+}
+
+func (r Service) Plan(ctx context.Context, h *Host) (*Plan, error) {
+	initSys, err := detectInit(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	cmds, err := serviceCommands(initSys, r.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []string
+	var actions [][]string
+	if r.State != "" {
+		running, err := h.check(ctx, cmds.status...)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case r.State == ServiceRunning && !running:
+			changes = append(changes, "start "+r.Name)
+			actions = append(actions, cmds.start)
+		case r.State == ServiceStopped && running:
+			changes = append(changes, "stop "+r.Name)
+			actions = append(actions, cmds.stop)
+		case r.State != ServiceRunning && r.State != ServiceStopped:
+			return nil, fmt.Errorf("invalid service state %q", r.State)
+		}
+	}
+	if r.Boot != "" {
+		if cmds.enabled == nil {
+			return nil, fmt.Errorf("%s services can't be enabled at boot", initSys)
+		}
+		enabled, err := h.check(ctx, cmds.enabled...)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case r.Boot == BootEnabled && !enabled:
+			changes = append(changes, "enable "+r.Name+" at boot")
+			actions = append(actions, cmds.enable)
+		case r.Boot == BootDisabled && enabled:
+			changes = append(changes, "disable "+r.Name+" at boot")
+			actions = append(actions, cmds.disable)
+		case r.Boot != BootEnabled && r.Boot != BootDisabled:
+			return nil, fmt.Errorf("invalid boot state %q", r.Boot)
+		}
+	}
+	return &Plan{Changes: changes, Apply: runAll(h, actions)}, nil
+}
+
+// Refresh restarts or reloads the service, unless it is meant to be stopped
+// or, if its state isn't managed, it isn't running.
+func (r Service) Refresh(ctx context.Context, h *Host) (*Plan, error) {
+	action := r.OnRefresh
+	switch action {
+	case "":
+		action = ServiceRestart
+	case ServiceRestart, ServiceReload:
+	default:
+		return nil, fmt.Errorf("invalid refresh action %q", r.OnRefresh)
+	}
+	if r.State == ServiceStopped {
+		return &Plan{}, nil
+	}
+
+	initSys, err := detectInit(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	cmds, err := serviceCommands(initSys, r.Name)
+	if err != nil {
+		return nil, err
+	}
+	if r.State == "" {
+		running, err := h.check(ctx, cmds.status...)
+		if err != nil || !running {
+			return &Plan{}, err
+		}
+	}
+	cmd := cmds.restart
+	if action == ServiceReload {
+		cmd = cmds.reload
+	}
+	return &Plan{
+		Changes: []string{string(action) + " " + r.Name},
+		Apply:   runAll(h, [][]string{cmd}),
+	}, nil
+}
+
+// runAll returns a function running each command in turn.
+func runAll(h *Host, cmds [][]string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		for _, cmd := range cmds {
+			if err := h.run(ctx, cmd...); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// detectInit returns the init system of the host.
+func detectInit(ctx context.Context, h *Host) (string, error) {
+	return h.fact("init", func() (string, error) {
+		out, err := h.Client.ShellOutput(ctx, `
+if [ -d /run/systemd/system ] && command -v systemctl >/dev/null 2>&1; then
+	echo systemd
+elif command -v rc-service >/dev/null 2>&1; then
+	echo openrc
+elif [ -d /etc/init.d ]; then
+	echo sysv
+fi`)
+		if err != nil {
+			return "", err
+		}
+		initSys := strings.TrimSpace(string(out))
+		if initSys == "" {
+			return "", fmt.Errorf("no supported init system found")
+		}
+		return initSys, nil
+	})
+}
+
+// serviceCommandSet holds the commands managing a service. status and enabled
+// exit successfully if the service is running or enabled.
+type serviceCommandSet struct {
+	status, start, stop, restart, reload []string
+	enabled, enable, disable             []string
+}
+
+func serviceCommands(initSys, name string) (*serviceCommandSet, error) {
+	switch initSys {
+	case initSystemd:
+		return &serviceCommandSet{
+			status:  []string{"systemctl", "is-active", "--quiet", name},
+			start:   []string{"systemctl", "start", name},
+			stop:    []string{"systemctl", "stop", name},
+			restart: []string{"systemctl", "restart", name},
+			reload:  []string{"systemctl", "reload", name},
+			enabled: []string{"systemctl", "is-enabled", "--quiet", name},
+			enable:  []string{"systemctl", "enable", name},
+			disable: []string{"systemctl", "disable", name},
+		}, nil
+	case initOpenRC:
+		return &serviceCommandSet{
+			status:  []string{"rc-service", name, "status"},
+			start:   []string{"rc-service", name, "start"},
+			stop:    []string{"rc-service", name, "stop"},
+			restart: []string{"rc-service", name, "restart"},
+			reload:  []string{"rc-service", name, "reload"},
+			enabled: []string{"test", "-e", "/etc/runlevels/default/" + name},
+			enable:  []string{"rc-update", "add", name, "default"},
+			disable: []string{"rc-update", "del", name, "default"},
+		}, nil
+	case initSysV:
+		script := "/etc/init.d/" + name
+		return &serviceCommandSet{
+			status:  []string{script, "status"},
+			start:   []string{script, "start"},
+			stop:    []string{script, "stop"},
+			restart: []string{script, "restart"},
+			reload:  []string{script, "reload"},
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported init system %q", initSys)
+}
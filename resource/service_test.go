@@ -0,0 +1,47 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package resource
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestServiceCommands(t *testing.T) {
+	cmds, err := serviceCommands(initSystemd, "sshd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"systemctl", "is-active", "--quiet", "sshd"}; !reflect.DeepEqual(cmds.status, want) {
+		t.Errorf("got status command %q, want %q", cmds.status, want)
+	}
+
+	cmds, err = serviceCommands(initSysV, "sshd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmds.enabled != nil {
+		t.Errorf("SysV init scripts can't be enabled at boot")
+	}
+
+	if _, err := serviceCommands("upstart", "sshd"); err == nil {
+		t.Errorf("expected an unknown init system to fail")
+	}
+}
+
+func TestServiceRejectsInvalidStates(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHost(ctx, t)
+	// Pretend the host uses OpenRC so nothing runs systemctl for real.
+	h.facts = map[string]string{"init": initOpenRC}
+
+	if _, err := (Service{Name: "x", Boot: "sometimes"}).Plan(ctx, h); err == nil {
+		t.Errorf("expected an invalid boot state to fail")
+	}
+	if _, err := (Service{Name: "x", OnRefresh: "kill"}).Refresh(ctx, h); err == nil {
+		t.Errorf("expected an invalid refresh action to fail")
+	}
+}
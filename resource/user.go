@@ -0,0 +1,148 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package resource
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aucloud/go-sshutil"
+)
+
+// User is a local user account.
+type User struct {
+	Name string
+
+	// UID, Group, Home and Shell are the user's settings. If nil or empty,
+	// the system defaults are used for new users and existing users are left
+	// as they are.
+	UID   *uint32
+	Group string
+	Home  string
+	Shell string
+
+	// Groups is the complete list of the user's supplementary groups. If
+	// nil, they are left as they are.
+	Groups []string
+
+	// Absent removes the user rather than creating it. The user's home
+	// directory is kept.
+	Absent bool
+}
+
+func (r User) ID() string {
+	return "user:" + r.Name
+}
+
+func (r User) Plan(ctx context.Context, h *Host) (*Plan, error) {
+	accounts, err := h.Client.ReadAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	u, exists := accounts.User(r.Name)
+
+	opts := sshutil.UserOptions{
+		UID:    r.UID,
+		Group:  r.Group,
+		Groups: r.Groups,
+		Home:   r.Home,
+		Shell:  r.Shell,
+	}
+	switch {
+	case r.Absent && exists:
+		return &Plan{
+			Changes: []string{"remove user " + r.Name},
+			Apply: func(ctx context.Context) error {
+				return h.Client.DeleteUser(ctx, r.Name, false)
+			},
+		}, nil
+	case r.Absent:
+		return &Plan{}, nil
+	case !exists:
+		return &Plan{
+			Changes: []string{"create user " + r.Name},
+			Apply: func(ctx context.Context) error {
+				return h.Client.CreateUser(ctx, r.Name, opts)
+			},
+		}, nil
+	}
+
+	var changes []string
+	describe := func(setting, from, to string) {
+		if to != "" && from != to {
+			changes = append(changes, fmt.Sprintf("change %s of %s from %s to %s", setting, r.Name, from, to))
+		}
+	}
+	if r.UID != nil {
+		describe("uid", fmt.Sprint(u.UID), fmt.Sprint(*r.UID))
+	}
+	describe("group", u.Group, r.Group)
+	describe("home", u.Home, r.Home)
+	describe("shell", u.Shell, r.Shell)
+	if r.Groups != nil {
+		describe("groups", sortedList(u.Groups), sortedList(r.Groups))
+	}
+	if len(changes) == 0 {
+		return &Plan{}, nil
+	}
+	return &Plan{
+		Changes: changes,
+		Apply: func(ctx context.Context) error {
+			_, err := h.Client.ModifyUser(ctx, r.Name, opts)
+			return err
+		},
+	}, nil
+}
+
+// Group is a local group.
+type Group struct {
+	Name string
+
+	// GID of a new group, or zero to let the system pick one. The GID of
+	// existing groups isn't changed.
+	GID uint32
+
+	// Absent removes the group rather than creating it.
+	Absent bool
+}
+
+func (r Group) ID() string {
+	return "group:" + r.Name
+}
+
+func (r Group) Plan(ctx context.Context, h *Host) (*Plan, error) {
+	accounts, err := h.Client.ReadAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, exists := accounts.Group(r.Name)
+
+	switch {
+	case r.Absent && exists:
+		return &Plan{
+			Changes: []string{"remove group " + r.Name},
+			Apply: func(ctx context.Context) error {
+				return h.Client.DeleteGroup(ctx, r.Name)
+			},
+		}, nil
+	case !r.Absent && !exists:
+		return &Plan{
+			Changes: []string{"create group " + r.Name},
+			Apply: func(ctx context.Context) error {
+				return h.Client.CreateGroup(ctx, r.Name, sshutil.GroupOptions{GID: r.GID})
+			},
+		}, nil
+	}
+	return &Plan{}, nil
+}
+
+// sortedList formats a list of names for display, ignoring their order.
+func sortedList(names []string) string {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	return "[" + strings.Join(sorted, ",") + "]"
+}
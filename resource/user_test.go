@@ -0,0 +1,49 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package resource
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestUserAndGroupPlans(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHost(ctx, t)
+
+	uid := func(v uint32) *uint32 { return &v }
+
+	// Plans are only checked, so the local accounts are never modified.
+	for _, tc := range []struct {
+		resource Resource
+		changes  []string
+	}{
+		{User{Name: "root"}, nil},
+		{User{Name: "root", Home: "/nonexistent"}, []string{"change home of root from /root to /nonexistent"}},
+		{User{Name: "root", UID: uid(0)}, nil},
+		{User{Name: "root", UID: uid(1234)}, []string{"change uid of root from 0 to 1234"}},
+		{User{Name: "no-such-user"}, []string{"create user no-such-user"}},
+		{User{Name: "no-such-user", Absent: true}, nil},
+		{User{Name: "root", Absent: true}, []string{"remove user root"}},
+		{Group{Name: "root"}, nil},
+		{Group{Name: "no-such-group"}, []string{"create group no-such-group"}},
+		{Group{Name: "root", Absent: true}, []string{"remove group root"}},
+	} {
+		report, err := Apply(ctx, h, []Task{{Resource: tc.resource}}, Options{Check: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := report.Results[0].Changes; !reflect.DeepEqual(got, tc.changes) {
+			t.Errorf("%+v: got changes %q, want %q", tc.resource, got, tc.changes)
+		}
+	}
+}
+
+func TestSortedList(t *testing.T) {
+	if got, want := sortedList([]string{"wheel", "audio"}), "[audio,wheel]"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
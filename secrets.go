@@ -0,0 +1,108 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// secretPreamble reads `export NAME=value` statements from stdin until a line
+// containing only ".", evaluates them, and execs its arguments. The read
+// builtin consumes its input a byte at a time from pipes, so whatever follows
+// the terminator is left as the standard input of the command.
+//
+// Values are quoted for the shell, with newlines spliced in from $__nl so that
+// each statement fits on one line.
+const secretPreamble = `__nl='
+'
+while IFS= read -r __l; do
+	[ "$__l" = . ] && break
+	eval "export $__l"
+done
+unset __l __nl
+exec "$@"`
+
+// SecretEnv is a set of environment variables to pass to a remote command
+// without putting their values on the command line, where they would be
+// visible to other users in ps(1) and in the debug logs of Run.
+type SecretEnv map[string]string
+
+// encode returns the statements secretPreamble reads, in a stable order.
+func (s SecretEnv) encode() ([]byte, error) {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		if !validEnvName(name) {
+			return nil, fmt.Errorf("invalid environment variable name %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	for _, name := range names {
+		parts := strings.Split(s[name], "\n")
+		for i, part := range parts {
+			parts[i] = ShellQuote(part)
+		}
+		fmt.Fprintf(&b, "%s=%s\n", name, strings.Join(parts, `"$__nl"`))
+	}
+	b.WriteString(".\n")
+	return b.Bytes(), nil
+}
+
+func validEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// secretCommand wraps command so that it runs after secretPreamble.
+func secretCommand(command []string) []string {
+	return append([]string{"sh", "-c", ShellQuote(secretPreamble), "sh"}, command...)
+}
+
+// RunWithSecrets runs a command like RunWithInput, with secrets in its
+// environment. The secrets are sent over the session's standard input ahead
+// of stdin, so they never appear on the remote command line or in logs.
+//
+// As with Run, command is joined with spaces and interpreted by the remote
+// shell, but it must be a simple command: it is exec'd by the preamble, so
+// pipelines and lists need to be wrapped in `sh -c`.
+func (c *Client) RunWithSecrets(ctx context.Context, command []string, secrets SecretEnv, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	encoded, err := secrets.encode()
+	if err != nil {
+		return err
+	}
+	input := io.Reader(bytes.NewReader(encoded))
+	if stdin != nil {
+		input = io.MultiReader(input, stdin)
+	}
+	return c.RunWithInput(ctx, secretCommand(command), input, stdout, stderr)
+}
+
+// OutputWithSecrets runs a command like Output, with secrets in its
+// environment as for RunWithSecrets. Errors carry the command, but never the
+// secrets.
+func (c *Client) OutputWithSecrets(ctx context.Context, secrets SecretEnv, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	if err := c.RunWithSecrets(ctx, shellQuoteArgs(args), secrets, nil, &stdout, &stderr); err != nil {
+		if ctx.Err() != nil || IsConnectionError(err) {
+			return nil, err
+		}
+		return nil, CommandError{Command: args, Stderr: stderr.String(), Err: err}
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+	"go.fuchsia.dev/fuchsia/tools/lib/color"
+	"go.fuchsia.dev/fuchsia/tools/lib/logger"
+)
+
+func TestSecretEnv(t *testing.T) {
+	var logs bytes.Buffer
+	ctx := logger.WithLogger(context.Background(),
+		logger.NewLogger(logger.TraceLevel, color.NewColor(color.ColorNever), &logs, &logs, ""))
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+
+	const token = "s3cr3t-t0ken"
+	secrets := SecretEnv{
+		"TOKEN":  token,
+		"TRICKY": "it's \"$HOME\" `x`\nand a second line\n",
+		"EMPTY":  "",
+	}
+
+	out, err := client.OutputWithSecrets(ctx, secrets, "sh", "-c", `printf '%s|%s|%s' "$TOKEN" "$TRICKY" "${EMPTY-unset}"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := token + "|" + secrets["TRICKY"] + "|"; string(out) != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+
+	// Input after the secrets reaches the command.
+	var stdout bytes.Buffer
+	if err := client.RunWithSecrets(ctx, []string{"cat"}, secrets, strings.NewReader("hello\n"), &stdout, nil); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "hello\n" {
+		t.Errorf("got stdin %q, want %q", stdout.String(), "hello\n")
+	}
+
+	// The secret isn't on the command line the remote shell was started with.
+	if _, err := os.Stat("/proc/self/cmdline"); err == nil {
+		out, err := client.OutputWithSecrets(ctx, secrets, "sh", "-c", "cat /proc/$PPID/cmdline")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(out, []byte("exec")) {
+			t.Errorf("expected to read the command line of the preamble, got %q", out)
+		}
+		if bytes.Contains(out, []byte(token)) {
+			t.Errorf("secret found on the remote command line: %q", out)
+		}
+	}
+
+	_, err = client.OutputWithSecrets(ctx, secrets, "sh", "-c", "echo failed >&2; exit 1")
+	if err == nil {
+		t.Fatalf("expected the command to fail")
+	}
+	if strings.Contains(err.Error(), token) {
+		t.Errorf("secret found in error: %v", err)
+	}
+
+	if !strings.Contains(logs.String(), "running over ssh") {
+		t.Errorf("expected commands to be logged, got:\n%s", logs.String())
+	}
+	if strings.Contains(logs.String(), token) {
+		t.Errorf("secret found in logs:\n%s", logs.String())
+	}
+
+	if _, err := client.OutputWithSecrets(ctx, SecretEnv{"BAD NAME": "x"}, "true"); err == nil {
+		t.Errorf("expected an invalid name to fail")
+	}
+}
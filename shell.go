@@ -0,0 +1,96 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// CommandError is returned when a command run on behalf of a higher-level API
+// exits unsuccessfully. It records the stderr of the command so callers don't
+// need to capture it themselves.
+type CommandError struct {
+	Command []string
+	Stderr  string
+	Err     error
+}
+
+func (e CommandError) Unwrap() error {
+	return e.Err
+}
+
+// ExitStatus returns the exit status of the command, or -1 if it didn't exit
+// normally.
+func (e CommandError) ExitStatus() int {
+	var exitErr *ssh.ExitError
+	if errors.As(e.Err, &exitErr) {
+		return exitErr.ExitStatus()
+	}
+	return -1
+}
+
+func (e CommandError) Error() string {
+	msg := fmt.Sprintf("command %q failed: %v", strings.Join(e.Command, " "), e.Err)
+	if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		msg += ": " + stderr
+	}
+	return msg
+}
+
+// ShellQuote quotes s so that a POSIX shell will interpret it as a single
+// literal word.
+func ShellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := true
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+			strings.ContainsRune("@%_-+=:,./", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+// shellQuoteArgs quotes every element of args. The result is suitable for
+// passing to `Run`, which joins the command with spaces.
+func shellQuoteArgs(args []string) []string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = ShellQuote(arg)
+	}
+	return quoted
+}
+
+// Output runs a command on the remote and returns its stdout. Each argument is
+// quoted, so it will reach the remote command unmodified. If the command
+// fails, the error is a CommandError carrying the command's stderr.
+func (c *Client) Output(ctx context.Context, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	if err := c.Run(ctx, shellQuoteArgs(args), &stdout, &stderr); err != nil {
+		if ctx.Err() != nil || IsConnectionError(err) {
+			return nil, err
+		}
+		return nil, CommandError{Command: args, Stderr: stderr.String(), Err: err}
+	}
+	return stdout.Bytes(), nil
+}
+
+// ShellOutput runs a shell script on the remote with `sh -c` and returns its
+// stdout.
+func (c *Client) ShellOutput(ctx context.Context, script string) ([]byte, error) {
+	return c.Output(ctx, "sh", "-c", script)
+}
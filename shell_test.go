@@ -0,0 +1,57 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestShellQuote(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+
+	for _, arg := range []string{
+		"",
+		"plain",
+		"/path/to/file.txt",
+		"with space",
+		"it's",
+		`"double" $HOME ` + "`x`",
+		"semi;colon && rm -rf /",
+		"new\nline",
+	} {
+		out, err := client.Output(ctx, "printf", "%s", arg)
+		if err != nil {
+			t.Fatalf("failed to run printf with %q: %v", arg, err)
+		}
+		if string(out) != arg {
+			t.Errorf("expected %q to round-trip through the shell, got %q", arg, out)
+		}
+	}
+}
+
+func TestCommandError(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+
+	_, err := client.ShellOutput(ctx, "echo oops >&2; exit 3")
+	var cmdErr CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("expected a CommandError, got %v", err)
+	}
+	if !bytes.Contains([]byte(cmdErr.Stderr), []byte("oops")) {
+		t.Errorf("expected stderr to be captured, got %q", cmdErr.Stderr)
+	}
+	var exitErr *ssh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitStatus() != 3 {
+		t.Errorf("expected CommandError to wrap exit status 3, got %v", err)
+	}
+}
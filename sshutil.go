@@ -32,6 +32,10 @@ const (
 	// many attempts.
 	totalConnectTimeout = 2 * time.Minute
 
+	// The allowed timeout for removing what a command left behind on the
+	// remote, which is done even if the command's context was cancelled.
+	cleanupTimeout = 30 * time.Second
+
 	sshUser = "fuchsia"
 )
 
@@ -0,0 +1,117 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aucloud/go-sshutil/cmdparse"
+)
+
+// fallbackOutput runs each command in turn until one succeeds, and returns its
+// output and index. Only failures of the commands themselves, such as a
+// missing command or unsupported flag, fall through to the next command.
+func (c *Client) fallbackOutput(ctx context.Context, commands ...[]string) ([]byte, int, error) {
+	var err error
+	for i, command := range commands {
+		var out []byte
+		out, err = c.Output(ctx, command...)
+		if err == nil {
+			return out, i, nil
+		}
+		var cmdErr CommandError
+		if !errors.As(err, &cmdErr) {
+			break
+		}
+	}
+	return nil, 0, err
+}
+
+// DiskFree returns the usage of the remote's mounted filesystems, from df.
+func (c *Client) DiskFree(ctx context.Context) ([]cmdparse.Filesystem, error) {
+	out, _, err := c.fallbackOutput(ctx, []string{"df", "-P", "-k"}, []string{"df", "-k"})
+	if err != nil {
+		return nil, err
+	}
+	return cmdparse.ParseDF(out)
+}
+
+// NetworkInterfaces returns the remote's network interfaces and their
+// addresses, from `ip -j addr`, or `ip addr` for versions of ip without JSON
+// output.
+func (c *Client) NetworkInterfaces(ctx context.Context) ([]cmdparse.Interface, error) {
+	out, i, err := c.fallbackOutput(ctx, []string{"ip", "-j", "addr"}, []string{"ip", "addr"})
+	if err != nil {
+		return nil, err
+	}
+	if i == 0 {
+		return cmdparse.ParseIPAddrJSON(out)
+	}
+	return cmdparse.ParseIPAddr(out)
+}
+
+// Sockets returns the remote's TCP and UDP sockets, from ss, or netstat where
+// ss isn't available.
+func (c *Client) Sockets(ctx context.Context) ([]cmdparse.Socket, error) {
+	out, i, err := c.fallbackOutput(ctx, []string{"ss", "-tuan"}, []string{"netstat", "-tuan"})
+	if err != nil {
+		return nil, err
+	}
+	if i == 0 {
+		return cmdparse.ParseSS(out)
+	}
+	return cmdparse.ParseNetstat(out)
+}
+
+// Mounts returns the remote's mounted filesystems, from /proc/mounts, or the
+// output of mount where that isn't available.
+func (c *Client) Mounts(ctx context.Context) ([]cmdparse.Mount, error) {
+	out, i, err := c.fallbackOutput(ctx, []string{"cat", "/proc/mounts"}, []string{"mount"})
+	if err != nil {
+		return nil, err
+	}
+	if i == 0 {
+		return cmdparse.ParseProcMounts(out)
+	}
+	return cmdparse.ParseMount(out)
+}
+
+// Memory returns the remote's memory usage, from free.
+func (c *Client) Memory(ctx context.Context) (*cmdparse.Memory, error) {
+	out, i, err := c.fallbackOutput(ctx, []string{"free", "-b"}, []string{"free"})
+	if err != nil {
+		return nil, err
+	}
+	if i == 0 {
+		return cmdparse.ParseFree(out, 1)
+	}
+	return cmdparse.ParseFree(out, 1024)
+}
+
+// Uptime returns how long the remote has been running and its load averages,
+// from uptime.
+func (c *Client) Uptime(ctx context.Context) (*cmdparse.Uptime, error) {
+	out, err := c.Output(ctx, "uptime")
+	if err != nil {
+		return nil, err
+	}
+	return cmdparse.ParseUptime(out)
+}
+
+// BlockDevices returns the remote's block devices, from lsblk, or
+// /proc/partitions where lsblk isn't available.
+func (c *Client) BlockDevices(ctx context.Context) ([]cmdparse.BlockDevice, error) {
+	out, i, err := c.fallbackOutput(ctx,
+		[]string{"lsblk", "-J", "-b", "-o", cmdparse.LsblkColumns},
+		[]string{"cat", "/proc/partitions"})
+	if err != nil {
+		return nil, err
+	}
+	if i == 0 {
+		return cmdparse.ParseLsblkJSON(out)
+	}
+	return cmdparse.ParseProcPartitions(out)
+}
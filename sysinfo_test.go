@@ -0,0 +1,96 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+)
+
+func TestSystemInfo(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+
+	// These run the real commands, which the test machine may not have.
+	for name, get := range map[string]func() (int, error){
+		"df": func() (int, error) {
+			fs, err := client.DiskFree(ctx)
+			return len(fs), err
+		},
+		"ip": func() (int, error) {
+			ifaces, err := client.NetworkInterfaces(ctx)
+			return len(ifaces), err
+		},
+		"ss": func() (int, error) {
+			// The test server is listening, so there is at least one socket.
+			sockets, err := client.Sockets(ctx)
+			return len(sockets), err
+		},
+		"lsblk": func() (int, error) {
+			_, err := client.BlockDevices(ctx)
+			return 1, err
+		},
+		"mount": func() (int, error) {
+			mounts, err := client.Mounts(ctx)
+			return len(mounts), err
+		},
+		"free": func() (int, error) {
+			m, err := client.Memory(ctx)
+			if err != nil {
+				return 0, err
+			}
+			return int(m.Total / (1 << 20)), nil
+		},
+		"uptime": func() (int, error) {
+			_, err := client.Uptime(ctx)
+			return 1, err
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := exec.LookPath(name); err != nil {
+				t.Skipf("%s isn't available", name)
+			}
+			n, err := get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n == 0 {
+				t.Errorf("got an empty result")
+			}
+		})
+	}
+}
+
+func TestSystemInfoFallback(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+
+	// A BusyBox-like ip without JSON output.
+	ip := `#!/bin/sh
+if [ "$1" = -j ]; then
+	echo "ip: invalid option -- 'j'" >&2
+	exit 1
+fi
+echo '1: lo: <LOOPBACK,UP> mtu 65536 state UNKNOWN'
+echo '    inet 127.0.0.1/8 scope host lo'
+`
+	setUpPath(t, map[string]string{"ip": ip}, "sh")
+
+	ifaces, err := client.NetworkInterfaces(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ifaces) != 1 || ifaces[0].Name != "lo" || len(ifaces[0].Addrs) != 1 {
+		t.Errorf("got %+v", ifaces)
+	}
+
+	// Neither ss nor netstat is available.
+	if _, err := client.Sockets(ctx); err == nil {
+		t.Errorf("expected Sockets to fail without ss or netstat")
+	}
+}
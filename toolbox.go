@@ -0,0 +1,155 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// DefaultBootstrapTools are the tools Bootstrap provides by default, which
+// features of this package and common scripts rely on.
+var DefaultBootstrapTools = []string{
+	"base64",
+	"find",
+	"gzip",
+	"nc",
+	"sha256sum",
+	"tar",
+	"timeout",
+	"xargs",
+}
+
+// missingToolsScript prints the arguments that aren't commands.
+const missingToolsScript = `for t; do command -v "$t" >/dev/null 2>&1 || echo "$t"; done`
+
+// toolboxListScript prints the tools a multi-call binary provides, asking it
+// the way busybox and then toybox are asked.
+const toolboxListScript = `"$1" --list 2>/dev/null || "$1" 2>/dev/null`
+
+// toolboxLinkScript links tools to a multi-call binary, which runs the tool
+// it's invoked as, in a directory next to it, and prints the directory.
+const toolboxLinkScript = `b=$1 d=$1.d && shift && mkdir -p "$d" && for t; do ln -sf "$b" "$d/$t" || exit; done && echo "$d"`
+
+// BootstrapOptions configure Bootstrap.
+type BootstrapOptions struct {
+	// Binaries maps platforms, as "GOOS/GOARCH", to local static multi-call
+	// binaries such as busybox or toybox.
+	Binaries map[string]string
+
+	// Tools are the tools to provide if they're missing. They default to
+	// DefaultBootstrapTools.
+	Tools []string
+
+	// Dir is the remote directory the binary is cached in. It defaults to
+	// sshutil in the user's cache directory.
+	Dir string
+}
+
+// BootstrapResult describes the tools Bootstrap provided.
+type BootstrapResult struct {
+	// Dir is the remote directory holding the provided tools, or empty if
+	// none were missing.
+	Dir string
+
+	// Provided are the missing tools the binary provides, and Missing those
+	// it doesn't.
+	Provided []string
+	Missing  []string
+}
+
+// MissingTools returns the tools that aren't on the remote's PATH, including
+// any provided by Bootstrap.
+func (c *Client) MissingTools(ctx context.Context, tools ...string) ([]string, error) {
+	args := append([]string{"sh", "-c", missingToolsScript, "sh"}, tools...)
+	out, err := c.Output(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// Bootstrap provides the tools missing on a minimal remote from a static
+// multi-call binary for its platform, which is uploaded to a directory
+// managed by this package. The client then appends the directory to the
+// PATH of the commands it runs, so that features relying on the tools work,
+// while the remote's own tools are still preferred. This is done with POSIX
+// shell syntax, so Bootstrap fails if the remote's login shell doesn't accept
+// it, as with csh or fish.
+func (c *Client) Bootstrap(ctx context.Context, opts BootstrapOptions) (*BootstrapResult, error) {
+	tools := opts.Tools
+	if len(tools) == 0 {
+		tools = DefaultBootstrapTools
+	}
+	missing, err := c.MissingTools(ctx, tools...)
+	if err != nil {
+		return nil, err
+	}
+	result := &BootstrapResult{}
+	if len(missing) == 0 {
+		return result, nil
+	}
+	if err := c.Run(ctx, withTools([]string{"true"}, "."), ioutil.Discard, ioutil.Discard); err != nil {
+		return nil, fmt.Errorf("the remote's login shell can't add tools to the PATH: %w", err)
+	}
+
+	platform, err := c.Platform(ctx)
+	if err != nil {
+		return nil, err
+	}
+	binary := opts.Binaries[platform]
+	if binary == "" {
+		return nil, fmt.Errorf("no multi-call binary for %s to provide %s", platform, strings.Join(missing, ", "))
+	}
+	remote, err := c.installBinary(ctx, binary, opts.Dir, "sshutil-toolbox")
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := c.Output(ctx, "sh", "-c", toolboxListScript, "sh", remote)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the tools %s provides: %w", binary, err)
+	}
+	available := make(map[string]bool)
+	for _, tool := range strings.Fields(string(out)) {
+		// busybox lists some tools with their directories.
+		available[tool[strings.LastIndexByte(tool, '/')+1:]] = true
+	}
+	for _, tool := range missing {
+		if available[tool] {
+			result.Provided = append(result.Provided, tool)
+		} else {
+			result.Missing = append(result.Missing, tool)
+		}
+	}
+	if len(result.Provided) == 0 {
+		return result, nil
+	}
+
+	args := append([]string{"sh", "-c", toolboxLinkScript, "sh", remote}, result.Provided...)
+	out, err = c.Output(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	result.Dir = strings.TrimSpace(string(out))
+
+	c.mu.Lock()
+	c.toolsDir = result.Dir
+	c.mu.Unlock()
+	return result, nil
+}
+
+// withTools makes command find the tools provided by Bootstrap in dir, after
+// the remote's own. It prefixes command with POSIX shell syntax, which
+// Bootstrap checks the login shell accepts before providing dir.
+func withTools(command []string, dir string) []string {
+	if dir == "" {
+		return command
+	}
+	prefix := fmt.Sprintf(`PATH="$PATH":%s; export PATH;`, ShellQuote(dir))
+	return append([]string{prefix}, command...)
+}
@@ -0,0 +1,94 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+)
+
+func TestBootstrap(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), nil)
+
+	dir := t.TempDir()
+	setUpPath(t, nil, "sh", "cat", "mkdir", "ln", "mv", "sync", "uname")
+
+	// The fake multi-call binary runs the tool it's invoked as, like busybox.
+	box := filepath.Join(dir, "box")
+	script := `#!/bin/sh
+case ${0##*/} in
+tar) echo "fake tar $*" ;;
+sha256sum) echo "fake sum" ;;
+*) [ "$1" = --list ] && printf 'tar\nusr/bin/sha256sum\nls\n' ;;
+esac
+`
+	if err := ioutil.WriteFile(box, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	opts := BootstrapOptions{
+		Binaries: map[string]string{platform: box},
+		Tools:    []string{"cat", "tar", "sha256sum", "nosuchtool"},
+		Dir:      filepath.Join(dir, "cache"),
+	}
+
+	if _, err := client.Bootstrap(ctx, BootstrapOptions{Tools: opts.Tools, Dir: opts.Dir}); err == nil {
+		t.Errorf("expected an error bootstrapping without a binary for %s", platform)
+	}
+
+	result, err := client.Bootstrap(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"tar", "sha256sum"}; !reflect.DeepEqual(result.Provided, want) {
+		t.Errorf("provided %q, want %q", result.Provided, want)
+	}
+	if want := []string{"nosuchtool"}; !reflect.DeepEqual(result.Missing, want) {
+		t.Errorf("missing %q, want %q", result.Missing, want)
+	}
+	if !strings.HasPrefix(result.Dir, opts.Dir) {
+		t.Errorf("tools provided in %s, want them under %s", result.Dir, opts.Dir)
+	}
+
+	// Commands now find the provided tools, as do compound ones.
+	out, err := client.Output(ctx, "tar", "-c", "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "fake tar -c x\n" {
+		t.Errorf("got %q from tar, want the provided one", out)
+	}
+	out, err = client.ShellOutput(ctx, "true && sha256sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "fake sum\n" {
+		t.Errorf("got %q from sha256sum, want the provided one", out)
+	}
+	missing, err := client.MissingTools(ctx, opts.Tools...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"nosuchtool"}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("got missing tools %q, want %q", missing, want)
+	}
+
+	// Nothing is missing the second time.
+	result, err = client.Bootstrap(ctx, BootstrapOptions{Tools: []string{"tar", "sha256sum"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Dir != "" || len(result.Provided) != 0 {
+		t.Errorf("got result %+v, want nothing provided", result)
+	}
+}
@@ -0,0 +1,612 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SymlinkPolicy controls how transfers handle symbolic links.
+type SymlinkPolicy int
+
+const (
+	// SymlinkCopy transfers symlinks as links, with their targets unchanged.
+	SymlinkCopy SymlinkPolicy = iota
+
+	// SymlinkFollow transfers the file or directory a symlink points at in
+	// place of the link.
+	SymlinkFollow
+
+	// SymlinkRejectOutside transfers symlinks as links, but fails the transfer
+	// if any link is absolute or lexically points outside the transferred
+	// tree.
+	SymlinkRejectOutside
+)
+
+// Upper bound on the number of symlinks followed along a single path, to
+// protect against long chains of them. Links back to an ancestor directory
+// are caught by treeWalker.ancestors instead.
+const maxSymlinkFollows = 40
+
+// modeBits are the bits of a mode that transfers preserve: the permissions,
+// and the setuid, setgid and sticky bits.
+const modeBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// dirWritePerm is added to the mode of directories while they are being
+// filled, so that read-only ones can be transferred without root.
+const dirWritePerm = 0o700
+
+// ChmodMask adjusts the permission bits of transferred entries, along with
+// their setuid, setgid and sticky bits. Bits in Clear are removed first, then
+// bits in Set are added.
+type ChmodMask struct {
+	Clear os.FileMode
+	Set   os.FileMode
+}
+
+func (m ChmodMask) apply(perm os.FileMode) os.FileMode {
+	return (perm &^ m.Clear) | (m.Set & modeBits)
+}
+
+// TransferOptions configures Upload, Download and Sync.
+type TransferOptions struct {
+	// SkipPreflight disables the free space, writability and conflicting path
+	// checks that are otherwise done before writing to the remote.
+	SkipPreflight bool
+
+	// Delete makes Sync remove remote entries that don't exist locally.
+	Delete bool
+
+	// Ownership controls how the owner and group of transferred entries are
+	// set. The ownership of symlinks is never changed.
+	Ownership OwnershipPolicy
+
+	// UIDMap and GIDMap translate source ids to destination ids when
+	// Ownership is OwnershipMap. Ids missing from the maps are unchanged.
+	UIDMap map[uint32]uint32
+	GIDMap map[uint32]uint32
+
+	// FileChmod and DirChmod adjust the permissions of transferred files and
+	// directories respectively.
+	FileChmod ChmodMask
+	DirChmod  ChmodMask
+
+	// Symlinks controls how symbolic links are transferred.
+	Symlinks SymlinkPolicy
+}
+
+// SymlinkEscapeError is returned when a transfer using SymlinkRejectOutside
+// finds a symlink pointing outside the transferred tree.
+type SymlinkEscapeError struct {
+	Path   string
+	Target string
+}
+
+func (e SymlinkEscapeError) Error() string {
+	return fmt.Sprintf("symlink %q points outside the transferred tree to %q", e.Path, e.Target)
+}
+
+// transferEntry is a single file, directory or symlink in a transfer.
+type transferEntry struct {
+	// Slash-separated path relative to the root of the transfer. The root
+	// itself is "".
+	rel     string
+	mode    os.FileMode
+	size    int64
+	modTime time.Time
+
+	// Target of a symlink.
+	link string
+
+	// Ownership of the source, if known.
+	uid, gid uint32
+	hasOwner bool
+}
+
+func (e transferEntry) isDir() bool     { return e.mode.IsDir() }
+func (e transferEntry) isRegular() bool { return e.mode.IsRegular() }
+func (e transferEntry) isSymlink() bool { return e.mode&os.ModeSymlink != 0 }
+
+// joinRel joins a relative transfer path onto a remote root.
+func joinRel(root, rel string) string {
+	if rel == "" {
+		return root
+	}
+	return path.Join(root, rel)
+}
+
+// localPath joins a relative transfer path onto a local root.
+func localPath(root, rel string) string {
+	if rel == "" {
+		return root
+	}
+	return filepath.Join(root, filepath.FromSlash(rel))
+}
+
+// transfer holds the state shared by the steps of a single Upload, Download
+// or Sync.
+type transfer struct {
+	f    *FileClient
+	opts TransferOptions
+
+	// The account databases of the source and destination, which are only
+	// loaded when mapping ownership by name.
+	srcAccounts accounts
+	dstAccounts accounts
+}
+
+func (f *FileClient) newTransfer(opts TransferOptions, upload bool) (*transfer, error) {
+	t := &transfer{f: f, opts: opts}
+	if opts.Ownership == OwnershipByName {
+		remote, err := f.remoteAccounts()
+		if err != nil {
+			return nil, err
+		}
+		if upload {
+			t.srcAccounts, t.dstAccounts = localAccounts{}, remote
+		} else {
+			t.srcAccounts, t.dstAccounts = remote, localAccounts{}
+		}
+	}
+	return t, nil
+}
+
+// perm returns the permissions, including the setuid, setgid and sticky
+// bits, a transferred entry should have at the destination.
+func (t *transfer) perm(e transferEntry) os.FileMode {
+	if e.isDir() {
+		return t.opts.DirChmod.apply(e.mode & modeBits)
+	}
+	return t.opts.FileChmod.apply(e.mode & modeBits)
+}
+
+// Upload copies the local file or directory tree at src to dst on the remote.
+// Files are written atomically, so an interrupted upload never leaves a
+// partially written file at its destination. Unless disabled in opts, the
+// destination is checked for space, writability and conflicting paths before
+// anything is written.
+func (f *FileClient) Upload(ctx context.Context, src, dst string, opts TransferOptions) error {
+	t, err := f.newTransfer(opts, true)
+	if err != nil {
+		return err
+	}
+	entries, err := walkTree(localFS{}, src, opts.Symlinks)
+	if err != nil {
+		return err
+	}
+	if !opts.SkipPreflight {
+		if err := f.preflight(ctx, dst, entries); err != nil {
+			return err
+		}
+	}
+	return t.upload(ctx, src, dst, entries)
+}
+
+// Download copies the remote file or directory tree at src to dst on the
+// local filesystem. Like Upload, files are written atomically.
+func (f *FileClient) Download(ctx context.Context, src, dst string, opts TransferOptions) error {
+	t, err := f.newTransfer(opts, false)
+	if err != nil {
+		return err
+	}
+	entries, err := walkTree(f.sftp, src, opts.Symlinks)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := t.downloadEntry(src, dst, e); err != nil {
+			return err
+		}
+	}
+	// Directories stay writable until everything in them is written.
+	for i := len(entries) - 1; i >= 0; i-- {
+		if e := entries[i]; e.isDir() {
+			if err := t.finishLocalDir(localPath(dst, e.rel), e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Sync makes the remote directory dst mirror the local directory src,
+// uploading only the entries that are missing or differ in size, modification
+// time, permissions, ownership or symlink target. Remote entries that don't
+// exist locally are removed if opts.Delete is set.
+func (f *FileClient) Sync(ctx context.Context, src, dst string, opts TransferOptions) error {
+	t, err := f.newTransfer(opts, true)
+	if err != nil {
+		return err
+	}
+	local, err := walkTree(localFS{}, src, opts.Symlinks)
+	if err != nil {
+		return err
+	}
+	if len(local) == 0 || !local[0].isDir() {
+		return fmt.Errorf("sync source %q is not a directory", src)
+	}
+	// The destination is always walked without following links, since we
+	// want to compare against what is actually there.
+	remote, err := walkTree(f.sftp, dst, SymlinkCopy)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	changed, extra := t.diffEntries(local, remote)
+	if !opts.SkipPreflight {
+		if err := f.preflight(ctx, dst, changed); err != nil {
+			return err
+		}
+	}
+
+	if opts.Delete {
+		// Remove the deepest entries first so directories are empty by the
+		// time we get to them.
+		for i := len(extra) - 1; i >= 0; i-- {
+			if err := f.sftp.Remove(joinRel(dst, extra[i].rel)); err != nil {
+				return err
+			}
+		}
+	}
+	return t.upload(ctx, src, dst, changed)
+}
+
+func (t *transfer) upload(ctx context.Context, src, dst string, entries []transferEntry) error {
+	if err := t.f.sftp.MkdirAll(path.Dir(dst)); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := t.uploadEntry(ctx, src, dst, e); err != nil {
+			return err
+		}
+	}
+	// Directories stay writable until everything in them is written, so
+	// read-only ones are given their modes last, deepest first.
+	for i := len(entries) - 1; i >= 0; i-- {
+		if e := entries[i]; e.isDir() {
+			if err := t.finishRemoteDir(joinRel(dst, e.rel), e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// diffEntries returns the local entries that need to be uploaded to make the
+// remote match, and the remote entries that don't exist locally.
+func (t *transfer) diffEntries(local, remote []transferEntry) (changed, extra []transferEntry) {
+	remoteByPath := make(map[string]transferEntry, len(remote))
+	for _, e := range remote {
+		remoteByPath[e.rel] = e
+	}
+	localPaths := make(map[string]bool, len(local))
+	for _, l := range local {
+		localPaths[l.rel] = true
+		r, ok := remoteByPath[l.rel]
+		if !ok || l.mode.Type() != r.mode.Type() {
+			changed = append(changed, l)
+			continue
+		}
+		differs := false
+		switch {
+		case l.isSymlink():
+			differs = l.link != r.link
+		case l.isRegular():
+			// SFTP only transfers modification times with second precision.
+			differs = l.size != r.size || l.modTime.Unix() != r.modTime.Unix()
+		}
+		if !l.isSymlink() {
+			differs = differs || t.perm(l) != r.mode&modeBits
+			if uid, gid, ok := t.owner(l); ok && r.hasOwner {
+				differs = differs || uid != r.uid || gid != r.gid
+			}
+		}
+		if differs {
+			changed = append(changed, l)
+		}
+	}
+	for _, r := range remote {
+		if !localPaths[r.rel] {
+			extra = append(extra, r)
+		}
+	}
+	return changed, extra
+}
+
+func (t *transfer) uploadEntry(ctx context.Context, srcRoot, dstRoot string, e transferEntry) error {
+	sftpClient := t.f.sftp
+	src := localPath(srcRoot, e.rel)
+	dst := joinRel(dstRoot, e.rel)
+
+	switch {
+	case e.isDir():
+		if fi, err := sftpClient.Lstat(dst); err != nil || !fi.IsDir() {
+			if err := sftpClient.Mkdir(dst); err != nil {
+				return fmt.Errorf("failed to create %q: %w", dst, err)
+			}
+		}
+		// The final mode and owner are set by finishRemoteDir.
+		return sftpClient.Chmod(dst, t.perm(e)|dirWritePerm)
+
+	case e.isSymlink():
+		if _, err := sftpClient.Lstat(dst); err == nil {
+			if err := sftpClient.Remove(dst); err != nil {
+				return err
+			}
+		}
+		return sftpClient.Symlink(e.link, dst)
+
+	case e.isRegular():
+		file, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if err := t.f.WriteFile(ctx, dst, file, t.perm(e)); err != nil {
+			return fmt.Errorf("failed to upload %q: %w", src, err)
+		}
+		if err := sftpClient.Chtimes(dst, e.modTime, e.modTime); err != nil {
+			return err
+		}
+
+	default:
+		return nil
+	}
+
+	if uid, gid, ok := t.owner(e); ok {
+		if err := sftpClient.Chown(dst, int(uid), int(gid)); err != nil {
+			return fmt.Errorf("failed to change ownership of %q: %w", dst, err)
+		}
+		// Changing the owner clears the setuid and setgid bits.
+		if t.perm(e)&(os.ModeSetuid|os.ModeSetgid) != 0 {
+			return sftpClient.Chmod(dst, t.perm(e))
+		}
+	}
+	return nil
+}
+
+// finishRemoteDir gives an uploaded directory its owner and mode, in that
+// order as changing the owner clears the setgid bit.
+func (t *transfer) finishRemoteDir(dst string, e transferEntry) error {
+	if uid, gid, ok := t.owner(e); ok {
+		if err := t.f.sftp.Chown(dst, int(uid), int(gid)); err != nil {
+			return fmt.Errorf("failed to change ownership of %q: %w", dst, err)
+		}
+	}
+	return t.f.sftp.Chmod(dst, t.perm(e))
+}
+
+func (t *transfer) downloadEntry(srcRoot, dstRoot string, e transferEntry) error {
+	src := joinRel(srcRoot, e.rel)
+	dst := localPath(dstRoot, e.rel)
+
+	switch {
+	case e.isDir():
+		if err := os.MkdirAll(dst, 0o700); err != nil {
+			return err
+		}
+		// The final mode and owner are set by finishLocalDir.
+		return os.Chmod(dst, t.perm(e)|dirWritePerm)
+
+	case e.isSymlink():
+		if _, err := os.Lstat(dst); err == nil {
+			if err := os.Remove(dst); err != nil {
+				return err
+			}
+		}
+		return os.Symlink(e.link, dst)
+
+	case e.isRegular():
+		file, err := t.f.sftp.Open(src)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if err := writeLocalFile(dst, file, t.perm(e)); err != nil {
+			return fmt.Errorf("failed to download %q: %w", src, err)
+		}
+		if err := os.Chtimes(dst, e.modTime, e.modTime); err != nil {
+			return err
+		}
+
+	default:
+		return nil
+	}
+
+	if uid, gid, ok := t.owner(e); ok {
+		if err := os.Lchown(dst, int(uid), int(gid)); err != nil {
+			return fmt.Errorf("failed to change ownership of %q: %w", dst, err)
+		}
+		// Changing the owner clears the setuid and setgid bits.
+		if t.perm(e)&(os.ModeSetuid|os.ModeSetgid) != 0 {
+			return os.Chmod(dst, t.perm(e))
+		}
+	}
+	return nil
+}
+
+// finishLocalDir gives a downloaded directory its owner and mode, in that
+// order as changing the owner clears the setgid bit.
+func (t *transfer) finishLocalDir(dst string, e transferEntry) error {
+	if uid, gid, ok := t.owner(e); ok {
+		if err := os.Lchown(dst, int(uid), int(gid)); err != nil {
+			return fmt.Errorf("failed to change ownership of %q: %w", dst, err)
+		}
+	}
+	return os.Chmod(dst, t.perm(e))
+}
+
+// writeLocalFile atomically replaces the local file name with the contents of
+// r.
+func writeLocalFile(name string, r io.Reader, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// treeFS abstracts over the local and remote filesystems so both can be walked
+// the same way. *sftp.Client implements it for the remote.
+type treeFS interface {
+	Lstat(name string) (os.FileInfo, error)
+	Stat(name string) (os.FileInfo, error)
+	ReadDir(name string) ([]os.FileInfo, error)
+	ReadLink(name string) (string, error)
+	Join(elem ...string) string
+
+	// RealPath returns the absolute path of name with symlinks resolved. The
+	// OpenSSH server resolves them, but not every SFTP server does.
+	RealPath(name string) (string, error)
+}
+
+// localFS implements treeFS for the local filesystem.
+type localFS struct{}
+
+func (localFS) Lstat(name string) (os.FileInfo, error)     { return os.Lstat(name) }
+func (localFS) Stat(name string) (os.FileInfo, error)      { return os.Stat(name) }
+func (localFS) ReadDir(name string) ([]os.FileInfo, error) { return ioutil.ReadDir(name) }
+func (localFS) ReadLink(name string) (string, error)       { return os.Readlink(name) }
+func (localFS) Join(elem ...string) string                 { return filepath.Join(elem...) }
+
+func (localFS) RealPath(name string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(resolved)
+}
+
+// walkTree lists the file or directory tree at root, handling symlinks
+// according to policy. Parents always come before their children.
+func walkTree(fsys treeFS, root string, policy SymlinkPolicy) ([]transferEntry, error) {
+	fi, err := fsys.Lstat(root)
+	if err != nil {
+		return nil, err
+	}
+	w := treeWalker{fsys: fsys, policy: policy}
+	if err := w.walk(root, "", fi, 0); err != nil {
+		return nil, err
+	}
+	return w.entries, nil
+}
+
+type treeWalker struct {
+	fsys    treeFS
+	policy  SymlinkPolicy
+	entries []transferEntry
+
+	// ancestors holds the real paths of the directories being walked when
+	// following symlinks, so that links back to them are caught rather than
+	// walked over and over.
+	ancestors map[string]bool
+}
+
+func (w *treeWalker) walk(p, rel string, fi os.FileInfo, follows int) error {
+	e := transferEntry{
+		rel:     rel,
+		mode:    fi.Mode(),
+		size:    fi.Size(),
+		modTime: fi.ModTime(),
+	}
+	e.uid, e.gid, e.hasOwner = fileOwner(fi)
+
+	if e.isSymlink() {
+		link, err := w.fsys.ReadLink(p)
+		if err != nil {
+			return err
+		}
+		switch w.policy {
+		case SymlinkFollow:
+			if follows >= maxSymlinkFollows {
+				return fmt.Errorf("too many levels of symbolic links at %q", p)
+			}
+			target, err := w.fsys.Stat(p)
+			if err != nil {
+				return fmt.Errorf("failed to follow symlink %q: %w", p, err)
+			}
+			return w.walk(p, rel, target, follows+1)
+		case SymlinkRejectOutside:
+			if linkEscapes(rel, link) {
+				return SymlinkEscapeError{Path: p, Target: link}
+			}
+		}
+		e.link = link
+	}
+
+	w.entries = append(w.entries, e)
+	if !e.isDir() {
+		return nil
+	}
+	if w.policy == SymlinkFollow {
+		real, err := w.fsys.RealPath(p)
+		if err != nil {
+			return err
+		}
+		if w.ancestors[real] {
+			return fmt.Errorf("symbolic link cycle at %q", p)
+		}
+		if w.ancestors == nil {
+			w.ancestors = make(map[string]bool)
+		}
+		w.ancestors[real] = true
+		defer delete(w.ancestors, real)
+	}
+
+	children, err := w.fsys.ReadDir(p)
+	if err != nil {
+		return err
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].Name() < children[j].Name() })
+	for _, child := range children {
+		if err := w.walk(w.fsys.Join(p, child.Name()), path.Join(rel, child.Name()), child, follows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkEscapes reports whether a symlink at rel (relative to the root of the
+// tree) with the given target lexically points outside the tree.
+func linkEscapes(rel, target string) bool {
+	// A link at the root is the whole tree, so anything it points at is
+	// outside of it.
+	if rel == "" || path.IsAbs(target) {
+		return true
+	}
+	resolved := path.Join(path.Dir(rel), target)
+	return resolved == ".." || strings.HasPrefix(resolved, "../")
+}
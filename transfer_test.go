@@ -0,0 +1,366 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTree creates the files in the map beneath dir. Keys ending in a slash
+// are created as directories.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if name[len(name)-1] == '/' {
+			if err := os.MkdirAll(p, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestUploadAndDownload(t *testing.T) {
+	ctx := context.Background()
+	f := setUpFileClient(ctx, t)
+
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{
+		"a.txt":       "a",
+		"sub/b.txt":   "b",
+		"sub/deeper/": "",
+	})
+	if err := os.Symlink("a.txt", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	remote := filepath.Join(t.TempDir(), "not", "yet", "created")
+	if err := f.Upload(ctx, src, remote, TransferOptions{}); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	assertFileContents(t, filepath.Join(remote, "a.txt"), "a")
+	assertFileContents(t, filepath.Join(remote, "sub", "b.txt"), "b")
+	if fi, err := os.Stat(filepath.Join(remote, "sub", "deeper")); err != nil || !fi.IsDir() {
+		t.Errorf("expected empty directory to be uploaded, got %v", err)
+	}
+	if link, err := os.Readlink(filepath.Join(remote, "link")); err != nil || link != "a.txt" {
+		t.Errorf("expected symlink to a.txt, got %q (err: %v)", link, err)
+	}
+
+	local := filepath.Join(t.TempDir(), "download")
+	if err := f.Download(ctx, remote, local, TransferOptions{}); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	assertFileContents(t, filepath.Join(local, "a.txt"), "a")
+	assertFileContents(t, filepath.Join(local, "sub", "b.txt"), "b")
+
+	// Single files can be transferred too.
+	single := filepath.Join(t.TempDir(), "single")
+	if err := f.Upload(ctx, filepath.Join(src, "sub", "b.txt"), single, TransferOptions{}); err != nil {
+		t.Fatalf("Upload of a single file failed: %v", err)
+	}
+	assertFileContents(t, single, "b")
+}
+
+// makeWritable lets the test's cleanup remove read-only trees.
+func makeWritable(t *testing.T, dirs ...string) {
+	t.Cleanup(func() {
+		for _, dir := range dirs {
+			filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+				if err == nil && fi.IsDir() {
+					os.Chmod(p, 0o755)
+				}
+				return nil
+			})
+		}
+	})
+}
+
+func TestTransferReadOnlyDirs(t *testing.T) {
+	ctx := context.Background()
+	f := setUpFileClient(ctx, t)
+
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{"mod/sub/file.go": "package sub"})
+	for _, dir := range []string{"mod/sub", "mod"} {
+		if err := os.Chmod(filepath.Join(src, dir), 0o555); err != nil {
+			t.Fatal(err)
+		}
+	}
+	remote := filepath.Join(t.TempDir(), "remote")
+	local := filepath.Join(t.TempDir(), "local")
+	makeWritable(t, src, remote, local)
+
+	// Read-only directories get their modes once their contents are written,
+	// as do directories made read-only by DirChmod.
+	if err := f.Upload(ctx, src, remote, TransferOptions{}); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if err := f.Download(ctx, remote, local, TransferOptions{DirChmod: ChmodMask{Clear: 0o222}}); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	for _, root := range []string{remote, local} {
+		assertFileContents(t, filepath.Join(root, "mod", "sub", "file.go"), "package sub")
+		for _, dir := range []string{"mod", "mod/sub"} {
+			if fi, err := os.Stat(filepath.Join(root, dir)); err != nil || fi.Mode().Perm() != 0o555 {
+				t.Errorf("expected %s in %s to have mode 0555, got %v (err: %v)", dir, root, fi.Mode(), err)
+			}
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	f := setUpFileClient(ctx, t)
+
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{
+		"keep.txt":       "keep",
+		"change.txt":     "v1",
+		"sub/nested.txt": "nested",
+	})
+	dst := filepath.Join(t.TempDir(), "dst")
+	if err := f.Sync(ctx, src, dst, TransferOptions{}); err != nil {
+		t.Fatalf("initial Sync failed: %v", err)
+	}
+	assertFileContents(t, filepath.Join(dst, "sub", "nested.txt"), "nested")
+
+	local, err := walkTree(localFS{}, src, SymlinkCopy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := walkTree(f.sftp, dst, SymlinkCopy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := &transfer{f: f}
+	if changed, extra := tr.diffEntries(local, remote); len(changed) != 0 || len(extra) != 0 {
+		t.Errorf("expected trees to match after sync, got changed=%v extra=%v", changed, extra)
+	}
+
+	writeTree(t, src, map[string]string{"change.txt": "version 2"})
+	writeTree(t, dst, map[string]string{"stale/old.txt": "old"})
+	if err := f.Sync(ctx, src, dst, TransferOptions{Delete: true}); err != nil {
+		t.Fatalf("second Sync failed: %v", err)
+	}
+	assertFileContents(t, filepath.Join(dst, "change.txt"), "version 2")
+	assertFileContents(t, filepath.Join(dst, "keep.txt"), "keep")
+	if _, err := os.Stat(filepath.Join(dst, "stale")); !os.IsNotExist(err) {
+		t.Errorf("expected extra remote directory to be deleted, got %v", err)
+	}
+}
+
+func TestTransferPolicies(t *testing.T) {
+	ctx := context.Background()
+	f := setUpFileClient(ctx, t)
+
+	t.Run("chmod masks", func(t *testing.T) {
+		src := t.TempDir()
+		writeTree(t, src, map[string]string{"dir/file": "x"})
+		if err := os.Chmod(filepath.Join(src, "dir", "file"), 0o666); err != nil {
+			t.Fatal(err)
+		}
+
+		dst := filepath.Join(t.TempDir(), "dst")
+		opts := TransferOptions{
+			FileChmod: ChmodMask{Clear: 0o022, Set: 0o100},
+			DirChmod:  ChmodMask{Clear: 0o077},
+		}
+		if err := f.Upload(ctx, src, dst, opts); err != nil {
+			t.Fatalf("Upload failed: %v", err)
+		}
+		for name, want := range map[string]os.FileMode{
+			"dir":      0o700,
+			"dir/file": 0o744,
+		} {
+			fi, err := os.Stat(filepath.Join(dst, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode().Perm() != want {
+				t.Errorf("expected %s to have mode %v, got %v", name, want, fi.Mode().Perm())
+			}
+		}
+	})
+
+	t.Run("symlinks", func(t *testing.T) {
+		src := t.TempDir()
+		writeTree(t, src, map[string]string{"real/file": "x"})
+		if err := os.Symlink("real", filepath.Join(src, "inside")); err != nil {
+			t.Fatal(err)
+		}
+
+		// Links within the tree are accepted by every policy.
+		for _, policy := range []SymlinkPolicy{SymlinkCopy, SymlinkFollow, SymlinkRejectOutside} {
+			dst := filepath.Join(t.TempDir(), "dst")
+			if err := f.Upload(ctx, src, dst, TransferOptions{Symlinks: policy}); err != nil {
+				t.Fatalf("Upload with policy %d failed: %v", policy, err)
+			}
+			fi, err := os.Lstat(filepath.Join(dst, "inside"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			isLink := fi.Mode()&os.ModeSymlink != 0
+			if isLink != (policy != SymlinkFollow) {
+				t.Errorf("policy %d: expected symlink to be copied as a link: %v, got mode %v", policy, policy != SymlinkFollow, fi.Mode())
+			}
+			assertFileContents(t, filepath.Join(dst, "inside", "file"), "x")
+		}
+
+		outside := t.TempDir()
+		writeTree(t, outside, map[string]string{"secret": "s"})
+		if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(src, "escape")); err != nil {
+			t.Fatal(err)
+		}
+		err := f.Upload(ctx, src, filepath.Join(t.TempDir(), "dst"), TransferOptions{Symlinks: SymlinkRejectOutside})
+		var escapeErr SymlinkEscapeError
+		if !errors.As(err, &escapeErr) {
+			t.Fatalf("expected SymlinkEscapeError, got %v", err)
+		}
+
+		// Following applies to downloads as well.
+		local := filepath.Join(t.TempDir(), "local")
+		if err := f.Download(ctx, src, local, TransferOptions{Symlinks: SymlinkFollow}); err != nil {
+			t.Fatalf("Download failed: %v", err)
+		}
+		assertFileContents(t, filepath.Join(local, "escape"), "s")
+	})
+
+	t.Run("symlink cycles", func(t *testing.T) {
+		src := t.TempDir()
+		// Several links back to ancestors would be walked exponentially
+		// often if only the depth of links were limited.
+		writeTree(t, src, map[string]string{"a/b/file": "x"})
+		for _, link := range []string{"loop", "a/up", "a/b/up", "a/b/top"} {
+			target := ".."
+			switch link {
+			case "loop":
+				target = "."
+			case "a/b/top":
+				target = src
+			}
+			if err := os.Symlink(target, filepath.Join(src, link)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, err := walkTree(localFS{}, src, SymlinkFollow)
+		if err == nil || !strings.Contains(err.Error(), "cycle") {
+			t.Errorf("expected following a symlink cycle to fail, got %v", err)
+		}
+		err = f.Upload(ctx, src, filepath.Join(t.TempDir(), "dst"), TransferOptions{Symlinks: SymlinkFollow})
+		if err == nil {
+			t.Errorf("expected following a symlink cycle to fail")
+		}
+	})
+
+	t.Run("special mode bits", func(t *testing.T) {
+		src := t.TempDir()
+		writeTree(t, src, map[string]string{"shared/tool": "x"})
+		for name, mode := range map[string]os.FileMode{
+			"shared":      0o755 | os.ModeSetgid | os.ModeSticky,
+			"shared/tool": 0o755 | os.ModeSetuid,
+		} {
+			if err := os.Chmod(filepath.Join(src, name), mode); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want := map[string]os.FileMode{
+			"shared":      0o755 | os.ModeSetgid | os.ModeSticky,
+			"shared/tool": 0o755 | os.ModeSetuid,
+		}
+
+		// The test sftp server can't set these bits, so check the modes an
+		// upload would request.
+		entries, err := walkTree(localFS{}, src, SymlinkCopy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr := &transfer{f: f, opts: TransferOptions{FileChmod: ChmodMask{Clear: 0o022}}}
+		for _, e := range entries {
+			if w, ok := want[e.rel]; ok {
+				if e.isRegular() {
+					w &^= 0o022
+				}
+				if got := tr.perm(e); got != w {
+					t.Errorf("expected an upload of %s to use mode %v, got %v", e.rel, w, got)
+				}
+			}
+		}
+
+		local := filepath.Join(t.TempDir(), "local")
+		if err := f.Download(ctx, src, local, TransferOptions{Ownership: OwnershipPreserve}); err != nil {
+			t.Fatalf("Download failed: %v", err)
+		}
+		for name, w := range want {
+			fi, err := os.Stat(filepath.Join(local, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fi.Mode() & modeBits; got != w {
+				t.Errorf("expected %s to have mode %v, got %v", name, w, got)
+			}
+		}
+	})
+
+	t.Run("ownership", func(t *testing.T) {
+		src := t.TempDir()
+		writeTree(t, src, map[string]string{"file": "x"})
+		fi, err := os.Stat(filepath.Join(src, "file"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		uid, gid, ok := fileOwner(fi)
+		if !ok {
+			t.Skip("file ownership is not supported on this platform")
+		}
+
+		tr := &transfer{opts: TransferOptions{
+			Ownership: OwnershipMap,
+			UIDMap:    map[uint32]uint32{uid: 1234},
+		}}
+		e := transferEntry{mode: fi.Mode(), uid: uid, gid: gid, hasOwner: true}
+		if gotUID, gotGID, ok := tr.owner(e); !ok || gotUID != 1234 || gotGID != gid {
+			t.Errorf("expected mapped owner 1234:%d, got %d:%d (%v)", gid, gotUID, gotGID, ok)
+		}
+
+		tr.opts.Ownership = OwnershipIgnore
+		if _, _, ok := tr.owner(e); ok {
+			t.Errorf("expected ownership to be ignored")
+		}
+
+		tr.opts.Ownership = OwnershipByName
+		tr.srcAccounts = parseAccountDB([]byte("alice:x:1000:1000::/home/alice:/bin/sh\n"), []byte("staff:x:50:\n"))
+		tr.dstAccounts = parseAccountDB([]byte("root:x:0:0::/root:/bin/sh\nalice:x:2000:2000::/home/alice:/bin/sh\n"), nil)
+		e.uid, e.gid = 1000, 50
+		if gotUID, gotGID, _ := tr.owner(e); gotUID != 2000 || gotGID != 50 {
+			t.Errorf("expected owner mapped by name to 2000:50, got %d:%d", gotUID, gotGID)
+		}
+
+		// Preserving ownership as the current user is always permitted, so
+		// this exercises the chown path end to end.
+		dst := filepath.Join(t.TempDir(), "dst")
+		if err := f.Upload(ctx, src, dst, TransferOptions{Ownership: OwnershipPreserve}); err != nil {
+			t.Fatalf("Upload failed: %v", err)
+		}
+		if fi, err := os.Stat(filepath.Join(dst, "file")); err != nil {
+			t.Fatal(err)
+		} else if gotUID, gotGID, _ := fileOwner(fi); gotUID != uid || gotGID != gid {
+			t.Errorf("expected owner %d:%d, got %d:%d", uid, gid, gotUID, gotGID)
+		}
+	})
+}
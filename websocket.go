@@ -0,0 +1,304 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The parts of the WebSocket protocol, RFC 6455, needed by WebTerminal.
+const (
+	// wsGUID is appended to the client's key to accept the handshake.
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	// wsMaxMessageSize bounds the messages read, which from a terminal are
+	// keystrokes and pastes.
+	wsMaxMessageSize = 1 << 20
+
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+
+	wsCloseNormal    = 1000
+	wsCloseGoingAway = 1001
+	wsCloseInternal  = 1011
+)
+
+// These are variables so tests can shorten them.
+var (
+	// wsWriteTimeout bounds how long sending a frame may take, so a peer
+	// that stops reading can't block writers forever.
+	wsWriteTimeout = 30 * time.Second
+
+	// wsCloseTimeout bounds how long sending a close frame may take,
+	// including waiting for a blocked write to give up.
+	wsCloseTimeout = 5 * time.Second
+)
+
+// wsConn is a WebSocket connection. Reads must be from one goroutine, while
+// writes may be from any.
+type wsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	// client is whether this is the client end, which masks what it sends.
+	client bool
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// isWebSocketUpgrade reports whether r asks to switch to a WebSocket.
+func isWebSocketUpgrade(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") &&
+		headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wsAccept returns the Sec-WebSocket-Accept header for a client's key.
+func wsAccept(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// upgradeWebSocket completes the handshake of a WebSocket request, and takes
+// over its connection. If the request isn't a valid handshake, it responds
+// with an error.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	switch {
+	case r.Method != http.MethodGet:
+		http.Error(w, "websocket requests must be GET", http.StatusMethodNotAllowed)
+		return nil, fmt.Errorf("websocket request with method %s", r.Method)
+	case !isWebSocketUpgrade(r):
+		http.Error(w, "not a websocket request", http.StatusBadRequest)
+		return nil, errors.New("not a websocket request")
+	case r.Header.Get("Sec-WebSocket-Version") != "13":
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("unsupported websocket version %q", r.Header.Get("Sec-WebSocket-Version"))
+	case key == "":
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("missing Sec-WebSocket-Key")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websockets are unsupported", http.StatusInternalServerError)
+		return nil, errors.New("response can't be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// The server may have set a deadline for the request.
+	conn.SetDeadline(time.Time{})
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAccept(key) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, r: rw.Reader}, nil
+}
+
+// readMessage reads the next data message, answering the control frames
+// before it. It returns io.EOF once the peer closes the connection.
+func (c *wsConn) readMessage() (opcode byte, data []byte, err error) {
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch op {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			// Echo the peer's close code, as RFC 6455 asks.
+			code := uint16(wsCloseNormal)
+			if len(payload) >= 2 {
+				code = binary.BigEndian.Uint16(payload)
+			}
+			c.close(code, "")
+			return 0, nil, io.EOF
+		case wsContinuation:
+			if opcode == 0 {
+				return 0, nil, errors.New("websocket continuation without a message")
+			}
+		case wsText, wsBinary:
+			if opcode != 0 {
+				return 0, nil, errors.New("websocket message interrupted by another")
+			}
+			opcode = op
+		default:
+			return 0, nil, fmt.Errorf("unknown websocket opcode %#x", op)
+		}
+		if len(data)+len(payload) > wsMaxMessageSize {
+			return 0, nil, fmt.Errorf("websocket message exceeds %d bytes", wsMaxMessageSize)
+		}
+		data = append(data, payload...)
+		if fin {
+			return opcode, data, nil
+		}
+	}
+}
+
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = header[0]&0x80 != 0
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, errors.New("websocket frame uses an extension")
+	}
+	opcode = header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+	if masked == c.client {
+		return false, 0, nil, fmt.Errorf("websocket frame masked=%t, want %t", masked, !c.client)
+	}
+
+	n := uint64(header[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode&0x8 != 0 && (n > 125 || !fin) {
+		return false, 0, nil, errors.New("invalid websocket control frame")
+	}
+	if n > wsMaxMessageSize {
+		return false, 0, nil, fmt.Errorf("websocket frame exceeds %d bytes", wsMaxMessageSize)
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeMessage sends a data message in a single frame.
+func (c *wsConn) writeMessage(opcode byte, data []byte) error {
+	return c.writeFrame(opcode, data)
+}
+
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return c.writeFrameLocked(opcode, payload)
+}
+
+func (c *wsConn) writeFrameLocked(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|opcode)
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = append(frame, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(frame[len(frame)-8:], uint64(n))
+	}
+	if !c.client {
+		frame = append(frame, payload...)
+	} else {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// close sends a close frame with the code and reason, then closes the
+// connection. Only the first call has any effect.
+func (c *wsConn) close(code uint16, reason string) error {
+	// A write blocked on a peer that isn't reading holds c.mu, so cut it
+	// short before waiting for the lock.
+	c.conn.SetWriteDeadline(time.Now().Add(wsCloseTimeout))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	// Control frames are limited to 125 bytes.
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, code)
+	payload = append(payload, reason...)
+	c.conn.SetWriteDeadline(time.Now().Add(wsCloseTimeout))
+	c.writeFrameLocked(wsClose, payload)
+	return c.conn.Close()
+}
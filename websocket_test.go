@@ -0,0 +1,224 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// dialWebSocket opens a WebSocket to rawurl, an "http://" URL. If the
+// handshake is refused, it returns the response and no connection.
+func dialWebSocket(t *testing.T, rawurl string, header http.Header) (*wsConn, *http.Response) {
+	t.Helper()
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	req, err := http.NewRequest(http.MethodGet, rawurl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, resp
+	}
+	if got, want := resp.Header.Get("Sec-WebSocket-Accept"), wsAccept(key); got != want {
+		t.Fatalf("got Sec-WebSocket-Accept %q, want %q", got, want)
+	}
+	ws := &wsConn{conn: conn, r: r, client: true}
+	t.Cleanup(func() { conn.Close() })
+	return ws, resp
+}
+
+func TestWebSocketAccept(t *testing.T) {
+	// The example from RFC 6455.
+	if got, want := wsAccept("dGhlIHNhbXBsZSBub25jZQ=="), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgradeWebSocket(w, r)
+		if err != nil {
+			return
+		}
+		// Echo every message, then close once the client does.
+		for {
+			op, data, err := ws.readMessage()
+			if err != nil {
+				return
+			}
+			if err := ws.writeMessage(op, data); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("got status %d without a handshake, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	ws, _ := dialWebSocket(t, server.URL, nil)
+	if ws == nil {
+		t.Fatal("handshake refused")
+	}
+	for _, size := range []int{0, 5, 125, 126, 70000} {
+		data := bytes.Repeat([]byte{'x'}, size)
+		if err := ws.writeMessage(wsBinary, data); err != nil {
+			t.Fatal(err)
+		}
+		op, got, err := ws.readMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if op != wsBinary || !bytes.Equal(got, data) {
+			t.Errorf("got message %#x of %d bytes, want %d bytes echoed", op, len(got), size)
+		}
+	}
+
+	// Fragments are reassembled, with pings answered in between.
+	ws.mu.Lock()
+	frames := []struct {
+		header  byte
+		payload string
+	}{
+		{wsText, "hello, "},
+		{0x80 | wsPing, "ping"},
+		{0x80 | wsContinuation, "world"},
+	}
+	for _, f := range frames {
+		if err := writeRawFrame(ws, f.header, []byte(f.payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ws.mu.Unlock()
+	_, _, payload, err := ws.readFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payload) != "ping" {
+		t.Errorf("got %q in answer to a ping, want it echoed", payload)
+	}
+	op, got, err := ws.readMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op != wsText || string(got) != "hello, world" {
+		t.Errorf("got message %#x %q, want the fragments joined", op, got)
+	}
+
+	// The server answers a close with one.
+	if err := ws.writeFrame(wsClose, []byte{0x03, 0xe8}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ws.readMessage(); err != io.EOF {
+		t.Errorf("got error %v after closing, want EOF", err)
+	}
+}
+
+func TestWebSocketStalledPeer(t *testing.T) {
+	defer func(write, close time.Duration) {
+		wsWriteTimeout, wsCloseTimeout = write, close
+	}(wsWriteTimeout, wsCloseTimeout)
+	wsWriteTimeout, wsCloseTimeout = time.Hour, 100*time.Millisecond
+
+	// The server writes to a client that never reads until its writes
+	// block, and then closes the connection.
+	closed := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgradeWebSocket(w, r)
+		if err != nil {
+			closed <- err
+			return
+		}
+		written := make(chan struct{}, 1)
+		go func() {
+			data := make([]byte, 64<<10)
+			for ws.writeMessage(wsBinary, data) == nil {
+				select {
+				case written <- struct{}{}:
+				default:
+				}
+			}
+		}()
+		// Wait for the writes to stop making progress.
+		for {
+			select {
+			case <-written:
+				continue
+			case <-time.After(200 * time.Millisecond):
+			}
+			break
+		}
+		ws.close(wsCloseGoingAway, "")
+		closed <- nil
+	}))
+	defer server.Close()
+
+	if ws, _ := dialWebSocket(t, server.URL, nil); ws == nil {
+		t.Fatal("handshake refused")
+	}
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("closing a websocket with a blocked write hung")
+	}
+}
+
+// writeRawFrame writes a masked frame with the header's first byte as is,
+// for frames that aren't final.
+func writeRawFrame(ws *wsConn, header byte, payload []byte) error {
+	if len(payload) > 125 {
+		return fmt.Errorf("payload of %d bytes is too long", len(payload))
+	}
+	frame := []byte{header, 0x80 | byte(len(payload)), 0, 0, 0, 0}
+	frame = append(frame, payload...)
+	_, err := ws.conn.Write(frame)
+	return err
+}
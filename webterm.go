@@ -0,0 +1,576 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.fuchsia.dev/fuchsia/tools/lib/logger"
+)
+
+const (
+	// defaultWebIdleTimeout is how long a web terminal may go without input.
+	defaultWebIdleTimeout = 15 * time.Minute
+
+	// maxWebTerminalSize bounds the terminal size a browser may ask for.
+	maxWebTerminalSize = 1000
+
+	// maxNumberedFiles bounds the numbers tried for the name of a recording,
+	// profile or core dump.
+	maxNumberedFiles = 1000
+)
+
+// ClientPool provides the clients of the hosts a WebTerminal opens
+// terminals on.
+type ClientPool interface {
+	Client(ctx context.Context, host string) (*Client, error)
+}
+
+// ClientMap is a fixed pool of clients by host name.
+type ClientMap map[string]*Client
+
+func (m ClientMap) Client(ctx context.Context, host string) (*Client, error) {
+	if c, ok := m[host]; ok {
+		return c, nil
+	}
+	return nil, UnknownHostError{Host: host}
+}
+
+// UnknownHostError is returned by a ClientPool for a host it has no client
+// for.
+type UnknownHostError struct {
+	Host string
+}
+
+func (e UnknownHostError) Error() string {
+	return fmt.Sprintf("unknown host %q", e.Host)
+}
+
+// WebTerminalOptions configure NewWebTerminal.
+type WebTerminalOptions struct {
+	Pool ClientPool
+
+	// Authorize decides whether the request may open a terminal on host,
+	// and returns the name of the user it's for, such as from a header set
+	// by an authenticating proxy. It is required, so that a gateway is
+	// never open by accident.
+	Authorize func(r *http.Request, host string) (user string, err error)
+
+	// Command is run in the terminal. It defaults to the login shell.
+	Command []string
+
+	// Term is the terminal type. The size comes from the browser.
+	Term string
+
+	// RecordDir, if set, gets a recording of every session's output in
+	// asciicast v2 format, as HOST-USER-TIME.cast, or HOST-USER-TIME-N.cast
+	// for sessions started in the same second.
+	RecordDir string
+
+	// IdleTimeout ends sessions without input for that long. It defaults
+	// to 15 minutes, and a negative timeout never ends them.
+	IdleTimeout time.Duration
+}
+
+// WebSessionInfo describes a session of a WebTerminal.
+type WebSessionInfo struct {
+	User      string
+	Host      string
+	Started   time.Time
+	LastInput time.Time
+
+	// Recording is the path of the session's recording, if any.
+	Recording string
+}
+
+// WebTerminal is an HTTP handler giving browsers terminals on hosts. A
+// request for "?host=NAME" gets an xterm.js page, loaded from jsdelivr,
+// which opens a WebSocket to the same URL that is bridged to a terminal
+// session on the host.
+//
+// Over the WebSocket, the browser sends JSON text messages, either
+// {"type":"input","data":"..."} or {"type":"resize","cols":N,"rows":N},
+// and receives the terminal's output as binary messages.
+type WebTerminal struct {
+	opts WebTerminalOptions
+
+	mu       sync.Mutex
+	sessions map[*webSession]struct{}
+	closed   bool
+}
+
+type webSession struct {
+	ws      *wsConn
+	session *Session
+	record  *asciicastRecording
+
+	mu    sync.Mutex
+	info  WebSessionInfo
+	ended bool
+
+	endOnce sync.Once
+}
+
+// NewWebTerminal returns a gateway to the hosts of the pool.
+func NewWebTerminal(opts WebTerminalOptions) (*WebTerminal, error) {
+	if opts.Pool == nil {
+		return nil, errors.New("a web terminal needs a client pool")
+	}
+	if opts.Authorize == nil {
+		return nil, errors.New("a web terminal needs an authorization hook")
+	}
+	if opts.Term == "" {
+		opts.Term = defaultTerm
+	}
+	if opts.IdleTimeout == 0 {
+		opts.IdleTimeout = defaultWebIdleTimeout
+	}
+	if opts.RecordDir != "" {
+		if err := os.MkdirAll(opts.RecordDir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &WebTerminal{
+		opts:     opts,
+		sessions: make(map[*webSession]struct{}),
+	}, nil
+}
+
+func (t *WebTerminal) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	host := r.URL.Query().Get("host")
+	if host == "" {
+		http.Error(w, "missing host", http.StatusBadRequest)
+		return
+	}
+	user, err := t.opts.Authorize(r, host)
+	if err != nil {
+		logger.Debugf(ctx, "refused a web terminal on %s: %v", host, err)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	client, err := t.opts.Pool.Client(ctx, host)
+	if err != nil {
+		var unknown UnknownHostError
+		if errors.As(err, &unknown) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+		return
+	}
+
+	if !isWebSocketUpgrade(r) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := webTerminalPage.Execute(w, host); err != nil {
+			logger.Debugf(ctx, "failed to write the web terminal page: %v", err)
+		}
+		return
+	}
+	// Browsers send cookies with WebSockets from any site, so only the
+	// page's own are accepted.
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin websocket", http.StatusForbidden)
+		return
+	}
+	pty := PTYOptions{
+		Term:   t.opts.Term,
+		Width:  queryTerminalSize(r, "cols"),
+		Height: queryTerminalSize(r, "rows"),
+	}
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		logger.Debugf(ctx, "failed to upgrade to a websocket: %v", err)
+		return
+	}
+	if err := t.serve(ctx, ws, client, user, host, pty); err != nil {
+		logger.Warningf(ctx, "web terminal for %s on %s failed: %v", user, host, err)
+	}
+}
+
+// sameOrigin reports whether the request has no Origin, as from non-browser
+// clients, or one on the requested host.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// queryTerminalSize returns a dimension of the terminal from the request's
+// query, or zero for the default.
+func queryTerminalSize(r *http.Request, name string) int {
+	n, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil || n <= 0 || n > maxWebTerminalSize {
+		return 0
+	}
+	return n
+}
+
+// webTerminalMessage is a message from the browser.
+type webTerminalMessage struct {
+	Type string `json:"type"`
+	Data string `json:"data,omitempty"`
+	Cols int    `json:"cols,omitempty"`
+	Rows int    `json:"rows,omitempty"`
+}
+
+// serve bridges the WebSocket to a terminal session on the client, until
+// either ends.
+func (t *WebTerminal) serve(ctx context.Context, ws *wsConn, client *Client, user, host string, pty PTYOptions) error {
+	now := time.Now()
+	s := &webSession{
+		ws: ws,
+		info: WebSessionInfo{
+			User:      user,
+			Host:      host,
+			Started:   now,
+			LastInput: now,
+		},
+	}
+	if !t.track(s) {
+		ws.close(wsCloseGoingAway, "shutting down")
+		return nil
+	}
+	defer t.untrack(s)
+
+	pty = pty.withDefaults()
+	if t.opts.RecordDir != "" {
+		name := fmt.Sprintf("%s-%s-%s.cast", sanitizeFileName(host), sanitizeFileName(user), now.UTC().Format(profileTimeFormat))
+		record, err := newAsciicastRecording(filepath.Join(t.opts.RecordDir, name), pty, user+"@"+host, now)
+		if err != nil {
+			ws.close(wsCloseInternal, "failed to record the session")
+			return err
+		}
+		defer record.Close()
+		s.record = record
+		s.mu.Lock()
+		s.info.Recording = record.path
+		s.mu.Unlock()
+	}
+
+	session, stdin, err := client.startPTYWithStdinPipe(ctx, shellQuoteArgs(t.opts.Command), pty, s, s)
+	if err != nil {
+		ws.close(wsCloseInternal, "failed to start the session")
+		return err
+	}
+	s.mu.Lock()
+	s.session = session
+	ended := s.ended
+	s.mu.Unlock()
+	if ended {
+		session.Close()
+	}
+	logger.Debugf(ctx, "started a web terminal for %s on %s", user, host)
+
+	waited := make(chan error, 1)
+	go func() {
+		err := session.Wait(context.Background())
+		s.end(wsCloseNormal, "session ended")
+		waited <- err
+	}()
+
+	var idle *time.Timer
+	if t.opts.IdleTimeout > 0 {
+		idle = time.AfterFunc(t.opts.IdleTimeout, func() {
+			s.end(wsCloseNormal, fmt.Sprintf("idle for %s", t.opts.IdleTimeout))
+		})
+		defer idle.Stop()
+	}
+
+	for {
+		_, data, err := ws.readMessage()
+		if err != nil {
+			break
+		}
+		var msg webTerminalMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			logger.Debugf(ctx, "ignoring a malformed web terminal message: %v", err)
+			continue
+		}
+		switch msg.Type {
+		case "input":
+			if idle != nil {
+				idle.Reset(t.opts.IdleTimeout)
+			}
+			s.mu.Lock()
+			s.info.LastInput = time.Now()
+			s.mu.Unlock()
+			if _, err := stdin.Write([]byte(msg.Data)); err != nil {
+				logger.Debugf(ctx, "failed to send input to %s: %v", host, err)
+			}
+		case "resize":
+			if msg.Cols <= 0 || msg.Rows <= 0 || msg.Cols > maxWebTerminalSize || msg.Rows > maxWebTerminalSize {
+				continue
+			}
+			if err := session.WindowChange(msg.Cols, msg.Rows); err != nil {
+				logger.Debugf(ctx, "failed to resize the terminal on %s: %v", host, err)
+			}
+			if s.record != nil {
+				s.record.resize(msg.Cols, msg.Rows)
+			}
+		default:
+			logger.Debugf(ctx, "ignoring a web terminal message of type %q", msg.Type)
+		}
+	}
+	// The browser went away.
+	s.end(wsCloseNormal, "")
+	if err := <-waited; err != nil {
+		logger.Debugf(ctx, "web terminal session for %s on %s ended: %v", user, host, err)
+	} else {
+		logger.Debugf(ctx, "ended the web terminal for %s on %s", user, host)
+	}
+	return nil
+}
+
+// Write sends output to the browser, and records it.
+func (s *webSession) Write(p []byte) (int, error) {
+	if s.record != nil {
+		s.record.output(p)
+	}
+	if err := s.ws.writeMessage(wsBinary, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// end closes the WebSocket with the code and reason, and the session. Only
+// the first call has any effect.
+func (s *webSession) end(code uint16, reason string) {
+	s.endOnce.Do(func() {
+		s.ws.close(code, reason)
+		s.mu.Lock()
+		s.ended = true
+		session := s.session
+		s.mu.Unlock()
+		if session != nil {
+			session.Close()
+		}
+	})
+}
+
+func (t *WebTerminal) track(s *webSession) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return false
+	}
+	t.sessions[s] = struct{}{}
+	return true
+}
+
+func (t *WebTerminal) untrack(s *webSession) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.sessions, s)
+}
+
+// Sessions describes the open sessions, oldest first.
+func (t *WebTerminal) Sessions() []WebSessionInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var infos []WebSessionInfo
+	for s := range t.sessions {
+		s.mu.Lock()
+		infos = append(infos, s.info)
+		s.mu.Unlock()
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Started.Before(infos[j].Started)
+	})
+	return infos
+}
+
+// Close ends every session, and refuses new ones. The HTTP server must be
+// shut down separately, as it doesn't track WebSockets.
+func (t *WebTerminal) Close() error {
+	t.mu.Lock()
+	t.closed = true
+	var sessions []*webSession
+	for s := range t.sessions {
+		sessions = append(sessions, s)
+	}
+	t.mu.Unlock()
+
+	for _, s := range sessions {
+		s.end(wsCloseGoingAway, "shutting down")
+	}
+	return nil
+}
+
+// asciicastRecording is a recording of a terminal session in asciicast v2
+// format: a JSON header line, then a JSON array per event.
+type asciicastRecording struct {
+	path  string
+	start time.Time
+
+	mu sync.Mutex
+	f  *os.File
+	// partial is the incomplete UTF-8 sequence ending the previous output,
+	// as events hold strings.
+	partial []byte
+}
+
+// newAsciicastRecording creates a recording at path, or if that exists, at
+// the first free path numbered from it.
+func newAsciicastRecording(path string, pty PTYOptions, title string, start time.Time) (*asciicastRecording, error) {
+	ext := filepath.Ext(path)
+	f, err := createNumbered(strings.TrimSuffix(path, ext), ext)
+	if err != nil {
+		return nil, err
+	}
+	path = f.Name()
+	header := map[string]interface{}{
+		"version":   2,
+		"width":     pty.Width,
+		"height":    pty.Height,
+		"timestamp": start.Unix(),
+		"title":     title,
+		"env":       map[string]string{"TERM": pty.Term},
+	}
+	data, err := json.Marshal(header)
+	if err == nil {
+		_, err = f.Write(append(data, '\n'))
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &asciicastRecording{path: path, start: start, f: f}, nil
+}
+
+// createNumbered creates a new file at base+ext, or if one exists, with
+// "-2", "-3" and so on between base and ext.
+func createNumbered(base, ext string) (*os.File, error) {
+	var f *os.File
+	_, err := numbered(base, ext, func(name string) error {
+		var err error
+		f, err = os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		return err
+	})
+	return f, err
+}
+
+// mkdirNumbered creates a new directory at path, or if one exists, at path
+// with "-2", "-3" and so on after it, and returns the directory's path.
+func mkdirNumbered(path string) (string, error) {
+	return numbered(path, "", func(name string) error {
+		return os.Mkdir(name, 0o755)
+	})
+}
+
+// numbered calls create with base+ext, and then with numbers added between
+// them for as long as create fails because the path exists. It returns the
+// last path tried.
+func numbered(base, ext string, create func(name string) error) (string, error) {
+	name := base + ext
+	for i := 2; ; i++ {
+		err := create(name)
+		if !os.IsExist(err) || i > maxNumberedFiles {
+			return name, err
+		}
+		name = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+}
+
+func (r *asciicastRecording) output(p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p = append(r.partial, p...)
+	r.partial = nil
+	// Hold back a trailing incomplete character until the rest arrives.
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if !utf8.FullRune(p[i:]) {
+				r.partial = append([]byte(nil), p[i:]...)
+				p = p[:i]
+			}
+			break
+		}
+	}
+	if len(p) > 0 {
+		r.event("o", string(p))
+	}
+}
+
+func (r *asciicastRecording) resize(width, height int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.event("r", fmt.Sprintf("%dx%d", width, height))
+}
+
+func (r *asciicastRecording) event(kind, data string) {
+	elapsed := float64(time.Since(r.start).Microseconds()) / 1e6
+	line, err := json.Marshal([]interface{}{elapsed, kind, data})
+	if err != nil {
+		return
+	}
+	if _, err := r.f.Write(append(line, '\n')); err != nil {
+		logger.Debugf(context.Background(), "failed to record to %s: %v", r.path, err)
+	}
+}
+
+// Close ends the recording, with any incomplete character left.
+func (r *asciicastRecording) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.partial) > 0 {
+		r.event("o", string(r.partial))
+		r.partial = nil
+	}
+	return r.f.Close()
+}
+
+var webTerminalPage = template.Must(template.New("webterm").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.}}</title>
+<link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/xterm@5.3.0/css/xterm.css">
+<script src="https://cdn.jsdelivr.net/npm/xterm@5.3.0/lib/xterm.js"></script>
+<script src="https://cdn.jsdelivr.net/npm/xterm-addon-fit@0.8.0/lib/xterm-addon-fit.js"></script>
+<style>html, body, #terminal { height: 100%; margin: 0; background: #000; }</style>
+</head>
+<body>
+<div id="terminal"></div>
+<script>
+const term = new Terminal();
+const fit = new FitAddon.FitAddon();
+term.loadAddon(fit);
+term.open(document.getElementById("terminal"));
+fit.fit();
+const url = new URL(location.href);
+url.protocol = url.protocol === "https:" ? "wss:" : "ws:";
+url.searchParams.set("cols", term.cols);
+url.searchParams.set("rows", term.rows);
+const ws = new WebSocket(url);
+ws.binaryType = "arraybuffer";
+ws.onmessage = (e) => term.write(new Uint8Array(e.data));
+ws.onclose = (e) => term.write("\r\n[" + (e.reason || "disconnected") + "]\r\n");
+const send = (msg) => { if (ws.readyState === WebSocket.OPEN) ws.send(JSON.stringify(msg)); };
+term.onData((data) => send({type: "input", data: data}));
+term.onResize((size) => send({type: "resize", cols: size.cols, rows: size.rows}));
+window.addEventListener("resize", () => fit.fit());
+term.focus();
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,308 @@
+// Copyright 2026 Sovereign Cloud Australia Pty Ltd. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sshutil
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aucloud/go-sshutil/internal/sshtest"
+	"golang.org/x/crypto/ssh"
+)
+
+// setUpWebTerminal serves a web terminal to a host named "lab", for users
+// named by the X-User header.
+func setUpWebTerminal(t *testing.T, opts WebTerminalOptions) (*WebTerminal, *httptest.Server) {
+	t.Helper()
+	ctx := context.Background()
+	client, _ := setUpClient(ctx, t, sshtest.OnShellChannel(), func(req *ssh.Request) {
+		req.Reply(true, nil)
+	})
+	opts.Pool = ClientMap{"lab": client}
+	opts.Authorize = func(r *http.Request, host string) (string, error) {
+		user := r.Header.Get("X-User")
+		if user == "" {
+			return "", errors.New("no user")
+		}
+		return user, nil
+	}
+	term, err := NewWebTerminal(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(term)
+	t.Cleanup(func() {
+		term.Close()
+		server.Close()
+	})
+	return term, server
+}
+
+func sendWebTerminalMessage(t *testing.T, ws *wsConn, msg webTerminalMessage) {
+	t.Helper()
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.writeMessage(wsText, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readWebTerminal reads output until it contains want, and returns it.
+func readWebTerminal(t *testing.T, ws *wsConn, want string) string {
+	t.Helper()
+	ws.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	var out []byte
+	for !strings.Contains(string(out), want) {
+		_, data, err := ws.readMessage()
+		if err != nil {
+			t.Fatalf("failed waiting for %q in output %q: %v", want, out, err)
+		}
+		out = append(out, data...)
+	}
+	return string(out)
+}
+
+// waitForWebTerminalEnd waits for the server to close the WebSocket.
+func waitForWebTerminalEnd(t *testing.T, ws *wsConn) {
+	t.Helper()
+	ws.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		if _, _, err := ws.readMessage(); err != nil {
+			if err != io.EOF {
+				t.Errorf("got error %v, want the server to close the websocket", err)
+			}
+			return
+		}
+	}
+}
+
+func waitForWebSessions(t *testing.T, term *WebTerminal, n int) []WebSessionInfo {
+	t.Helper()
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		sessions := term.Sessions()
+		if len(sessions) == n {
+			return sessions
+		}
+		if time.Since(start) > 10*time.Second {
+			t.Fatalf("got sessions %+v, want %d", sessions, n)
+		}
+	}
+}
+
+func TestWebTerminal(t *testing.T) {
+	recordDir := t.TempDir()
+	term, server := setUpWebTerminal(t, WebTerminalOptions{RecordDir: recordDir})
+	alice := http.Header{"X-User": {"alice"}}
+
+	for _, test := range []struct {
+		name   string
+		query  string
+		header http.Header
+		want   int
+	}{
+		{"page", "?host=lab", alice, http.StatusOK},
+		{"no host", "", alice, http.StatusBadRequest},
+		{"unauthorized", "?host=lab", nil, http.StatusForbidden},
+		{"unknown host", "?host=nosuchhost", alice, http.StatusNotFound},
+	} {
+		req, err := http.NewRequest(http.MethodGet, server.URL+test.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header = test.header
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != test.want {
+			t.Errorf("%s: got status %d, want %d", test.name, resp.StatusCode, test.want)
+		}
+		if test.want == http.StatusOK && !strings.Contains(string(body), "xterm.js") {
+			t.Errorf("%s: got %q, want the xterm.js page", test.name, body)
+		}
+	}
+
+	crossOrigin := http.Header{"X-User": {"alice"}, "Origin": {"http://example.com"}}
+	if ws, resp := dialWebSocket(t, server.URL+"?host=lab", crossOrigin); ws != nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("got status %d for a cross-origin websocket, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+
+	// The terminal starts at the browser's size, and follows its resizes.
+	ws, _ := dialWebSocket(t, server.URL+"?host=lab&cols=100&rows=30", alice)
+	if ws == nil {
+		t.Fatal("handshake refused")
+	}
+	sendWebTerminalMessage(t, ws, webTerminalMessage{Type: "input", Data: "echo size $COLUMNS $LINES\n"})
+	readWebTerminal(t, ws, "size 100 30\n")
+	sendWebTerminalMessage(t, ws, webTerminalMessage{Type: "resize", Cols: 120, Rows: 40})
+
+	sessions := waitForWebSessions(t, term, 1)
+	if s := sessions[0]; s.User != "alice" || s.Host != "lab" || !strings.HasPrefix(s.Recording, recordDir) {
+		t.Errorf("got session %+v", s)
+	}
+	recording := sessions[0].Recording
+
+	sendWebTerminalMessage(t, ws, webTerminalMessage{Type: "input", Data: "exit\n"})
+	waitForWebTerminalEnd(t, ws)
+	waitForWebSessions(t, term, 0)
+
+	f, err := os.Open(recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		t.Fatal("empty recording")
+	}
+	var header struct {
+		Version, Width, Height int
+		Title                  string
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		t.Fatal(err)
+	}
+	if header.Version != 2 || header.Width != 100 || header.Height != 30 || header.Title != "alice@lab" {
+		t.Errorf("got recording header %+v", header)
+	}
+	var output, resizes string
+	for scanner.Scan() {
+		var event []interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatal(err)
+		}
+		if len(event) != 3 {
+			t.Fatalf("got event %v, want time, kind and data", event)
+		}
+		switch event[1] {
+		case "o":
+			output += event[2].(string)
+		case "r":
+			resizes += event[2].(string)
+		}
+	}
+	if output != "size 100 30\n" || resizes != "120x40" {
+		t.Errorf("recorded output %q and resizes %q", output, resizes)
+	}
+	if filepath.Dir(recording) != recordDir || !strings.HasPrefix(filepath.Base(recording), "lab-alice-") {
+		t.Errorf("got recording %s, want it named by host and user in %s", recording, recordDir)
+	}
+}
+
+func TestWebTerminalIdle(t *testing.T) {
+	term, server := setUpWebTerminal(t, WebTerminalOptions{IdleTimeout: 200 * time.Millisecond})
+	alice := http.Header{"X-User": {"alice"}}
+
+	ws, _ := dialWebSocket(t, server.URL+"?host=lab", alice)
+	if ws == nil {
+		t.Fatal("handshake refused")
+	}
+	// Input keeps the session open past the timeout.
+	for i := 0; i < 3; i++ {
+		time.Sleep(100 * time.Millisecond)
+		sendWebTerminalMessage(t, ws, webTerminalMessage{Type: "input", Data: "echo alive\n"})
+	}
+	readWebTerminal(t, ws, "alive\nalive\nalive\n")
+	waitForWebTerminalEnd(t, ws)
+	waitForWebSessions(t, term, 0)
+}
+
+func TestWebTerminalClose(t *testing.T) {
+	term, server := setUpWebTerminal(t, WebTerminalOptions{IdleTimeout: -1})
+	alice := http.Header{"X-User": {"alice"}}
+
+	// Closing the terminal ends the sessions, and refuses new ones.
+	ws, _ := dialWebSocket(t, server.URL+"?host=lab", alice)
+	if ws == nil {
+		t.Fatal("handshake refused")
+	}
+	waitForWebSessions(t, term, 1)
+	term.Close()
+	waitForWebTerminalEnd(t, ws)
+	ws, _ = dialWebSocket(t, server.URL+"?host=lab", alice)
+	if ws == nil {
+		t.Fatal("handshake refused")
+	}
+	waitForWebTerminalEnd(t, ws)
+}
+
+func TestAsciicastRecordingSplitCharacter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rec.cast")
+	record, err := newAsciicastRecording(path, PTYOptions{}.withDefaults(), "test", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A character split across writes is recorded whole.
+	euro := []byte("€")
+	record.output(append([]byte("a"), euro[:1]...))
+	record.output(euro[1:])
+	if err := record.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var output string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n")[1:] {
+		var event []interface{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatal(err)
+		}
+		output += event[2].(string)
+	}
+	if output != "a€" {
+		t.Errorf("recorded %q, want %q", output, "a€")
+	}
+}
+
+func TestAsciicastRecordingSameName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lab-alice-20260101T000000Z.cast")
+	// Sessions started in the same second get numbered recordings.
+	for _, want := range []string{path, strings.TrimSuffix(path, ".cast") + "-2.cast", strings.TrimSuffix(path, ".cast") + "-3.cast"} {
+		record, err := newAsciicastRecording(path, PTYOptions{}.withDefaults(), "test", time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer record.Close()
+		if record.path != want {
+			t.Errorf("got recording %s, want %s", record.path, want)
+		}
+	}
+}
+
+func TestMkdirNumbered(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lab-42-20260101T000000Z")
+	for _, want := range []string{path, path + "-2", path + "-3"} {
+		dir, err := mkdirNumbered(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dir != want {
+			t.Errorf("got directory %s, want %s", dir, want)
+		}
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			t.Errorf("expected %s to be a directory (err: %v)", dir, err)
+		}
+	}
+}